@@ -22,6 +22,9 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 )
 
 var configuration types.JinxServerConfiguration
@@ -41,6 +44,7 @@ func init() {
 }
 
 func HandleStart() {
+	writePIDFile()
 
 	switch configuration.Mode {
 	case constant.HTTP_SERVER:
@@ -89,3 +93,28 @@ func HandleRestart() {
 func HandleDestroy() {
 	server.Destroy()
 }
+
+// HandleReload asks the running Jinx server to reload its TLS certificates by sending it SIGHUP. The server
+// is found through the PID file written when it was started.
+func HandleReload() {
+	content, readErr := os.ReadFile(constant.PID_FILE_PATH)
+	if readErr != nil {
+		log.Fatalf("unable to read %s, is jinx running? %v", constant.PID_FILE_PATH, readErr)
+	}
+
+	pid, parseErr := strconv.Atoi(strings.TrimSpace(string(content)))
+	if parseErr != nil {
+		log.Fatalf("%s does not contain a valid process id: %v", constant.PID_FILE_PATH, parseErr)
+	}
+
+	if err := syscall.Kill(pid, syscall.SIGHUP); err != nil {
+		log.Fatalf("unable to signal jinx process %d: %v", pid, err)
+	}
+}
+
+// writePIDFile records the process id of the server so that later commands can signal it.
+func writePIDFile() {
+	if err := os.WriteFile(constant.PID_FILE_PATH, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		log.Printf("unable to write %s: %v", constant.PID_FILE_PATH, err)
+	}
+}
@@ -29,10 +29,13 @@ func main() {
 	case constant.DESTROY:
 		HandleDestroy()
 		break
+	case constant.RELOAD:
+		HandleReload()
+		break
 	case constant.VERSION:
 		fmt.Printf("Jinx Version %s", constant.VERSION_NUMBER)
 		break
 	default:
-		log.Fatalf("%s is an invalid or unrecognized command. valid commands are: start, stop, restart and destroy.", command)
+		log.Fatalf("%s is an invalid or unrecognized command. valid commands are: start, stop, restart, reload and destroy.", command)
 	}
 }
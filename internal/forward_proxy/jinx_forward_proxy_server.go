@@ -15,8 +15,14 @@ package forward_proxy
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"jinx/internal/jinx_tls"
+	"jinx/internal/listener"
+	"jinx/internal/rate_limit"
+	"jinx/internal/upstream_error"
+	"jinx/pkg/util/constant"
 	"jinx/pkg/util/helper"
 	"jinx/pkg/util/types"
 	"log"
@@ -33,11 +39,17 @@ import (
 )
 
 type JinxForwardProxyServer struct {
-	config         types.JinxForwardProxyServerConfig
-	errorLogger    *slog.Logger
-	serverLogger   *slog.Logger
-	serverRootDir  string
-	serverInstance *http.Server
+	config               types.JinxForwardProxyServerConfig
+	errorLogger          *slog.Logger
+	serverLogger         *slog.Logger
+	serverRootDir        string
+	serverInstance       *http.Server
+	certificates         *jinx_tls.CertificateStore
+	challengeServer      *http.Server
+	redirectServer       *http.Server
+	stopCertificateWatch func()
+	limiter              *rate_limit.Limiter
+	errorPages           *upstream_error.Pages
 }
 
 func NewJinxForwardProxyServer(config types.JinxForwardProxyServerConfig, serverRoot string) *JinxForwardProxyServer {
@@ -52,25 +64,37 @@ func NewJinxForwardProxyServer(config types.JinxForwardProxyServerConfig, server
 		log.Fatal(logFileErr)
 	}
 
+	trustedProxies, trustedProxiesErr := helper.ParseTrustedProxies(config.RateLimit.TrustedProxies)
+	if trustedProxiesErr != nil {
+		log.Fatal(trustedProxiesErr)
+	}
+
+	serverLogger := slog.New(slog.NewJSONHandler(serverLogFile, nil))
+	limiter, limiterErr := rate_limit.New(config.RateLimit, trustedProxies, serverLogger)
+	if limiterErr != nil {
+		log.Fatal(limiterErr)
+	}
+
+	errorPages, errorPagesErr := upstream_error.NewPages(config.ErrorPages)
+	if errorPagesErr != nil {
+		log.Fatal(errorPagesErr)
+	}
+
 	return &JinxForwardProxyServer{
 		config:         config,
 		errorLogger:    slog.New(slog.NewJSONHandler(errorLogFile, nil)),
-		serverLogger:   slog.New(slog.NewJSONHandler(serverLogFile, nil)),
+		serverLogger:   serverLogger,
 		serverRootDir:  serverRoot,
 		serverInstance: nil,
+		limiter:        limiter,
+		errorPages:     errorPages,
 	}
 }
 
 func (jx *JinxForwardProxyServer) Start() types.JinxServer {
 	addr := fmt.Sprintf("%s:%d", jx.config.IP, jx.config.Port)
 
-	s := &http.Server{
-		Addr:           addr,
-		Handler:        jx,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
-	}
+	s := listener.NewHttpServer(addr, jx, jx.config.Limits)
 
 	jx.serverInstance = s
 
@@ -83,21 +107,22 @@ func (jx *JinxForwardProxyServer) Start() types.JinxServer {
 		sig := <-signalChan
 		jx.serverLogger.Info(fmt.Sprintf("Received signal %v: shutting down server...", sig))
 
-		// Create a context with a timeout to tell the server how long to wait for existing requests to finish
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-		defer cancel()
+		// Stop also shuts down the listeners started alongside the server, such as the HTTPS redirect
+		jx.Stop()
+	}()
 
-		// Attempt to gracefully shut down the server
-		if err := s.Shutdown(ctx); err != nil {
-			jx.errorLogger.Error(fmt.Sprintf("Server shutdown error: %s", err))
+	// Reload the certificates when the reload command sends SIGHUP
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			jx.Reload()
 		}
-
-		jx.serverLogger.Info(fmt.Sprintf("Successfully shutdown server"))
 	}()
 
-	if jx.config.CertFile != "" && jx.config.KeyFile != "" {
+	if jinx_tls.IsEnabled(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME) {
 		jx.serverLogger.Info(fmt.Sprintf("Starting Jinx Forward Proxy Sever on %s using HTTPS Protocol", addr))
-		err := s.ListenAndServeTLS(jx.config.CertFile, jx.config.KeyFile)
+		err := jx.listenAndServeTLS(s)
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
 			log.Fatal(err)
@@ -106,7 +131,7 @@ func (jx *JinxForwardProxyServer) Start() types.JinxServer {
 	}
 
 	jx.serverLogger.Info(fmt.Sprintf("Starting Jinx Forward Proxy Sever on %s using HTTP Protocol", addr))
-	err := s.ListenAndServe()
+	err := jx.listenAndServe(s)
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
 		log.Fatal(err)
@@ -147,6 +172,23 @@ func (jx *JinxForwardProxyServer) Stop() {
 		jx.errorLogger.Error(fmt.Sprintf("Server shutdown error: %s", err))
 	}
 
+	if jx.challengeServer != nil {
+		_ = jx.challengeServer.Shutdown(ctx)
+		jx.challengeServer = nil
+	}
+
+	if jx.redirectServer != nil {
+		_ = jx.redirectServer.Shutdown(ctx)
+		jx.redirectServer = nil
+	}
+
+	if jx.stopCertificateWatch != nil {
+		jx.stopCertificateWatch()
+		jx.stopCertificateWatch = nil
+	}
+	// A restart loads the certificates again, together with the challenge listener stopped above
+	jx.certificates = nil
+
 	jx.serverLogger.Info(fmt.Sprintf("Successfully shutdown server manually"))
 }
 
@@ -182,9 +224,10 @@ func (jx *JinxForwardProxyServer) Restart() types.JinxServer {
 	}
 
 	jx.Stop()
+	jx.serverInstance = listener.NewHttpServer(jx.serverInstance.Addr, jx, jx.config.Limits)
 	go func() {
-		if jx.config.CertFile != "" && jx.config.KeyFile != "" {
-			err := jx.serverInstance.ListenAndServeTLS(jx.config.CertFile, jx.config.KeyFile)
+		if jinx_tls.IsEnabled(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME) {
+			err := jx.listenAndServeTLS(jx.serverInstance)
 			if err != nil && !errors.Is(err, http.ErrServerClosed) {
 				jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
 				log.Fatal(err)
@@ -193,7 +236,7 @@ func (jx *JinxForwardProxyServer) Restart() types.JinxServer {
 		}
 
 		// Start the server
-		err := jx.serverInstance.ListenAndServe()
+		err := jx.listenAndServe(jx.serverInstance)
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
 			log.Fatal(err)
@@ -203,6 +246,139 @@ func (jx *JinxForwardProxyServer) Restart() types.JinxServer {
 	return jx
 }
 
+// listenAndServe binds the server address with the configured connection limits applied and serves plain
+// HTTP on it. Connections refused because of the per IP limit receive a 503 Service Unavailable response.
+// The additional listeners of the server are served alongside, see serveListeners.
+func (jx *JinxForwardProxyServer) listenAndServe(s *http.Server) error {
+	l, err := listener.Listen(s.Addr, jx.config.Limits, listener.RejectWithServiceUnavailable)
+	if err != nil {
+		return err
+	}
+
+	if err := jx.serveListeners(s); err != nil {
+		_ = l.Close()
+		return err
+	}
+	jx.watchCertificates()
+	return s.Serve(l)
+}
+
+// listenAndServeTLS loads the certificate store of the server, binds the server address with the configured
+// connection limits applied and serves HTTPS on it under the TLS policy of the server. The additional listeners
+// of the server are served alongside, see serveListeners.
+func (jx *JinxForwardProxyServer) listenAndServeTLS(s *http.Server) error {
+	certificates, err := jx.loadCertificates()
+	if err != nil {
+		return err
+	}
+
+	l, err := listener.Listen(s.Addr, jx.config.Limits, nil)
+	if err != nil {
+		return err
+	}
+
+	// The certificate is picked from the store during the handshake, based on the server name sent by the client
+	tlsConfig, err := certificates.NewTLSConfig(jx.config.TLSPolicy, listener.HTTPProtocols)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	s.TLSConfig = tlsConfig
+
+	if err := jx.serveListeners(s); err != nil {
+		_ = l.Close()
+		return err
+	}
+	jx.watchCertificates()
+	return s.Serve(tls.NewListener(l, tlsConfig))
+}
+
+// loadCertificates loads the certificate store and the client verifier of the server. When an ACME HTTP-01
+// challenge port is configured, a plain HTTP listener answering the challenges is started alongside. The store is
+// only loaded once, later calls return the loaded store.
+func (jx *JinxForwardProxyServer) loadCertificates() (*jinx_tls.CertificateStore, error) {
+	if jx.certificates != nil {
+		return jx.certificates, nil
+	}
+
+	certificates, err := jinx_tls.NewServerCertificateStore(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME)
+	if err != nil {
+		return nil, err
+	}
+	clientVerifier, err := jinx_tls.NewClientVerifier(jx.config.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	certificates.SetClientVerifier(clientVerifier)
+
+	jx.certificates = certificates
+
+	if jx.config.ACME.HTTPChallengePort != 0 {
+		challengeAddr := fmt.Sprintf("%s:%d", jx.config.IP, jx.config.ACME.HTTPChallengePort)
+		jx.challengeServer = certificates.StartHTTPChallengeServer(challengeAddr, jx.config.Limits, jx.errorLogger)
+	}
+
+	return certificates, nil
+}
+
+// serveListeners serves the additional listeners of the server next to its main address. TLS listeners share
+// the certificate store of the server and use their own TLS policy, or the one of the server when they have none. Plain listeners with RedirectToHTTPS set redirect every request to the
+// HTTPS listener, except for ACME HTTP-01 challenges and the exempt paths of the HTTPSRedirect configuration
+// which are answered over plain HTTP.
+func (jx *JinxForwardProxyServer) serveListeners(s *http.Server) error {
+	if len(jx.config.Listeners) == 0 {
+		return nil
+	}
+
+	if listener.AnyTLS(jx.config.Listeners) {
+		if _, err := jx.loadCertificates(); err != nil {
+			return err
+		}
+	}
+
+	if listener.AnyRedirect(jx.config.Listeners) {
+		tlsEnabled := jinx_tls.IsEnabled(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME)
+		httpsPort := listener.HTTPSPort(jx.config.HTTPSRedirect, jx.config.Port, tlsEnabled, jx.config.Listeners)
+
+		redirect := listener.RedirectToHTTPS(httpsPort, jx.config.HTTPSRedirect.ExemptPaths, jx)
+		if jx.certificates != nil {
+			redirect = jx.certificates.HTTPChallengeHandler(redirect)
+		}
+		jx.redirectServer = listener.NewHttpServer("", redirect, jx.config.Limits)
+	}
+
+	for _, config := range jx.config.Listeners {
+		jx.serverLogger.Info(fmt.Sprintf("Listening on %s (TLS=%t, RedirectToHTTPS=%t)", listener.Address(config, jx.config.IP), config.TLS, config.RedirectToHTTPS))
+	}
+
+	tlsConfig := func(config types.ListenerConfig) (*tls.Config, error) {
+		return jx.certificates.NewTLSConfig(listener.TLSPolicy(config, jx.config.TLSPolicy), listener.HTTPProtocols)
+	}
+
+	return listener.ServeListeners(s, jx.redirectServer, jx.config.Listeners, jx.config.IP, jx.config.Limits, tlsConfig, jx.errorLogger)
+}
+
+// watchCertificates starts reloading the certificates, revocation lists and session ticket keys of the server
+// when their files change. It is called once every listener has been set up so that all their files are watched.
+func (jx *JinxForwardProxyServer) watchCertificates() {
+	if jx.certificates == nil || jx.stopCertificateWatch != nil {
+		return
+	}
+	jx.stopCertificateWatch = jx.certificates.StartWatching(jx.config.CertificateReload, jx.serverLogger, jx.errorLogger)
+}
+
+// Reload re-reads the certificate and key files of the server and swaps the certificates that changed into the
+// live TLS configuration. New handshakes use the new certificates while established connections are left
+// untouched. A certificate whose new files cannot be loaded is reported as a JinxError and kept in use.
+// Reload is triggered by the reload command, which sends SIGHUP to the running server.
+func (jx *JinxForwardProxyServer) Reload() {
+	if jx.certificates == nil {
+		return
+	}
+
+	jx.certificates.ReloadAndReport(jx.serverLogger, jx.errorLogger)
+}
+
 // Destroy performs a complete teardown of the JinxHttpServer instance, effectively stopping the server
 // and removing its working directory and all contained data. This method first checks if the server instance
 // (`serverInstance`) is currently running; if it is not, the method returns immediately, as there is no server
@@ -234,16 +410,55 @@ func (jx *JinxForwardProxyServer) Destroy() {
 
 func (jx *JinxForwardProxyServer) HandleHTTPProxyRequest(w http.ResponseWriter, r *http.Request) {
 	jx.serverLogger.Info(fmt.Sprintf("Handling %s request...", r.URL.RequestURI()))
+	start := time.Now()
 	proxy := &httputil.ReverseProxy{
 		Director: func(r *http.Request) {},
 		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
-			jx.errorLogger.Error(err.Error(), err, r)
+			jx.writeUpstreamError(w, r, err, start)
 		},
 	}
 	proxy.ServeHTTP(w, r)
 	jx.serverLogger.Info(fmt.Sprintf("Handling %s request completed...", r.URL.RequestURI()))
 }
 
+// writeUpstreamError answers the request r that could not be forwarded to the server it is for with the status of
+// the class of err, see upstream_error.Status, and the error page of the status. The request ID sent by the client
+// is kept, requests without one are given a new ID. The failure is logged with the requested server, the class of
+// the error and the time spent on the request.
+//
+// Parameters:
+//   - w: The http.ResponseWriter of the client, nothing must have been written to it yet.
+//   - r: The *http.Request of the client.
+//   - err: The error of the request.
+//   - start: When the request to the server was started.
+func (jx *JinxForwardProxyServer) writeUpstreamError(w http.ResponseWriter, r *http.Request, err error, start time.Time) {
+	class := upstream_error.Classify(err)
+	status := upstream_error.Status(class)
+
+	requestID := r.Header.Get(constant.REQUEST_ID_HEADER)
+	if requestID == "" {
+		requestID = helper.NewRequestID()
+	}
+	upstreamURL := r.Host
+	if r.Method != http.MethodConnect {
+		upstreamURL = r.URL.Scheme + "://" + r.URL.Host
+	}
+	// The route of the forward proxy is the requested host, as for its rate limit rules
+	jx.errorLogger.Error("Upstream request failed",
+		"upstream", upstreamURL,
+		"route", r.Host,
+		"class", class,
+		"status", status,
+		"duration", time.Since(start),
+		"method", r.Method,
+		"path", r.URL.Path,
+		"request_id", requestID,
+		"error", err.Error(),
+	)
+
+	jx.errorPages.Write(w, status, requestID)
+}
+
 func (jx *JinxForwardProxyServer) ValidateUpstreamURL(r *http.Request) error {
 
 	reqHost := strings.Split(r.Host, ":")[0]
@@ -260,32 +475,38 @@ func (jx *JinxForwardProxyServer) ValidateUpstreamURL(r *http.Request) error {
 }
 
 func (jx *JinxForwardProxyServer) handleHTTPSProxyRequest(w http.ResponseWriter, r *http.Request) {
+	// Connect to the destination server first, so that the client can still be told why it cannot be reached
+	start := time.Now()
+	destConn, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		jx.writeUpstreamError(w, r, err, start)
+		return
+	}
+
 	// Hijack the connection
 	hijacker, ok := w.(http.Hijacker)
 	if !ok {
+		_ = destConn.Close()
 		http.Error(w, "HTTP Server does not support hijacking", http.StatusInternalServerError)
 		return
 	}
 
 	clientConn, _, err := hijacker.Hijack()
 	if err != nil {
+		_ = destConn.Close()
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Connect to the destination server
-	destConn, err := net.Dial("tcp", r.Host)
-	if err != nil {
-		_ = clientConn.Close()
-		return
-	}
+	// The read and write deadlines armed for the HTTP request must not cut the tunnel short
+	_ = clientConn.SetDeadline(time.Time{})
 
 	// Send a 200 OK response to client
 	_, _ = clientConn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
 
-	// Stream data between the client and the destination server
+	// Stream data between the client and the destination server, until either side closes the tunnel
 	go helper.Transfer(clientConn, destConn)
-	go helper.Transfer(destConn, clientConn)
+	helper.Transfer(destConn, clientConn)
 }
 
 func (jx *JinxForwardProxyServer) handleWebSocketProxyRequest(w http.ResponseWriter, r *http.Request) {
@@ -301,6 +522,9 @@ func (jx *JinxForwardProxyServer) handleWebSocketProxyRequest(w http.ResponseWri
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	// The read and write deadlines armed for the HTTP request must not cut the tunnel short
+	_ = clientConn.SetDeadline(time.Time{})
 	defer func(clientConn net.Conn) {
 		_ = clientConn.Close()
 	}(clientConn)
@@ -335,14 +559,20 @@ func (jx *JinxForwardProxyServer) handleWebSocketProxyRequest(w http.ResponseWri
 		return
 	}
 
-	// At this point, the WebSocket handshake is complete, and we can start relaying messages
+	// At this point, the WebSocket handshake is complete, and we can start relaying messages until the
+	// connection is closed, the deferred closes must not run before
 	go helper.Transfer(destConn, clientConn)
-	go helper.Transfer(clientConn, destConn)
+	helper.Transfer(clientConn, destConn)
 }
 
 func (jx *JinxForwardProxyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	jx.logRequestDetails(r)
 
+	// Clients are throttled per destination host when a rule is keyed by route
+	if !jx.limiter.Allow(w, r, r.Host) {
+		return
+	}
+
 	// Validate the upstream URL for HTTP requests
 	err := jx.ValidateUpstreamURL(r)
 	if err != nil {
@@ -352,6 +582,12 @@ func (jx *JinxForwardProxyServer) ServeHTTP(w http.ResponseWriter, r *http.Reque
 
 	// Special handling for HTTPS CONNECT requests
 	if r.Method == http.MethodConnect {
+		closeTunnel, ok := jx.limiter.OpenTunnel(w, r)
+		if !ok {
+			return
+		}
+		defer closeTunnel()
+
 		jx.handleHTTPSProxyRequest(w, r)
 		return
 	}
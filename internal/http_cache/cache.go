@@ -0,0 +1,371 @@
+// File: cache.go
+// Package: http_cache
+
+// Program Description:
+// This file implements the storage of the shared response cache of the
+// reverse proxy. Responses are stored on disk, a body file and a metadata
+// file per response, behind an in-memory index which evicts the least
+// recently used responses once the cache exceeds its maximum size
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package http_cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	bodySuffix = ".body"
+	metaSuffix = ".meta"
+	tempSuffix = ".tmp"
+)
+
+// Cache is the shared response cache of the reverse proxy.
+type Cache struct {
+	dir          string
+	maxSize      int64
+	maxEntrySize int64
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element // variant key to the element of its entry in lru
+	vary    map[string][]string      // primary key to the request headers its responses vary on
+	lru     *list.List               // entries, most recently used first
+	size    int64
+
+	revalidating sync.Map // variant keys being revalidated in the background
+}
+
+// entry is a stored response. Its metadata is kept in memory and in the metadata file, its body only on disk.
+type entry struct {
+	Key          string // variant key, the primary key followed by the values of the Vary headers
+	Primary      string
+	Vary         []string
+	Status       int
+	Header       http.Header
+	RequestTime  time.Time // when the request of the stored response was sent
+	ResponseTime time.Time // when the stored response, or the last response confirming it, was received
+	BodySize     int64
+
+	name      string // name of the files of the entry, without suffix
+	footprint int64  // bytes used on disk
+}
+
+// New creates the response cache stored in dir and indexes the responses a previous run left there.
+//
+// Parameters:
+//   - config: The types.CacheConfig of the reverse proxy. MaxSize must be positive.
+//   - dir: The directory the responses are stored in, created if missing.
+//
+// Returns:
+//   - The *Cache, or an error if dir could not be created or read.
+func New(config types.CacheConfig, dir string) (*Cache, error) {
+	if config.MaxSize <= 0 {
+		return nil, errors.New("the cache size must be positive")
+	}
+
+	maxEntrySize := config.MaxEntrySize
+	if maxEntrySize <= 0 {
+		maxEntrySize = min(constant.DEFAULT_CACHE_MAX_ENTRY_SIZE, config.MaxSize)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cache directory %s: %v", dir, err)
+	}
+
+	c := &Cache{
+		dir:          dir,
+		maxSize:      int64(config.MaxSize) << 20,
+		maxEntrySize: int64(maxEntrySize) << 20,
+		entries:      make(map[string]*list.Element),
+		vary:         make(map[string][]string),
+		lru:          list.New(),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load indexes the responses stored in the directory of the cache. Temporary files of interrupted writes and
+// responses whose files are incomplete are removed.
+func (c *Cache) load() error {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("cache directory %s: %v", c.dir, err)
+	}
+
+	var loaded []*entry
+	for _, file := range files {
+		name := file.Name()
+		if strings.HasSuffix(name, tempSuffix) {
+			_ = os.Remove(filepath.Join(c.dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, metaSuffix) {
+			continue
+		}
+
+		e, loadErr := c.loadEntry(strings.TrimSuffix(name, metaSuffix))
+		if loadErr != nil {
+			c.removeFiles(strings.TrimSuffix(name, metaSuffix))
+			continue
+		}
+		loaded = append(loaded, e)
+	}
+
+	sort.Slice(loaded, func(i, j int) bool {
+		return loaded[i].ResponseTime.After(loaded[j].ResponseTime)
+	})
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, e := range loaded {
+		c.entries[e.Key] = c.lru.PushBack(e)
+		c.vary[e.Primary] = e.Vary
+		c.size += e.footprint
+	}
+	c.evict()
+
+	return nil
+}
+
+func (c *Cache) loadEntry(name string) (*entry, error) {
+	meta, err := os.ReadFile(filepath.Join(c.dir, name+metaSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	e := &entry{}
+	if err = json.Unmarshal(meta, e); err != nil {
+		return nil, err
+	}
+	if fileName(e.Key) != name {
+		return nil, errors.New("the entry does not belong to its file")
+	}
+
+	body, err := os.Stat(filepath.Join(c.dir, name+bodySuffix))
+	if err != nil {
+		return nil, err
+	}
+	if body.Size() != e.BodySize {
+		return nil, errors.New("incomplete body")
+	}
+
+	e.name = name
+	e.footprint = body.Size() + int64(len(meta))
+	return e, nil
+}
+
+// lookup returns the stored response for r under the primary key, selecting the variant by the request headers the
+// responses of the key vary on.
+func (c *Cache) lookup(primary string, r *http.Request) (*entry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[variantKey(primary, c.vary[primary], r)]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	return element.Value.(*entry), true
+}
+
+// open returns the body of e.
+func (c *Cache) open(e *entry) (*os.File, error) {
+	return os.Open(filepath.Join(c.dir, e.name+bodySuffix))
+}
+
+// store commits the response whose body was written to the temporary file bodyPath as the entry e, replacing the
+// response stored under the same variant key. Responses of the primary key varying on other headers are removed.
+func (c *Cache) store(e *entry, bodyPath string) error {
+	e.name = fileName(e.Key)
+
+	meta, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	e.footprint = e.BodySize + int64(len(meta))
+	if e.footprint > c.maxSize {
+		return errors.New("the response is larger than the cache")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if previous, ok := c.vary[e.Primary]; ok && !slices.Equal(previous, e.Vary) {
+		c.removePrimary(e.Primary)
+	}
+	if element, ok := c.entries[e.Key]; ok {
+		c.remove(element)
+	}
+
+	if err = os.Rename(bodyPath, filepath.Join(c.dir, e.name+bodySuffix)); err != nil {
+		return err
+	}
+	if err = c.writeMeta(e.name, meta); err != nil {
+		c.removeFiles(e.name)
+		return err
+	}
+
+	c.entries[e.Key] = c.lru.PushFront(e)
+	c.vary[e.Primary] = e.Vary
+	c.size += e.footprint
+	c.evict()
+
+	return nil
+}
+
+// refresh replaces the entry e with updated, a copy with the headers and times of a response confirming it. The
+// body on disk is kept.
+func (c *Cache) refresh(e *entry, updated *entry) error {
+	meta, err := json.Marshal(updated)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[e.Key]
+	if !ok || element.Value.(*entry) != e {
+		// The entry was replaced or evicted meanwhile
+		return nil
+	}
+	if err = c.writeMeta(e.name, meta); err != nil {
+		return err
+	}
+
+	updated.name = e.name
+	updated.footprint = updated.BodySize + int64(len(meta))
+	c.size += updated.footprint - e.footprint
+	element.Value = updated
+	c.lru.MoveToFront(element)
+	c.evict()
+
+	return nil
+}
+
+// drop removes the entry e unless it was replaced meanwhile.
+func (c *Cache) drop(e *entry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[e.Key]; ok && element.Value.(*entry) == e {
+		delete(c.entries, e.Key)
+		c.remove(element)
+	}
+}
+
+// Invalidate removes every response stored under the primary key, after a request that may have changed the
+// resource it identifies.
+func (c *Cache) Invalidate(primary string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.removePrimary(primary)
+}
+
+func (c *Cache) removePrimary(primary string) {
+	for key, element := range c.entries {
+		if element.Value.(*entry).Primary == primary {
+			c.remove(element)
+			delete(c.entries, key)
+		}
+	}
+	delete(c.vary, primary)
+}
+
+// evict removes the least recently used responses until the cache fits its maximum size.
+func (c *Cache) evict() {
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		element := c.lru.Back()
+		delete(c.entries, element.Value.(*entry).Key)
+		c.remove(element)
+	}
+}
+
+func (c *Cache) remove(element *list.Element) {
+	e := element.Value.(*entry)
+	c.lru.Remove(element)
+	c.size -= e.footprint
+	c.removeFiles(e.name)
+}
+
+func (c *Cache) removeFiles(name string) {
+	_ = os.Remove(filepath.Join(c.dir, name+metaSuffix))
+	_ = os.Remove(filepath.Join(c.dir, name+bodySuffix))
+}
+
+// writeMeta replaces the metadata file name atomically.
+func (c *Cache) writeMeta(name string, meta []byte) error {
+	file, err := os.CreateTemp(c.dir, "*"+tempSuffix)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(meta)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), filepath.Join(c.dir, name+metaSuffix))
+}
+
+// createBody creates the temporary file the body of a response is written to before it is stored.
+func (c *Cache) createBody() (*os.File, error) {
+	return os.CreateTemp(c.dir, "*"+tempSuffix)
+}
+
+// variantKey is the key of the response to r among the responses of the primary key varying on the request
+// headers vary.
+func variantKey(primary string, vary []string, r *http.Request) string {
+	var key strings.Builder
+	key.WriteString(primary)
+	for _, name := range vary {
+		key.WriteString("\n")
+		key.WriteString(name)
+		key.WriteString(":")
+		key.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return key.String()
+}
+
+// varyHeaders returns the canonical names of the request headers listed by the Vary headers of a response.
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
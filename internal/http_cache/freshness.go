@@ -0,0 +1,190 @@
+// File: freshness.go
+// Package: http_cache
+
+// Program Description:
+// This file implements the caching rules of RFC 9111 for shared caches:
+// which responses may be stored, how long they are fresh and for how long
+// stale responses may still be served, following Cache-Control, Expires,
+// Age and Date, stale-while-revalidate and stale-if-error (RFC 5861)
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package http_cache
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxHeuristicFreshness bounds the freshness of responses that only carry Last-Modified.
+const maxHeuristicFreshness = 24 * time.Hour
+
+// heuristicallyCacheable are the statuses of responses that may be stored without explicit freshness.
+var heuristicallyCacheable = []int{200, 203, 204, 300, 301, 308, 404, 405, 410, 414, 501}
+
+// cacheControl holds the directives of the Cache-Control headers of a request or response, with lowercase names.
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	directives := make(cacheControl)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, argument, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(argument, `"`)
+		}
+	}
+	return directives
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// duration returns the argument of a directive in seconds, such as max-age=60. ok is false when the directive is
+// missing or its argument is not a number.
+func (cc cacheControl) duration(directive string) (time.Duration, bool) {
+	argument, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(argument, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// storable reports whether the response to r with status and header may be stored by a shared cache.
+func storable(r *http.Request, status int, header http.Header) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+
+	cc := parseCacheControl(header)
+	if parseCacheControl(r.Header).has("no-store") || cc.has("no-store") || cc.has("private") {
+		return false
+	}
+	// Responses setting cookies are personal even when they do not say so
+	if header.Get("Set-Cookie") != "" || slices.Contains(varyHeaders(header), "*") {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" && !cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate") {
+		return false
+	}
+
+	explicit := cc.has("public") || cc.has("s-maxage") || cc.has("max-age") || cc.has("no-cache") || header.Get("Expires") != ""
+	temporaryRedirect := status == http.StatusFound || status == http.StatusTemporaryRedirect
+	if !slices.Contains(heuristicallyCacheable, status) && !(explicit && temporaryRedirect) {
+		return false
+	}
+	// Responses with neither freshness nor validators would never be served
+	return explicit || header.Get("ETag") != "" || header.Get("Last-Modified") != ""
+}
+
+// lifetime returns how long the response of e is fresh after it was generated.
+func (e *entry) lifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if maxAge, ok := cc.duration("s-maxage"); ok {
+		return maxAge
+	}
+	if maxAge, ok := cc.duration("max-age"); ok {
+		return maxAge
+	}
+
+	date := e.date()
+	if expires := e.Header.Get("Expires"); expires != "" {
+		expiry, err := http.ParseTime(expires)
+		if err != nil {
+			// Invalid dates, such as 0, mean already expired
+			return 0
+		}
+		return max(0, expiry.Sub(date))
+	}
+
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && slices.Contains(heuristicallyCacheable, e.Status) {
+		return min(max(0, date.Sub(lastModified)/10), maxHeuristicFreshness)
+	}
+	return 0
+}
+
+// age returns the age of the response of e at now, as defined by RFC 9111 section 4.2.3.
+func (e *entry) age(now time.Time) time.Duration {
+	apparentAge := max(0, e.ResponseTime.Sub(e.date()))
+
+	ageValue := time.Duration(0)
+	if seconds, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+	correctedAgeValue := ageValue + e.ResponseTime.Sub(e.RequestTime)
+
+	return max(apparentAge, correctedAgeValue) + now.Sub(e.ResponseTime)
+}
+
+// date returns the Date of the response of e, the time it was received when it has none.
+func (e *entry) date() time.Time {
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return date
+	}
+	return e.ResponseTime
+}
+
+// staleAllowed reports whether the response of e may be served stale at all.
+func (e *entry) staleAllowed() bool {
+	cc := parseCacheControl(e.Header)
+	return !cc.has("must-revalidate") && !cc.has("proxy-revalidate") && !cc.has("s-maxage") && !cc.has("no-cache")
+}
+
+// staleWhileRevalidate reports whether the response of e, stale for staleness, may be served while it is
+// revalidated in the background.
+func (e *entry) staleWhileRevalidate(staleness time.Duration) bool {
+	window, ok := parseCacheControl(e.Header).duration("stale-while-revalidate")
+	return ok && e.staleAllowed() && staleness <= window
+}
+
+// staleIfError reports whether the response of e, stale for staleness, may be served in place of an error of the
+// upstream. The directive is honored in the stored response and in the request r.
+func (e *entry) staleIfError(r *http.Request, staleness time.Duration) bool {
+	if !e.staleAllowed() {
+		return false
+	}
+	for _, cc := range []cacheControl{parseCacheControl(r.Header), parseCacheControl(e.Header)} {
+		if window, ok := cc.duration("stale-if-error"); ok && staleness <= window {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether the conditional request r is answered with 304 Not Modified by a response with
+// header, comparing If-None-Match with ETag or, without If-None-Match, If-Modified-Since with Last-Modified.
+func notModified(r *http.Request, header http.Header) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		etag := strings.TrimPrefix(header.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	return err == nil && !lastModified.After(ifModifiedSince)
+}
@@ -0,0 +1,415 @@
+// File: serve.go
+// Package: http_cache
+
+// Program Description:
+// This file implements how the response cache answers requests: fresh
+// responses are served from disk, stale ones are revalidated with the
+// upstream, in the background when stale-while-revalidate allows it, or
+// served in place of upstream errors when stale-if-error allows it
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package http_cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"jinx/pkg/util/constant"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fetch forwards a request to the upstream and writes its response to w.
+type Fetch func(w http.ResponseWriter, r *http.Request)
+
+// unstoredHeaders are response headers that describe a single response rather than the stored resource.
+var unstoredHeaders = []string{"Age", constant.CACHE_STATUS_HEADER, constant.REQUEST_ID_HEADER}
+
+// Serve answers r from the cache when it holds a usable response under key and forwards it with fetch otherwise,
+// storing the response when it may be. Requests with methods other than GET and HEAD are forwarded and invalidate
+// the responses stored under key. The cache status of the response is set in the X-Cache header.
+//
+// Parameters:
+//   - w: The http.ResponseWriter of the client.
+//   - r: The *http.Request of the client.
+//   - key: The cache key of the request, the variant is selected by the Vary headers of the stored responses.
+//   - fetch: The function forwarding requests to the upstream. It is called at most once while the client waits
+//     and at most once more in the background to revalidate a stale response.
+func (c *Cache) Serve(w http.ResponseWriter, r *http.Request, key string, fetch Fetch) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set(constant.CACHE_STATUS_HEADER, constant.CACHE_BYPASS)
+		fetch(w, r)
+		c.Invalidate(key)
+		return
+	}
+
+	requestCC := parseCacheControl(r.Header)
+	if requestCC.has("no-store") || r.Header.Get("Range") != "" {
+		w.Header().Set(constant.CACHE_STATUS_HEADER, constant.CACHE_BYPASS)
+		fetch(w, r)
+		return
+	}
+
+	e, ok := c.lookup(key, r)
+	if !ok {
+		if requestCC.has("only-if-cached") {
+			w.Header().Set(constant.CACHE_STATUS_HEADER, constant.CACHE_MISS)
+			http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+			return
+		}
+		c.fetch(w, r, key, nil, fetch)
+		return
+	}
+
+	now := time.Now()
+	age := e.age(now)
+	lifetime := e.lifetime()
+
+	revalidate := requestCC.has("no-cache") || parseCacheControl(e.Header).has("no-cache")
+	if len(requestCC) == 0 && strings.Contains(strings.ToLower(r.Header.Get("Pragma")), "no-cache") {
+		revalidate = true
+	}
+	if maxAge, ok := requestCC.duration("max-age"); ok && age > maxAge {
+		revalidate = true
+	}
+
+	switch {
+	case !revalidate && age < lifetime:
+		c.serve(w, r, e, now, constant.CACHE_HIT, key, fetch)
+	case requestCC.has("only-if-cached"):
+		c.serve(w, r, e, now, constant.CACHE_STALE, key, fetch)
+	case !revalidate && e.staleWhileRevalidate(age-lifetime):
+		c.serve(w, r, e, now, constant.CACHE_UPDATING, key, fetch)
+		c.revalidateInBackground(r, key, e, fetch)
+	default:
+		c.fetch(w, r, key, e, fetch)
+	}
+}
+
+// serve writes the stored response e to w, or 304 Not Modified when the client already has it. When the body can
+// no longer be read, as the response was evicted meanwhile, the request is forwarded instead.
+func (c *Cache) serve(w http.ResponseWriter, r *http.Request, e *entry, now time.Time, status string, key string, fetch Fetch) {
+	body, err := c.open(e)
+	if err != nil {
+		c.fetch(w, r, key, nil, fetch)
+		return
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+
+	header := w.Header()
+	for name, values := range e.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set("Age", strconv.FormatInt(int64(e.age(now)/time.Second), 10))
+	header.Set(constant.CACHE_STATUS_HEADER, status)
+
+	if e.Status == http.StatusOK && notModified(r, e.Header) {
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(e.Status)
+	if r.Method != http.MethodHead {
+		_, _ = io.Copy(w, body)
+	}
+}
+
+// fetch forwards r and stores the response when it may be. When stale is not nil the request is made conditional
+// on the stored response, which is served again when the upstream confirms it or, if allowed, fails.
+func (c *Cache) fetch(w http.ResponseWriter, r *http.Request, key string, stale *entry, fetch Fetch) {
+	out := r.Clone(r.Context())
+	if r.Method == http.MethodGet {
+		// The cache answers the conditions of the client itself, the upstream must send the full response
+		out.Header.Del("If-None-Match")
+		out.Header.Del("If-Modified-Since")
+		if stale != nil {
+			if etag := stale.Header.Get("ETag"); etag != "" {
+				out.Header.Set("If-None-Match", etag)
+			}
+			if lastModified := stale.Header.Get("Last-Modified"); lastModified != "" {
+				out.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	_, background := w.(*discardWriter)
+	cw := &cacheWriter{
+		cache:       c,
+		client:      w,
+		request:     r,
+		stale:       stale,
+		header:      make(http.Header),
+		requestTime: time.Now(),
+		background:  background,
+	}
+	defer cw.discard()
+
+	fetch(cw, out)
+	if !cw.wroteHeader {
+		return
+	}
+
+	switch {
+	case cw.revalidated:
+		updated := cw.updated()
+		_ = c.refresh(stale, updated)
+		if !cw.background {
+			c.serve(w, r, updated, time.Now(), constant.CACHE_REVALIDATED, key, fetch)
+		}
+	case cw.replaced:
+		if !cw.background {
+			c.serve(w, r, stale, time.Now(), constant.CACHE_STALE, key, fetch)
+		}
+	case cw.body != nil:
+		cw.commit(key)
+	case stale != nil:
+		// The stale response was replaced by one that is not stored
+		c.drop(stale)
+	}
+}
+
+// revalidateInBackground revalidates the stale response e after the client was answered. Only one revalidation
+// of a response runs at a time.
+func (c *Cache) revalidateInBackground(r *http.Request, key string, e *entry, fetch Fetch) {
+	if _, running := c.revalidating.LoadOrStore(e.Key, true); running {
+		return
+	}
+
+	out := r.Clone(context.WithoutCancel(r.Context()))
+	out.Body = http.NoBody
+	out.ContentLength = 0
+
+	go func() {
+		defer c.revalidating.Delete(e.Key)
+		c.fetch(&discardWriter{header: make(http.Header)}, out, key, e, fetch)
+	}()
+}
+
+// cacheWriter passes the response of the upstream on to the client while writing its body to a temporary file
+// when it may be stored. Responses confirming a stale response and errors the stale response may replace are
+// not passed on.
+type cacheWriter struct {
+	cache       *Cache
+	client      http.ResponseWriter
+	request     *http.Request
+	stale       *entry
+	header      http.Header
+	requestTime time.Time
+
+	wroteHeader  bool
+	status       int
+	responseTime time.Time
+	revalidated  bool // the upstream answered 304 Not Modified to the revalidation of stale
+	replaced     bool // the upstream failed and stale is served instead
+	background   bool
+	body         *os.File // temporary body file, nil when the response is not stored
+	written      int64
+}
+
+func (cw *cacheWriter) Header() http.Header {
+	return cw.header
+}
+
+func (cw *cacheWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+
+	// Informational responses are passed on, the final response follows
+	if status < http.StatusOK {
+		copyHeader(cw.client.Header(), cw.header)
+		cw.client.WriteHeader(status)
+		return
+	}
+
+	cw.wroteHeader = true
+	cw.status = status
+	cw.responseTime = time.Now()
+
+	if cw.stale != nil {
+		staleness := cw.stale.age(cw.responseTime) - cw.stale.lifetime()
+		switch {
+		case status == http.StatusNotModified:
+			cw.revalidated = true
+			return
+		case status >= http.StatusInternalServerError && cw.stale.staleIfError(cw.request, staleness):
+			cw.replaced = true
+			return
+		}
+	}
+
+	cacheStatus := constant.CACHE_MISS
+	if cw.stale != nil {
+		cacheStatus = constant.CACHE_EXPIRED
+	}
+	copyHeader(cw.client.Header(), cw.header)
+	cw.client.Header().Set(constant.CACHE_STATUS_HEADER, cacheStatus)
+
+	if storable(cw.request, status, cw.header) && !cw.tooLarge() {
+		if body, err := cw.cache.createBody(); err == nil {
+			cw.body = body
+		}
+	}
+
+	cw.client.WriteHeader(status)
+}
+
+func (cw *cacheWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.revalidated || cw.replaced {
+		return len(p), nil
+	}
+
+	if cw.body != nil {
+		if _, err := cw.body.Write(p); err != nil || cw.written+int64(len(p)) > cw.cache.maxEntrySize {
+			cw.discard()
+		} else {
+			cw.written += int64(len(p))
+		}
+	}
+	return cw.client.Write(p)
+}
+
+// FlushError flushes the response to the client, responses that are not passed on are not flushed.
+func (cw *cacheWriter) FlushError() error {
+	if !cw.wroteHeader || cw.revalidated || cw.replaced {
+		return nil
+	}
+	return http.NewResponseController(cw.client).Flush()
+}
+
+func (cw *cacheWriter) Unwrap() http.ResponseWriter {
+	return cw.client
+}
+
+// tooLarge reports whether the Content-Length of the response exceeds the largest response stored.
+func (cw *cacheWriter) tooLarge() bool {
+	length, err := strconv.ParseInt(cw.header.Get("Content-Length"), 10, 64)
+	return err == nil && length > cw.cache.maxEntrySize
+}
+
+// commit stores the response once its body was completely received.
+func (cw *cacheWriter) commit(key string) {
+	if length, err := strconv.ParseInt(cw.header.Get("Content-Length"), 10, 64); err == nil && length != cw.written {
+		return
+	}
+	if err := cw.body.Close(); err != nil {
+		return
+	}
+
+	header := storedHeader(cw.header)
+	e := &entry{
+		Primary:      key,
+		Vary:         varyHeaders(header),
+		Status:       cw.status,
+		Header:       header,
+		RequestTime:  cw.requestTime,
+		ResponseTime: cw.responseTime,
+		BodySize:     cw.written,
+	}
+	e.Key = variantKey(key, e.Vary, cw.request)
+
+	if err := cw.cache.store(e, cw.body.Name()); err == nil {
+		cw.body = nil
+	}
+}
+
+// discard removes the temporary body file of a response that is not stored.
+func (cw *cacheWriter) discard() {
+	if cw.body == nil {
+		return
+	}
+	_ = cw.body.Close()
+	_ = os.Remove(cw.body.Name())
+	cw.body = nil
+}
+
+// updated returns a copy of the stale entry with the headers and times of the 304 Not Modified response
+// confirming it.
+func (cw *cacheWriter) updated() *entry {
+	updated := *cw.stale
+	updated.Header = cw.stale.Header.Clone()
+	for name, values := range storedHeader(cw.header) {
+		if name == "Content-Length" {
+			continue
+		}
+		updated.Header[name] = values
+	}
+	updated.RequestTime = cw.requestTime
+	updated.ResponseTime = cw.responseTime
+	return &updated
+}
+
+// discardWriter is the http.ResponseWriter of background revalidations, which have no client.
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header {
+	return d.header
+}
+
+func (d *discardWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (d *discardWriter) WriteHeader(int) {}
+
+// Bypass reports whether r matches one of the bypass rules of a route, see types.RouteCacheConfig.
+func Bypass(r *http.Request, rules []string) bool {
+	for _, rule := range rules {
+		kind, name, _ := strings.Cut(rule, ":")
+		switch kind {
+		case "header":
+			if r.Header.Get(name) != "" {
+				return true
+			}
+		case "cookie":
+			if _, err := r.Cookie(name); err == nil {
+				return true
+			}
+		case "query":
+			if r.URL.Query().Has(name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateBypass checks the bypass rules of a route.
+func ValidateBypass(rules []string) error {
+	for _, rule := range rules {
+		kind, name, ok := strings.Cut(rule, ":")
+		if !ok || name == "" || (kind != "header" && kind != "cookie" && kind != "query") {
+			return fmt.Errorf("%q is not a bypass rule, expected header:Name, cookie:name or query:name", rule)
+		}
+	}
+	return nil
+}
+
+// storedHeader returns a copy of the response header without the headers describing a single response.
+func storedHeader(header http.Header) http.Header {
+	stored := header.Clone()
+	for _, name := range unstoredHeaders {
+		stored.Del(name)
+	}
+	return stored
+}
+
+func copyHeader(dst http.Header, src http.Header) {
+	for name, values := range src {
+		dst[name] = append([]string(nil), values...)
+	}
+}
@@ -14,8 +14,12 @@ package jinx_http
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"jinx/internal/jinx_tls"
+	"jinx/internal/listener"
+	"jinx/internal/rate_limit"
 	"jinx/pkg/util/constant"
 	"jinx/pkg/util/helper"
 	"jinx/pkg/util/types"
@@ -32,11 +36,16 @@ import (
 )
 
 type JinxHttpServer struct {
-	config           types.JinxHttpServerConfig // Server configuration settings.
-	errorLogger      *slog.Logger               // Logger for error messages.
-	serverLogger     *slog.Logger               // Logger for general server activity.
-	serverWorkingDir string                     // Server root dir where website files are stored
-	serverInstance   *http.Server
+	config               types.JinxHttpServerConfig // Server configuration settings.
+	errorLogger          *slog.Logger               // Logger for error messages.
+	serverLogger         *slog.Logger               // Logger for general server activity.
+	serverWorkingDir     string                     // Server root dir where website files are stored
+	serverInstance       *http.Server
+	certificates         *jinx_tls.CertificateStore
+	challengeServer      *http.Server
+	redirectServer       *http.Server
+	stopCertificateWatch func()
+	limiter              *rate_limit.Limiter
 }
 
 // NewJinxHttpServer initializes a new instance of JinxHttpServer with the provided configuration
@@ -86,12 +95,24 @@ func NewJinxHttpServer(config types.JinxHttpServerConfig, serverWorkingDir strin
 		log.Fatalf("%s does not exist or is not readable", serverWorkingDir)
 	}
 
+	trustedProxies, trustedProxiesErr := helper.ParseTrustedProxies(config.RateLimit.TrustedProxies)
+	if trustedProxiesErr != nil {
+		log.Fatal(trustedProxiesErr)
+	}
+
+	serverLogger := slog.New(slog.NewJSONHandler(serverLogFile, nil))
+	limiter, limiterErr := rate_limit.New(config.RateLimit, trustedProxies, serverLogger)
+	if limiterErr != nil {
+		log.Fatal(limiterErr)
+	}
+
 	return &JinxHttpServer{
 		config:           config,
 		errorLogger:      slog.New(slog.NewJSONHandler(errorLogFile, nil)),
-		serverLogger:     slog.New(slog.NewJSONHandler(serverLogFile, nil)),
+		serverLogger:     serverLogger,
 		serverWorkingDir: serverWorkingDir,
 		serverInstance:   nil,
+		limiter:          limiter,
 	}
 }
 
@@ -121,13 +142,7 @@ func (jx *JinxHttpServer) Start() types.JinxServer {
 	addr := fmt.Sprintf("%s:%d", jx.config.IP, jx.config.Port)
 	jx.serverLogger.Info(fmt.Sprintf("Starting Jinx on %s", addr))
 
-	s := &http.Server{
-		Addr:           addr,
-		Handler:        jx,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
-	}
+	s := listener.NewHttpServer(addr, jx, jx.config.Limits)
 
 	jx.serverInstance = s
 
@@ -140,20 +155,21 @@ func (jx *JinxHttpServer) Start() types.JinxServer {
 		sig := <-signalChan
 		jx.serverLogger.Info(fmt.Sprintf("Received signal %v: shutting down server...", sig))
 
-		// Create a context with a timeout to tell the server how long to wait for existing requests to finish
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-		defer cancel()
+		// Stop also shuts down the listeners started alongside the server, such as the HTTPS redirect
+		jx.Stop()
+	}()
 
-		// Attempt to gracefully shut down the server
-		if err := s.Shutdown(ctx); err != nil {
-			jx.errorLogger.Error(fmt.Sprintf("Server shutdown error: %s", err))
+	// Reload the certificates when the reload command sends SIGHUP
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			jx.Reload()
 		}
-
-		jx.serverLogger.Info(fmt.Sprintf("Successfully shutdown server"))
 	}()
 
-	if jx.config.CertFile != "" && jx.config.KeyFile != "" {
-		err := s.ListenAndServeTLS(jx.config.CertFile, jx.config.KeyFile)
+	if jinx_tls.IsEnabled(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME) {
+		err := jx.listenAndServeTLS(s)
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
 			log.Fatal(err)
@@ -162,7 +178,7 @@ func (jx *JinxHttpServer) Start() types.JinxServer {
 	}
 
 	// Start the server
-	err := s.ListenAndServe()
+	err := jx.listenAndServe(s)
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
 		log.Fatal(err)
@@ -203,6 +219,23 @@ func (jx *JinxHttpServer) Stop() {
 		jx.errorLogger.Error(fmt.Sprintf("Server shutdown error: %s", err))
 	}
 
+	if jx.challengeServer != nil {
+		_ = jx.challengeServer.Shutdown(ctx)
+		jx.challengeServer = nil
+	}
+
+	if jx.redirectServer != nil {
+		_ = jx.redirectServer.Shutdown(ctx)
+		jx.redirectServer = nil
+	}
+
+	if jx.stopCertificateWatch != nil {
+		jx.stopCertificateWatch()
+		jx.stopCertificateWatch = nil
+	}
+	// A restart loads the certificates again, together with the challenge listener stopped above
+	jx.certificates = nil
+
 	jx.serverLogger.Info(fmt.Sprintf("Successfully shutdown server manually"))
 }
 
@@ -238,9 +271,10 @@ func (jx *JinxHttpServer) Restart() types.JinxServer {
 	}
 
 	jx.Stop()
+	jx.serverInstance = listener.NewHttpServer(jx.serverInstance.Addr, jx, jx.config.Limits)
 	go func() {
-		if jx.config.CertFile != "" && jx.config.KeyFile != "" {
-			err := jx.serverInstance.ListenAndServeTLS(jx.config.CertFile, jx.config.KeyFile)
+		if jinx_tls.IsEnabled(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME) {
+			err := jx.listenAndServeTLS(jx.serverInstance)
 			if err != nil && !errors.Is(err, http.ErrServerClosed) {
 				jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
 				log.Fatal(err)
@@ -249,7 +283,7 @@ func (jx *JinxHttpServer) Restart() types.JinxServer {
 		}
 
 		// Start the server
-		err := jx.serverInstance.ListenAndServe()
+		err := jx.listenAndServe(jx.serverInstance)
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
 			log.Fatal(err)
@@ -259,6 +293,139 @@ func (jx *JinxHttpServer) Restart() types.JinxServer {
 	return jx
 }
 
+// listenAndServe binds the server address with the configured connection limits applied and serves plain
+// HTTP on it. Connections refused because of the per IP limit receive a 503 Service Unavailable response.
+// The additional listeners of the server are served alongside, see serveListeners.
+func (jx *JinxHttpServer) listenAndServe(s *http.Server) error {
+	l, err := listener.Listen(s.Addr, jx.config.Limits, listener.RejectWithServiceUnavailable)
+	if err != nil {
+		return err
+	}
+
+	if err := jx.serveListeners(s); err != nil {
+		_ = l.Close()
+		return err
+	}
+	jx.watchCertificates()
+	return s.Serve(l)
+}
+
+// listenAndServeTLS loads the certificate store of the server, binds the server address with the configured
+// connection limits applied and serves HTTPS on it under the TLS policy of the server. The additional listeners
+// of the server are served alongside, see serveListeners.
+func (jx *JinxHttpServer) listenAndServeTLS(s *http.Server) error {
+	certificates, err := jx.loadCertificates()
+	if err != nil {
+		return err
+	}
+
+	l, err := listener.Listen(s.Addr, jx.config.Limits, nil)
+	if err != nil {
+		return err
+	}
+
+	// The certificate is picked from the store during the handshake, based on the server name sent by the client
+	tlsConfig, err := certificates.NewTLSConfig(jx.config.TLSPolicy, listener.HTTPProtocols)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	s.TLSConfig = tlsConfig
+
+	if err := jx.serveListeners(s); err != nil {
+		_ = l.Close()
+		return err
+	}
+	jx.watchCertificates()
+	return s.Serve(tls.NewListener(l, tlsConfig))
+}
+
+// loadCertificates loads the certificate store and the client verifier of the server. When an ACME HTTP-01
+// challenge port is configured, a plain HTTP listener answering the challenges is started alongside. The store is
+// only loaded once, later calls return the loaded store.
+func (jx *JinxHttpServer) loadCertificates() (*jinx_tls.CertificateStore, error) {
+	if jx.certificates != nil {
+		return jx.certificates, nil
+	}
+
+	certificates, err := jinx_tls.NewServerCertificateStore(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME)
+	if err != nil {
+		return nil, err
+	}
+	clientVerifier, err := jinx_tls.NewClientVerifier(jx.config.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	certificates.SetClientVerifier(clientVerifier)
+
+	jx.certificates = certificates
+
+	if jx.config.ACME.HTTPChallengePort != 0 {
+		challengeAddr := fmt.Sprintf("%s:%d", jx.config.IP, jx.config.ACME.HTTPChallengePort)
+		jx.challengeServer = certificates.StartHTTPChallengeServer(challengeAddr, jx.config.Limits, jx.errorLogger)
+	}
+
+	return certificates, nil
+}
+
+// serveListeners serves the additional listeners of the server next to its main address. TLS listeners share
+// the certificate store of the server and use their own TLS policy, or the one of the server when they have none. Plain listeners with RedirectToHTTPS set redirect every request to the
+// HTTPS listener, except for ACME HTTP-01 challenges and the exempt paths of the HTTPSRedirect configuration
+// which are answered over plain HTTP.
+func (jx *JinxHttpServer) serveListeners(s *http.Server) error {
+	if len(jx.config.Listeners) == 0 {
+		return nil
+	}
+
+	if listener.AnyTLS(jx.config.Listeners) {
+		if _, err := jx.loadCertificates(); err != nil {
+			return err
+		}
+	}
+
+	if listener.AnyRedirect(jx.config.Listeners) {
+		tlsEnabled := jinx_tls.IsEnabled(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME)
+		httpsPort := listener.HTTPSPort(jx.config.HTTPSRedirect, jx.config.Port, tlsEnabled, jx.config.Listeners)
+
+		redirect := listener.RedirectToHTTPS(httpsPort, jx.config.HTTPSRedirect.ExemptPaths, jx)
+		if jx.certificates != nil {
+			redirect = jx.certificates.HTTPChallengeHandler(redirect)
+		}
+		jx.redirectServer = listener.NewHttpServer("", redirect, jx.config.Limits)
+	}
+
+	for _, config := range jx.config.Listeners {
+		jx.serverLogger.Info(fmt.Sprintf("Listening on %s (TLS=%t, RedirectToHTTPS=%t)", listener.Address(config, jx.config.IP), config.TLS, config.RedirectToHTTPS))
+	}
+
+	tlsConfig := func(config types.ListenerConfig) (*tls.Config, error) {
+		return jx.certificates.NewTLSConfig(listener.TLSPolicy(config, jx.config.TLSPolicy), listener.HTTPProtocols)
+	}
+
+	return listener.ServeListeners(s, jx.redirectServer, jx.config.Listeners, jx.config.IP, jx.config.Limits, tlsConfig, jx.errorLogger)
+}
+
+// watchCertificates starts reloading the certificates, revocation lists and session ticket keys of the server
+// when their files change. It is called once every listener has been set up so that all their files are watched.
+func (jx *JinxHttpServer) watchCertificates() {
+	if jx.certificates == nil || jx.stopCertificateWatch != nil {
+		return
+	}
+	jx.stopCertificateWatch = jx.certificates.StartWatching(jx.config.CertificateReload, jx.serverLogger, jx.errorLogger)
+}
+
+// Reload re-reads the certificate and key files of the server and swaps the certificates that changed into the
+// live TLS configuration. New handshakes use the new certificates while established connections are left
+// untouched. A certificate whose new files cannot be loaded is reported as a JinxError and kept in use.
+// Reload is triggered by the reload command, which sends SIGHUP to the running server.
+func (jx *JinxHttpServer) Reload() {
+	if jx.certificates == nil {
+		return
+	}
+
+	jx.certificates.ReloadAndReport(jx.serverLogger, jx.errorLogger)
+}
+
 // Destroy performs a complete teardown of the JinxHttpServer instance, effectively stopping the server
 // and removing its working directory and all contained data. This method first checks if the server instance
 // (`serverInstance`) is currently running; if it is not, the method returns immediately, as there is no server
@@ -322,6 +489,10 @@ func (jx *JinxHttpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Log the incoming request
 	jx.serverLogger.Info(fmt.Sprintf("Received request: Method=%s, URL=%s, RemoteAddr=%s", r.Method, r.URL.String(), r.RemoteAddr))
 
+	if !jx.limiter.Allow(w, r, "") {
+		return
+	}
+
 	// Determine the file to serve
 	filePath, err := jx.ResolveFilePath(r)
 	if err != nil {
@@ -408,9 +579,7 @@ func (jx *JinxHttpServer) ResolveFilePath(r *http.Request) (string, error) {
 // Finally, it uses the http.ServeFile function to handle the file serving, including support for
 // partial content delivery and automatic MIME type detection.
 func (jx *JinxHttpServer) ServeFile(w http.ResponseWriter, r *http.Request, filePath string) {
-	w.Header().Set("Cache-Control", "max-age=3600")
-	w.Header().Set("Server", constant.SOFTWARE_NAME)
-	http.ServeFile(w, r, filePath)
+	serveFile(w, r, filePath)
 }
 
 // Serve404 sends a 404 Not Found response to the client with the content of a specified file.
@@ -429,6 +598,20 @@ func (jx *JinxHttpServer) ServeFile(w http.ResponseWriter, r *http.Request, file
 // If an error occurs while reading the custom error file, the status code is still set to 404.
 // However, if an error occurs while writing the content to the response, the status code is set to 500 Internal Server Error.
 func (jx *JinxHttpServer) Serve404(w http.ResponseWriter, filePath string) {
+	serveNotFound(w, filePath)
+}
+
+// serveFile sends the file at filePath with the caching and Server headers of the HTTP server, see ServeFile. It is
+// shared with the static locations of the reverse proxy.
+func serveFile(w http.ResponseWriter, r *http.Request, filePath string) {
+	w.Header().Set("Cache-Control", "max-age=3600")
+	w.Header().Set("Server", constant.SOFTWARE_NAME)
+	http.ServeFile(w, r, filePath)
+}
+
+// serveNotFound answers with 404 Not Found and the content of the page at filePath, see Serve404. It is shared with
+// the static locations of the reverse proxy.
+func serveNotFound(w http.ResponseWriter, filePath string) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		http.Error(w, "404 Not Found", http.StatusNotFound)
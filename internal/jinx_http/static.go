@@ -0,0 +1,98 @@
+// File: static.go
+// Package: jinx_http
+
+// Program Description:
+// This file implements the static locations of the reverse proxy. A route
+// with a document root is answered from its files with the file serving of
+// the HTTP server rather than forwarded, so that one server can host a
+// single page application and proxy its API. Paths without a file are
+// answered with the fallback file of the location or with its 404 page
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package jinx_http
+
+import (
+	"fmt"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// StaticFiles serves the files of the document root of a static location.
+type StaticFiles struct {
+	root     string
+	fallback string // absolute path of the fallback file, empty without fallback
+	prefix   string // path of the route removed from request paths, empty unless the prefix is stripped
+}
+
+// NewStaticFiles checks the static location config and returns its handler.
+//
+// Parameters:
+//   - config: The types.StaticLocation of the route.
+//   - prefix: The path of the route without trailing slash, removed from request paths when config.StripPrefix is set.
+//
+// Returns:
+//   - The *StaticFiles, or an error if the root is not a readable directory or the fallback is not a file of the root.
+func NewStaticFiles(config types.StaticLocation, prefix string) (*StaticFiles, error) {
+	if readable, err := helper.IsDirReadable(config.Root); !readable || err != nil {
+		return nil, fmt.Errorf("the static root %s does not exist or is not readable", config.Root)
+	}
+
+	static := &StaticFiles{root: config.Root}
+	if config.StripPrefix {
+		static.prefix = prefix
+	}
+	if config.Fallback != "" {
+		// The fallback is kept within the root, whatever dot segments it holds
+		fallback := filepath.Join(config.Root, filepath.FromSlash(path.Clean("/"+config.Fallback)))
+		if info, err := os.Stat(fallback); err != nil || info.IsDir() {
+			return nil, fmt.Errorf("the static fallback %s is not a file of %s", config.Fallback, config.Root)
+		}
+		static.fallback = fallback
+	}
+	return static, nil
+}
+
+// ServeHTTP answers GET and HEAD requests with the file of the request path in the document root, the index.html
+// file of a directory, the fallback file when there is no such file, or 404 Not Found with the 404.html page of the
+// root. Other methods are answered with 405 Method Not Allowed.
+//
+// Parameters:
+//   - w: The http.ResponseWriter of the client.
+//   - r: The *http.Request of the client, matching the route of the location.
+func (s *StaticFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Cleaning before joining keeps dot segments from leaving the root
+	urlPath := path.Clean("/" + r.URL.Path)
+	if s.prefix != "" {
+		urlPath = path.Clean("/" + strings.TrimPrefix(urlPath, s.prefix))
+	}
+
+	file := filepath.Join(s.root, filepath.FromSlash(urlPath))
+	if info, err := os.Stat(file); err == nil && info.IsDir() {
+		file = filepath.Join(file, constant.INDEX_FILE)
+	}
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		if s.fallback != "" {
+			serveFile(w, r, s.fallback)
+			return
+		}
+		serveNotFound(w, filepath.Join(s.root, constant.NOT_FOUND))
+		return
+	}
+
+	serveFile(w, r, file)
+}
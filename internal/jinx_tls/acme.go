@@ -0,0 +1,180 @@
+// File: acme.go
+// Package: jinx_tls
+
+// Program Description:
+// This file adds automatic certificate management through an ACME (RFC 8555)
+// certificate authority to the certificate store. Certificates are obtained
+// and renewed using HTTP-01 or TLS-ALPN-01 challenges
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package jinx_tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"jinx/internal/listener"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// NewACMEManager creates the autocert.Manager obtaining certificates for the hosts of config. Certificates and
+// the ACME account key are cached in cacheDir so they survive restarts and are only renewed when due.
+//
+// Parameters:
+//   - config: The types.ACMEConfig of the server.
+//   - cacheDir: The directory certificates are stored in.
+//
+// Returns:
+//   - The configured *autocert.Manager, or an error if the directory CA bundle could not be read.
+func NewACMEManager(config types.ACMEConfig, cacheDir string) (*autocert.Manager, error) {
+	client := &acme.Client{DirectoryURL: config.DirectoryURL}
+
+	if config.DirectoryCAFile != "" {
+		caBundle, err := os.ReadFile(config.DirectoryCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("%s contains no PEM encoded certificate", config.DirectoryCAFile)
+		}
+
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}},
+			Timeout:   time.Minute,
+		}
+	}
+
+	renewBefore := config.RenewBefore
+	if renewBefore == 0 {
+		renewBefore = constant.DEFAULT_ACME_RENEW_BEFORE
+	}
+
+	return &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       autocert.DirCache(cacheDir),
+		HostPolicy:  autocert.HostWhitelist(config.Hosts...),
+		RenewBefore: time.Duration(renewBefore) * 24 * time.Hour,
+		Email:       config.Email,
+		Client:      client,
+	}, nil
+}
+
+// NewServerCertificateStore builds the certificate store of a server from its certificate files and its ACME
+// configuration. Either source may be empty but not both.
+//
+// Parameters:
+//   - certFile, keyFile, certificates: The certificate files of the server, see LoadCertificateStore.
+//   - acmeConfig: The ACME configuration of the server. When it lists hosts, certificates for those hosts are
+//     obtained from the ACME directory instead of the files.
+//
+// Returns:
+//   - The populated *CertificateStore, or an error if a certificate or the ACME configuration could not be loaded.
+func NewServerCertificateStore(certFile string, keyFile string, certificates []types.CertificateConfig, acmeConfig types.ACMEConfig) (*CertificateStore, error) {
+	if !IsEnabled(certFile, keyFile, certificates, acmeConfig) {
+		return nil, errors.New("no certificate configured")
+	}
+
+	store := NewCertificateStore()
+	if IsEnabled(certFile, keyFile, certificates, types.ACMEConfig{}) {
+		loaded, err := LoadCertificateStore(certFile, keyFile, certificates)
+		if err != nil {
+			return nil, err
+		}
+		store = loaded
+	}
+
+	if len(acmeConfig.Hosts) > 0 {
+		manager, err := NewACMEManager(acmeConfig, constant.ACME_CERTIFICATE_DIR)
+		if err != nil {
+			return nil, err
+		}
+		store.SetACMEManager(manager, acmeConfig.Hosts)
+	}
+
+	return store, nil
+}
+
+// SetACMEManager makes the store obtain the certificates of hosts through manager. Handshakes for those
+// hosts, and TLS-ALPN-01 challenge handshakes, are answered by the manager instead of the static certificates.
+func (s *CertificateStore) SetACMEManager(manager *autocert.Manager, hosts []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.acmeManager = manager
+	s.acmeHosts = make(map[string]bool)
+	for _, host := range hosts {
+		s.acmeHosts[normalizeServerName(host)] = true
+	}
+}
+
+// acmeCertificate returns the certificate for the handshake from the ACME manager when the handshake is a
+// TLS-ALPN-01 challenge or asks for an ACME managed host. The boolean is false when the manager is not involved.
+func (s *CertificateStore) acmeCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, bool, error) {
+	s.mutex.RLock()
+	manager := s.acmeManager
+	managed := s.acmeHosts[normalizeServerName(hello.ServerName)]
+	s.mutex.RUnlock()
+
+	if manager == nil {
+		return nil, false, nil
+	}
+
+	if !managed && !slices.Contains(hello.SupportedProtos, acme.ALPNProto) {
+		return nil, false, nil
+	}
+
+	certificate, err := manager.GetCertificate(hello)
+	return certificate, true, err
+}
+
+// HTTPChallengeHandler returns a handler answering the HTTP-01 challenges of the ACME manager and passing every
+// other request to next. When next is nil, other requests are redirected to HTTPS. When the store has no ACME
+// manager, next is returned unchanged.
+func (s *CertificateStore) HTTPChallengeHandler(next http.Handler) http.Handler {
+	s.mutex.RLock()
+	manager := s.acmeManager
+	s.mutex.RUnlock()
+
+	if manager == nil {
+		return next
+	}
+	return manager.HTTPHandler(next)
+}
+
+// StartHTTPChallengeServer starts a plain HTTP server on addr answering the HTTP-01 challenges of the store and
+// redirecting every other request to HTTPS. Errors while serving are reported through errorLogger.
+//
+// Returns:
+//   - The started *http.Server so that it can be shut down together with the TLS listener.
+func (s *CertificateStore) StartHTTPChallengeServer(addr string, limits types.ListenerLimits, errorLogger *slog.Logger) *http.Server {
+	server := listener.NewHttpServer(addr, s.HTTPChallengeHandler(nil), limits)
+
+	go func() {
+		l, err := listener.Listen(addr, limits, listener.RejectWithServiceUnavailable)
+		if err != nil {
+			errorLogger.Error(fmt.Sprintf("Failed to start ACME challenge listener: %s", err.Error()))
+			return
+		}
+
+		if serveErr := server.Serve(l); serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+			errorLogger.Error(fmt.Sprintf("ACME challenge listener stopped: %s", serveErr.Error()))
+		}
+	}()
+
+	return server
+}
@@ -0,0 +1,267 @@
+// File: certificate_reload.go
+// Package: jinx_tls
+
+// Program Description:
+// This file implements hot reloading of the certificates of a certificate
+// store when their files are replaced on disk, for example by an external
+// renewal job, without restarting the server or dropping connections
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package jinx_tls
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/error_handler"
+	"jinx/pkg/util/types"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// certificateEntry is a certificate of the store together with the files it was loaded from.
+// Entries added without files are never reloaded.
+type certificateEntry struct {
+	config      types.CertificateConfig
+	certificate *tls.Certificate
+	stamp       fileStamp // stamp of the files the certificate was loaded from
+	failedStamp fileStamp // stamp of the files that last failed to load, so the failure is only reported once
+}
+
+// fileStamp identifies a version of the certificate and key files by their modification times and sizes.
+type fileStamp struct {
+	certModTime time.Time
+	certSize    int64
+	keyModTime  time.Time
+	keySize     int64
+}
+
+func (e *certificateEntry) serverNames() []string {
+	if len(e.config.ServerNames) > 0 || e.certificate.Leaf == nil {
+		return e.config.ServerNames
+	}
+
+	if len(e.certificate.Leaf.DNSNames) > 0 {
+		return e.certificate.Leaf.DNSNames
+	}
+
+	if e.certificate.Leaf.Subject.CommonName != "" {
+		return []string{e.certificate.Leaf.Subject.CommonName}
+	}
+
+	return nil
+}
+
+func (e *certificateEntry) hasFiles() bool {
+	return e.config.CertFile != "" && e.config.KeyFile != ""
+}
+
+func statCertificateFiles(config types.CertificateConfig) fileStamp {
+	stamp := fileStamp{}
+	if info, err := os.Stat(config.CertFile); err == nil {
+		stamp.certModTime = info.ModTime()
+		stamp.certSize = info.Size()
+	}
+	if info, err := os.Stat(config.KeyFile); err == nil {
+		stamp.keyModTime = info.ModTime()
+		stamp.keySize = info.Size()
+	}
+	return stamp
+}
+
+// Reload re-reads the certificate and key files of every certificate of the store, the revocation lists of its
+// client verifier and the session ticket key files of its listeners, see ReloadIfChanged.
+func (s *CertificateStore) Reload() (bool, error) {
+	return s.reload(true)
+}
+
+// ReloadIfChanged re-reads the certificate and key files that changed since they were last loaded. Each new key
+// pair is validated before it replaces the live certificate, so new handshakes use it while established
+// connections are left untouched. When a certificate cannot be loaded, the certificate currently in use is kept.
+//
+// Returns:
+//   - bool: True if at least one certificate was replaced.
+//   - error: The errors of the certificates that could not be loaded, or nil.
+func (s *CertificateStore) ReloadIfChanged() (bool, error) {
+	return s.reload(false)
+}
+
+func (s *CertificateStore) reload(force bool) (bool, error) {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	s.mutex.RLock()
+	entries := make([]*certificateEntry, len(s.entries))
+	copy(entries, s.entries)
+	clientVerifier := s.clientVerifier
+	s.mutex.RUnlock()
+
+	replaced := make(map[*tls.Certificate]*tls.Certificate)
+	loadErrors := make([]error, 0)
+
+	for i, entry := range entries {
+		if !entry.hasFiles() {
+			continue
+		}
+
+		stamp := statCertificateFiles(entry.config)
+		if !force && (stamp == entry.stamp || stamp == entry.failedStamp) {
+			continue
+		}
+
+		certificate, err := LoadCertificate(entry.config.CertFile, entry.config.KeyFile)
+		if err == nil && len((&certificateEntry{config: entry.config, certificate: certificate}).serverNames()) == 0 {
+			err = fmt.Errorf("%s: certificate has no server name", entry.config.CertFile)
+		}
+		if err != nil {
+			loadErrors = append(loadErrors, err)
+			entries[i] = &certificateEntry{config: entry.config, certificate: entry.certificate, stamp: entry.stamp, failedStamp: stamp}
+			continue
+		}
+
+		entries[i] = &certificateEntry{config: entry.config, certificate: certificate, stamp: stamp}
+		replaced[entry.certificate] = certificate
+	}
+
+	s.mutex.Lock()
+	s.entries = entries
+	if len(replaced) > 0 {
+		s.rebuildIndex()
+		if certificate, ok := replaced[s.defaultCert]; ok {
+			s.defaultCert = certificate
+		}
+	}
+	s.mutex.Unlock()
+
+	crlsReloaded := false
+	if clientVerifier != nil {
+		var crlErr error
+		crlsReloaded, crlErr = clientVerifier.reloadCRLs(force)
+		loadErrors = append(loadErrors, crlErr)
+	}
+
+	ticketKeysRotated, ticketKeyErr := s.reloadTicketKeys(force)
+	loadErrors = append(loadErrors, ticketKeyErr)
+
+	return len(replaced) > 0 || crlsReloaded || ticketKeysRotated, errors.Join(loadErrors...)
+}
+
+// ReloadAndReport reloads every certificate of the store and logs the outcome. Certificates that fail to load
+// are reported as a JinxError through errorLogger and the certificate in use is kept.
+func (s *CertificateStore) ReloadAndReport(serverLogger *slog.Logger, errorLogger *slog.Logger) {
+	reloaded, err := s.Reload()
+	reportReload(reloaded, err, serverLogger, errorLogger)
+}
+
+// StartWatching reloads the certificates of the store whenever their files change. Changes are detected by
+// checking the files every Interval seconds and, when Watch is set, through file system notifications.
+//
+// Parameters:
+//   - config: The types.CertificateReloadConfig of the server.
+//   - serverLogger, errorLogger: The loggers reloads and failures are reported to.
+//
+// Returns:
+//   - A function stopping the watch.
+func (s *CertificateStore) StartWatching(config types.CertificateReloadConfig, serverLogger *slog.Logger, errorLogger *slog.Logger) func() {
+	if config.Interval <= 0 && !config.Watch {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	closeWatch := func() {}
+
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	if config.Interval > 0 {
+		ticker = time.NewTicker(time.Duration(config.Interval) * time.Second)
+		tick = ticker.C
+	}
+
+	var events <-chan struct{}
+	if config.Watch {
+		watchEvents, closeFunc, err := watchFiles(s.files())
+		if err != nil {
+			errorLogger.Error(error_handler.NewJinxError(constant.ERR_RELOAD_CERTIFICATE, fmt.Errorf("unable to watch certificate files: %v", err)).Error())
+		} else {
+			events = watchEvents
+			closeWatch = closeFunc
+		}
+	}
+
+	go func() {
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-tick:
+			case _, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				// Renewal jobs usually write the certificate and the key one after the other, wait for both
+				drainEvents(events, 250*time.Millisecond)
+			}
+
+			reloaded, err := s.ReloadIfChanged()
+			reportReload(reloaded, err, serverLogger, errorLogger)
+		}
+	}()
+
+	return func() {
+		close(stop)
+		closeWatch()
+	}
+}
+
+// files returns the certificate and key files of the store together with the revocation lists of its client
+// verifier and the session ticket key files of its listeners.
+func (s *CertificateStore) files() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	files := make([]string, 0, 2*len(s.entries))
+	for _, entry := range s.entries {
+		if entry.hasFiles() {
+			files = append(files, entry.config.CertFile, entry.config.KeyFile)
+		}
+	}
+	if s.clientVerifier != nil {
+		files = append(files, s.clientVerifier.crlFiles()...)
+	}
+	for _, entry := range s.ticketKeys {
+		files = append(files, entry.file)
+	}
+	return files
+}
+
+func drainEvents(events <-chan struct{}, quietPeriod time.Duration) {
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-time.After(quietPeriod):
+			return
+		}
+	}
+}
+
+func reportReload(reloaded bool, err error, serverLogger *slog.Logger, errorLogger *slog.Logger) {
+	if err != nil {
+		errorLogger.Error(error_handler.NewJinxError(constant.ERR_RELOAD_CERTIFICATE, err).Error())
+	}
+	if reloaded {
+		serverLogger.Info("Reloaded TLS certificates")
+	}
+}
@@ -0,0 +1,235 @@
+// File: certificate_store.go
+// Package: jinx_tls
+
+// Program Description:
+// This file implements a certificate store that selects the certificate
+// presented during the TLS handshake from the server name (SNI) sent by
+// the client, so that one listener can serve many domains
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package jinx_tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"jinx/pkg/util/types"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// CertificateStore holds the certificates of a TLS listener keyed by server name. Names may be exact
+// (www.example.com) or wildcards (*.example.com). A default certificate is presented to clients that
+// send no server name or a name no certificate was configured for.
+type CertificateStore struct {
+	mutex          sync.RWMutex
+	reloadMutex    sync.Mutex
+	entries        []*certificateEntry
+	exact          map[string]*tls.Certificate
+	wildcard       map[string]*tls.Certificate
+	defaultCert    *tls.Certificate
+	acmeManager    *autocert.Manager
+	acmeHosts      map[string]bool
+	clientVerifier *ClientVerifier
+	ticketKeys     []*ticketKeyEntry
+}
+
+// NewCertificateStore returns an empty CertificateStore.
+func NewCertificateStore() *CertificateStore {
+	return &CertificateStore{
+		exact:    make(map[string]*tls.Certificate),
+		wildcard: make(map[string]*tls.Certificate),
+	}
+}
+
+// LoadCertificateStore builds a CertificateStore from the certificate and key files of a server configuration.
+//
+// Parameters:
+//   - certFile, keyFile: The main certificate of the server. When set it becomes the default certificate.
+//   - certificates: Additional certificates. When the main certificate is not set, the first of these
+//     becomes the default certificate.
+//
+// Returns:
+//   - The populated *CertificateStore, or an error if a certificate could not be loaded.
+func LoadCertificateStore(certFile string, keyFile string, certificates []types.CertificateConfig) (*CertificateStore, error) {
+	store := NewCertificateStore()
+
+	all := make([]types.CertificateConfig, 0, len(certificates)+1)
+	if certFile != "" && keyFile != "" {
+		all = append(all, types.CertificateConfig{CertFile: certFile, KeyFile: keyFile})
+	}
+	all = append(all, certificates...)
+
+	if len(all) == 0 {
+		return nil, errors.New("no certificate configured")
+	}
+
+	for i, certificateConfig := range all {
+		certificate, err := LoadCertificate(certificateConfig.CertFile, certificateConfig.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &certificateEntry{
+			config:      certificateConfig,
+			certificate: certificate,
+			stamp:       statCertificateFiles(certificateConfig),
+		}
+		if err := store.addEntry(entry); err != nil {
+			return nil, fmt.Errorf("%s: %v", certificateConfig.CertFile, err)
+		}
+
+		if i == 0 {
+			store.SetDefault(certificate)
+		}
+	}
+
+	return store, nil
+}
+
+// LoadCertificate reads a PEM encoded certificate chain and private key and checks that they belong together.
+// The parsed leaf certificate is kept on the returned certificate so its names and validity can be inspected.
+func LoadCertificate(certFile string, keyFile string) (*tls.Certificate, error) {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading certificate %s: %v", certFile, err)
+	}
+
+	if certificate.Leaf == nil {
+		leaf, parseErr := x509.ParseCertificate(certificate.Certificate[0])
+		if parseErr != nil {
+			return nil, fmt.Errorf("error parsing certificate %s: %v", certFile, parseErr)
+		}
+		certificate.Leaf = leaf
+	}
+
+	return &certificate, nil
+}
+
+// Add registers certificate for serverNames. When serverNames is empty, the DNS names the certificate was
+// issued for are used, falling back to its common name.
+func (s *CertificateStore) Add(certificate *tls.Certificate, serverNames []string) error {
+	return s.addEntry(&certificateEntry{
+		config:      types.CertificateConfig{ServerNames: serverNames},
+		certificate: certificate,
+	})
+}
+
+func (s *CertificateStore) addEntry(entry *certificateEntry) error {
+	if len(entry.serverNames()) == 0 {
+		return errors.New("certificate has no server name")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries = append(s.entries, entry)
+	s.rebuildIndex()
+	return nil
+}
+
+// rebuildIndex recomputes the server name lookup tables from the entries of the store. It must be called with
+// the write lock held.
+func (s *CertificateStore) rebuildIndex() {
+	s.exact = make(map[string]*tls.Certificate)
+	s.wildcard = make(map[string]*tls.Certificate)
+
+	for _, entry := range s.entries {
+		for _, name := range entry.serverNames() {
+			name = normalizeServerName(name)
+			if suffix, isWildcard := strings.CutPrefix(name, "*."); isWildcard {
+				s.wildcard[suffix] = entry.certificate
+				continue
+			}
+			s.exact[name] = entry.certificate
+		}
+	}
+}
+
+// SetDefault sets the certificate presented to clients whose server name matches no certificate.
+func (s *CertificateStore) SetDefault(certificate *tls.Certificate) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.defaultCert = certificate
+}
+
+// SetClientVerifier makes the listeners of the store verify client certificates through verifier. A nil
+// verifier disables client certificate verification.
+func (s *CertificateStore) SetClientVerifier(verifier *ClientVerifier) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clientVerifier = verifier
+}
+
+// Lookup returns the certificate for serverName. An exact match wins over a wildcard match and the
+// default certificate is returned when neither matches.
+func (s *CertificateStore) Lookup(serverName string) *tls.Certificate {
+	name := normalizeServerName(serverName)
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if name != "" {
+		if certificate, ok := s.exact[name]; ok {
+			return certificate
+		}
+
+		// A wildcard only covers a single label, so a.example.com matches *.example.com but a.b.example.com does not
+		if _, suffix, found := strings.Cut(name, "."); found {
+			if certificate, ok := s.wildcard[suffix]; ok {
+				return certificate
+			}
+		}
+	}
+
+	return s.defaultCert
+}
+
+// GetCertificate implements tls.Config.GetCertificate by selecting the certificate from the server name
+// sent by the client in the handshake.
+func (s *CertificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if certificate, managed, err := s.acmeCertificate(hello); managed {
+		return certificate, err
+	}
+
+	certificate := s.Lookup(hello.ServerName)
+	if certificate == nil {
+		return nil, fmt.Errorf("no certificate available for %q", hello.ServerName)
+	}
+	return certificate, nil
+}
+
+// TLSConfig returns a tls.Config presenting the certificates of the store. When certificates are managed
+// through ACME, the acme-tls/1 protocol is advertised so that TLS-ALPN-01 challenges can be answered. When a
+// client verifier is set, clients are asked for a certificate as configured by its verify mode.
+func (s *CertificateStore) TLSConfig() *tls.Config {
+	config := &tls.Config{
+		GetCertificate: s.GetCertificate,
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.acmeManager != nil {
+		config.NextProtos = []string{acme.ALPNProto}
+	}
+	s.clientVerifier.Apply(config)
+
+	return config
+}
+
+func normalizeServerName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// IsEnabled reports whether a server configuration provides any certificate, either from files or through
+// ACME, meaning its listener should speak TLS.
+func IsEnabled(certFile string, keyFile string, certificates []types.CertificateConfig, acmeConfig types.ACMEConfig) bool {
+	return (certFile != "" && keyFile != "") || len(certificates) > 0 || len(acmeConfig.Hosts) > 0
+}
@@ -0,0 +1,315 @@
+// File: client_auth.go
+// Package: jinx_tls
+
+// Program Description:
+// This file implements mutual TLS: client certificates are verified against
+// a CA bundle and checked against certificate revocation lists read from
+// local files, and the details of verified certificates are exposed so that
+// they can be forwarded to upstream servers
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package jinx_tls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientVerifier verifies the certificates presented by clients during the TLS handshake. The CA bundle is
+// loaded once while the revocation lists are reloaded together with the server certificates, since CRLs are
+// typically republished far more often than CAs change.
+type ClientVerifier struct {
+	mode      tls.ClientAuthType
+	clientCAs *x509.CertPool
+	mutex     sync.RWMutex
+	crls      []*crlEntry
+}
+
+// crlEntry is a certificate revocation list together with the file it was loaded from.
+type crlEntry struct {
+	file    string
+	list    *x509.RevocationList
+	modTime time.Time
+	size    int64
+}
+
+// NewClientVerifier creates the ClientVerifier described by config.
+//
+// Parameters:
+//   - config: The types.ClientAuthConfig of the listener.
+//
+// Returns:
+//   - The *ClientVerifier, or nil when client authentication is disabled.
+//   - An error if the verify mode is unknown or the CA bundle or a revocation list could not be loaded.
+func NewClientVerifier(config types.ClientAuthConfig) (*ClientVerifier, error) {
+	mode, err := ClientAuthType(config.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if mode == tls.NoClientCert {
+		return nil, nil
+	}
+
+	caBundle, err := os.ReadFile(config.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading client CA bundle: %v", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("%s contains no PEM encoded certificate", config.CAFile)
+	}
+
+	verifier := &ClientVerifier{mode: mode, clientCAs: clientCAs}
+	for _, file := range config.CRLFiles {
+		entry, crlErr := loadCRL(file)
+		if crlErr != nil {
+			return nil, crlErr
+		}
+		verifier.crls = append(verifier.crls, entry)
+	}
+
+	return verifier, nil
+}
+
+// ClientAuthType converts a verify mode of the configuration into the corresponding tls.ClientAuthType.
+// An empty mode is treated as none.
+func ClientAuthType(mode string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(mode) {
+	case "", constant.CLIENT_AUTH_NONE:
+		return tls.NoClientCert, nil
+	case constant.CLIENT_AUTH_OPTIONAL:
+		return tls.VerifyClientCertIfGiven, nil
+	case constant.CLIENT_AUTH_REQUIRE:
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("%q is not a valid client verify mode, valid modes are: none, optional and require", mode)
+	}
+}
+
+// Apply enables client certificate verification on config. Calling Apply on a nil ClientVerifier leaves
+// config unchanged.
+func (v *ClientVerifier) Apply(config *tls.Config) {
+	if v == nil {
+		return
+	}
+
+	config.ClientAuth = v.mode
+	config.ClientCAs = v.clientCAs
+	config.VerifyConnection = v.verifyConnection
+}
+
+// verifyConnection rejects the handshake when the client certificate has been revoked. The chains it receives
+// have already been verified against the CA bundle, a certificate is accepted when at least one of its chains
+// contains no revoked certificate.
+func (v *ClientVerifier) verifyConnection(state tls.ConnectionState) error {
+	if len(state.VerifiedChains) == 0 {
+		return nil
+	}
+
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	for _, chain := range state.VerifiedChains {
+		if !v.isRevoked(chain) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("client certificate %s has been revoked", state.VerifiedChains[0][0].Subject)
+}
+
+func (v *ClientVerifier) isRevoked(chain []*x509.Certificate) bool {
+	// The last certificate of a chain is the trusted root, it is never checked against a revocation list
+	for i := 0; i < len(chain)-1; i++ {
+		certificate, issuer := chain[i], chain[i+1]
+		for _, entry := range v.crls {
+			if !bytes.Equal(entry.list.RawIssuer, certificate.RawIssuer) {
+				continue
+			}
+			if entry.list.CheckSignatureFrom(issuer) != nil {
+				continue
+			}
+			for _, revoked := range entry.list.RevokedCertificateEntries {
+				if revoked.SerialNumber.Cmp(certificate.SerialNumber) == 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// reloadCRLs re-reads the revocation lists whose files changed, or all of them when force is set. A list that
+// cannot be loaded keeps its previous version in use.
+//
+// Returns:
+//   - bool: True if at least one list was replaced.
+//   - error: The errors of the lists that could not be loaded, or nil.
+func (v *ClientVerifier) reloadCRLs(force bool) (bool, error) {
+	v.mutex.RLock()
+	entries := slices.Clone(v.crls)
+	v.mutex.RUnlock()
+
+	replaced := false
+	loadErrors := make([]error, 0)
+
+	for i, entry := range entries {
+		if !force {
+			info, err := os.Stat(entry.file)
+			if err == nil && info.ModTime().Equal(entry.modTime) && info.Size() == entry.size {
+				continue
+			}
+		}
+
+		reloaded, err := loadCRL(entry.file)
+		if err != nil {
+			loadErrors = append(loadErrors, err)
+			continue
+		}
+		if !force || !bytes.Equal(reloaded.list.Raw, entry.list.Raw) {
+			replaced = true
+		}
+		entries[i] = reloaded
+	}
+
+	v.mutex.Lock()
+	v.crls = entries
+	v.mutex.Unlock()
+
+	return replaced, errors.Join(loadErrors...)
+}
+
+// crlFiles returns the files the revocation lists were loaded from.
+func (v *ClientVerifier) crlFiles() []string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	files := make([]string, 0, len(v.crls))
+	for _, entry := range v.crls {
+		files = append(files, entry.file)
+	}
+	return files
+}
+
+// loadCRL reads a PEM or DER encoded certificate revocation list.
+func loadCRL(file string) (*crlEntry, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CRL: %v", err)
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CRL: %v", err)
+	}
+
+	if block, _ := pem.Decode(content); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("%s: unexpected PEM block %q, expected X509 CRL", file, block.Type)
+		}
+		content = block.Bytes
+	}
+
+	list, err := x509.ParseRevocationList(content)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CRL %s: %v", file, err)
+	}
+
+	return &crlEntry{file: file, list: list, modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// SetClientCertificateHeaders replaces the client certificate headers of header with the details of the client
+// certificate verified during the handshake of state. Values sent by the client itself are always removed so
+// that upstream servers can trust the headers. X-Client-Verify is set to SUCCESS for a verified certificate and
+// NONE otherwise.
+func SetClientCertificateHeaders(header http.Header, state *tls.ConnectionState) {
+	header.Del(constant.HEADER_CLIENT_SUBJECT)
+	header.Del(constant.HEADER_CLIENT_SAN)
+	header.Del(constant.HEADER_CLIENT_ISSUER)
+	header.Del(constant.HEADER_CLIENT_SERIAL)
+
+	certificate := VerifiedClientCertificate(state)
+	if certificate == nil {
+		header.Set(constant.HEADER_CLIENT_VERIFY, "NONE")
+		return
+	}
+
+	header.Set(constant.HEADER_CLIENT_VERIFY, "SUCCESS")
+	header.Set(constant.HEADER_CLIENT_SUBJECT, certificate.Subject.String())
+	header.Set(constant.HEADER_CLIENT_ISSUER, certificate.Issuer.String())
+	header.Set(constant.HEADER_CLIENT_SERIAL, certificate.SerialNumber.Text(16))
+	if names := SubjectAlternativeNames(certificate); len(names) > 0 {
+		header.Set(constant.HEADER_CLIENT_SAN, strings.Join(names, ", "))
+	}
+}
+
+// VerifiedClientCertificate returns the client certificate verified during the handshake of state, or nil when
+// the client presented none or the connection is not encrypted.
+func VerifiedClientCertificate(state *tls.ConnectionState) *x509.Certificate {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
+
+// SubjectAlternativeNames lists the subject alternative names of certificate prefixed with their type, in the
+// form used by OpenSSL: DNS:, email:, URI: and IP Address:.
+func SubjectAlternativeNames(certificate *x509.Certificate) []string {
+	names := make([]string, 0)
+	for _, name := range certificate.DNSNames {
+		names = append(names, "DNS:"+name)
+	}
+	for _, name := range certificate.EmailAddresses {
+		names = append(names, "email:"+name)
+	}
+	for _, uri := range certificate.URIs {
+		names = append(names, "URI:"+uri.String())
+	}
+	for _, ip := range certificate.IPAddresses {
+		names = append(names, "IP Address:"+ip.String())
+	}
+	return names
+}
+
+// CertificateHasName reports whether certificate carries name as its subject common name or as one of its
+// subject alternative names. Names are compared case-insensitively.
+func CertificateHasName(certificate *x509.Certificate, name string) bool {
+	if strings.EqualFold(certificate.Subject.CommonName, name) {
+		return true
+	}
+
+	for _, candidates := range [][]string{certificate.DNSNames, certificate.EmailAddresses} {
+		for _, candidate := range candidates {
+			if strings.EqualFold(candidate, name) {
+				return true
+			}
+		}
+	}
+	for _, uri := range certificate.URIs {
+		if uri.String() == name {
+			return true
+		}
+	}
+	for _, ip := range certificate.IPAddresses {
+		if ip.String() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,297 @@
+// File: policy.go
+// Package: jinx_tls
+
+// Program Description:
+// This file turns the TLS policy of a listener (protocol versions, cipher
+// suites, curves, ALPN protocols and session resumption) into the
+// tls.Config of the listener, and rotates its session ticket keys from a
+// key file
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package jinx_tls
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"jinx/pkg/util/types"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var curves = map[string]tls.CurveID{
+	"x25519":     tls.X25519,
+	"p-256":      tls.CurveP256,
+	"secp256r1":  tls.CurveP256,
+	"prime256v1": tls.CurveP256,
+	"p-384":      tls.CurveP384,
+	"secp384r1":  tls.CurveP384,
+	"p-521":      tls.CurveP521,
+	"secp521r1":  tls.CurveP521,
+}
+
+// ticketKeyEntry is a session ticket key file together with the listener configurations using its keys.
+type ticketKeyEntry struct {
+	file    string
+	configs []*tls.Config
+	modTime time.Time
+	size    int64
+}
+
+// NewTLSConfig returns the tls.Config of a listener presenting the certificates of the store under policy. When
+// the policy names a session ticket key file, the keys of the returned configuration are rotated whenever the file
+// changes and the store is reloaded.
+//
+// Parameters:
+//   - policy: The types.TLSPolicy of the listener.
+//   - defaultProtocols: The ALPN protocols offered when the policy lists none.
+//
+// Returns:
+//   - The *tls.Config of the listener, or an error if the policy is invalid or the key file could not be read.
+func (s *CertificateStore) NewTLSConfig(policy types.TLSPolicy, defaultProtocols []string) (*tls.Config, error) {
+	config := s.TLSConfig()
+	if err := ApplyTLSPolicy(config, policy, defaultProtocols); err != nil {
+		return nil, err
+	}
+
+	if policy.SessionTicketKeyFile != "" && !policy.DisableSessionResumption {
+		keys, err := LoadSessionTicketKeys(policy.SessionTicketKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.SetSessionTicketKeys(keys)
+		s.registerTicketKeys(policy.SessionTicketKeyFile, config)
+	}
+
+	return config, nil
+}
+
+// ApplyTLSPolicy applies the versions, cipher suites, curves, ALPN protocols and session resumption setting of
+// policy to config. Protocols already offered by config, such as acme-tls/1, are kept after the policy protocols.
+//
+// Parameters:
+//   - config: The tls.Config to update.
+//   - policy: The types.TLSPolicy to apply.
+//   - defaultProtocols: The ALPN protocols offered when the policy lists none.
+//
+// Returns:
+//   - An error describing the first invalid setting of policy, or nil.
+func ApplyTLSPolicy(config *tls.Config, policy types.TLSPolicy, defaultProtocols []string) error {
+	minVersion, err := parseTLSVersion(policy.MinVersion)
+	if err != nil {
+		return err
+	}
+	maxVersion, err := parseTLSVersion(policy.MaxVersion)
+	if err != nil {
+		return err
+	}
+	if minVersion != 0 && maxVersion != 0 && minVersion > maxVersion {
+		return fmt.Errorf("MinVersion %s is higher than MaxVersion %s", policy.MinVersion, policy.MaxVersion)
+	}
+	config.MinVersion = minVersion
+	config.MaxVersion = maxVersion
+
+	if len(policy.CipherSuites) > 0 {
+		if config.CipherSuites, err = parseCipherSuites(policy.CipherSuites); err != nil {
+			return err
+		}
+	}
+
+	if len(policy.Curves) > 0 {
+		if config.CurvePreferences, err = parseCurves(policy.Curves); err != nil {
+			return err
+		}
+	}
+
+	protocols := policy.ALPN
+	if len(protocols) == 0 {
+		protocols = defaultProtocols
+	}
+	for _, protocol := range protocols {
+		if protocol == "" {
+			return errors.New("ALPN protocols must not be empty")
+		}
+	}
+	config.NextProtos = append(slices.Clone(protocols), config.NextProtos...)
+
+	config.SessionTicketsDisabled = policy.DisableSessionResumption
+
+	return nil
+}
+
+// ValidateTLSPolicy checks that every setting of policy is understood and that its session ticket key file holds
+// at least one valid key.
+func ValidateTLSPolicy(policy types.TLSPolicy) error {
+	if err := ApplyTLSPolicy(&tls.Config{}, policy, nil); err != nil {
+		return err
+	}
+
+	if policy.SessionTicketKeyFile != "" {
+		if _, err := LoadSessionTicketKeys(policy.SessionTicketKeyFile); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadSessionTicketKeys reads the session ticket keys of file. The file holds one base64 encoded 32 byte key per
+// line, empty lines and lines starting with # are ignored. The first key encrypts new tickets while the others
+// are only used to decrypt tickets issued before the last rotation.
+func LoadSessionTicketKeys(file string) ([][32]byte, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading session ticket keys: %v", err)
+	}
+
+	keys := make([][32]byte, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		decoded, decodeErr := base64.StdEncoding.DecodeString(text)
+		if decodeErr != nil || len(decoded) != 32 {
+			return nil, fmt.Errorf("%s:%d: session ticket keys must be base64 encoded 32 byte values", file, line)
+		}
+
+		var key [32]byte
+		copy(key[:], decoded)
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s contains no session ticket key", file)
+	}
+
+	return keys, nil
+}
+
+// registerTicketKeys records that config uses the session ticket keys of file, so that they are rotated when the
+// file changes.
+func (s *CertificateStore) registerTicketKeys(file string, config *tls.Config) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, entry := range s.ticketKeys {
+		if entry.file == file {
+			entry.configs = append(entry.configs, config)
+			return
+		}
+	}
+
+	entry := &ticketKeyEntry{file: file, configs: []*tls.Config{config}}
+	if info, err := os.Stat(file); err == nil {
+		entry.modTime, entry.size = info.ModTime(), info.Size()
+	}
+	s.ticketKeys = append(s.ticketKeys, entry)
+}
+
+// reloadTicketKeys re-reads the session ticket key files that changed, or all of them when force is set, and
+// hands the new keys to the listeners using them. A file that cannot be loaded keeps the previous keys in use.
+func (s *CertificateStore) reloadTicketKeys(force bool) (bool, error) {
+	s.mutex.RLock()
+	entries := slices.Clone(s.ticketKeys)
+	s.mutex.RUnlock()
+
+	rotated := false
+	loadErrors := make([]error, 0)
+
+	for _, entry := range entries {
+		info, err := os.Stat(entry.file)
+		if !force && err == nil && info.ModTime().Equal(entry.modTime) && info.Size() == entry.size {
+			continue
+		}
+
+		keys, err := LoadSessionTicketKeys(entry.file)
+		if err != nil {
+			loadErrors = append(loadErrors, err)
+			continue
+		}
+
+		s.mutex.Lock()
+		for _, config := range entry.configs {
+			config.SetSessionTicketKeys(keys)
+		}
+		if info != nil {
+			entry.modTime, entry.size = info.ModTime(), info.Size()
+		}
+		s.mutex.Unlock()
+		rotated = true
+	}
+
+	return rotated, errors.Join(loadErrors...)
+}
+
+func parseTLSVersion(version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+
+	normalized := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(version), "tls"), "v")
+	if value, ok := tlsVersions[strings.TrimSpace(normalized)]; ok {
+		return value, nil
+	}
+	return 0, fmt.Errorf("%q is not a valid TLS version, valid versions are: 1.0, 1.1, 1.2 and 1.3", version)
+}
+
+func parseCipherSuites(names []string) ([]uint16, error) {
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, err := cipherSuiteID(name)
+		if err != nil {
+			return nil, err
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+func cipherSuiteID(name string) (uint16, error) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name != name {
+			continue
+		}
+		if slices.Contains(suite.SupportedVersions, tls.VersionTLS13) && !slices.Contains(suite.SupportedVersions, tls.VersionTLS12) {
+			return 0, fmt.Errorf("%s is a TLS 1.3 cipher suite, TLS 1.3 cipher suites are not configurable", name)
+		}
+		return suite.ID, nil
+	}
+
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.Name == name {
+			return 0, fmt.Errorf("%s is an insecure cipher suite and cannot be enabled", name)
+		}
+	}
+
+	return 0, fmt.Errorf("%q is not a known cipher suite", name)
+}
+
+func parseCurves(names []string) ([]tls.CurveID, error) {
+	preferences := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		curve, ok := curves[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("%q is not a supported curve, supported curves are: X25519, P-256, P-384 and P-521", name)
+		}
+		preferences = append(preferences, curve)
+	}
+	return preferences, nil
+}
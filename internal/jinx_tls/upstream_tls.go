@@ -0,0 +1,128 @@
+// File: upstream_tls.go
+// Package: jinx_tls
+
+// Program Description:
+// This file turns the TLS client settings of upstream servers (CA bundle,
+// client certificate, server name, minimum version, pinned certificates and
+// skipped verification) into the tls.Config the reverse proxy and the load
+// balancer connect to TLS upstreams with
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package jinx_tls
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"jinx/pkg/util/types"
+	"os"
+	"slices"
+	"strings"
+)
+
+// NewUpstreamTLSConfig returns the tls.Config of the connections to the upstream servers described by config.
+//
+// Parameters:
+//   - config: The types.UpstreamTLSConfig of a route, an upstream group or the server pool of the load balancer.
+//
+// Returns:
+//   - The *tls.Config, or nil when config is empty and the default TLS client settings apply.
+//   - An error if the CA bundle or the client certificate could not be loaded, the minimum version is unknown or
+//     a pinned certificate is not a SHA-256 fingerprint.
+func NewUpstreamTLSConfig(config types.UpstreamTLSConfig) (*tls.Config, error) {
+	if config.CAFile == "" && config.CertFile == "" && config.KeyFile == "" && config.ServerName == "" &&
+		config.MinVersion == "" && len(config.PinnedCertificates) == 0 && !config.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	minVersion, err := parseTLSVersion(config.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         config.ServerName,
+		MinVersion:         minVersion,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CAFile != "" {
+		caBundle, readErr := os.ReadFile(config.CAFile)
+		if readErr != nil {
+			return nil, fmt.Errorf("error reading upstream CA bundle: %v", readErr)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("%s contains no PEM encoded certificate", config.CAFile)
+		}
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		if config.CertFile == "" || config.KeyFile == "" {
+			return nil, errors.New("the client certificate of the upstream needs both CertFile and KeyFile")
+		}
+		certificate, loadErr := LoadCertificate(config.CertFile, config.KeyFile)
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		tlsConfig.Certificates = []tls.Certificate{*certificate}
+	}
+
+	if len(config.PinnedCertificates) > 0 {
+		pins := make([]string, 0, len(config.PinnedCertificates))
+		for _, pin := range config.PinnedCertificates {
+			fingerprint := strings.ToLower(strings.ReplaceAll(pin, ":", ""))
+			if decoded, decodeErr := hex.DecodeString(fingerprint); decodeErr != nil || len(decoded) != sha256.Size {
+				return nil, fmt.Errorf("%q is not a SHA-256 certificate fingerprint", pin)
+			}
+			pins = append(pins, fingerprint)
+		}
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyPinnedCertificate(state, pins)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// UpstreamTLSConfigFor returns the tls.Config of a connection to the upstream server host. The server name of
+// config, or host when config names none, is sent as SNI and verified against the certificate of the upstream.
+// A nil config stands for the default TLS client settings.
+func UpstreamTLSConfigFor(config *tls.Config, host string, protocols ...string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+
+	tlsConfig := config.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	if len(protocols) > 0 {
+		tlsConfig.NextProtos = protocols
+	}
+	return tlsConfig
+}
+
+// verifyPinnedCertificate rejects the handshake unless the fingerprint of the certificate of the upstream is one of
+// pins. Pins are checked even when the verification of the certificate chain is skipped. Rejections are reported as
+// a *tls.CertificateVerificationError, like the failures of the verification of the chain.
+func verifyPinnedCertificate(state tls.ConnectionState, pins []string) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("the upstream presented no certificate")
+	}
+
+	fingerprint := sha256.Sum256(state.PeerCertificates[0].Raw)
+	if !slices.Contains(pins, hex.EncodeToString(fingerprint[:])) {
+		return &tls.CertificateVerificationError{
+			UnverifiedCertificates: state.PeerCertificates,
+			Err:                    fmt.Errorf("the certificate of the upstream %s is not pinned", state.ServerName),
+		}
+	}
+	return nil
+}
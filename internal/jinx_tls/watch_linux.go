@@ -0,0 +1,83 @@
+//go:build linux
+
+// File: watch_linux.go
+// Package: jinx_tls
+
+// Program Description:
+// This file notifies changes of certificate files through inotify
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package jinx_tls
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// watchFiles reports changes of files on the returned channel. The directories containing the files are watched
+// rather than the files themselves, so that files replaced through a rename, as most renewal tools do, are still
+// noticed. The returned function stops the watch and closes the channel.
+func watchFiles(files []string) (<-chan struct{}, func(), error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The non-blocking descriptor is handed to the runtime poller, so closing the file unblocks pending reads
+	inotifyFile := os.NewFile(uintptr(fd), "inotify")
+
+	watched := make(map[string]map[string]bool)
+	for _, file := range files {
+		dir, name := filepath.Split(filepath.Clean(file))
+		if watched[dir] == nil {
+			watched[dir] = make(map[string]bool)
+		}
+		watched[dir][name] = true
+	}
+
+	directories := make(map[int32]string)
+	for dir := range watched {
+		mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_DELETE)
+		wd, watchErr := syscall.InotifyAddWatch(fd, dir, mask)
+		if watchErr != nil {
+			_ = inotifyFile.Close()
+			return nil, nil, watchErr
+		}
+		directories[int32(wd)] = dir
+	}
+
+	events := make(chan struct{}, 1)
+	go func() {
+		defer close(events)
+
+		buffer := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, readErr := inotifyFile.Read(buffer)
+			if readErr != nil {
+				return
+			}
+
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+				nameStart := offset + syscall.SizeofInotifyEvent
+				name := strings.TrimRight(string(buffer[nameStart:nameStart+int(event.Len)]), "\x00")
+				offset = nameStart + int(event.Len)
+
+				if watched[directories[event.Wd]][name] {
+					select {
+					case events <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}
+	}()
+
+	return events, func() { _ = inotifyFile.Close() }, nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+// File: watch_other.go
+// Package: jinx_tls
+
+// Program Description:
+// This file provides the file watch fallback for platforms without inotify
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package jinx_tls
+
+import "errors"
+
+// watchFiles is not supported on this platform. Certificate changes are still picked up by the reload
+// interval and the reload command.
+func watchFiles(files []string) (<-chan struct{}, func(), error) {
+	return nil, nil, errors.New("file system notifications are not supported on this platform")
+}
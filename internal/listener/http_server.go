@@ -0,0 +1,159 @@
+// File: http_server.go
+// Package: listener
+
+// Program Description:
+// This file builds the http.Server and net.Listener used by the HTTP based
+// modes from the listener limits found in the configuration
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package listener
+
+import (
+	"io"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NewHttpServer creates an http.Server listening on addr with the timeouts and size limits from limits applied.
+// Settings left at zero fall back to the Jinx defaults defined in the constant package.
+//
+// The header read timeout protects against slowloris style clients that trickle their headers in, while the
+// body read timeout is armed per request while its body is read so that it only covers the body.
+// Request bodies larger than MaxBodyBytes are rejected with 413 Request Entity Too Large.
+//
+// Parameters:
+//   - addr: The address the server listens on, in host:port form.
+//   - handler: The handler serving the requests.
+//   - limits: The types.ListenerLimits of the listener.
+//
+// Returns:
+//   - A configured *http.Server. The caller is responsible for serving it on a listener.
+func NewHttpServer(addr string, handler http.Handler, limits types.ListenerLimits) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           LimitRequest(handler, limits),
+		ReadHeaderTimeout: Seconds(limits.HeaderReadTimeout, constant.DEFAULT_HEADER_READ_TIMEOUT),
+		IdleTimeout:       Seconds(limits.IdleTimeout, constant.DEFAULT_IDLE_TIMEOUT),
+		WriteTimeout:      Seconds(limits.WriteTimeout, constant.DEFAULT_WRITE_TIMEOUT),
+		MaxHeaderBytes:    valueOrDefault(limits.MaxHeaderBytes, constant.DEFAULT_MAX_HEADER_BYTES),
+	}
+}
+
+// Listen opens a TCP listener on addr and wraps it in a LimitListener enforcing the connection limits
+// from limits.
+//
+// Parameters:
+//   - addr: The address to listen on, in host:port form.
+//   - limits: The types.ListenerLimits of the listener.
+//   - reject: Optional function called for connections refused by the per IP limit, see NewLimitListener.
+//
+// Returns:
+//   - The wrapped listener, or an error if the address could not be bound.
+func Listen(addr string, limits types.ListenerLimits, reject func(net.Conn)) (*LimitListener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLimitListener(l, limits.MaxConnections, limits.MaxConnectionsPerIP, reject), nil
+}
+
+// RejectWithServiceUnavailable writes a minimal 503 Service Unavailable response to a plain text HTTP
+// connection. It is meant to be used as the reject function of a LimitListener serving HTTP without TLS.
+func RejectWithServiceUnavailable(conn net.Conn) {
+	_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+	_, _ = conn.Write([]byte("HTTP/1.1 503 Service Unavailable\r\nRetry-After: 1\r\nConnection: close\r\nContent-Length: 0\r\n\r\n"))
+}
+
+// LimitRequest wraps handler so that the body read timeout and maximum body size from limits are
+// enforced on every request. The read deadline is only set while the body is read and cleared once it was read
+// completely or closed, so that responses taking longer than the timeout, such as downloads, long polls and gRPC
+// streams, are not cut off. Requests without a body get no deadline at all.
+func LimitRequest(handler http.Handler, limits types.ListenerLimits) http.Handler {
+	bodyReadTimeout := Seconds(limits.BodyReadTimeout, constant.DEFAULT_BODY_READ_TIMEOUT)
+	maxBodyBytes := limits.MaxBodyBytes
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maxBodyBytes > 0 {
+			if r.ContentLength > maxBodyBytes {
+				http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		}
+
+		if bodyReadTimeout > 0 && r.Body != nil && r.Body != http.NoBody {
+			r.Body = &deadlineBody{ReadCloser: r.Body, controller: http.NewResponseController(w), timeout: bodyReadTimeout}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// deadlineBody sets the read deadline of the connection when its first Read starts and clears it once the body
+// was read completely or closed.
+type deadlineBody struct {
+	io.ReadCloser
+	controller *http.ResponseController
+	timeout    time.Duration
+	mutex      sync.Mutex
+	armed      bool
+	done       bool
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	b.mutex.Lock()
+	if !b.armed && !b.done {
+		b.armed = true
+		b.setDeadline(time.Now().Add(b.timeout))
+	}
+	b.mutex.Unlock()
+
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.clear()
+	}
+	return n, err
+}
+
+func (b *deadlineBody) Close() error {
+	b.clear()
+	return b.ReadCloser.Close()
+}
+
+// clear removes the deadline once, when it was set.
+func (b *deadlineBody) clear() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.armed && !b.done {
+		b.setDeadline(time.Time{})
+	}
+	b.done = true
+}
+
+// setDeadline sets the read deadline of the connection. Writers that do not support deadlines, such as
+// httptest.ResponseRecorder, read the body without one.
+func (b *deadlineBody) setDeadline(deadline time.Time) {
+	_ = b.controller.SetReadDeadline(deadline)
+}
+
+// Seconds converts a timeout in seconds taken from the configuration into a time.Duration,
+// using fallback when the configured value is zero.
+func Seconds(value int, fallback int) time.Duration {
+	return time.Duration(valueOrDefault(value, fallback)) * time.Second
+}
+
+func valueOrDefault(value int, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,203 @@
+// File: limit_listener.go
+// Package: listener
+
+// Program Description:
+// This file implements a net.Listener that caps the number of concurrent
+// connections, both in total and per client IP address
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package listener
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// LimitListener wraps a net.Listener and enforces a maximum number of concurrent connections
+// as well as a maximum number of concurrent connections from a single client IP address.
+//
+// When the total limit is reached, Accept stops accepting new connections until an existing one
+// is closed. Pending clients therefore wait in the kernel backlog instead of being dropped, which
+// lets the server ride out short bursts gracefully. When a single client exceeds its own limit the
+// connection is handed to the reject function (if any) and closed immediately, so one misbehaving
+// client cannot hold every slot of the server. Closing the listener ends a pending Accept even while
+// every slot is held, for example by hijacked WebSocket or CONNECT tunnels.
+type LimitListener struct {
+	net.Listener
+	maxPerIP  int
+	slots     chan struct{}
+	reject    func(net.Conn)
+	mutex     sync.Mutex
+	perIP     map[string]int
+	done      chan struct{} // closed by Close
+	closeOnce sync.Once
+}
+
+// NewLimitListener returns a LimitListener wrapping inner.
+//
+// Parameters:
+//   - inner: The listener accepting the raw connections.
+//   - maxConnections: Maximum number of concurrent connections. Zero means no limit.
+//   - maxPerIP: Maximum number of concurrent connections from one client IP. Zero means no limit.
+//   - reject: Optional function called with a connection refused because of the per IP limit before
+//     it is closed. It can be used to write a protocol specific error response.
+//
+// Returns:
+//   - A *LimitListener ready to be passed to http.Server.Serve or used directly.
+func NewLimitListener(inner net.Listener, maxConnections int, maxPerIP int, reject func(net.Conn)) *LimitListener {
+	var slots chan struct{}
+	if maxConnections > 0 {
+		slots = make(chan struct{}, maxConnections)
+	}
+
+	return &LimitListener{
+		Listener: inner,
+		maxPerIP: maxPerIP,
+		slots:    slots,
+		reject:   reject,
+		perIP:    make(map[string]int),
+		done:     make(chan struct{}),
+	}
+}
+
+// Accept waits for a free connection slot and returns the next connection that is within
+// the per IP limit. It returns net.ErrClosed once the listener was closed.
+func (l *LimitListener) Accept() (net.Conn, error) {
+	for {
+		if l.slots != nil {
+			select {
+			case l.slots <- struct{}{}:
+			case <-l.done:
+				return nil, net.ErrClosed
+			}
+		}
+
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.releaseSlot()
+			return nil, err
+		}
+
+		ip := remoteIP(conn)
+		if !l.acquireIP(ip) {
+			if l.reject != nil {
+				l.reject(conn)
+			}
+			_ = conn.Close()
+			l.releaseSlot()
+			continue
+		}
+
+		return &limitedConn{Conn: conn, release: func() {
+			l.releaseIP(ip)
+			l.releaseSlot()
+		}}, nil
+	}
+}
+
+// Close closes the listener and ends a pending Accept waiting for a free connection slot.
+func (l *LimitListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	return err
+}
+
+// ActiveConnections returns the number of connections currently held open through the listener.
+func (l *LimitListener) ActiveConnections() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	total := 0
+	for _, count := range l.perIP {
+		total += count
+	}
+	return total
+}
+
+func (l *LimitListener) releaseSlot() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+func (l *LimitListener) acquireIP(ip string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.maxPerIP > 0 && l.perIP[ip] >= l.maxPerIP {
+		return false
+	}
+	l.perIP[ip]++
+	return true
+}
+
+func (l *LimitListener) releaseIP(ip string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.perIP[ip]--
+	if l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+// limitedConn gives its slot back to the LimitListener exactly once when closed.
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
+
+// IdleTimeoutConn wraps a net.Conn and pushes its deadline forward on every successful read or write,
+// so that the connection is only closed when no data has flowed for the idle timeout. It is used by the
+// load balancer which relays raw TCP streams and has no request boundaries to hang timeouts on.
+type IdleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+// NewIdleTimeoutConn returns conn wrapped in an IdleTimeoutConn. A zero timeout disables the idle timeout
+// and conn is returned unchanged.
+func NewIdleTimeoutConn(conn net.Conn, timeout time.Duration) net.Conn {
+	if timeout <= 0 {
+		return conn
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	return &IdleTimeoutConn{Conn: conn, timeout: timeout}
+}
+
+func (c *IdleTimeoutConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		_ = c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	return n, err
+}
+
+func (c *IdleTimeoutConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		_ = c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	return n, err
+}
@@ -0,0 +1,198 @@
+// File: listeners.go
+// Package: listener
+
+// Program Description:
+// This file serves a server on the additional listeners found in its
+// configuration, each with or without TLS, and redirects plain HTTP
+// listeners to HTTPS when asked to
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package listener
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"jinx/pkg/util/types"
+	"log/slog"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// HTTPProtocols are the ALPN protocols offered by the HTTP based modes when the TLS policy of a listener lists none.
+var HTTPProtocols = []string{"h2", "http/1.1"}
+
+// ServeListeners binds every listener of configs and serves it in the background. Listeners with RedirectToHTTPS
+// are served by redirectServer and every other listener by s, TLS listeners with the tls.Config built for them
+// from their TLS policy. All addresses are bound before any of them is served, so nothing is left running when
+// one of them cannot be bound.
+//
+// Parameters:
+//   - s: The main server of the mode.
+//   - redirectServer: The server redirecting to HTTPS, only used when a listener has RedirectToHTTPS set.
+//   - configs: The additional listeners of the server.
+//   - defaultIP: The IP used for listeners that do not set one.
+//   - limits: The types.ListenerLimits applied to every listener.
+//   - tlsConfig: Returns the tls.Config of a TLS listener, see TLSPolicy.
+//   - errorLogger: The logger errors while serving are reported to.
+//
+// Returns:
+//   - An error if one of the addresses could not be bound or the TLS configuration of a listener is invalid.
+func ServeListeners(s *http.Server, redirectServer *http.Server, configs []types.ListenerConfig, defaultIP string, limits types.ListenerLimits, tlsConfig func(types.ListenerConfig) (*tls.Config, error), errorLogger *slog.Logger) error {
+	tlsConfigs := make([]*tls.Config, len(configs))
+	for i, config := range configs {
+		if !config.TLS {
+			continue
+		}
+
+		listenerTLSConfig, err := tlsConfig(config)
+		if err != nil {
+			return fmt.Errorf("listener %s: %v", Address(config, defaultIP), err)
+		}
+		tlsConfigs[i] = listenerTLSConfig
+	}
+
+	listeners := make([]net.Listener, 0, len(configs))
+	for i, config := range configs {
+		reject := RejectWithServiceUnavailable
+		if config.TLS {
+			reject = nil
+		}
+
+		l, err := Listen(Address(config, defaultIP), limits, reject)
+		if err != nil {
+			for _, bound := range listeners {
+				_ = bound.Close()
+			}
+			return err
+		}
+
+		if tlsConfigs[i] != nil {
+			listeners = append(listeners, tls.NewListener(l, tlsConfigs[i]))
+			continue
+		}
+		listeners = append(listeners, l)
+	}
+
+	for i, config := range configs {
+		go func(config types.ListenerConfig, l net.Listener) {
+			var err error
+			if config.RedirectToHTTPS {
+				err = redirectServer.Serve(l)
+			} else {
+				err = s.Serve(l)
+			}
+
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errorLogger.Error(fmt.Sprintf("Listener %s stopped: %s", l.Addr(), err.Error()))
+			}
+		}(config, listeners[i])
+	}
+
+	return nil
+}
+
+// Address returns the address of the listener described by config in host:port form.
+func Address(config types.ListenerConfig, defaultIP string) string {
+	ip := config.IP
+	if ip == "" {
+		ip = defaultIP
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(config.Port))
+}
+
+// TLSPolicy returns the TLS policy of the listener described by config, which is its own policy when it has one
+// and serverPolicy otherwise.
+func TLSPolicy(config types.ListenerConfig, serverPolicy types.TLSPolicy) types.TLSPolicy {
+	if config.TLSPolicy != nil {
+		return *config.TLSPolicy
+	}
+	return serverPolicy
+}
+
+// AnyTLS reports whether one of the listeners of configs serves TLS.
+func AnyTLS(configs []types.ListenerConfig) bool {
+	for _, config := range configs {
+		if config.TLS {
+			return true
+		}
+	}
+	return false
+}
+
+// AnyRedirect reports whether one of the listeners of configs redirects to HTTPS.
+func AnyRedirect(configs []types.ListenerConfig) bool {
+	for _, config := range configs {
+		if config.RedirectToHTTPS {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPSPort returns the port plain HTTP requests are redirected to. The port of redirect is used when set,
+// otherwise the main port when it serves TLS and finally the port of the first additional TLS listener.
+// Zero is returned when the server has no HTTPS listener.
+func HTTPSPort(redirect types.HTTPSRedirectConfig, port int, tlsEnabled bool, configs []types.ListenerConfig) int {
+	if redirect.Port != 0 {
+		return redirect.Port
+	}
+	if tlsEnabled {
+		return port
+	}
+	for _, config := range configs {
+		if config.TLS {
+			return config.Port
+		}
+	}
+	return 0
+}
+
+// RedirectToHTTPS returns a handler redirecting every request to the same URL over HTTPS on httpsPort. The port
+// is left out of the redirect when it is the default HTTPS port. GET and HEAD requests are answered with
+// 301 Moved Permanently and other methods with 308 Permanent Redirect, so that clients repeat them unchanged.
+//
+// Parameters:
+//   - httpsPort: The port of the HTTPS listener.
+//   - exemptPaths: Path prefixes that are passed to next instead of being redirected.
+//   - next: The handler serving exempt requests.
+//
+// Returns:
+//   - The redirecting http.Handler.
+func RedirectToHTTPS(httpsPort int, exemptPaths []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestPath := path.Clean("/" + r.URL.Path)
+		for _, exemptPath := range exemptPaths {
+			prefix := strings.TrimSuffix(exemptPath, "/")
+			if requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if httpsPort != 0 && httpsPort != 443 {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(httpsPort))
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, target, status)
+	})
+}
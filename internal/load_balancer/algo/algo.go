@@ -0,0 +1,86 @@
+package algo
+
+import (
+	"fmt"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+)
+
+// ByName returns the balancing algorithm called name, see the algorithm constants. ok is false for an unknown or
+// unsupported name, see Validate.
+func ByName(name types.LoadBalancerAlgo) (algorithm types.LoadBalancingAlgorithm, ok bool) {
+	switch name {
+	case constant.ROUND_ROBIN:
+		return RoundRobin, true
+	case constant.LEAST_CONNECTIONS:
+		return LeastConnection, true
+	case constant.LEAST_RESPONSE_TIME:
+		return LeastResponse, true
+	case constant.HASHING:
+		return Hash, true
+	case constant.WEIGHTED_ROUND_ROBIN:
+		return WeightedRoundRobin, true
+	case constant.WEIGHTED_LEAST_CONNECTIONS:
+		return WeightedLeastConnection, true
+	case constant.WEIGHTED_LEAST_RESPONSE_TIME:
+		return WeightedLeastResponse, true
+	case constant.RANDOM:
+		return Random, true
+	default:
+		return RoundRobin, false
+	}
+}
+
+// Validate checks that the balancing algorithm called name can run. The resource based and geographical algorithms
+// are rejected rather than replaced by another one, Jinx collects no resource metrics of the upstream servers and
+// has no geolocation data to place clients.
+func Validate(name types.LoadBalancerAlgo) error {
+	switch name {
+	case constant.RESOURCE_BASED, constant.GEOGRAPHICAL:
+		return fmt.Errorf("the %s balancing algorithm is not supported", name)
+	}
+	if _, ok := ByName(name); !ok {
+		return fmt.Errorf("%q is not a known balancing algorithm", name)
+	}
+	return nil
+}
+
+// available reports whether member may be picked. Members marked unhealthy by the health checks and members
+// ejected by their circuit breaker are skipped.
+func available(member *types.UpstreamMember) bool {
+	return !member.Unhealthy.Load() && !member.Ejected.Load()
+}
+
+// weight returns the weight of member, members without a weight count as 1.
+func weight(member *types.UpstreamMember) int64 {
+	if member.Weight <= 0 {
+		return 1
+	}
+	return int64(member.Weight)
+}
+
+// leastScore returns the available member with the lowest score. Ties are broken in round robin order, so that idle members
+// share the traffic instead of the first one receiving everything.
+func leastScore(pool *types.UpstreamPool, less func(a, b *types.UpstreamMember) bool) *types.UpstreamMember {
+	pool.Mutex.Lock()
+	defer pool.Mutex.Unlock()
+
+	if len(pool.Members) == 0 {
+		return nil
+	}
+
+	start := pool.Next % len(pool.Members)
+	pool.Next++
+
+	var best *types.UpstreamMember
+	for i := range pool.Members {
+		member := pool.Members[(start+i)%len(pool.Members)]
+		if !available(member) {
+			continue
+		}
+		if best == nil || less(member, best) {
+			best = member
+		}
+	}
+	return best
+}
@@ -1,10 +1,34 @@
 package algo
 
 import (
+	"hash/fnv"
 	"jinx/pkg/util/types"
-	"sync"
+	"math"
 )
 
-func Hash(servers []types.UpStreamServer, currentServer int, lock *sync.Mutex) types.UpStreamServer {
-	return types.UpStreamServer{}
+// Hash sends every key to the same member as long as the pool is unchanged. It uses weighted rendezvous hashing,
+// so adding or removing a member, or a member becoming unhealthy, only moves the keys of that member.
+func Hash(pool *types.UpstreamPool, key string) *types.UpstreamMember {
+	pool.Mutex.Lock()
+	defer pool.Mutex.Unlock()
+
+	var best *types.UpstreamMember
+	bestScore := math.Inf(-1)
+	for _, member := range pool.Members {
+		if !available(member) {
+			continue
+		}
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(member.Address))
+
+		// Map the hash into (0, 1) and turn it into a score growing with the weight of the member
+		unit := (float64(h.Sum64()>>11) + 0.5) / float64(1<<53)
+		score := -float64(weight(member)) / math.Log(unit)
+		if best == nil || score > bestScore {
+			best, bestScore = member, score
+		}
+	}
+	return best
 }
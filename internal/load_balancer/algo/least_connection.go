@@ -2,9 +2,11 @@ package algo
 
 import (
 	"jinx/pkg/util/types"
-	"sync"
 )
 
-func LeastConnection(servers []types.UpStreamServer, currentServer int, lock *sync.Mutex) types.UpStreamServer {
-	return types.UpStreamServer{}
+// LeastConnection picks the member with the fewest active connections.
+func LeastConnection(pool *types.UpstreamPool, key string) *types.UpstreamMember {
+	return leastScore(pool, func(a, b *types.UpstreamMember) bool {
+		return a.ActiveConnections.Load() < b.ActiveConnections.Load()
+	})
 }
@@ -2,9 +2,16 @@ package algo
 
 import (
 	"jinx/pkg/util/types"
-	"sync"
 )
 
-func LeastResponse(servers []types.UpStreamServer, currentServer int, lock *sync.Mutex) types.UpStreamServer {
-	return types.UpStreamServer{}
+// LeastResponse picks the member with the lowest average response time weighed by its active connections. Members
+// that have not answered yet have no response time and are tried first.
+func LeastResponse(pool *types.UpstreamPool, key string) *types.UpstreamMember {
+	return leastScore(pool, func(a, b *types.UpstreamMember) bool {
+		return responseScore(a) < responseScore(b)
+	})
+}
+
+func responseScore(member *types.UpstreamMember) float64 {
+	return float64(member.ResponseTime.Load()) * float64(member.ActiveConnections.Load()+1)
 }
@@ -2,9 +2,33 @@ package algo
 
 import (
 	"jinx/pkg/util/types"
-	"sync"
+	"math/rand"
 )
 
-func Random(servers []types.UpStreamServer, currentServer int, lock *sync.Mutex) types.UpStreamServer {
-	return types.UpStreamServer{}
+// Random picks a member at random, members with a higher weight proportionally more often.
+func Random(pool *types.UpstreamPool, key string) *types.UpstreamMember {
+	pool.Mutex.Lock()
+	defer pool.Mutex.Unlock()
+
+	var total int64
+	for _, member := range pool.Members {
+		if available(member) {
+			total += weight(member)
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	n := rand.Int63n(total)
+	for _, member := range pool.Members {
+		if !available(member) {
+			continue
+		}
+		n -= weight(member)
+		if n < 0 {
+			return member
+		}
+	}
+	return nil
 }
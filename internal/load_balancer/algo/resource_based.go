@@ -1,10 +0,0 @@
-package algo
-
-import (
-	"jinx/pkg/util/types"
-	"sync"
-)
-
-func ResourceBased(servers []types.UpStreamServer, currentServer int, lock *sync.Mutex) types.UpStreamServer {
-	return types.UpStreamServer{}
-}
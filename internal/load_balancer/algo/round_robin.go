@@ -2,12 +2,19 @@ package algo
 
 import (
 	"jinx/pkg/util/types"
-	"sync"
 )
 
-func RoundRobin(servers []types.UpStreamServer, currentServer int, lock *sync.Mutex) types.UpStreamServer {
-	lock.Lock()
-	defer lock.Unlock()
-	nextServerIndex := (currentServer + 1) % len(servers)
-	return servers[nextServerIndex]
+// RoundRobin hands out the available members of the pool in turn.
+func RoundRobin(pool *types.UpstreamPool, key string) *types.UpstreamMember {
+	pool.Mutex.Lock()
+	defer pool.Mutex.Unlock()
+
+	for i := 0; i < len(pool.Members); i++ {
+		member := pool.Members[pool.Next%len(pool.Members)]
+		pool.Next++
+		if available(member) {
+			return member
+		}
+	}
+	return nil
 }
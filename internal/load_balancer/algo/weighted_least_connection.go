@@ -2,9 +2,11 @@ package algo
 
 import (
 	"jinx/pkg/util/types"
-	"sync"
 )
 
-func WeightedLeastConnection(servers []types.UpStreamServer, currentServer int, lock *sync.Mutex) types.UpStreamServer {
-	return types.UpStreamServer{}
+// WeightedLeastConnection picks the member with the fewest active connections relative to its weight.
+func WeightedLeastConnection(pool *types.UpstreamPool, key string) *types.UpstreamMember {
+	return leastScore(pool, func(a, b *types.UpstreamMember) bool {
+		return a.ActiveConnections.Load()*weight(b) < b.ActiveConnections.Load()*weight(a)
+	})
 }
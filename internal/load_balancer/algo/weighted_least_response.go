@@ -2,9 +2,11 @@ package algo
 
 import (
 	"jinx/pkg/util/types"
-	"sync"
 )
 
-func WeightedLeastResponse(servers []types.UpStreamServer, currentServer int, lock *sync.Mutex) types.UpStreamServer {
-	return types.UpStreamServer{}
+// WeightedLeastResponse picks the member with the lowest response score, see LeastResponse, relative to its weight.
+func WeightedLeastResponse(pool *types.UpstreamPool, key string) *types.UpstreamMember {
+	return leastScore(pool, func(a, b *types.UpstreamMember) bool {
+		return responseScore(a)/float64(weight(a)) < responseScore(b)/float64(weight(b))
+	})
 }
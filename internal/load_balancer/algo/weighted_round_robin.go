@@ -2,9 +2,30 @@ package algo
 
 import (
 	"jinx/pkg/util/types"
-	"sync"
 )
 
-func WeightedRoundRobin(servers []types.UpStreamServer, currentServer int, lock *sync.Mutex) types.UpStreamServer {
-	return types.UpStreamServer{}
+// WeightedRoundRobin hands out the members of the pool in proportion to their weight. It uses the smooth weighted
+// round robin of nginx, so a member with weight 5 next to one with weight 1 is not picked five times in a row.
+func WeightedRoundRobin(pool *types.UpstreamPool, key string) *types.UpstreamMember {
+	pool.Mutex.Lock()
+	defer pool.Mutex.Unlock()
+
+	var best *types.UpstreamMember
+	total := 0
+	for _, member := range pool.Members {
+		if !available(member) {
+			continue
+		}
+		memberWeight := int(weight(member))
+		member.CurrentWeight += memberWeight
+		total += memberWeight
+		if best == nil || member.CurrentWeight > best.CurrentWeight {
+			best = member
+		}
+	}
+
+	if best != nil {
+		best.CurrentWeight -= total
+	}
+	return best
 }
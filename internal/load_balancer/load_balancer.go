@@ -11,12 +11,14 @@
 package load_balancer
 
 import (
-	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"jinx/internal/jinx_tls"
+	"jinx/internal/listener"
 	"jinx/internal/load_balancer/algo"
+	"jinx/internal/upstream"
 	"jinx/pkg/util/constant"
 	"jinx/pkg/util/types"
 	"log"
@@ -24,20 +26,27 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sync"
+	"syscall"
 	"time"
 )
 
 type JinxLoadBalancingServer struct {
-	config         types.JinxLoadBalancingServerConfig
-	errorLogger    *slog.Logger
-	serverLogger   *slog.Logger
-	serverInstance *http.Server
-	serverRootDir  string
-	mode           string
-	currentServer  int
-	mutex          *sync.Mutex
+	config               types.JinxLoadBalancingServerConfig
+	errorLogger          *slog.Logger
+	serverLogger         *slog.Logger
+	listeners            []net.Listener // listeners relaying connections to the server pool, nil when not running
+	certificates         *jinx_tls.CertificateStore
+	challengeServer      *http.Server
+	stopCertificateWatch func()
+	stopHealthChecks     func()
+	adminServer          *http.Server
+	serverRootDir        string
+	mode                 string
+	serverPool           *upstream.Group
+	upstreamTLS          *tls.Config // TLS client settings of the server pool, nil when connections are relayed as they are
 }
 
 func NewJinxLoadBalancingServer(config types.JinxLoadBalancingServerConfig, serverRoot string) *JinxLoadBalancingServer {
@@ -52,79 +61,176 @@ func NewJinxLoadBalancingServer(config types.JinxLoadBalancingServerConfig, serv
 	}
 
 	loadBalancerMode := "http"
-	if config.CertFile != "" && config.KeyFile != "" {
+	if jinx_tls.IsEnabled(config.CertFile, config.KeyFile, config.Certificates, config.ACME) {
 		loadBalancerMode = "https"
 	}
 
-	return &JinxLoadBalancingServer{
-		config:         config,
-		errorLogger:    slog.New(slog.NewJSONHandler(errorLogFile, nil)),
-		serverLogger:   slog.New(slog.NewJSONHandler(serverLogFile, nil)),
-		serverRootDir:  serverRoot,
-		serverInstance: nil,
-		mode:           loadBalancerMode,
-		currentServer:  -1,
-		mutex:          &sync.Mutex{},
+	jx := &JinxLoadBalancingServer{
+		config:        config,
+		errorLogger:   slog.New(slog.NewJSONHandler(errorLogFile, nil)),
+		serverLogger:  slog.New(slog.NewJSONHandler(serverLogFile, nil)),
+		serverRootDir: serverRoot,
+		mode:          loadBalancerMode,
 	}
+	serverPool, serverPoolErr := upstream.NewServerPoolGroup("server pool", config.ServerPool, jx.PickAlgorithm(), config.HealthCheck, config.CircuitBreaker)
+	if serverPoolErr != nil {
+		log.Fatal(serverPoolErr)
+	}
+	serverPool.SetLoggers(jx.serverLogger, jx.errorLogger)
+	jx.serverPool = serverPool
+
+	if config.UpstreamTLS != nil {
+		upstreamTLS, upstreamTLSErr := jinx_tls.NewUpstreamTLSConfig(*config.UpstreamTLS)
+		if upstreamTLSErr != nil {
+			log.Fatal(upstreamTLSErr)
+		}
+		// Empty settings still re-encrypt, with the default TLS client settings
+		jx.upstreamTLS = jinx_tls.UpstreamTLSConfigFor(upstreamTLS, "")
+	}
+
+	return jx
 }
 
 func (jx *JinxLoadBalancingServer) Start() types.JinxServer {
+	jx.serve()
+
+	// Reload the certificates when the reload command sends SIGHUP
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			jx.Reload()
+		}
+	}()
+
+	return jx
+}
+
+// serve binds the listeners of the load balancer, loads its certificates and relays the connections of every
+// listener to the server pool in the background. Start and Restart both call it, Stop closes what it opens.
+func (jx *JinxLoadBalancingServer) serve() {
 	addr := fmt.Sprintf("%s:%d", jx.config.IP, jx.config.Port)
-	var listener net.Listener
 
-	if jx.mode == "https" {
-		certificate, certErr := tls.LoadX509KeyPair(jx.config.CertFile, jx.config.KeyFile)
+	l, listenerErr := listener.Listen(addr, jx.config.Limits, nil)
+	if listenerErr != nil {
+		msg := fmt.Sprintf("error starting %s load balancer: %v", jx.mode, listenerErr)
+		jx.errorLogger.Error(msg)
+		log.Fatal(listenerErr)
+	}
+	var connListener net.Listener = l
+
+	if jx.mode == "https" || listener.AnyTLS(jx.config.Listeners) {
+		_, certErr := jx.loadCertificates()
 		if certErr != nil {
 			msg := fmt.Sprintf("error loading certificate: %v", certErr)
 			jx.errorLogger.Error(msg)
+			log.Fatal(certErr)
 		}
-		config := &tls.Config{
-			Certificates: []tls.Certificate{certificate},
+		if jx.mode == "https" {
+			connListener = tls.NewListener(l, jx.newTLSConfig(jx.config.TLSPolicy))
 		}
-		l, listenerErr := tls.Listen("tcp", addr, config)
-		if listenerErr != nil {
-			msg := fmt.Sprintf("error starting https load balancer: %v", listenerErr)
+	}
+
+	// Additional listeners relay to the same server pool, each with or without TLS
+	jx.listeners = []net.Listener{connListener}
+	for _, listenerConfig := range jx.config.Listeners {
+		extra, extraErr := listener.Listen(listener.Address(listenerConfig, jx.config.IP), jx.config.Limits, nil)
+		if extraErr != nil {
+			msg := fmt.Sprintf("error starting %s load balancer: %v", jx.mode, extraErr)
 			jx.errorLogger.Error(msg)
+			log.Fatal(extraErr)
 		}
-		listener = l
-	} else {
-		l, listenerErr := net.Listen("tcp", addr)
-		if listenerErr != nil {
-			msg := fmt.Sprintf("error starting http load balancer: %v", listenerErr)
-			jx.errorLogger.Error(msg)
+
+		var extraListener net.Listener = extra
+		if listenerConfig.TLS {
+			extraListener = tls.NewListener(extra, jx.newTLSConfig(listener.TLSPolicy(listenerConfig, jx.config.TLSPolicy)))
 		}
-		listener = l
+		jx.listeners = append(jx.listeners, extraListener)
 	}
 
-	go func() {
-		for {
-			if listener != nil {
-				conn, err := listener.Accept()
-				if err != nil {
-					msg := fmt.Sprintf("error accepting connection: %v", err)
-					jx.errorLogger.Error(msg)
-				}
-				go jx.ProxyTCP(conn)
+	if jx.certificates != nil {
+		jx.stopCertificateWatch = jx.certificates.StartWatching(jx.config.CertificateReload, jx.serverLogger, jx.errorLogger)
+	}
+
+	jx.startUpstreamServices()
+
+	for _, l := range jx.listeners {
+		go jx.serveConnections(l)
+	}
+}
+
+// startUpstreamServices starts the health checks of the server pool and the admin listener, which Stop shuts down.
+// serve calls it on every start, so that a restarted load balancer keeps checking its unhealthy servers and keeps
+// reporting its pool.
+func (jx *JinxLoadBalancingServer) startUpstreamServices() {
+	// Unhealthy servers are taken out of the pool until they pass their checks again
+	jx.stopHealthChecks = jx.serverPool.StartHealthChecks(jx.serverLogger, jx.errorLogger)
+
+	adminServer, adminErr := upstream.StartAdmin(jx.config.Admin, []*upstream.Group{jx.serverPool}, nil, jx.errorLogger)
+	if adminErr != nil {
+		jx.errorLogger.Error(adminErr.Error())
+		log.Fatal(adminErr)
+	}
+	jx.adminServer = adminServer
+}
+
+// serveConnections accepts the connections of l and relays each of them to a server of the pool until l is closed.
+func (jx *JinxLoadBalancingServer) serveConnections(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			msg := fmt.Sprintf("error accepting connection: %v", err)
+			jx.errorLogger.Error(msg)
+			if errors.Is(err, net.ErrClosed) {
+				return
 			}
+			continue
 		}
-	}()
+		go jx.ProxyTCP(conn)
+	}
+}
 
-	return jx
+// newTLSConfig returns the tls.Config of a TLS listener of the load balancer under policy. No ALPN protocol is
+// offered by default since the load balancer relays the raw stream and cannot speak for the upstream servers.
+func (jx *JinxLoadBalancingServer) newTLSConfig(policy types.TLSPolicy) *tls.Config {
+	tlsConfig, err := jx.certificates.NewTLSConfig(policy, nil)
+	if err != nil {
+		msg := fmt.Sprintf("error applying TLS policy: %v", err)
+		jx.errorLogger.Error(msg)
+		log.Fatal(err)
+	}
+	return tlsConfig
+}
+
+// loadCertificates loads the certificate store and the client verifier of the load balancer. When an ACME HTTP-01 challenge port is configured, a plain HTTP listener answering the challenges
+// is started alongside.
+func (jx *JinxLoadBalancingServer) loadCertificates() (*jinx_tls.CertificateStore, error) {
+	certificates, err := jinx_tls.NewServerCertificateStore(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME)
+	if err != nil {
+		return nil, err
+	}
+	clientVerifier, err := jinx_tls.NewClientVerifier(jx.config.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	certificates.SetClientVerifier(clientVerifier)
+
+	jx.certificates = certificates
+
+	if jx.config.ACME.HTTPChallengePort != 0 {
+		challengeAddr := fmt.Sprintf("%s:%d", jx.config.IP, jx.config.ACME.HTTPChallengePort)
+		jx.challengeServer = certificates.StartHTTPChallengeServer(challengeAddr, jx.config.Limits, jx.errorLogger)
+	}
+
+	return certificates, nil
 }
 
-// Stop gracefully shuts down the JinxHttpServer instance, ensuring all ongoing requests are
-// completed before closure. This method initiates a graceful shutdown by creating a context
-// with a 15-second timeout, signaling the server to cease accepting new requests and wait
-// for existing requests to conclude within this timeframe. If the server successfully shuts
-// down within the allotted time, it logs a confirmation message. If an error occurs during
-// shutdown (e.g., the timeout is exceeded), it logs the error. This method is essential for
-// clean server termination, minimizing the risk of interrupting active client connections
-// and ensuring resources are properly released.
+// Stop closes the listeners of the load balancer so that no new connection is accepted, shuts down its ACME
+// challenge listener, its certificate watch, the health checks of its server pool and its admin listener. Relayed
+// connections are left to end on their own. The ports of the load balancer are free once Stop returns.
 //
-// The method does nothing if the server instance (`serverInstance`) is nil, which implies
-// that the server has not been started or has already been stopped. This check prevents
-// potential nil pointer dereferences and ensures the method's idempotency, allowing it to
-// be safely called multiple times.
+// The method does nothing if the load balancer has no listeners, which implies that it has not been started or
+// has already been stopped, so that it can be safely called multiple times.
 //
 // Usage:
 // - This method should be called when the server needs to be stopped, such as in response
@@ -132,77 +238,84 @@ func (jx *JinxLoadBalancingServer) Start() types.JinxServer {
 //   the server's lifecycle management, facilitating controlled and safe server termination.
 
 func (jx *JinxLoadBalancingServer) Stop() {
-	if jx.serverInstance == nil {
+	if jx.listeners == nil {
 		return
 	}
-	// Create a context with a timeout to tell the server how long to wait for existing requests to finish
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
 
-	// Attempt to gracefully shut down the server
-	if err := jx.serverInstance.Shutdown(ctx); err != nil {
-		jx.errorLogger.Error(fmt.Sprintf("Server shutdown error: %s", err))
+	for _, l := range jx.listeners {
+		if err := l.Close(); err != nil {
+			jx.errorLogger.Error(fmt.Sprintf("Server shutdown error: %s", err))
+		}
+	}
+	jx.listeners = nil
+
+	if jx.challengeServer != nil {
+		_ = jx.challengeServer.Close()
+		jx.challengeServer = nil
+	}
+
+	if jx.stopCertificateWatch != nil {
+		jx.stopCertificateWatch()
+		jx.stopCertificateWatch = nil
+	}
+	// A restart loads the certificates again, together with the challenge listener stopped above
+	jx.certificates = nil
+
+	if jx.stopHealthChecks != nil {
+		jx.stopHealthChecks()
+		jx.stopHealthChecks = nil
+	}
+
+	if jx.adminServer != nil {
+		_ = jx.adminServer.Close()
+		jx.adminServer = nil
 	}
 
 	jx.serverLogger.Info(fmt.Sprintf("Successfully shutdown server manually"))
 }
 
-// Restart attempts to gracefully restart the JinxHttpServer instance. It first checks if the server
-// is running (`serverInstance` is not nil); if not, it returns nil, indicating there's no server to restart.
-// If the server is running, it performs a graceful shutdown by calling the Stop method, which waits
-// for ongoing requests to finish before stopping the server. After stopping, it immediately initiates
-// the server's restart process in a new goroutine, allowing the method to return without waiting for
-// the server to restart. This non-blocking approach facilitates rapid restarts without stalling the
-// calling thread or process.
-//
-// The server is restarted with TLS if both `CertFile` and `KeyFile` are specified in the server's
-// configuration (`config`). If these are not provided, it restarts without TLS. If an error occurs
-// during the restart process, such as issues with binding to the specified port or problems with
-// the TLS configuration, it logs the error and terminates the application with `log.Fatal`.
-// This method ensures the server can be dynamically restarted with updated configurations or
-// in response to certain runtime conditions without manual intervention.
+// Restart stops the load balancer and binds its listeners again. It returns nil, indicating there's no server to
+// restart, when the load balancer is not running. Otherwise it calls Stop, which closes the listeners while the
+// relayed connections are left to end, then loads the certificates again, binds the listeners and restarts the
+// health checks and the admin listener. If a listener cannot be bound, it logs the error and terminates the
+// application with `log.Fatal`.
 //
 // Usage:
 // - This method is useful in scenarios where changes to the server's configuration or runtime
 //   environment necessitate a restart, such as after updating TLS certificates or changing server
 //   settings. It provides a programmatic way to restart the server, encapsulating the shutdown
-//   and restart logic within the JinxHttpServer's lifecycle management.
+//   and restart logic within the load balancer's lifecycle management.
 //
 // Returns:
-// - A reference to the restarted JinxHttpServer instance (`jx`), allowing for chaining or further
-//   actions. Returns nil if the server was not running at the time of the call, indicating there
-//   was no server instance to restart.
+// - A reference to the restarted load balancer (`jx`), allowing for chaining or further actions.
+//   Returns nil if the server was not running at the time of the call.
 
 func (jx *JinxLoadBalancingServer) Restart() types.JinxServer {
-	if jx.serverInstance == nil {
+	if jx.listeners == nil {
 		return nil
 	}
 
 	jx.Stop()
-	go func() {
-		if jx.config.CertFile != "" && jx.config.KeyFile != "" {
-			err := jx.serverInstance.ListenAndServeTLS(jx.config.CertFile, jx.config.KeyFile)
-			if err != nil && !errors.Is(err, http.ErrServerClosed) {
-				jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
-				log.Fatal(err)
-			}
-			return
-		}
-
-		// Start the server
-		err := jx.serverInstance.ListenAndServe()
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
-			log.Fatal(err)
-		}
-	}()
+	jx.serve()
 
 	return jx
 }
 
+// Reload re-reads the certificate and key files of the server and swaps the certificates that changed into the
+// live TLS configuration. New handshakes use the new certificates while established connections are left
+// untouched. A certificate whose new files cannot be loaded is reported as a JinxError and kept in use.
+// Reload is triggered by the reload command, which sends SIGHUP to the running server.
+func (jx *JinxLoadBalancingServer) Reload() {
+	if jx.certificates == nil {
+		return
+	}
+
+	jx.certificates.ReloadAndReport(jx.serverLogger, jx.errorLogger)
+}
+
 // Destroy performs a complete teardown of the JinxHttpServer instance, effectively stopping the server
-// and removing its working directory and all contained data. This method first checks if the server instance
-// (`serverInstance`) is currently running; if it is not, the method returns immediately, as there is no server
+// and removing its working directory and all contained data. This method first checks if the load balancer
+// (`listeners`) is currently running; if it is not, the method returns immediately, as there is no server
 // to stop or resources to clean up. If the server is running, it calls the Stop method to gracefully shut down
 // the server, ensuring that all ongoing requests are allowed to complete before the server stops accepting new
 // requests. Following the server shutdown, Destroy removes the server's working directory (`serverWorkingDir`),
@@ -220,7 +333,7 @@ func (jx *JinxLoadBalancingServer) Restart() types.JinxServer {
 //     contents, which may include application data, logs, and configuration files. Ensure that any important data
 //     is backed up before calling Destroy.
 func (jx *JinxLoadBalancingServer) Destroy() {
-	if jx.serverInstance == nil {
+	if jx.listeners == nil {
 		return
 	}
 
@@ -229,16 +342,26 @@ func (jx *JinxLoadBalancingServer) Destroy() {
 
 }
 
+// ProxyTCP relays conn to a server of the pool picked for the client until either side closes its connection or
+// the connection is idle for longer than the idle timeout.
 func (jx *JinxLoadBalancingServer) ProxyTCP(conn net.Conn) {
-	upstreamServer := jx.PickAlgorithm()(jx.config.ServerPool, jx.currentServer, jx.mutex)
-	addr := fmt.Sprintf("%s:%d", upstreamServer.IP, upstreamServer.Port)
+	clientIP := conn.RemoteAddr().String()
+	if host, _, splitErr := net.SplitHostPort(clientIP); splitErr == nil {
+		clientIP = host
+	}
 
-	remoteConn, err := net.Dial("tcp", addr)
+	remoteConn, release, err := jx.dialServerPool(clientIP)
 	if err != nil {
 		jx.errorLogger.Error(fmt.Sprintf("error connecting to remote: %v", err))
 		_ = conn.Close() // Only close conn here as remoteConn is not yet established.
 		return
 	}
+	defer release()
+
+	// Close both sides when no data has flowed for the configured idle timeout
+	idleTimeout := listener.Seconds(jx.config.Limits.IdleTimeout, constant.DEFAULT_IDLE_TIMEOUT)
+	conn = listener.NewIdleTimeoutConn(conn, idleTimeout)
+	remoteConn = listener.NewIdleTimeoutConn(remoteConn, idleTimeout)
 	var wg sync.WaitGroup
 	wg.Add(2)
 
@@ -265,28 +388,85 @@ func (jx *JinxLoadBalancingServer) ProxyTCP(conn net.Conn) {
 	_ = conn.Close()
 }
 
+// PickAlgorithm returns the balancing algorithm named in the configuration, round robin when the name is unknown or
+// not supported. The setup rejects such names, a warning is logged for configurations that bypass it.
 func (jx *JinxLoadBalancingServer) PickAlgorithm() types.LoadBalancingAlgorithm {
-	switch jx.config.Algorithm {
-	case constant.ROUND_ROBIN:
-		return algo.RoundRobin
-	case constant.LEAST_CONNECTIONS:
-		return algo.LeastConnection
-	case constant.LEAST_RESPONSE_TIME:
-		return algo.LeastResponse
-	case constant.HASHING:
-		return algo.Hash
-	case constant.WEIGHTED_ROUND_ROBIN:
-		return algo.WeightedRoundRobin
-	case constant.WEIGHTED_LEAST_CONNECTIONS:
-		return algo.WeightedLeastConnection
-	case constant.WEIGHTED_LEAST_RESPONSE_TIME:
-		return algo.WeightedLeastResponse
-	case constant.RESOURCE_BASED:
-		return algo.ResourceBased
-	case constant.GEOGRAPHICAL:
-		return algo.Geographical
-	default:
-		return algo.RoundRobin
+	if err := algo.Validate(jx.config.Algorithm); jx.config.Algorithm != "" && err != nil {
+		jx.errorLogger.Warn(fmt.Sprintf("%v: balancing with %s instead", err, constant.ROUND_ROBIN))
+	}
+	algorithm, _ := algo.ByName(jx.config.Algorithm)
+	return algorithm
+}
+
+// dialServerPool connects to a server of the pool for the client at clientIP, over TLS when the load balancer
+// re-encrypts the connections to its server pool. When the server picked cannot be connected to, the connection is tried on another server until the retry budget of the load balancer is spent,
+// within the per-try timeout and the overall deadline of its retry settings. Failed connects count against the
+// circuit breaker of their server. A successful connect is recorded as soon as it is made rather than when the
+// relayed session ends, so that a half-open breaker closes without waiting for a long-lived trial session.
+//
+// Parameters:
+//   - clientIP: The IP address of the client, the key of the hashing algorithms.
+//
+// Returns:
+//   - The connection to the server, the function recording the end of the connection, and an error if no server
+//     could be connected to.
+func (jx *JinxLoadBalancingServer) dialServerPool(clientIP string) (net.Conn, func(), error) {
+	config := jx.config.Retry
+	attempts := config.Attempts
+	if attempts <= 0 {
+		attempts = constant.DEFAULT_RETRY_ATTEMPTS
+	}
+	var deadline time.Time
+	if config.Deadline > 0 {
+		deadline = time.Now().Add(time.Duration(config.Deadline) * time.Second)
+	}
+
+	member, ok := jx.serverPool.Pick(clientIP)
+	if !ok {
+		return nil, nil, upstream.ErrNoAvailableMember
+	}
+
+	tried := []*types.UpstreamMember{member}
+	for attempt := 1; ; attempt++ {
+		timeout := time.Duration(config.TryTimeout) * time.Second
+		if !deadline.IsZero() && (timeout == 0 || time.Until(deadline) < timeout) {
+			timeout = time.Until(deadline)
+		}
+
+		done := jx.serverPool.Begin(member)
+		remoteConn, err := jx.dial(member.Address, timeout)
+		if err == nil {
+			// The session still counts as an active connection of the server until it ends
+			member.ActiveConnections.Add(1)
+			done(false)
+			return remoteConn, func() { member.ActiveConnections.Add(-1) }, nil
+		}
+		// Dial errors count against the circuit breaker of the server
+		done(true)
+
+		if attempt >= attempts || (!deadline.IsZero() && !time.Now().Before(deadline)) {
+			return nil, nil, err
+		}
+
+		next, ok := jx.serverPool.PickRetry(clientIP, tried)
+		if !ok {
+			return nil, nil, err
+		}
+		jx.serverLogger.Info(fmt.Sprintf("Connecting to %s failed: %v, retrying on %s", member.Address, err, next.Address))
+
+		member = next
+		tried = append(tried, member)
+	}
+}
+
+// dial connects to the server of the pool at address within timeout, no timeout applies when it is zero. The TLS
+// handshake with the server is part of the connect when the load balancer re-encrypts the connections.
+func (jx *JinxLoadBalancingServer) dial(address string, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if jx.upstreamTLS == nil {
+		return dialer.Dial("tcp", address)
 	}
 
+	host, _, _ := net.SplitHostPort(address)
+	return tls.DialWithDialer(dialer, "tcp", address, jinx_tls.UpstreamTLSConfigFor(jx.upstreamTLS, host))
 }
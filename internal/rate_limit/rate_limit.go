@@ -0,0 +1,266 @@
+// File: rate_limit.go
+// Package: rate_limit
+
+// Program Description:
+// This file implements the request rate limiting of the HTTP based servers.
+// Every rule holds a token bucket per key, the client IP address, the value
+// of a header or the route of a request. Requests finding no token in one of
+// the buckets of their rules are rejected with 429 Too Many Requests, or only
+// logged in dry run mode. The forward proxy also limits the number of open
+// CONNECT tunnels of every client
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package rate_limit
+
+import (
+	"fmt"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the buckets that refilled completely are removed.
+const sweepInterval = time.Minute
+
+// Limiter applies the rate limit rules of a server to its requests.
+type Limiter struct {
+	rules          []*rule
+	dryRun         bool
+	trustedProxies []*net.IPNet
+	maxTunnels     int
+	logger         *slog.Logger
+
+	mutex   sync.Mutex
+	tunnels map[string]int // open CONNECT tunnels per client IP
+}
+
+type rule struct {
+	config types.RateLimitRule
+	name   string
+	rate   float64 // tokens added per second
+	burst  float64
+
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket holds the tokens of a key at the time it was last used.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New creates the rate limiter of a server.
+//
+// Parameters:
+//   - config: The types.RateLimitConfig of the server.
+//   - trustedProxies: The proxies whose forwarding headers name the client of a request. Servers without trusted
+//     proxies of their own pass those of config, see helper.ParseTrustedProxies.
+//   - logger: The logger of the requests exceeding a limit.
+//
+// Returns:
+//   - The *Limiter, or an error if config is invalid.
+func New(config types.RateLimitConfig, trustedProxies []*net.IPNet, logger *slog.Logger) (*Limiter, error) {
+	if err := helper.ValidateRateLimitConfig(config); err != nil {
+		return nil, err
+	}
+
+	rules := make([]*rule, 0, len(config.Rules))
+	for i, ruleConfig := range config.Rules {
+		period := ruleConfig.Period
+		if period == 0 {
+			period = constant.DEFAULT_RATE_LIMIT_PERIOD
+		}
+		burst := ruleConfig.Burst
+		if burst == 0 {
+			burst = ruleConfig.Requests
+		}
+		name := ruleConfig.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+
+		rules = append(rules, &rule{
+			config:    ruleConfig,
+			name:      name,
+			rate:      float64(ruleConfig.Requests) / float64(period),
+			burst:     float64(burst),
+			buckets:   make(map[string]*bucket),
+			lastSweep: time.Now(),
+		})
+	}
+
+	return &Limiter{
+		rules:          rules,
+		dryRun:         config.DryRun,
+		trustedProxies: trustedProxies,
+		maxTunnels:     config.MaxTunnels,
+		logger:         logger,
+		tunnels:        make(map[string]int),
+	}, nil
+}
+
+// Allow takes a token for r from the bucket of every rule it matches. When one of the buckets is empty, the request
+// is answered with 429 Too Many Requests and a Retry-After header telling the client when the bucket has a token
+// again, unless the limiter runs in dry run mode and the request is only logged.
+//
+// Parameters:
+//   - w: The http.ResponseWriter of the client.
+//   - r: The *http.Request of the client.
+//   - route: The route of r, used by the rules keyed by route. When empty, the path prefix of the rule that matched
+//     is the route.
+//
+// Returns:
+//   - true if the request may be served, false if it was rejected.
+func (l *Limiter) Allow(w http.ResponseWriter, r *http.Request, route string) bool {
+	if len(l.rules) == 0 {
+		return true
+	}
+
+	clientIP := helper.RealClientIP(r, l.trustedProxies)
+	now := time.Now()
+
+	var exceeded *rule
+	var retryAfter time.Duration
+	for _, rl := range l.rules {
+		prefix, ok := rl.matches(r)
+		if !ok {
+			continue
+		}
+		if wait, taken := rl.take(rl.key(r, clientIP, route, prefix), now); !taken && wait >= retryAfter {
+			exceeded = rl
+			retryAfter = wait
+		}
+	}
+	if exceeded == nil {
+		return true
+	}
+
+	if l.dryRun {
+		l.logger.Info(fmt.Sprintf("Rate limit %s exceeded by %s for %s %s (dry run)", exceeded.name, clientIP, r.Method, r.URL.String()))
+		return true
+	}
+
+	l.logger.Info(fmt.Sprintf("Rate limit %s exceeded by %s for %s %s", exceeded.name, clientIP, r.Method, r.URL.String()))
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+	return false
+}
+
+// OpenTunnel counts a CONNECT tunnel of the client of r against the maximum number of open tunnels per client.
+// When the client already has as many tunnels open, the request is answered with 429 Too Many Requests, unless the
+// limiter runs in dry run mode.
+//
+// Returns:
+//   - The function to call once the tunnel is closed, and false if the request was rejected.
+func (l *Limiter) OpenTunnel(w http.ResponseWriter, r *http.Request) (closeTunnel func(), ok bool) {
+	if l.maxTunnels == 0 {
+		return func() {}, true
+	}
+
+	clientIP := helper.RealClientIP(r, l.trustedProxies)
+
+	l.mutex.Lock()
+	exceeded := l.tunnels[clientIP] >= l.maxTunnels
+	if exceeded && !l.dryRun {
+		l.mutex.Unlock()
+		l.logger.Info(fmt.Sprintf("Tunnel limit exceeded by %s for %s", clientIP, r.Host))
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return nil, false
+	}
+	l.tunnels[clientIP]++
+	l.mutex.Unlock()
+
+	if exceeded {
+		l.logger.Info(fmt.Sprintf("Tunnel limit exceeded by %s for %s (dry run)", clientIP, r.Host))
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			if l.tunnels[clientIP]--; l.tunnels[clientIP] <= 0 {
+				delete(l.tunnels, clientIP)
+			}
+		})
+	}, true
+}
+
+// matches reports whether the rule applies to r and returns the path prefix that matched, "/" for rules without
+// paths. Prefixes match whole path segments, /login matches /login and /login/reset but not /loginfo.
+func (rl *rule) matches(r *http.Request) (string, bool) {
+	if len(rl.config.Paths) == 0 {
+		return "/", true
+	}
+	for _, prefix := range rl.config.Paths {
+		trimmed := strings.TrimSuffix(prefix, "/")
+		if trimmed == "" || r.URL.Path == trimmed || strings.HasPrefix(r.URL.Path, trimmed+"/") {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// key returns the bucket key of r under the rule.
+func (rl *rule) key(r *http.Request, clientIP string, route string, prefix string) string {
+	switch {
+	case rl.config.Key == constant.RATE_LIMIT_ROUTE:
+		if route == "" {
+			return prefix
+		}
+		return route
+	case strings.HasPrefix(rl.config.Key, constant.RATE_LIMIT_HEADER_PREFIX):
+		if value := r.Header.Get(strings.TrimPrefix(rl.config.Key, constant.RATE_LIMIT_HEADER_PREFIX)); value != "" {
+			return "header " + value
+		}
+	}
+	return clientIP
+}
+
+// take removes a token from the bucket of key. When the bucket is empty, it returns how long until it holds a token
+// again.
+func (rl *rule) take(key string, now time.Time) (time.Duration, bool) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if now.Sub(rl.lastSweep) >= sweepInterval {
+		rl.sweep(now)
+	}
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = b
+	}
+
+	b.tokens = min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / rl.rate * float64(time.Second)), false
+}
+
+// sweep removes the buckets that refilled completely, they hold no state a new bucket would not.
+func (rl *rule) sweep(now time.Time) {
+	for key, b := range rl.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rl.rate >= rl.burst {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastSweep = now
+}
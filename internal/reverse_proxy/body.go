@@ -0,0 +1,245 @@
+// File: body.go
+// Package: reverse_proxy
+
+// Program Description:
+// This file implements the body limits and buffering of routes. Request
+// bodies larger than the limit of their route are rejected with 413 before
+// they reach the upstream. Buffered request bodies are read completely
+// before the upstream is contacted, so that slow clients do not hold
+// connections of slow upstreams, and buffered responses are read completely
+// before they are passed on. Buffers larger than the memory buffer size
+// spill to temporary files in the working directory of the server
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package reverse_proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// errResponseTooLarge aborts responses of the upstream larger than the maximum response size of their route.
+var errResponseTooLarge = errors.New("upstream response larger than the maximum response size of the route")
+
+// validateBody checks the body limits and buffering of a route.
+func validateBody(config types.BodyConfig) error {
+	if config.MaxRequestSize < 0 || config.MaxResponseSize < 0 || config.MemoryBufferSize < 0 {
+		return fmt.Errorf("the body MaxRequestSize, MaxResponseSize and MemoryBufferSize must not be negative")
+	}
+	if config.FlushInterval < -1 {
+		return fmt.Errorf("the body FlushInterval must be -1, zero or a number of milliseconds, got %d", config.FlushInterval)
+	}
+	if config.BufferResponses && config.FlushInterval != 0 {
+		return fmt.Errorf("the body FlushInterval cannot be set for buffered responses")
+	}
+	return nil
+}
+
+// limitRequestBody enforces the maximum request size of the route of r and buffers its body when the route buffers
+// requests. Requests announcing a larger body are answered with 413 Request Entity Too Large right away, the bodies
+// of other requests are cut off at the limit, which fails their request with 413 as well, see proxyHTTP.
+//
+// Parameters:
+//   - w: The http.ResponseWriter of the client, answered when the request is rejected.
+//   - r: The *http.Request of the client. Its body is replaced by the limited or buffered body.
+//   - target: The upstream the route of r resolved to.
+//
+// Returns:
+//   - The function releasing the buffer of the body, to be called once the request was handled.
+//   - Whether the request may be forwarded, false when it was answered already.
+func (jx *JinxReverseProxyServer) limitRequestBody(w http.ResponseWriter, r *http.Request, target upstreamTarget) (release func(), ok bool) {
+	config := target.route.Body
+	release = func() {}
+	if r.Body == nil || r.Body == http.NoBody {
+		return release, true
+	}
+
+	if config.MaxRequestSize > 0 {
+		if r.ContentLength > config.MaxRequestSize {
+			jx.errorLogger.Error(fmt.Sprintf("Rejected request for %s: body of %d bytes, the limit is %d", r.URL.Path, r.ContentLength, config.MaxRequestSize))
+			writeError(w, r, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return release, false
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, config.MaxRequestSize)
+	}
+	if !config.BufferRequests {
+		return release, true
+	}
+
+	body, size, err := bufferBody(r.Body, jx.bodyBufferDir(), config.MemoryBufferSize, 0)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			jx.errorLogger.Error(fmt.Sprintf("Rejected request for %s: body larger than %d bytes", r.URL.Path, config.MaxRequestSize))
+			writeError(w, r, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return release, false
+		}
+		jx.errorLogger.Error(fmt.Sprintf("Failed to buffer the body of the request for %s: %v", r.URL.Path, err))
+		writeError(w, r, "Bad Request: the request body could not be read", http.StatusBadRequest)
+		return release, false
+	}
+
+	// The upstream receives the body in one piece with its length, rather than as it trickles in
+	r.Body = body
+	r.ContentLength = size
+	r.TransferEncoding = nil
+	r.Header.Set("Content-Length", strconv.FormatInt(size, 10))
+	return func() {
+		_ = body.Close()
+	}, true
+}
+
+// limitResponseBody enforces the maximum response size of the route of the response res and buffers it when the
+// route buffers responses. gRPC responses, responses to HEAD requests and responses without a body are passed on
+// as they are.
+//
+// Parameters:
+//   - r: The *http.Request sent to the upstream.
+//   - res: The *http.Response of the upstream. Its body is replaced by the limited or buffered body.
+//   - config: The types.BodyConfig of the route of the request.
+//
+// Returns:
+//   - errResponseTooLarge when the response is larger than the maximum response size, or the error reading the
+//     response of the upstream.
+func (jx *JinxReverseProxyServer) limitResponseBody(r *http.Request, res *http.Response, config types.BodyConfig) error {
+	if isGRPC(r) || r.Method == http.MethodHead || res.Body == nil || res.Body == http.NoBody ||
+		res.StatusCode < http.StatusOK || res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	if config.MaxResponseSize > 0 && res.ContentLength > config.MaxResponseSize {
+		_ = res.Body.Close()
+		return errResponseTooLarge
+	}
+	if !config.BufferResponses {
+		if config.MaxResponseSize > 0 {
+			res.Body = &limitedBody{ReadCloser: res.Body, remaining: config.MaxResponseSize}
+		}
+		return nil
+	}
+
+	body, size, err := bufferBody(res.Body, jx.bodyBufferDir(), config.MemoryBufferSize, config.MaxResponseSize)
+	// The connection to the upstream is free for other requests once the response was read
+	_ = res.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	res.Body = body
+	res.ContentLength = size
+	res.TransferEncoding = nil
+	res.Header.Set("Content-Length", strconv.FormatInt(size, 10))
+	return nil
+}
+
+// flushInterval returns the flush interval of the streamed responses of a route, see httputil.ReverseProxy.
+func flushInterval(config types.BodyConfig) time.Duration {
+	if config.FlushInterval < 0 {
+		return -1
+	}
+	return time.Duration(config.FlushInterval) * time.Millisecond
+}
+
+// bodyBufferDir returns the directory buffered bodies spill to.
+func (jx *JinxReverseProxyServer) bodyBufferDir() string {
+	return filepath.Join(jx.serverWorkingDir, constant.BODY_BUFFER_DIR)
+}
+
+// bufferBody reads body completely. Bodies up to memoryBufferSize bytes are kept in memory, larger ones are written
+// to a temporary file in dir, which is removed when the returned body is closed.
+//
+// Parameters:
+//   - body: The body to read.
+//   - dir: The directory of the temporary files, created when missing.
+//   - memoryBufferSize: The number of bytes kept in memory, constant.DEFAULT_BODY_MEMORY_BUFFER_SIZE when zero.
+//   - limit: The largest body read in bytes, unlimited when zero.
+//
+// Returns:
+//   - The buffered body, to be closed once it is no longer needed.
+//   - The size of the body in bytes.
+//   - errResponseTooLarge when the body is larger than limit, or the error reading body or writing the file.
+func bufferBody(body io.Reader, dir string, memoryBufferSize int64, limit int64) (io.ReadCloser, int64, error) {
+	if memoryBufferSize == 0 {
+		memoryBufferSize = constant.DEFAULT_BODY_MEMORY_BUFFER_SIZE
+	}
+	if limit > 0 {
+		body = &limitedBody{ReadCloser: io.NopCloser(body), remaining: limit}
+	}
+
+	var memory bytes.Buffer
+	size, err := io.CopyN(&memory, body, memoryBufferSize+1)
+	if errors.Is(err, io.EOF) {
+		return io.NopCloser(bytes.NewReader(memory.Bytes())), size, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, 0, err
+	}
+	file, err := os.CreateTemp(dir, "body-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	spilled := &spilledBody{File: file}
+
+	if _, err = file.Write(memory.Bytes()); err == nil {
+		var rest int64
+		rest, err = io.Copy(file, body)
+		size += rest
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = spilled.Close()
+		return nil, 0, err
+	}
+	return spilled, size, nil
+}
+
+// spilledBody is a body buffered in a temporary file, which is removed once the body is closed.
+type spilledBody struct {
+	*os.File
+}
+
+func (b *spilledBody) Close() error {
+	err := b.File.Close()
+	_ = os.Remove(b.File.Name())
+	return err
+}
+
+// limitedBody fails with errResponseTooLarge once more than remaining bytes were read from it.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errResponseTooLarge
+	}
+	// One byte more than allowed is read to tell a body of exactly the limit from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), errResponseTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,63 @@
+// File: cache.go
+// Package: reverse_proxy
+
+// Program Description:
+// This file connects the routes of the reverse proxy to its shared response
+// cache: the cache key of a request is built from the key template of its
+// route, and requests matching the bypass rules of the route skip the cache
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package reverse_proxy
+
+import (
+	"fmt"
+	"jinx/internal/http_cache"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net/http"
+)
+
+// defaultCacheKey is the cache key of routes without their own.
+const defaultCacheKey = "$scheme://$host$path?$query"
+
+// serveCached answers r through the response cache of the server when caching is enabled for the route of the
+// request and forwards it to the upstream otherwise.
+func (jx *JinxReverseProxyServer) serveCached(w http.ResponseWriter, r *http.Request, target upstreamTarget) {
+	forward := func(w http.ResponseWriter, r *http.Request) {
+		jx.forward(w, r, target)
+	}
+
+	config := target.route.Cache
+	if jx.cache == nil || config.Disabled {
+		forward(w, r)
+		return
+	}
+	if http_cache.Bypass(r, config.Bypass) {
+		w.Header().Set(constant.CACHE_STATUS_HEADER, constant.CACHE_BYPASS)
+		forward(w, r)
+		return
+	}
+
+	key := config.Key
+	if key == "" {
+		key = defaultCacheKey
+	}
+	key = expandVariables(key, requestVariables(r, jx.realClientIP(r), target.url))
+	if target.version != "" {
+		// The versions of a split answer differently, each has its own entries
+		key += " version=" + target.version
+	}
+
+	jx.cache.Serve(w, r, key, forward)
+}
+
+// validateRouteCache checks the cache key and bypass rules of a route.
+func validateRouteCache(config types.RouteCacheConfig) error {
+	if unknown := unknownVariable(config.Key); unknown != "" {
+		return fmt.Errorf("the cache key refers to the unknown variable %q", unknown)
+	}
+	return http_cache.ValidateBypass(config.Bypass)
+}
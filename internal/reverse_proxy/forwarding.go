@@ -0,0 +1,256 @@
+// File: forwarding.go
+// Package: reverse_proxy
+
+// Program Description:
+// This file implements the forwarding headers telling upstreams about the
+// client of a request, X-Forwarded-For, X-Forwarded-Host, X-Forwarded-Proto,
+// X-Real-IP and Forwarded, together with the header rules of routes. Values
+// of forwarding headers sent by the client are only honored when the client
+// is a trusted proxy
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package reverse_proxy
+
+import (
+	"context"
+	"fmt"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// hopHeaders are the hop-by-hop headers that are not forwarded to upstreams.
+var hopHeaders = []string{"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// headerVariables are the variables header rules may refer to.
+var headerVariables = []string{"client_ip", "remote_addr", "request_id", "host", "scheme", "method", "path", "query", "upstream"}
+
+// isTrusted reports whether the peer of r is a trusted proxy, whose forwarding headers are honored.
+func (jx *JinxReverseProxyServer) isTrusted(r *http.Request) bool {
+	ip := net.ParseIP(clientIP(r))
+	if ip == nil {
+		return false
+	}
+	return jx.trustedIP(ip)
+}
+
+func (jx *JinxReverseProxyServer) trustedIP(ip net.IP) bool {
+	return helper.IsTrustedIP(ip, jx.trustedProxies)
+}
+
+// realClientIP returns the IP address of the client of r, see helper.RealClientIP.
+func (jx *JinxReverseProxyServer) realClientIP(r *http.Request) string {
+	return helper.RealClientIP(r, jx.trustedProxies)
+}
+
+// assignRequestID sets the request ID of r, which is kept when a trusted proxy already assigned one. The ID is
+// forwarded to the upstream and returned to the client in the X-Request-Id header.
+func (jx *JinxReverseProxyServer) assignRequestID(w http.ResponseWriter, r *http.Request) string {
+	id := r.Header.Get(constant.REQUEST_ID_HEADER)
+	if id == "" || !jx.isTrusted(r) {
+		id = helper.NewRequestID()
+		r.Header.Set(constant.REQUEST_ID_HEADER, id)
+	}
+	w.Header().Set(constant.REQUEST_ID_HEADER, id)
+	return id
+}
+
+// setForwardingHeaders sets the forwarding headers of the request out sent to the upstream. Values sent by an
+// untrusted client are replaced, those of a trusted proxy are kept and extended. X-Forwarded-For is completed with
+// the address of the peer by httputil.ReverseProxy.
+//
+// Parameters:
+//   - out: The request sent to the upstream, before its Host is rewritten.
+//   - realIP: The IP address of the client, see realClientIP.
+func (jx *JinxReverseProxyServer) setForwardingHeaders(out *http.Request, realIP string) {
+	trusted := jx.isTrusted(out)
+
+	scheme := "http"
+	if out.TLS != nil {
+		scheme = "https"
+	}
+
+	if !trusted {
+		out.Header.Del("X-Forwarded-For")
+		out.Header.Del("X-Forwarded-Host")
+		out.Header.Del("X-Forwarded-Proto")
+		out.Header.Del("Forwarded")
+	}
+	if out.Header.Get("X-Forwarded-Host") == "" {
+		out.Header.Set("X-Forwarded-Host", out.Host)
+	}
+	if out.Header.Get("X-Forwarded-Proto") == "" {
+		out.Header.Set("X-Forwarded-Proto", scheme)
+	}
+	out.Header.Set("X-Real-IP", realIP)
+
+	// RFC 7239: every proxy adds an element naming the address it received the request from
+	element := fmt.Sprintf("for=%s;host=%s;proto=%s", forwardedNode(clientIP(out)), quoteForwarded(out.Host), scheme)
+	if previous := strings.Join(out.Header.Values("Forwarded"), ", "); previous != "" {
+		element = previous + ", " + element
+	}
+	out.Header.Set("Forwarded", element)
+}
+
+// newUpstreamRequest returns the request sent to the upstream base for the request r of the client, for requests
+// not sent through httputil.ReverseProxy. Like the requests it sends, it carries the forwarding headers, the path
+// rewritten by the route of target and the request headers of the route. Hop-by-hop headers are removed and the
+// body of r is shared with the returned request.
+//
+// Parameters:
+//   - ctx: The context of the returned request.
+//   - r: The *http.Request of the client.
+//   - base: The URL of the upstream, its path is the base of the path sent to it.
+//   - target: The upstream the route of r resolved to.
+func (jx *JinxReverseProxyServer) newUpstreamRequest(ctx context.Context, r *http.Request, base *url.URL, target upstreamTarget) *http.Request {
+	realIP := jx.realClientIP(r)
+	vars := requestVariables(r, realIP, base.String())
+
+	out := r.Clone(ctx)
+	out.RequestURI = ""
+	for _, name := range hopHeaders {
+		out.Header.Del(name)
+	}
+
+	jx.setForwardingHeaders(out, realIP)
+	forwardedFor := clientIP(r)
+	if previous := strings.Join(out.Header.Values("X-Forwarded-For"), ", "); previous != "" {
+		forwardedFor = previous + ", " + forwardedFor
+	}
+	out.Header.Set("X-Forwarded-For", forwardedFor)
+
+	out.URL.Scheme = base.Scheme
+	out.URL.Host = base.Host
+	if !jx.config.PreserveHost {
+		out.Host = base.Host
+	}
+	if target.rewriter != nil {
+		target.rewriter.rewrite(out.URL)
+	}
+	setEscapedPath(out.URL, helper.SingleJoiningSlash(base.EscapedPath(), out.URL.EscapedPath()))
+
+	applyHeaderRules(out.Header, target.route.RequestHeaders, vars)
+	return out
+}
+
+// forwardedNode formats an IP address as a node of the Forwarded header, IPv6 addresses are bracketed and quoted.
+func forwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
+
+// quoteForwarded quotes value when it is not a valid token of the Forwarded header, such as a host with a port.
+func quoteForwarded(value string) string {
+	for _, c := range value {
+		if !isTokenChar(c) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+	}
+	return value
+}
+
+// requestVariables returns the values of the variables of header rules for r, see types.HeaderRules.
+func requestVariables(r *http.Request, realIP string, upstreamURL string) map[string]string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return map[string]string{
+		"client_ip":   realIP,
+		"remote_addr": r.RemoteAddr,
+		"request_id":  r.Header.Get(constant.REQUEST_ID_HEADER),
+		"host":        r.Host,
+		"scheme":      scheme,
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"query":       r.URL.RawQuery,
+		"upstream":    upstreamURL,
+	}
+}
+
+// applyHeaderRules removes, sets and adds the headers of rules to header, with the variables in their values
+// replaced by those of vars.
+func applyHeaderRules(header http.Header, rules types.HeaderRules, vars map[string]string) {
+	for _, name := range rules.Remove {
+		header.Del(name)
+	}
+	for name, value := range rules.Set {
+		header.Set(name, expandVariables(value, vars))
+	}
+	for name, value := range rules.Add {
+		header.Add(name, expandVariables(value, vars))
+	}
+}
+
+// expandVariables replaces the variables in value by those of vars.
+func expandVariables(value string, vars map[string]string) string {
+	return os.Expand(value, func(name string) string {
+		return vars[name]
+	})
+}
+
+// unknownVariable returns the first variable value refers to that is not one of the request variables, or an empty
+// string when there is none.
+func unknownVariable(value string) string {
+	var unknown string
+	os.Expand(value, func(variable string) string {
+		if unknown == "" && !slices.Contains(headerVariables, variable) {
+			unknown = variable
+		}
+		return ""
+	})
+	return unknown
+}
+
+// validateHeaderRules checks that the header names of rules are valid and that their values only refer to known
+// variables.
+func validateHeaderRules(rules types.HeaderRules) error {
+	for _, name := range rules.Remove {
+		if !isToken(name) {
+			return fmt.Errorf("%q is not a valid header name", name)
+		}
+	}
+
+	for _, values := range []map[string]string{rules.Set, rules.Add} {
+		for name, value := range values {
+			if !isToken(name) {
+				return fmt.Errorf("%q is not a valid header name", name)
+			}
+
+			if unknown := unknownVariable(value); unknown != "" {
+				return fmt.Errorf("header %s refers to the unknown variable %q", name, unknown)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isToken reports whether value is a token as defined by RFC 9110, which header names are.
+func isToken(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, c := range value {
+		if !isTokenChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTokenChar(c rune) bool {
+	return c < 0x7f && (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", c))
+}
@@ -13,10 +13,19 @@
 package reverse_proxy
 
 import (
-	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"jinx/internal/http_cache"
+	"jinx/internal/jinx_http"
+	"jinx/internal/jinx_tls"
+	"jinx/internal/listener"
+	"jinx/internal/rate_limit"
+	"jinx/internal/upstream"
+	"jinx/internal/upstream_error"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/error_handler"
 	"jinx/pkg/util/helper"
 	"jinx/pkg/util/types"
 	"log"
@@ -27,18 +36,40 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 type JinxReverseProxyServer struct {
-	config           types.JinxReverseProxyServerConfig
-	errorLogger      *slog.Logger
-	serverLogger     *slog.Logger
-	serverWorkingDir string
-	serverInstance   *http.Server
+	config               types.JinxReverseProxyServerConfig
+	errorLogger          *slog.Logger
+	serverLogger         *slog.Logger
+	serverWorkingDir     string
+	serverInstance       *http.Server
+	certificates         *jinx_tls.CertificateStore
+	challengeServer      *http.Server
+	redirectServer       *http.Server
+	stopCertificateWatch func()
+	router               atomic.Pointer[Router] // swapped when Reload reads the route file again
+	upstreamGroups       map[string]*upstream.Group
+	stopHealthChecks     []func()
+	adminServer          *http.Server
+	trustedProxies       []*net.IPNet
+	cache                *http_cache.Cache
+	limiter              *rate_limit.Limiter
+	webSockets           atomic.Int64               // open WebSocket connections
+	pool                 *connectionPool            // shared by the routes with a single upstream and no TLS settings
+	groupPools           map[string]*connectionPool // by upstream group
+	pendingMirrors       chan struct{}              // copies of mirrored requests in flight
+	errorPages           *upstream_error.Pages
 }
 
 // NewJinxReverseProxyServer initializes a new instance of JinxReverseProxyServer with the provided configuration
@@ -73,13 +104,79 @@ func NewJinxReverseProxyServer(config types.JinxReverseProxyServerConfig, server
 		log.Fatal(logFileErr)
 	}
 
-	return &JinxReverseProxyServer{
+	router, upstreamGroups, routerErr := NewRouting(append(RoutesFromTable(config.RouteTable), config.Routes...), config.UpstreamGroups)
+	if routerErr != nil {
+		log.Fatal(routerErr)
+	}
+
+	if poolErr := helper.ValidateConnectionPoolConfig(config.ConnectionPool); poolErr != nil {
+		log.Fatal(poolErr)
+	}
+	router.newConnectionPools(config.ConnectionPool)
+
+	trustedProxies, trustedProxiesErr := helper.ParseTrustedProxies(config.TrustedProxies)
+	if trustedProxiesErr != nil {
+		log.Fatal(trustedProxiesErr)
+	}
+
+	var cache *http_cache.Cache
+	if config.Cache.MaxSize > 0 {
+		cacheDir := config.Cache.Dir
+		if cacheDir == "" {
+			cacheDir = filepath.Join(serverWorkingDir, constant.DEFAULT_CACHE_DIR)
+		}
+
+		var cacheErr error
+		cache, cacheErr = http_cache.New(config.Cache, cacheDir)
+		if cacheErr != nil {
+			log.Fatal(cacheErr)
+		}
+	}
+
+	errorLogger := slog.New(slog.NewJSONHandler(errorLogFile, nil))
+	serverLogger := slog.New(slog.NewJSONHandler(serverLogFile, nil))
+	groupPools := make(map[string]*connectionPool, len(upstreamGroups))
+	for name, group := range upstreamGroups {
+		group.SetLoggers(serverLogger, errorLogger)
+
+		poolConfig := config.ConnectionPool
+		if groupPoolConfig := config.UpstreamGroups[name].ConnectionPool; groupPoolConfig != nil {
+			poolConfig = *groupPoolConfig
+		}
+		groupPools[name] = newConnectionPool("upstream group "+name, poolConfig, group.TLSConfig())
+	}
+
+	if webSocketErr := helper.ValidateWebSocketConfig(config.WebSocket); webSocketErr != nil {
+		log.Fatal(webSocketErr)
+	}
+
+	limiter, limiterErr := rate_limit.New(config.RateLimit, trustedProxies, serverLogger)
+	if limiterErr != nil {
+		log.Fatal(limiterErr)
+	}
+
+	errorPages, errorPagesErr := upstream_error.NewPages(config.ErrorPages)
+	if errorPagesErr != nil {
+		log.Fatal(errorPagesErr)
+	}
+
+	jx := &JinxReverseProxyServer{
 		config:           config,
-		errorLogger:      slog.New(slog.NewJSONHandler(errorLogFile, nil)),
-		serverLogger:     slog.New(slog.NewJSONHandler(serverLogFile, nil)),
+		errorLogger:      errorLogger,
+		serverLogger:     serverLogger,
 		serverWorkingDir: serverWorkingDir,
 		serverInstance:   nil,
+		upstreamGroups:   upstreamGroups,
+		trustedProxies:   trustedProxies,
+		cache:            cache,
+		limiter:          limiter,
+		pool:             newConnectionPool(defaultPoolName, config.ConnectionPool, nil),
+		groupPools:       groupPools,
+		pendingMirrors:   make(chan struct{}, maxPendingMirrors),
+		errorPages:       errorPages,
 	}
+	jx.router.Store(router)
+	return jx
 }
 
 // Start initiates the JinxReverseProxyServer, making it ready to handle incoming HTTP or HTTPS requests
@@ -121,14 +218,7 @@ func NewJinxReverseProxyServer(config types.JinxReverseProxyServerConfig, server
 func (jx *JinxReverseProxyServer) Start() types.JinxServer {
 	addr := fmt.Sprintf("%s:%d", jx.config.IP, jx.config.Port)
 
-	s := &http.Server{
-		Addr:           addr,
-		Handler:        jx,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
-	}
-
+	s := jx.newServer(addr)
 	jx.serverInstance = s
 
 	// Set up a channel to listen for interrupt or termination signals
@@ -140,21 +230,24 @@ func (jx *JinxReverseProxyServer) Start() types.JinxServer {
 		sig := <-signalChan
 		jx.serverLogger.Info(fmt.Sprintf("Received signal %v: shutting down server...", sig))
 
-		// Create a context with a timeout to tell the server how long to wait for existing requests to finish
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-		defer cancel()
+		// Stop also shuts down the listeners started alongside the server, such as the HTTPS redirect
+		jx.Stop()
+	}()
 
-		// Attempt to gracefully shut down the server
-		if err := s.Shutdown(ctx); err != nil {
-			jx.errorLogger.Error(fmt.Sprintf("Server shutdown error: %s", err))
+	// Reload the certificates when the reload command sends SIGHUP
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			jx.Reload()
 		}
-
-		jx.serverLogger.Info(fmt.Sprintf("Successfully shutdown server"))
 	}()
 
-	if jx.config.CertFile != "" && jx.config.KeyFile != "" {
+	jx.startUpstreamServices()
+
+	if jinx_tls.IsEnabled(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME) {
 		jx.serverLogger.Info(fmt.Sprintf("Starting Jinx Reverse Proxy Sever on %s using HTTPS Protocol", addr))
-		err := s.ListenAndServeTLS(jx.config.CertFile, jx.config.KeyFile)
+		err := jx.listenAndServeTLS(s)
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
 			log.Fatal(err)
@@ -163,7 +256,7 @@ func (jx *JinxReverseProxyServer) Start() types.JinxServer {
 	}
 
 	jx.serverLogger.Info(fmt.Sprintf("Starting Jinx Reverse Proxy Sever on %s using HTTP Protocol", addr))
-	err := s.ListenAndServe()
+	err := jx.listenAndServe(s)
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
 		log.Fatal(err)
@@ -204,6 +297,33 @@ func (jx *JinxReverseProxyServer) Stop() {
 		jx.errorLogger.Error(fmt.Sprintf("Server shutdown error: %s", err))
 	}
 
+	if jx.challengeServer != nil {
+		_ = jx.challengeServer.Shutdown(ctx)
+		jx.challengeServer = nil
+	}
+
+	if jx.redirectServer != nil {
+		_ = jx.redirectServer.Shutdown(ctx)
+		jx.redirectServer = nil
+	}
+
+	if jx.stopCertificateWatch != nil {
+		jx.stopCertificateWatch()
+		jx.stopCertificateWatch = nil
+	}
+	// A restart loads the certificates again, together with the challenge listener stopped above
+	jx.certificates = nil
+
+	for _, stop := range jx.stopHealthChecks {
+		stop()
+	}
+	jx.stopHealthChecks = nil
+
+	if jx.adminServer != nil {
+		_ = jx.adminServer.Shutdown(ctx)
+		jx.adminServer = nil
+	}
+
 	jx.serverLogger.Info(fmt.Sprintf("Successfully shutdown server manually"))
 }
 
@@ -239,9 +359,11 @@ func (jx *JinxReverseProxyServer) Restart() types.JinxServer {
 	}
 
 	jx.Stop()
+	jx.serverInstance = jx.newServer(jx.serverInstance.Addr)
+	jx.startUpstreamServices()
 	go func() {
-		if jx.config.CertFile != "" && jx.config.KeyFile != "" {
-			err := jx.serverInstance.ListenAndServeTLS(jx.config.CertFile, jx.config.KeyFile)
+		if jinx_tls.IsEnabled(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME) {
+			err := jx.listenAndServeTLS(jx.serverInstance)
 			if err != nil && !errors.Is(err, http.ErrServerClosed) {
 				jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
 				log.Fatal(err)
@@ -250,7 +372,7 @@ func (jx *JinxReverseProxyServer) Restart() types.JinxServer {
 		}
 
 		// Start the server
-		err := jx.serverInstance.ListenAndServe()
+		err := jx.listenAndServe(jx.serverInstance)
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			jx.errorLogger.Error(fmt.Sprintf("Failed to start server: %s", err.Error()))
 			log.Fatal(err)
@@ -260,6 +382,210 @@ func (jx *JinxReverseProxyServer) Restart() types.JinxServer {
 	return jx
 }
 
+// newServer returns the http.Server of the proxy listening on addr, with the connection limits of the
+// configuration applied. Start and Restart both build their server here so that a restarted server keeps every
+// setting of the first one.
+func (jx *JinxReverseProxyServer) newServer(addr string) *http.Server {
+	s := listener.NewHttpServer(addr, jx, jx.config.Limits)
+	if jx.config.H2C {
+		// Cleartext connections starting with the HTTP/2 preface are served over HTTP/2
+		s.Handler = h2c.NewHandler(s.Handler, &http2.Server{IdleTimeout: s.IdleTimeout})
+	}
+	return s
+}
+
+// startUpstreamServices starts the health checks of the upstream groups and the admin listener, which Stop shuts
+// down. Start and Restart both call it, so that a restarted server keeps checking its unhealthy members and keeps
+// reporting its groups, circuit breakers and connection pools.
+func (jx *JinxReverseProxyServer) startUpstreamServices() {
+	// Unhealthy members are taken out of their upstream group until they pass their checks again
+	for _, group := range jx.upstreamGroups {
+		jx.stopHealthChecks = append(jx.stopHealthChecks, group.StartHealthChecks(jx.serverLogger, jx.errorLogger))
+	}
+
+	groups := make([]*upstream.Group, 0, len(jx.upstreamGroups))
+	for _, group := range jx.upstreamGroups {
+		groups = append(groups, group)
+	}
+	adminServer, adminErr := upstream.StartAdmin(jx.config.Admin, groups, jx.ConnectionPools, jx.errorLogger)
+	if adminErr != nil {
+		jx.errorLogger.Error(adminErr.Error())
+		log.Fatal(adminErr)
+	}
+	jx.adminServer = adminServer
+}
+
+// listenAndServe binds the server address with the configured connection limits applied and serves plain
+// HTTP on it. Connections refused because of the per IP limit receive a 503 Service Unavailable response.
+// The additional listeners of the server are served alongside, see serveListeners.
+func (jx *JinxReverseProxyServer) listenAndServe(s *http.Server) error {
+	l, err := listener.Listen(s.Addr, jx.config.Limits, listener.RejectWithServiceUnavailable)
+	if err != nil {
+		return err
+	}
+
+	if err := jx.serveListeners(s); err != nil {
+		_ = l.Close()
+		return err
+	}
+	jx.watchCertificates()
+	return s.Serve(l)
+}
+
+// listenAndServeTLS loads the certificate store of the server, binds the server address with the configured
+// connection limits applied and serves HTTPS on it under the TLS policy of the server. The additional listeners
+// of the server are served alongside, see serveListeners.
+func (jx *JinxReverseProxyServer) listenAndServeTLS(s *http.Server) error {
+	certificates, err := jx.loadCertificates()
+	if err != nil {
+		return err
+	}
+
+	l, err := listener.Listen(s.Addr, jx.config.Limits, nil)
+	if err != nil {
+		return err
+	}
+
+	// The certificate is picked from the store during the handshake, based on the server name sent by the client
+	tlsConfig, err := certificates.NewTLSConfig(jx.config.TLSPolicy, listener.HTTPProtocols)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	s.TLSConfig = tlsConfig
+
+	if err := jx.serveListeners(s); err != nil {
+		_ = l.Close()
+		return err
+	}
+	jx.watchCertificates()
+	return s.Serve(tls.NewListener(l, tlsConfig))
+}
+
+// loadCertificates loads the certificate store and the client verifier of the server. When an ACME HTTP-01
+// challenge port is configured, a plain HTTP listener answering the challenges is started alongside. The store is
+// only loaded once, later calls return the loaded store.
+func (jx *JinxReverseProxyServer) loadCertificates() (*jinx_tls.CertificateStore, error) {
+	if jx.certificates != nil {
+		return jx.certificates, nil
+	}
+
+	certificates, err := jinx_tls.NewServerCertificateStore(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME)
+	if err != nil {
+		return nil, err
+	}
+	clientVerifier, err := jinx_tls.NewClientVerifier(jx.config.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	certificates.SetClientVerifier(clientVerifier)
+
+	jx.certificates = certificates
+
+	if jx.config.ACME.HTTPChallengePort != 0 {
+		challengeAddr := fmt.Sprintf("%s:%d", jx.config.IP, jx.config.ACME.HTTPChallengePort)
+		jx.challengeServer = certificates.StartHTTPChallengeServer(challengeAddr, jx.config.Limits, jx.errorLogger)
+	}
+
+	return certificates, nil
+}
+
+// serveListeners serves the additional listeners of the server next to its main address. TLS listeners share
+// the certificate store of the server and use their own TLS policy, or the one of the server when they have none. Plain listeners with RedirectToHTTPS set redirect every request to the
+// HTTPS listener, except for ACME HTTP-01 challenges and the exempt paths of the HTTPSRedirect configuration
+// which are answered over plain HTTP.
+func (jx *JinxReverseProxyServer) serveListeners(s *http.Server) error {
+	if len(jx.config.Listeners) == 0 {
+		return nil
+	}
+
+	if listener.AnyTLS(jx.config.Listeners) {
+		if _, err := jx.loadCertificates(); err != nil {
+			return err
+		}
+	}
+
+	if listener.AnyRedirect(jx.config.Listeners) {
+		tlsEnabled := jinx_tls.IsEnabled(jx.config.CertFile, jx.config.KeyFile, jx.config.Certificates, jx.config.ACME)
+		httpsPort := listener.HTTPSPort(jx.config.HTTPSRedirect, jx.config.Port, tlsEnabled, jx.config.Listeners)
+
+		redirect := listener.RedirectToHTTPS(httpsPort, jx.config.HTTPSRedirect.ExemptPaths, jx)
+		if jx.certificates != nil {
+			redirect = jx.certificates.HTTPChallengeHandler(redirect)
+		}
+		jx.redirectServer = listener.NewHttpServer("", redirect, jx.config.Limits)
+	}
+
+	for _, config := range jx.config.Listeners {
+		jx.serverLogger.Info(fmt.Sprintf("Listening on %s (TLS=%t, RedirectToHTTPS=%t)", listener.Address(config, jx.config.IP), config.TLS, config.RedirectToHTTPS))
+	}
+
+	tlsConfig := func(config types.ListenerConfig) (*tls.Config, error) {
+		return jx.certificates.NewTLSConfig(listener.TLSPolicy(config, jx.config.TLSPolicy), listener.HTTPProtocols)
+	}
+
+	return listener.ServeListeners(s, jx.redirectServer, jx.config.Listeners, jx.config.IP, jx.config.Limits, tlsConfig, jx.errorLogger)
+}
+
+// watchCertificates starts reloading the certificates, revocation lists and session ticket keys of the server
+// when their files change. It is called once every listener has been set up so that all their files are watched.
+func (jx *JinxReverseProxyServer) watchCertificates() {
+	if jx.certificates == nil || jx.stopCertificateWatch != nil {
+		return
+	}
+	jx.stopCertificateWatch = jx.certificates.StartWatching(jx.config.CertificateReload, jx.serverLogger, jx.errorLogger)
+}
+
+// Reload re-reads the route file and the certificate and key files of the server. The routes of the route file
+// replace the live routes, so that traffic splits and other route settings can be changed without a restart,
+// see reloadRoutes. Certificates that changed are swapped into the live TLS configuration. New handshakes use
+// the new certificates while established connections are left untouched. A certificate whose new files cannot
+// be loaded is reported as a JinxError and kept in use. Reload is triggered by the reload command, which sends
+// SIGHUP to the running server.
+func (jx *JinxReverseProxyServer) Reload() {
+	jx.reloadRoutes()
+
+	if jx.certificates == nil {
+		return
+	}
+
+	jx.certificates.ReloadAndReport(jx.serverLogger, jx.errorLogger)
+}
+
+// reloadRoutes reads the route file again and swaps its routes in for the requests that follow. Requests in flight
+// finish with the routes they were matched with. Invalid route files are reported as a JinxError and the routes in
+// use are kept. Upstream groups are created when the server starts, changes to them take effect after a restart.
+func (jx *JinxReverseProxyServer) reloadRoutes() {
+	if jx.config.RouteFile == "" {
+		return
+	}
+
+	routingConfig, err := LoadRoutingConfig(jx.config.RouteFile)
+	if err != nil {
+		jx.errorLogger.Error(error_handler.NewJinxError(constant.ERR_INVALID_ROUTE_TABLE, err).Error())
+		return
+	}
+
+	routes := append(RoutesFromTable(jx.config.RouteTable), routingConfig.Routes...)
+	router, err := NewRouter(routes)
+	if err == nil {
+		err = checkUpstreamGroups(routes, jx.upstreamGroups)
+	}
+	if err != nil {
+		jx.errorLogger.Error(error_handler.NewJinxError(constant.ERR_INVALID_ROUTE_TABLE, err).Error())
+		return
+	}
+
+	if !reflect.DeepEqual(routingConfig.UpstreamGroups, jx.config.UpstreamGroups) {
+		jx.serverLogger.Info("The upstream groups of the route file changed, the changes take effect after a restart")
+	}
+	router.newConnectionPools(jx.config.ConnectionPool)
+	for _, pool := range jx.router.Swap(router).connectionPools() {
+		pool.closeIdleConnections()
+	}
+	jx.serverLogger.Info(fmt.Sprintf("Reloaded %d routes from %s", len(routes), jx.config.RouteFile))
+}
+
 // Destroy performs a complete teardown of the JinxHttpServer instance, effectively stopping the server
 // and removing its working directory and all contained data. This method first checks if the server instance
 // (`serverInstance`) is currently running; if it is not, the method returns immediately, as there is no server
@@ -308,7 +634,8 @@ func (jx *JinxReverseProxyServer) Destroy() {
 // Workflow:
 //  1. Logs the initiation of request handling to the specified upstream URL.
 //  2. Creates a new httputil.ReverseProxy instance with a Director function that modifies the request to point to the upstream service.
-//  3. Sets a custom ErrorHandler on the proxy to log any errors that occur during the request forwarding.
+//  3. Sets a custom ErrorHandler on the proxy to log any errors that occur during the request forwarding and to
+//     answer with 502 Bad Gateway or 504 Gateway Timeout, see writeUpstreamError.
 //  4. Calls ServeHTTP on the proxy instance to forward the request and handle the response.
 //  5. Logs the completion of request handling.
 //
@@ -324,21 +651,140 @@ func (jx *JinxReverseProxyServer) Destroy() {
 //     or responsiveness of the upstream service; it is the caller's responsibility to ensure that the upstreamURL points
 //     to a valid and available service.
 func (jx *JinxReverseProxyServer) HandleHTTPProxyRequest(w http.ResponseWriter, r *http.Request, upstreamURL string) {
+	_, _ = jx.proxyHTTP(w, r, upstreamURL, nil, nil)
+}
+
+// proxyHTTP implements HandleHTTPProxyRequest and reports whether the upstream failed, that is whether it could
+// not be reached or answered with a server error. Failures count against the circuit breaker of the member.
+//
+// The forwarding headers are set on every request. When target is not nil, the path of the request is rewritten
+// by the rules of its route, whose header rules are applied to the request and to the response. retry, when not
+// nil, is asked whether the response or transport error of the upstream is retried on another member. Nothing is
+// written to w for retried tries, and retried is true. Responses are limited, buffered and flushed according to the
+// body settings of the route, see limitResponseBody. The httputil.ReverseProxy of a request only carries its
+// rewrite rules, the connections to the upstream come from the connection pool of its upstream group or route.
+func (jx *JinxReverseProxyServer) proxyHTTP(w http.ResponseWriter, r *http.Request, upstreamURL string, target *upstreamTarget, retry retryDecision) (failed bool, retried bool) {
 	jx.serverLogger.Info(fmt.Sprintf("Handling %s request...", upstreamURL))
+	start := time.Now()
+
+	realIP := jx.realClientIP(r)
+	vars := requestVariables(r, realIP, upstreamURL)
+	base, _ := url.Parse(upstreamURL)
+
 	proxy := &httputil.ReverseProxy{
+		Transport: jx.transport(target),
 		Director: func(r *http.Request) {
-			target, _ := url.Parse(upstreamURL)
-			r.URL.Scheme = target.Scheme
-			r.URL.Host = target.Host
-			r.Host = target.Host
-			r.URL.Path = helper.SingleJoiningSlash(target.Path, r.URL.Path)
+			jx.setForwardingHeaders(r, realIP)
+
+			r.URL.Scheme = base.Scheme
+			r.URL.Host = base.Host
+			if !jx.config.PreserveHost {
+				r.Host = base.Host
+			}
+			if target != nil && target.rewriter != nil {
+				target.rewriter.rewrite(r.URL)
+			}
+			setEscapedPath(r.URL, helper.SingleJoiningSlash(base.EscapedPath(), r.URL.EscapedPath()))
+
+			if target != nil {
+				applyHeaderRules(r.Header, target.route.RequestHeaders, vars)
+			}
+		},
+		ModifyResponse: func(res *http.Response) error {
+			if target != nil && target.rewriter != nil {
+				target.rewriter.rewriteResponse(res.Header, base)
+			}
+			if target != nil {
+				applyHeaderRules(res.Header, target.route.ResponseHeaders, vars)
+			}
+
+			failed = res.StatusCode >= http.StatusInternalServerError
+			if retry != nil && retry(res, nil) {
+				_ = res.Body.Close()
+				return errRetryableStatus
+			}
+			grpcResponse(r, res)
+			if target != nil {
+				return jx.limitResponseBody(r, res, target.route.Body)
+			}
+			return nil
 		},
 		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
-			jx.errorLogger.Error(err.Error(), err, r)
+			// The client sent a larger body than its route accepts, the upstream is not at fault
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				jx.errorLogger.Error(fmt.Sprintf("Rejected request for %s: body larger than %d bytes", r.URL.Path, tooLarge.Limit))
+				writeError(w, r, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			failed = true
+			// Responses too large for the route are too large for every member of its upstream group
+			if errors.Is(err, errRetryableStatus) || (retry != nil && !errors.Is(err, errResponseTooLarge) && retry(nil, err)) {
+				retried = true
+				return
+			}
+			// Tries cut short by the per-try timeout of their upstream group are canceled with a timeout as cause
+			if cause := context.Cause(r.Context()); errors.Is(err, context.Canceled) && cause != nil {
+				err = cause
+			}
+			jx.writeUpstreamError(w, r, upstreamURL, target, err, start)
 		},
 	}
+	if target != nil {
+		proxy.FlushInterval = flushInterval(target.route.Body)
+	}
+	if isGRPC(r) {
+		// Messages of gRPC streams are passed on as soon as they arrive
+		proxy.FlushInterval = -1
+	}
 	proxy.ServeHTTP(w, r)
 	jx.serverLogger.Info(fmt.Sprintf("Handling %s request completed...", upstreamURL))
+	return failed, retried
+}
+
+// writeUpstreamError answers the request r that could not be forwarded to upstreamURL with the status of the class
+// of err, see upstream_error.Status, and the error page of the status. gRPC requests are answered with a gRPC
+// status instead. The failure is logged with the upstream, the route, the class of the error and the time spent
+// on the request.
+//
+// Parameters:
+//   - w: The http.ResponseWriter of the client, nothing must have been written to it yet.
+//   - r: The *http.Request of the client.
+//   - upstreamURL: The URL of the upstream, the address of the member for upstream groups.
+//   - target: The route of the request, nil when the request was not routed.
+//   - err: The error of the request, or upstream.ErrNoAvailableMember when no member could take it.
+//   - start: When the request to the upstream was started.
+func (jx *JinxReverseProxyServer) writeUpstreamError(w http.ResponseWriter, r *http.Request, upstreamURL string, target *upstreamTarget, err error, start time.Time) {
+	class := upstream_error.Classify(err)
+	if errors.Is(err, upstream.ErrNoAvailableMember) {
+		class = constant.UPSTREAM_ERROR_UNAVAILABLE
+	}
+	status := upstream_error.Status(class)
+
+	var route string
+	if target != nil {
+		route = target.route.Host + target.route.Path
+	}
+	requestID := r.Header.Get(constant.REQUEST_ID_HEADER)
+	jx.errorLogger.Error("Upstream request failed",
+		"upstream", upstreamURL,
+		"route", route,
+		"class", class,
+		"status", status,
+		"duration", time.Since(start),
+		"method", r.Method,
+		"path", r.URL.Path,
+		"request_id", requestID,
+		"error", err.Error(),
+	)
+
+	if isGRPC(r) {
+		writeGRPCStatus(w.Header(), grpcErrorCode(err), "upstream unavailable")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	jx.errorPages.Write(w, status, requestID)
 }
 
 // handleHTTPSProxyRequest manages the forwarding of HTTPS requests through the JinxReverseProxyServer.
@@ -392,6 +838,9 @@ func (jx *JinxReverseProxyServer) handleHTTPSProxyRequest(w http.ResponseWriter,
 		return
 	}
 
+	// The read and write deadlines armed for the HTTP request must not cut the tunnel short
+	_ = clientConn.SetDeadline(time.Time{})
+
 	// Connect to the destination server
 	destConn, err := net.Dial("tcp", r.Host)
 	if err != nil {
@@ -407,141 +856,167 @@ func (jx *JinxReverseProxyServer) handleHTTPSProxyRequest(w http.ResponseWriter,
 	go helper.Transfer(destConn, clientConn)
 }
 
-// handleWebSocketConnect establishes a WebSocket connection through the JinxReverseProxyServer by
-// hijacking the client's HTTP connection and forwarding the WebSocket upgrade request to the
-// destination server. It acts as a transparent intermediary, facilitating WebSocket communication
-// between the client and the destination server without modifying or inspecting the transferred data.
-//
-// This method performs the following steps to establish the WebSocket connection:
-//  1. Hijacks the client's HTTP connection to gain direct control over the underlying TCP connection.
-//  2. Establishes a new TCP connection to the destination server specified in the request's Host header.
-//  3. Forwards the client's WebSocket upgrade request to the destination server and reads the server's
-//     upgrade response.
-//  4. Forwards the destination server's WebSocket upgrade response back to the client, completing the
-//     WebSocket handshake.
-//  5. Initiates bidirectional streaming of WebSocket messages between the client and the destination server.
+// DetermineUpstreamURL analyzes the incoming HTTP request to identify the appropriate upstream URL
+// based on the request's Host header and path. It uses the server's router, built from the routing table and
+// the routes of the route file, to find the destination URL where the request should be forwarded. This
+// method is a key component of the reverse proxy's routing logic, enabling it to dynamically route requests
+// to different backend services based on the host and URL path.
 //
 // Parameters:
-//   - w: The http.ResponseWriter, which allows for hijacking the connection to directly manipulate the TCP socket.
-//   - r: The *http.Request representing the client's request, including the WebSocket upgrade headers.
+//   - r: The *http.Request object representing the client's request. Its Host header and URL path are
+//     matched against the routes of the server.
 //
-// Workflow:
-//   - Checks for hijacking support and hijacks the client's connection. If hijacking is not supported or fails,
-//     an internal server error is returned to the client.
-//   - Connects to the destination server using the address specified in the request's Host header.
-//     If the connection fails, the method returns without further action.
-//   - Forwards the WebSocket upgrade request to the destination server and reads its response.
-//     If forwarding fails or the response cannot be read, an internal server error is returned to the client.
-//   - Forwards the destination server's response back to the client, completing the WebSocket handshake.
-//   - Starts two goroutines to relay WebSocket messages between the client and the destination server,
-//     allowing for full-duplex communication.
+// Returns:
+//   - A string representing the upstream URL to which the request should be forwarded.
+//   - An error if no route matches the request, indicating that there is no configured upstream URL for
+//     the requested host and path.
 //
-// Usage:
-//   - This method is designed to handle WebSocket connections in a reverse proxy setup, enabling real-time
-//     web applications to communicate through the proxy without any modifications to the WebSocket protocol.
-//     It should be called when the proxy server detects a WebSocket upgrade request.
+// Workflow:
+//  1. Cleans the request's URL path with URL semantics, see CleanPath, so that the upstream receives the
+//     path that was matched. Its escaped form is kept when it means the same path, see cleanRequestPath.
+//  2. Selects the route of the request, see Router for the priority between exact, regex and prefix routes
+//     and between host names.
+//  3. For routes whose traffic is split between versions, picks the version of the request, see
+//     types.TrafficSplit. The upstream of the version replaces the upstream of the route.
+//  4. For routes balanced over an upstream group, picks the member of the group with the algorithm of the
+//     group, the client IP being the key of the hashing algorithm.
+//  5. For regex routes whose upstream refers to captures of the expression, the expanded upstream replaces
+//     the path and query of the request and only its scheme and host are returned.
 //
 // Note:
-//   - The "transfer" function referenced in the code is responsible for relaying WebSocket messages between
-//     the client and destination connections. It should efficiently handle message streaming and close both
-//     connections when the WebSocket session ends or an error occurs.
-//   - Proper error handling and resource cleanup are crucial in this method to prevent resource leaks and
-//     ensure the stability and reliability of the proxy server during WebSocket communication.
-func (jx *JinxReverseProxyServer) handleWebSocketConnect(w http.ResponseWriter, r *http.Request) {
-	// Hijack the connection
-	hijacker, ok := w.(http.Hijacker)
+//   - The request is modified in place, the returned URL is meant to be passed to HandleHTTPProxyRequest
+//     together with r.
+func (jx *JinxReverseProxyServer) DetermineUpstreamURL(r *http.Request) (string, error) {
+	target, err := jx.resolveUpstream(r)
+	return target.url, err
+}
+
+// upstreamTarget is where resolveUpstream sends a request.
+type upstreamTarget struct {
+	url         string
+	route       types.Route
+	requestPath string                // the cleaned path of the client, before a regex route replaced it
+	group       *upstream.Group       // nil for routes with a single upstream
+	member      *types.UpstreamMember // the member of group picked for the request
+
+	rewriter *pathRewriter // nil for regex routes
+
+	tlsConfig *tls.Config     // TLS client settings of the upstream, nil for the default settings
+	pool      *connectionPool // connection pool of the upstream, nil for the default pool
+
+	version     string       // the version of the split of the route the request was sent to, empty for its upstream
+	splitCookie *http.Cookie // the cookie assigning a new client to a version, set on the response
+
+	static *jinx_http.StaticFiles // files the request is answered from instead of an upstream, nil for forwarded routes
+}
+
+// resolveUpstream implements DetermineUpstreamURL and also returns the route of the request and, for routes
+// balanced over an upstream group, the group and the member of it the request is sent to.
+func (jx *JinxReverseProxyServer) resolveUpstream(r *http.Request) (upstreamTarget, error) {
+	cleanRequestPath(r.URL)
+
+	match, ok := jx.router.Load().match(r.Host, r.URL.Path, r.URL.EscapedPath())
 	if !ok {
-		http.Error(w, "HTTP Server does not support hijacking", http.StatusInternalServerError)
-		return
+		msg := fmt.Sprintf("no route for %s%s", r.Host, r.URL.Path)
+		return upstreamTarget{}, errors.New(msg)
 	}
 
-	clientConn, _, err := hijacker.Hijack()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer func(clientConn net.Conn) {
-		_ = clientConn.Close()
-	}(clientConn)
+	target := upstreamTarget{url: match.Upstream, route: match.Route, requestPath: r.URL.Path, rewriter: match.rewriter, tlsConfig: match.tlsConfig, pool: match.pool, static: match.static}
+	groupName := match.Route.UpstreamGroup
+	replacePath := match.ReplacePath
 
-	// Connect to the destination server
-	destConn, err := net.Dial("tcp", r.Host)
-	if err != nil {
-		return
+	// Versions of a split take the place of the upstream of the route
+	if match.split != nil {
+		version, cookie := match.split.pick(r, jx.realClientIP(r))
+		target.splitCookie = cookie
+		if version != nil {
+			target.url = version.config.Upstream
+			target.version = version.name
+			groupName = version.config.UpstreamGroup
+			replacePath = false
+		}
 	}
-	defer func(destConn net.Conn) {
-		_ = destConn.Close()
-	}(destConn)
 
-	// Forward the client's WebSocket upgrade request to the destination server
-	err = r.Write(destConn)
-	if err != nil {
-		http.Error(w, "Failed to send WebSocket upgrade request to the destination server", http.StatusInternalServerError)
-		return
+	if groupName != "" {
+		group := jx.upstreamGroups[groupName]
+		member, ok := group.Pick(jx.realClientIP(r))
+		if !ok {
+			// The route is kept for the rate limiter and the log of the rejected request
+			return upstreamTarget{route: match.Route}, fmt.Errorf("upstream group %s: %w", groupName, upstream.ErrNoAvailableMember)
+		}
+		target.url, target.group, target.member = member.Address, group, member
+		// Members of a group are reached with the TLS settings of their group rather than those of the route
+		target.tlsConfig, target.pool = group.TLSConfig(), jx.groupPools[groupName]
+		return target, nil
 	}
 
-	// Read the response from the destination server
-	response, err := http.ReadResponse(bufio.NewReader(destConn), r)
-	if err != nil {
-		http.Error(w, "Failed to read WebSocket upgrade response from the destination server", http.StatusInternalServerError)
-		return
+	if !replacePath {
+		return target, nil
 	}
 
-	// Forward the destination server's response back to the client
-	err = response.Write(clientConn)
+	expanded, err := url.Parse(match.Upstream)
 	if err != nil {
-		http.Error(w, "Failed to send WebSocket upgrade request to the client", http.StatusInternalServerError)
-		return
+		return upstreamTarget{}, fmt.Errorf("invalid upstream %s for %s: %v", match.Upstream, r.URL.Path, err)
+	}
+	setEscapedPath(r.URL, expanded.EscapedPath())
+	// The query of the upstream comes first, the query of the client is kept after it
+	if expanded.RawQuery != "" && r.URL.RawQuery != "" {
+		r.URL.RawQuery = expanded.RawQuery + "&" + r.URL.RawQuery
+	} else if expanded.RawQuery != "" {
+		r.URL.RawQuery = expanded.RawQuery
 	}
 
-	// At this point, the WebSocket handshake is complete, and we can start relaying messages
-	go helper.Transfer(destConn, clientConn)
-	go helper.Transfer(clientConn, destConn)
+	target.url = expanded.Scheme + "://" + expanded.Host
+	return target, nil
 }
 
-// DetermineUpstreamURL analyzes the incoming HTTP request to identify the appropriate upstream URL
-// based on the request's path. It uses the server's routing table, which maps request paths to upstream
-// service URLs, to find the destination URL where the request should be forwarded. This method is a key
-// component of the reverse proxy's routing logic, enabling it to dynamically route requests to different
-// backend services based on the URL path.
+// AuthorizeClient reports whether the client of r may reach the route r is for. Routes listed in the
+// ClientAuthRoutes of the configuration are only reachable with a client certificate that was verified against
+// the client CA bundle during the TLS handshake. When a route lists AllowedNames, the certificate must also carry
+// one of them as its subject common name or as a subject alternative name. Other routes are always reachable.
 //
 // Parameters:
-//   - r: The *http.Request object representing the client's request. The URL path of this request is
-//     used to look up the corresponding upstream URL in the server's routing table.
+//   - r: The *http.Request of the client. Its TLS connection state holds the verified certificate chains.
 //
 // Returns:
-//   - A string representing the upstream URL to which the request should be forwarded. This URL is
-//     retrieved from the server's routing table based on the request's path.
-//   - An error if the request's path does not match any entry in the routing table, indicating that
-//     there is no configured upstream URL for the requested path. The error message includes the
-//     requested path to aid in debugging and configuration adjustments.
-//
-// Workflow:
-//  1. Cleans the request's URL path to ensure a standard, predictable format for lookup.
-//  2. Looks up the cleaned path in the server's routing table to find the corresponding upstream URL.
-//  3. If the path exists in the routing table, returns the mapped upstream URL.
-//  4. If the path does not exist in the routing table, returns an error indicating that the requested
-//     path is not configured for forwarding, suggesting a potential misconfiguration or an unsupported request.
-//
-// Usage:
-//   - This method should be called as part of the request handling process in the JinxReverseProxyServer
-//     to determine the destination for each incoming request. It allows the reverse proxy to support
-//     multiple backend services by routing requests to the appropriate service based on the request path.
-//
-// Note:
-//   - The routing table (`jx.config.RouteTable`) must be properly configured before starting the server
-//     to ensure that all expected paths are mapped to their respective upstream URLs. The absence of a
-//     path in the routing table will result in an error, preventing the request from being forwarded
-func (jx *JinxReverseProxyServer) DetermineUpstreamURL(r *http.Request) (string, error) {
-	path := filepath.Clean(r.URL.Path)
+//   - True if the request may be forwarded, false if it must be rejected with 403 Forbidden.
+func (jx *JinxReverseProxyServer) AuthorizeClient(r *http.Request) bool {
+	return jx.authorizeClient(r, r.URL.Path)
+}
 
-	upStreamUrl, ok := jx.config.RouteTable[path]
-	if !ok {
-		msg := fmt.Sprintf("%s does not exist in route table:", path)
-		return "", errors.New(msg)
+// authorizeClient implements AuthorizeClient for the path requestPath, which ServeHTTP passes as the client
+// requested it, so that regex routes replacing the request path cannot bypass the ClientAuthRoutes.
+func (jx *JinxReverseProxyServer) authorizeClient(r *http.Request, requestPath string) bool {
+	requestPath = path.Clean("/" + requestPath)
+
+	for _, route := range jx.config.ClientAuthRoutes {
+		routePath := strings.TrimSuffix(route.Path, "/")
+		if requestPath != routePath && !strings.HasPrefix(requestPath, routePath+"/") {
+			continue
+		}
+
+		certificate := jinx_tls.VerifiedClientCertificate(r.TLS)
+		if certificate == nil {
+			return false
+		}
+
+		if len(route.AllowedNames) == 0 {
+			continue
+		}
+
+		allowed := false
+		for _, name := range route.AllowedNames {
+			if jinx_tls.CertificateHasName(certificate, name) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
 	}
 
-	return upStreamUrl, nil
+	return true
 }
 
 // ServeHTTP is the core request handler for the JinxReverseProxyServer, implementing the http.Handler
@@ -560,14 +1035,18 @@ func (jx *JinxReverseProxyServer) DetermineUpstreamURL(r *http.Request) (string,
 // Workflow:
 //  1. Logs the incoming request, including its method, URL, and the client's remote address, for debugging
 //     and monitoring purposes.
-//  2. Determines the upstream URL by matching the request's path against the server's routing table. If no
-//     match is found, responds with a 404 error.
-//  3. For HTTPS CONNECT requests, invokes the handleHTTPSProxyRequest method to establish a tunnel between
+//  2. Determines the upstream URL by matching the request's host and path against the server's routes. If no
+//     route matches, responds with a 404 error, and with a 503 error when every member of the upstream group
+//     of the route is unhealthy.
+//  3. Rejects the request with 403 if the route requires a client certificate the client did not present, see
+//     AuthorizeClient, and forwards the details of the verified client certificate as X-Client-* headers.
+//  4. For HTTPS CONNECT requests, invokes the handleHTTPSProxyRequest method to establish a tunnel between
 //     the client and the destination server.
-//  4. For WebSocket connection requests, identified by the "Upgrade: websocket" header, invokes the
-//     handleWebSocketConnect method to facilitate the WebSocket handshake and data transfer.
-//  5. For all other HTTP requests, forwards the request to the determined upstream URL using the
-//     HandleHTTPProxyRequest method.
+//  5. For WebSocket connection requests, identified by the "Upgrade: websocket" header, invokes the
+//     handleWebSocketConnect method to pass the upgrade to the upstream of the route and relay the connection.
+//  6. For all other HTTP requests, forwards the request to the determined upstream URL using the
+//     HandleHTTPProxyRequest method. Routes with shadow upstreams also receive a copy, see mirror. Bodies larger
+//     than the limit of the route are rejected with 413 and buffered bodies are read first, see limitRequestBody.
 //
 // Usage:
 //   - This method is automatically called by the Go HTTP server infrastructure for each incoming request
@@ -584,15 +1063,46 @@ func (jx *JinxReverseProxyServer) DetermineUpstreamURL(r *http.Request) (string,
 func (jx *JinxReverseProxyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	jx.serverLogger.Info(fmt.Sprintf("Received request: Method=%s, URL=%s, RemoteAddr=%s", r.Method, r.URL.String(), r.RemoteAddr))
 
+	jx.assignRequestID(w, r)
+
 	// Example: Determine the upstream URL based on the request
-	upstreamURL, err := jx.DetermineUpstreamURL(r)
+	target, err := jx.resolveUpstream(r)
+	if !jx.limiter.Allow(w, r, target.route.Path) {
+		return
+	}
+	if errors.Is(err, upstream.ErrNoAvailableMember) {
+		jx.writeUpstreamError(w, r, target.route.UpstreamGroup, &target, err, time.Now())
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), 404)
+		writeError(w, r, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !jx.authorizeClient(r, target.requestPath) {
+		jx.errorLogger.Error(fmt.Sprintf("Rejected request for %s from %s: no acceptable client certificate", r.URL.Path, r.RemoteAddr))
+		writeError(w, r, "Forbidden: a valid client certificate is required", http.StatusForbidden)
 		return
 	}
 
+	// Static locations are answered from their files, next to the routes forwarded to upstreams
+	if target.static != nil {
+		jx.serveStatic(w, r, target)
+		return
+	}
+
+	// Upstream servers learn who the client is from these headers, values sent by the client are never trusted
+	jinx_tls.SetClientCertificateHeaders(r.Header, r.TLS)
+
+	if target.splitCookie != nil {
+		http.SetCookie(w, target.splitCookie)
+	}
+	if target.version != "" {
+		jx.serverLogger.Info(fmt.Sprintf("Sending %s %s to version %s of %s", r.Method, r.URL.Path, target.version, target.route.Path))
+	}
+
 	// Special handling for HTTPS CONNECT requests
 	if r.Method == http.MethodConnect {
+		defer jx.begin(target)(false)
 		jx.handleHTTPSProxyRequest(w, r)
 		return
 	}
@@ -601,11 +1111,38 @@ func (jx *JinxReverseProxyServer) ServeHTTP(w http.ResponseWriter, r *http.Reque
 	connectionHeader := strings.ToLower(r.Header.Get("Connection"))
 
 	if upgradeHeader == "websocket" && strings.Contains(connectionHeader, "upgrade") {
-		jx.handleWebSocketConnect(w, r)
+		done := jx.begin(target)
+		done(jx.handleWebSocketConnect(w, r, target))
+		return
+	}
+
+	// Bodies beyond the limit of the route are rejected, buffered bodies are read before the upstream is contacted
+	release, ok := jx.limitRequestBody(w, r, target)
+	if !ok {
 		return
 	}
+	defer release()
 
-	// Handle HTTP request
-	jx.HandleHTTPProxyRequest(w, r, upstreamURL)
+	// Handle HTTP request, a copy is sent to the shadow upstreams of the route
+	jx.mirror(r, target)
+	jx.serveCached(w, r, target)
 
 }
+
+// forward sends the HTTP request r to the upstream of target, retrying it on other members of its upstream group.
+func (jx *JinxReverseProxyServer) forward(w http.ResponseWriter, r *http.Request, target upstreamTarget) {
+	if target.group == nil {
+		_, _ = jx.proxyHTTP(w, r, target.url, &target, nil)
+		return
+	}
+	jx.proxyToGroup(w, r, target, jx.begin(target))
+}
+
+// begin records the start of a request to the member of target and returns the function recording its outcome,
+// see upstream.Group.Begin. Nothing is recorded for routes with a single upstream.
+func (jx *JinxReverseProxyServer) begin(target upstreamTarget) (done func(failed bool)) {
+	if target.member == nil {
+		return func(failed bool) {}
+	}
+	return target.group.Begin(target.member)
+}
@@ -0,0 +1,174 @@
+// File: mirror.go
+// Package: reverse_proxy
+
+// Program Description:
+// This file implements the request mirroring of routes. A share of the
+// requests of a route is copied to shadow upstreams, bodies included up to a
+// size limit. The copies are sent in the background once the body of the
+// request was received, their responses are discarded and the response to
+// the client never waits for them
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package reverse_proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// maxPendingMirrors bounds the copies in flight, further copies are dropped so that slow shadow upstreams cannot
+// pile up requests in the proxy.
+const maxPendingMirrors = 256
+
+// validateMirror checks the mirroring of a route.
+func validateMirror(config types.MirrorConfig) error {
+	if config.Percentage < 0 || config.Percentage > 100 {
+		return fmt.Errorf("the mirror percentage must be between 0 and 100, got %d", config.Percentage)
+	}
+	if config.MaxBodySize < 0 || config.Timeout < 0 {
+		return fmt.Errorf("the mirror MaxBodySize and Timeout must not be negative")
+	}
+	for _, upstreamURL := range config.Upstreams {
+		u, err := url.Parse(upstreamURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%q is not a valid mirror upstream, expected an http or https URL", upstreamURL)
+		}
+	}
+	return nil
+}
+
+// mirror copies r to the shadow upstreams of its route when it is picked by the mirror percentage. The copies are
+// sent once the body of r was completely read by the primary upstream, right away for requests without a body.
+// Requests whose body is larger than the maximum body size are not mirrored.
+//
+// Parameters:
+//   - r: The *http.Request of the client. Its body is replaced by a reader recording the body for the copies.
+//   - target: The upstream the route of r resolved to.
+func (jx *JinxReverseProxyServer) mirror(r *http.Request, target upstreamTarget) {
+	config := target.route.Mirror
+	if len(config.Upstreams) == 0 {
+		return
+	}
+
+	percentage := config.Percentage
+	if percentage == 0 {
+		percentage = constant.DEFAULT_MIRROR_PERCENTAGE
+	}
+	if rand.Intn(100) >= percentage {
+		return
+	}
+
+	maxBodySize := config.MaxBodySize
+	if maxBodySize == 0 {
+		maxBodySize = constant.DEFAULT_MIRROR_MAX_BODY_SIZE
+	}
+	if r.ContentLength > maxBodySize {
+		return
+	}
+
+	// The copies are prepared now, the request of the client may change once it is forwarded
+	copies := make([]*http.Request, 0, len(config.Upstreams))
+	for _, upstreamURL := range config.Upstreams {
+		base, err := url.Parse(upstreamURL)
+		if err != nil {
+			continue
+		}
+		copies = append(copies, jx.newUpstreamRequest(context.Background(), r, base, target))
+	}
+
+	send := func(body []byte) {
+		for _, out := range copies {
+			jx.sendMirror(out, body, target, config.Timeout)
+		}
+	}
+
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		send(nil)
+		return
+	}
+	r.Body = &mirrorBody{ReadCloser: r.Body, limit: maxBodySize, done: send}
+}
+
+// sendMirror sends the copy out with body to its shadow upstream in the background and discards the response. The
+// copy is dropped when too many copies are in flight already.
+func (jx *JinxReverseProxyServer) sendMirror(out *http.Request, body []byte, target upstreamTarget, timeout int) {
+	select {
+	case jx.pendingMirrors <- struct{}{}:
+	default:
+		jx.serverLogger.Info(fmt.Sprintf("Dropped mirror of %s %s to %s: %d mirrors in flight", out.Method, out.URL.Path, out.URL.Host, maxPendingMirrors))
+		return
+	}
+
+	if timeout == 0 {
+		timeout = constant.DEFAULT_MIRROR_TIMEOUT
+	}
+	transport := jx.transport(&target)
+
+	go func() {
+		defer func() {
+			<-jx.pendingMirrors
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		defer cancel()
+
+		out = out.WithContext(ctx)
+		out.Body = http.NoBody
+		out.ContentLength = 0
+		if len(body) > 0 {
+			out.Body = io.NopCloser(bytes.NewReader(body))
+			out.ContentLength = int64(len(body))
+		}
+		out.Header.Del("Content-Length")
+
+		res, err := transport.RoundTrip(out)
+		if err != nil {
+			jx.serverLogger.Info(fmt.Sprintf("Mirror of %s %s to %s failed: %v", out.Method, out.URL.Path, out.URL.Host, err))
+			return
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+		jx.serverLogger.Info(fmt.Sprintf("Mirror of %s %s to %s answered with status %d", out.Method, out.URL.Path, out.URL.Host, res.StatusCode))
+	}()
+}
+
+// mirrorBody records the body of a mirrored request while the primary upstream reads it and hands it to done once
+// it was read completely. Bodies larger than limit are not recorded and done is never called for them.
+type mirrorBody struct {
+	io.ReadCloser
+	limit    int64
+	done     func(body []byte)
+	buffer   bytes.Buffer
+	exceeded bool
+	once     sync.Once
+}
+
+func (b *mirrorBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.exceeded {
+		if int64(b.buffer.Len()+n) > b.limit {
+			b.exceeded = true
+			b.buffer = bytes.Buffer{}
+		} else {
+			b.buffer.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !b.exceeded {
+		b.once.Do(func() {
+			b.done(b.buffer.Bytes())
+		})
+	}
+	return n, err
+}
@@ -0,0 +1,263 @@
+// File: pool.go
+// Package: reverse_proxy
+
+// Program Description:
+// This file implements the upstream connection pools of the reverse proxy.
+// A pool holds the transports of the upstream protocols for an upstream
+// group, for the routes with a single upstream or for a route with TLS
+// settings, so that connections are reused across requests rather than
+// opened for each of them. Pools count their connections and requests for
+// the admin listener
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package reverse_proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"jinx/internal/listener"
+	"jinx/internal/upstream"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// defaultPoolName is the name of the pool of the routes with a single upstream and no TLS settings.
+const defaultPoolName = "default"
+
+// connectionPool holds the transports of the upstream protocols sharing one set of pool settings and TLS settings.
+type connectionPool struct {
+	name       string
+	transports map[string]http.RoundTripper // by upstream protocol, the empty protocol negotiates HTTP/2 over TLS
+
+	open           atomic.Int64
+	dials          atomic.Int64
+	dialErrors     atomic.Int64
+	activeRequests atomic.Int64
+	requests       atomic.Int64
+	reusedRequests atomic.Int64
+}
+
+// newConnectionPool creates the pool name with the transports of every upstream protocol routes may select.
+//
+// Parameters:
+//   - name: The name of the pool in the admin view, the name of its upstream group or route.
+//   - config: The types.ConnectionPoolConfig of the pool.
+//   - tlsConfig: The TLS client settings of the upstreams, nil for the default settings.
+//
+// Returns:
+//   - The *connectionPool.
+func newConnectionPool(name string, config types.ConnectionPoolConfig, tlsConfig *tls.Config) *connectionPool {
+	pool := &connectionPool{name: name}
+
+	dialer := &net.Dialer{
+		Timeout:   listener.Seconds(config.DialTimeout, constant.DEFAULT_POOL_DIAL_TIMEOUT),
+		KeepAlive: listener.Seconds(config.KeepAlive, constant.DEFAULT_POOL_KEEP_ALIVE),
+	}
+	dial := func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		return pool.dial(ctx, dialer, network, addr)
+	}
+	handshakeTimeout := listener.Seconds(config.TLSHandshakeTimeout, constant.DEFAULT_POOL_TLS_HANDSHAKE_TIMEOUT)
+
+	newTransport := func() *http.Transport {
+		return &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dial,
+			TLSClientConfig:       tlsConfig.Clone(),
+			MaxIdleConns:          valueOrDefault(config.MaxIdleConns, constant.DEFAULT_POOL_MAX_IDLE_CONNS),
+			MaxIdleConnsPerHost:   valueOrDefault(config.MaxIdleConnsPerHost, constant.DEFAULT_POOL_MAX_IDLE_CONNS_PER_HOST),
+			MaxConnsPerHost:       config.MaxConnsPerHost,
+			IdleConnTimeout:       listener.Seconds(config.IdleConnTimeout, constant.DEFAULT_POOL_IDLE_CONN_TIMEOUT),
+			TLSHandshakeTimeout:   handshakeTimeout,
+			ResponseHeaderTimeout: time.Duration(config.ResponseHeaderTimeout) * time.Second,
+			ExpectContinueTimeout: time.Second,
+			DisableKeepAlives:     config.DisableKeepAlives,
+		}
+	}
+
+	negotiated := newTransport()
+	negotiated.ForceAttemptHTTP2 = !config.DisableHTTP2
+	http1 := newTransport()
+	// A non-nil empty map keeps the transport from negotiating HTTP/2
+	http1.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	if config.DisableHTTP2 {
+		negotiated.TLSNextProto = http1.TLSNextProto
+	}
+
+	pool.transports = map[string]http.RoundTripper{
+		"":                               negotiated,
+		constant.UPSTREAM_PROTOCOL_HTTP1: http1,
+		constant.UPSTREAM_PROTOCOL_H2: &http2.Transport{
+			TLSClientConfig: tlsConfig.Clone(),
+			DialTLSContext: func(ctx context.Context, network string, addr string, tlsConfig *tls.Config) (net.Conn, error) {
+				conn, err := dial(ctx, network, addr)
+				if err != nil {
+					return nil, err
+				}
+				handshakeCtx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+				defer cancel()
+				tlsConn := tls.Client(conn, tlsConfig)
+				if err = tlsConn.HandshakeContext(handshakeCtx); err != nil {
+					_ = conn.Close()
+					return nil, err
+				}
+				return tlsConn, nil
+			},
+		},
+		constant.UPSTREAM_PROTOCOL_H2C: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network string, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
+		},
+	}
+	for protocol, transport := range pool.transports {
+		pool.transports[protocol] = &pooledTransport{RoundTripper: transport, pool: pool}
+	}
+
+	return pool
+}
+
+// transport returns the transport of the upstream protocol of a route.
+func (p *connectionPool) transport(protocol string) http.RoundTripper {
+	return p.transports[strings.ToLower(protocol)]
+}
+
+// dial opens a connection of the pool with dialer.
+func (p *connectionPool) dial(ctx context.Context, dialer *net.Dialer, network string, addr string) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		p.dialErrors.Add(1)
+		return nil, err
+	}
+	p.dials.Add(1)
+	p.open.Add(1)
+	return &pooledConn{Conn: conn, pool: p}, nil
+}
+
+// closeIdleConnections closes the idle connections of the transports of the pool.
+func (p *connectionPool) closeIdleConnections() {
+	for _, transport := range p.transports {
+		transport.(*pooledTransport).CloseIdleConnections()
+	}
+}
+
+// status returns the admin view of the pool.
+func (p *connectionPool) status() upstream.PoolStatus {
+	return upstream.PoolStatus{
+		Name:            p.name,
+		OpenConnections: p.open.Load(),
+		Dials:           p.dials.Load(),
+		DialErrors:      p.dialErrors.Load(),
+		ActiveRequests:  p.activeRequests.Load(),
+		Requests:        p.requests.Load(),
+		ReusedRequests:  p.reusedRequests.Load(),
+	}
+}
+
+// ConnectionPools returns the admin view of the connection pools of the server: the default pool, the pools of the
+// upstream groups sorted by name and the pools of the routes with TLS settings.
+func (jx *JinxReverseProxyServer) ConnectionPools() []upstream.PoolStatus {
+	statuses := []upstream.PoolStatus{jx.pool.status()}
+
+	names := make([]string, 0, len(jx.groupPools))
+	for name := range jx.groupPools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		statuses = append(statuses, jx.groupPools[name].status())
+	}
+
+	routePools := jx.router.Load().connectionPools()
+	sort.Slice(routePools, func(i, j int) bool {
+		return routePools[i].name < routePools[j].name
+	})
+	for _, pool := range routePools {
+		statuses = append(statuses, pool.status())
+	}
+	return statuses
+}
+
+// pooledTransport counts the requests of its pool and those sent over a reused connection.
+type pooledTransport struct {
+	http.RoundTripper
+	pool *connectionPool
+}
+
+func (t *pooledTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.pool.requests.Add(1)
+	t.pool.activeRequests.Add(1)
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.pool.reusedRequests.Add(1)
+			}
+		},
+	}
+	res, err := t.RoundTripper.RoundTrip(r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+	if err != nil || res.StatusCode == http.StatusSwitchingProtocols {
+		t.pool.activeRequests.Add(-1)
+		return res, err
+	}
+
+	// The request stays active until its response was read
+	res.Body = &pooledBody{ReadCloser: res.Body, pool: t.pool}
+	return res, nil
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport.
+func (t *pooledTransport) CloseIdleConnections() {
+	if closer, ok := t.RoundTripper.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// pooledBody is the body of a response of a pool, whose request is no longer active once the body is closed.
+type pooledBody struct {
+	io.ReadCloser
+	pool *connectionPool
+	once sync.Once
+}
+
+func (b *pooledBody) Close() error {
+	b.once.Do(func() {
+		b.pool.activeRequests.Add(-1)
+	})
+	return b.ReadCloser.Close()
+}
+
+// pooledConn is a connection of a pool, which stops counting it as open once it is closed.
+type pooledConn struct {
+	net.Conn
+	pool *connectionPool
+	once sync.Once
+}
+
+func (c *pooledConn) Close() error {
+	c.once.Do(func() {
+		c.pool.open.Add(-1)
+	})
+	return c.Conn.Close()
+}
+
+func valueOrDefault(value int, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,138 @@
+// File: protocol.go
+// Package: reverse_proxy
+
+// Program Description:
+// This file implements the upstream protocols of routes and the gRPC support
+// of the reverse proxy. Routes speak HTTP/1.1, HTTP/2 over TLS or cleartext
+// HTTP/2 to their upstream, so that gRPC requests, their streams and their
+// trailers pass through unchanged. gRPC clients learn about failures of the
+// proxy or of the upstream from a gRPC status rather than an HTTP error
+// they cannot interpret
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package reverse_proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"jinx/pkg/util/constant"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Status codes of gRPC, see https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	grpcUnknown          = 2
+	grpcDeadlineExceeded = 4
+	grpcPermissionDenied = 7
+	grpcUnimplemented    = 12
+	grpcInternal         = 13
+	grpcUnavailable      = 14
+	grpcUnauthenticated  = 16
+)
+
+// validateUpstreamProtocol checks the upstream protocol of a route.
+func validateUpstreamProtocol(protocol string) error {
+	switch strings.ToLower(protocol) {
+	case "", constant.UPSTREAM_PROTOCOL_HTTP1, constant.UPSTREAM_PROTOCOL_H2, constant.UPSTREAM_PROTOCOL_H2C:
+		return nil
+	}
+	return fmt.Errorf("unknown protocol %q, expected %s, %s or %s", protocol, constant.UPSTREAM_PROTOCOL_HTTP1, constant.UPSTREAM_PROTOCOL_H2, constant.UPSTREAM_PROTOCOL_H2C)
+}
+
+// transport returns the transport of the upstream protocol of target from the connection pool of its upstream group
+// or route, the default pool for requests without a target.
+func (jx *JinxReverseProxyServer) transport(target *upstreamTarget) http.RoundTripper {
+	if target == nil {
+		return jx.pool.transport("")
+	}
+	if target.pool != nil {
+		return target.pool.transport(target.route.Protocol)
+	}
+	return jx.pool.transport(target.route.Protocol)
+}
+
+// isGRPC reports whether r is a gRPC request.
+func isGRPC(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// writeError answers r with an HTTP error, or with the gRPC status matching status for gRPC requests.
+func writeError(w http.ResponseWriter, r *http.Request, message string, status int) {
+	if isGRPC(r) {
+		writeGRPCStatus(w.Header(), grpcCode(status), message)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Error(w, message, status)
+}
+
+// writeGRPCStatus sets the headers of a gRPC response made only of its status, a trailers-only response.
+func writeGRPCStatus(header http.Header, code int, message string) {
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/grpc")
+	header.Set("Grpc-Status", strconv.Itoa(code))
+	header.Set("Grpc-Message", encodeGRPCMessage(message))
+}
+
+// grpcResponse replaces the response of the upstream to the gRPC request r when it is an HTTP error rather than a
+// gRPC response, an upstream that is not a gRPC server or a proxy in front of it failed to serve the request.
+func grpcResponse(r *http.Request, res *http.Response) {
+	if !isGRPC(r) || res.StatusCode == http.StatusOK || strings.HasPrefix(res.Header.Get("Content-Type"), "application/grpc") {
+		return
+	}
+
+	_ = res.Body.Close()
+	writeGRPCStatus(res.Header, grpcCode(res.StatusCode), fmt.Sprintf("upstream answered with status %d", res.StatusCode))
+	res.StatusCode = http.StatusOK
+	res.Status = http.StatusText(http.StatusOK)
+	res.Body = http.NoBody
+	res.ContentLength = 0
+}
+
+// grpcErrorCode returns the gRPC status of a transport error of the upstream.
+func grpcErrorCode(err error) int {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return grpcDeadlineExceeded
+	}
+	return grpcUnavailable
+}
+
+// grpcCode maps an HTTP status to a gRPC status as gRPC clients do, see
+// https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+func grpcCode(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return grpcInternal
+	case http.StatusUnauthorized:
+		return grpcUnauthenticated
+	case http.StatusForbidden:
+		return grpcPermissionDenied
+	case http.StatusNotFound:
+		return grpcUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return grpcUnavailable
+	}
+	return grpcUnknown
+}
+
+// encodeGRPCMessage percent-encodes the bytes of message gRPC does not allow in the Grpc-Message header.
+func encodeGRPCMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c < ' ' || c > '~' || c == '%' {
+			_, _ = fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
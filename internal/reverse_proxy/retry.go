@@ -0,0 +1,205 @@
+// File: retry.go
+// Package: reverse_proxy
+
+// Program Description:
+// This file implements the retries of requests balanced over upstream
+// groups. A request the picked member could not serve is sent again to
+// another member of the group, as long as doing so is safe and the retry
+// budget, the per-try timeout and the overall deadline allow it
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package reverse_proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// defaultRetryStatuses are the upstream statuses retried on another member when a group does not configure any.
+var defaultRetryStatuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// errRetryableStatus aborts a try whose response is retried on another member before it reaches the client.
+var errRetryableStatus = errors.New("retryable upstream status")
+
+// retryDecision reports whether the response res, or the transport error err when res is nil, of a try is retried
+// on another member instead of being returned to the client.
+type retryDecision func(res *http.Response, err error) bool
+
+// retryBody records whether the body of a request was read. A body that was not read yet can still be sent to
+// another member, one that was partly sent to a failed member cannot.
+type retryBody struct {
+	io.ReadCloser
+	read atomic.Bool
+}
+
+func (b *retryBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.read.Store(true)
+	}
+	return n, err
+}
+
+// Close does nothing, the transport closes the body of failed tries while it may still be sent to another member.
+// The server closes the body of the request once it has been handled.
+func (b *retryBody) Close() error {
+	return nil
+}
+
+// proxyToGroup forwards r to member of group and retries it on other members of the group according to the retry
+// settings of the group. A try is retried when the member could not be reached, did not answer within the per-try
+// timeout or answered with a retryable status. Only idempotent methods are retried, other methods only when the
+// member could not be connected to, so that nothing was sent yet. Requests whose body was already read by a failed
+// member are never retried.
+//
+// Parameters:
+//   - w: The http.ResponseWriter of the client. Nothing is written to it by retried tries.
+//   - r: The *http.Request of the client.
+//   - target: The route, the upstream group the request is balanced over and the member picked for the first try.
+//   - done: The function recording the outcome of the first try, as returned by Begin of the group.
+func (jx *JinxReverseProxyServer) proxyToGroup(w http.ResponseWriter, r *http.Request, target upstreamTarget, done func(failed bool)) {
+	group, member := target.group, target.member
+	config := group.Retry()
+
+	attempts := config.Attempts
+	if attempts <= 0 {
+		attempts = constant.DEFAULT_RETRY_ATTEMPTS
+	}
+	statuses := config.Statuses
+	if len(statuses) == 0 {
+		statuses = defaultRetryStatuses
+	}
+	var deadline time.Time
+	if config.Deadline > 0 {
+		deadline = time.Now().Add(time.Duration(config.Deadline) * time.Second)
+	}
+
+	body := &retryBody{ReadCloser: r.Body}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = body
+	}
+
+	tried := []*types.UpstreamMember{member}
+	for attempt := 1; ; attempt++ {
+		tryCtx, cancel := context.WithCancelCause(r.Context())
+
+		var timedOut atomic.Bool
+		var timer *time.Timer
+		if timeout := tryTimeout(config, deadline); timeout > 0 {
+			timer = time.AfterFunc(timeout, func() {
+				timedOut.Store(true)
+				cancel(context.DeadlineExceeded)
+			})
+		}
+
+		var next *types.UpstreamMember
+		decision := func(res *http.Response, err error) bool {
+			if timer != nil {
+				timer.Stop()
+			}
+			if attempt >= attempts || !retryable(r, body, statuses, deadline, res, err, timedOut.Load()) {
+				return false
+			}
+
+			var ok bool
+			next, ok = group.PickRetry(jx.realClientIP(r), tried)
+			if !ok {
+				return false
+			}
+
+			var reason string
+			switch {
+			case res != nil:
+				reason = fmt.Sprintf("status %d", res.StatusCode)
+			case timedOut.Load():
+				reason = "a timeout"
+			default:
+				reason = err.Error()
+			}
+			jx.serverLogger.Info(fmt.Sprintf("Retrying %s %s of %s on %s after %s from %s", r.Method, r.URL.Path, group.Name(), next.Address, reason, member.Address))
+			return true
+		}
+
+		failed, retried := jx.proxyHTTP(w, r.WithContext(tryCtx), member.Address, &target, decision)
+		cancel(nil)
+		done(failed)
+		if !retried {
+			return
+		}
+
+		member = next
+		tried = append(tried, member)
+		done = group.Begin(member)
+	}
+}
+
+// retryable reports whether a failed try of r may be sent to another member, see proxyToGroup.
+func retryable(r *http.Request, body *retryBody, statuses []int, deadline time.Time, res *http.Response, err error, timedOut bool) bool {
+	// Requests abandoned by the client and requests out of time are not retried
+	if r.Context().Err() != nil || (!deadline.IsZero() && !time.Now().Before(deadline)) {
+		return false
+	}
+	if body.read.Load() {
+		return false
+	}
+
+	if res != nil {
+		return isIdempotent(r.Method) && slices.Contains(statuses, res.StatusCode)
+	}
+	if timedOut {
+		return isIdempotent(r.Method)
+	}
+
+	// Nothing was sent to a member that could not be connected to, which makes retrying safe for any method
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return isIdempotent(r.Method)
+}
+
+// tryTimeout returns how long a try may wait for the response headers of its member, zero when it is not limited.
+func tryTimeout(config types.RetryConfig, deadline time.Time) time.Duration {
+	timeout := time.Duration(config.TryTimeout) * time.Second
+	if deadline.IsZero() {
+		return timeout
+	}
+
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		remaining = time.Nanosecond
+	}
+	if timeout == 0 || remaining < timeout {
+		return remaining
+	}
+	return timeout
+}
+
+// isIdempotent reports whether requests with method may be sent more than once, as defined by RFC 9110.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// clientIP returns the IP address of the client of r, the key of the hashing algorithms.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,239 @@
+// File: rewrite.go
+// Package: reverse_proxy
+
+// Program Description:
+// This file implements the path rewriting of routes. The path sent to the
+// upstream may have the matched prefix stripped or replaced and may be
+// rewritten with a regular expression. Redirects and cookie paths of the
+// upstream are mapped back to the prefix of the route. Paths are handled in
+// their escaped form so that encoded characters such as %2F reach the
+// upstream unchanged
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package reverse_proxy
+
+import (
+	"errors"
+	"fmt"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// pathRewriter rewrites the paths of the requests of an exact or prefix route.
+type pathRewriter struct {
+	prefix      string // escaped path of the route without trailing slash
+	strip       bool
+	replacement string // escaped prefix replacing prefix, when replace is set
+	replace     bool
+	expression  *regexp.Regexp
+	template    string
+}
+
+// newPathRewriter compiles the rewrite rules of route. Regex routes have no rewriter, their upstream may refer to
+// the captures of their expression instead.
+func newPathRewriter(route types.Route) (*pathRewriter, error) {
+	rewrite := route.Rewrite
+	regexRoute := strings.ToLower(route.Match) == constant.ROUTE_MATCH_REGEX
+
+	if rewrite.StripPrefix && rewrite.ReplacePrefix != "" {
+		return nil, errors.New("StripPrefix and ReplacePrefix are mutually exclusive")
+	}
+	if regexRoute && (rewrite.StripPrefix || rewrite.ReplacePrefix != "" || rewrite.Regex != "") {
+		return nil, errors.New("the path of regex routes is rewritten through the captures of their upstream")
+	}
+	if rewrite.ReplacePrefix != "" && !strings.HasPrefix(rewrite.ReplacePrefix, "/") {
+		return nil, fmt.Errorf("%q is not a valid prefix, prefixes must start with /", rewrite.ReplacePrefix)
+	}
+	if rewrite.Regex == "" && rewrite.Replacement != "" {
+		return nil, errors.New("a Replacement requires a Regex")
+	}
+	if regexRoute {
+		return nil, nil
+	}
+
+	rewriter := &pathRewriter{
+		prefix:      escapePath(strings.TrimSuffix(CleanPath(route.Path), "/")),
+		strip:       rewrite.StripPrefix,
+		replacement: escapePath(strings.TrimSuffix(rewrite.ReplacePrefix, "/")),
+		replace:     rewrite.ReplacePrefix != "",
+		template:    rewrite.Replacement,
+	}
+	if rewrite.Regex != "" {
+		expression, err := regexp.Compile(rewrite.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", rewrite.Regex, err)
+		}
+		rewriter.expression = expression
+	}
+	return rewriter, nil
+}
+
+// rewrite rewrites the path of the request URL u. The prefix of the route is stripped or replaced first, then the
+// regular expression is applied to the escaped path. A query in the replacement is added in front of the query of
+// the request.
+func (pr *pathRewriter) rewrite(u *url.URL) {
+	escaped := u.EscapedPath()
+
+	if pr.strip || pr.replace {
+		rest, ok := cutPathPrefix(escaped, pr.prefix)
+		if !ok {
+			// The client encoded the prefix differently, the decoded path is used instead
+			rest, _ = cutPathPrefix(escapePath(u.Path), pr.prefix)
+		}
+		escaped = pr.replacement + rest
+	}
+
+	if pr.expression != nil {
+		rewritten, query, hasQuery := strings.Cut(pr.expression.ReplaceAllString(escaped, pr.template), "?")
+		escaped = rewritten
+		if hasQuery {
+			if u.RawQuery != "" {
+				query += "&" + u.RawQuery
+			}
+			u.RawQuery = query
+		}
+	}
+
+	if !strings.HasPrefix(escaped, "/") {
+		escaped = "/" + escaped
+	}
+	setEscapedPath(u, escaped)
+}
+
+// clientPath maps the escaped path of a redirect or cookie of the upstream back to the path the client uses. It
+// returns false for paths outside the route.
+//
+// Parameters:
+//   - escaped: The escaped path sent by the upstream.
+//   - base: The URL of the upstream, its path is the base of the paths sent to it.
+func (pr *pathRewriter) clientPath(escaped string, base *url.URL) (string, bool) {
+	upstreamPrefix := pr.prefix
+	if pr.strip || pr.replace {
+		upstreamPrefix = pr.replacement
+	}
+	from := strings.TrimSuffix(helper.SingleJoiningSlash(base.EscapedPath(), upstreamPrefix), "/")
+
+	rest, ok := cutPathPrefix(escaped, from)
+	if !ok {
+		return "", false
+	}
+	if clientPath := pr.prefix + rest; clientPath != "" {
+		return clientPath, true
+	}
+	return "/", true
+}
+
+// rewriteResponse rewrites the Location header and the cookie paths of a response of the upstream base so that
+// they point to the route rather than to the upstream. Absolute redirects to the upstream become relative to the
+// host of the client.
+func (pr *pathRewriter) rewriteResponse(header http.Header, base *url.URL) {
+	if location := header.Get("Location"); location != "" {
+		header.Set("Location", pr.rewriteLocation(location, base))
+	}
+
+	cookies := header.Values("Set-Cookie")
+	for i, cookie := range cookies {
+		cookies[i] = pr.rewriteCookiePath(cookie, base)
+	}
+}
+
+func (pr *pathRewriter) rewriteLocation(location string, base *url.URL) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+
+	switch {
+	case u.Host != "":
+		if !strings.EqualFold(u.Host, base.Host) {
+			return location
+		}
+		u.Scheme = ""
+		u.Host = ""
+		u.User = nil
+	case u.Scheme != "" || !strings.HasPrefix(u.Path, "/"):
+		// Other schemes and paths relative to the request are left alone
+		return location
+	}
+
+	if clientPath, ok := pr.clientPath(u.EscapedPath(), base); ok {
+		setEscapedPath(u, clientPath)
+	}
+	return u.String()
+}
+
+func (pr *pathRewriter) rewriteCookiePath(cookie string, base *url.URL) string {
+	attributes := strings.Split(cookie, ";")
+	for i, attribute := range attributes {
+		name, value, _ := strings.Cut(strings.TrimSpace(attribute), "=")
+		if !strings.EqualFold(name, "Path") {
+			continue
+		}
+
+		clientPath, ok := pr.clientPath(value, base)
+		if !ok {
+			return cookie
+		}
+		if clientPath != "/" {
+			clientPath = strings.TrimSuffix(clientPath, "/")
+		}
+		attributes[i] = " " + name + "=" + clientPath
+	}
+	return strings.Join(attributes, ";")
+}
+
+// cleanRequestPath cleans the path of u, see CleanPath. The escaped form of the path is kept when it means the same
+// path once cleaned, so that encoded characters reach the upstream unchanged. Otherwise, when dot segments were
+// encoded for instance, only the cleaned path is kept.
+func cleanRequestPath(u *url.URL) {
+	cleaned := CleanPath(u.Path)
+	escaped := CleanPath(u.EscapedPath())
+
+	if unescaped, err := url.PathUnescape(escaped); err == nil && unescaped == cleaned {
+		setEscapedPath(u, escaped)
+		return
+	}
+	u.Path = cleaned
+	u.RawPath = ""
+}
+
+// setEscapedPath sets the path of u from its escaped form.
+func setEscapedPath(u *url.URL, escaped string) {
+	unescaped, err := url.PathUnescape(escaped)
+	if err != nil {
+		u.Path = escaped
+		u.RawPath = ""
+		return
+	}
+
+	u.Path = unescaped
+	u.RawPath = ""
+	if u.EscapedPath() != escaped {
+		u.RawPath = escaped
+	}
+}
+
+// escapePath returns the default escaped form of path.
+func escapePath(path string) string {
+	return (&url.URL{Path: path}).EscapedPath()
+}
+
+// cutPathPrefix returns the rest of path after prefix, which must end at a segment boundary.
+func cutPathPrefix(path string, prefix string) (string, bool) {
+	if !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+	rest := path[len(prefix):]
+	if rest != "" && !strings.HasPrefix(rest, "/") {
+		return "", false
+	}
+	return rest, true
+}
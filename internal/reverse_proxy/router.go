@@ -0,0 +1,474 @@
+// File: router.go
+// Package: reverse_proxy
+
+// Program Description:
+// This file implements the request router of the reverse proxy. Routes
+// match the Host header, exactly or through wildcards, and the request
+// path exactly, by prefix or through a regular expression
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package reverse_proxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"jinx/internal/jinx_http"
+	"jinx/internal/jinx_tls"
+	"jinx/internal/upstream"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Router selects the route of a request. Its priority is deterministic and does not depend on the order of
+// the legacy route table:
+//
+//  1. Routes for the exact host of the request come first, then wildcard hosts from the longest suffix to the
+//     shortest and finally routes without a host. The next host group is only tried when no route of a more
+//     specific group matches the path.
+//  2. Within a host group an exact path match wins, then the first regex route in declaration order that
+//     matches, then the prefix route with the longest path.
+type Router struct {
+	exactHosts    map[string]*routeGroup
+	wildcardHosts []*routeGroup // ordered from the longest suffix to the shortest
+	anyHost       *routeGroup
+}
+
+// RouteMatch is the route selected for a request.
+type RouteMatch struct {
+	Route types.Route
+	// Upstream is the upstream URL of the route with the captures of a regex route expanded, it is empty for
+	// routes balanced over an upstream group
+	Upstream string
+	// ReplacePath is set when Upstream holds the full path to forward to, rather than a base the request path
+	// is appended to. This is the case for regex routes whose upstream refers to captures.
+	ReplacePath bool
+
+	rewriter  *pathRewriter          // nil for regex routes
+	split     *trafficSplit          // nil for routes without versions
+	tlsConfig *tls.Config            // nil for routes without TLS settings
+	pool      *connectionPool        // nil for routes without TLS settings, which share the default pool
+	static    *jinx_http.StaticFiles // nil for routes forwarded to an upstream
+}
+
+type routeGroup struct {
+	suffix   string // suffix of wildcard hosts, including the leading dot
+	exact    map[string]*compiledRoute
+	regex    []*compiledRoute
+	prefixes []*compiledRoute // ordered from the longest path to the shortest
+}
+
+type compiledRoute struct {
+	route      types.Route
+	pathPrefix string
+	expression *regexp.Regexp
+	rewriter   *pathRewriter
+	split      *trafficSplit
+	tlsConfig  *tls.Config
+	pool       *connectionPool // connection pool with the TLS settings of the route, see newConnectionPools
+	static     *jinx_http.StaticFiles
+}
+
+// NewRouter compiles routes into a Router.
+//
+// Parameters:
+//   - routes: The routes of the reverse proxy in declaration order.
+//
+// Returns:
+//   - The *Router, or an error if a route is invalid. Invalid routes are routes with an unknown match mode, a
+//     prefix or exact path not starting with /, an invalid regular expression, no upstream or both an upstream
+//     and an upstream group, invalid rewrite rules or TLS settings, a static root that is not a readable directory
+//     or is set together with an upstream, or the same host, match mode and path as an earlier route.
+func NewRouter(routes []types.Route) (*Router, error) {
+	router := &Router{exactHosts: make(map[string]*routeGroup)}
+	wildcards := make(map[string]*routeGroup)
+
+	for i, route := range routes {
+		if route.Upstream == "" && route.UpstreamGroup == "" && route.Static.Root == "" {
+			return nil, fmt.Errorf("route %d (%s): no upstream", i, route.Path)
+		}
+		if route.Static.Root != "" {
+			if err := validateStatic(route); err != nil {
+				return nil, fmt.Errorf("route %d (%s): %v", i, route.Path, err)
+			}
+		}
+		if route.Upstream != "" && route.UpstreamGroup != "" {
+			return nil, fmt.Errorf("route %d (%s): Upstream and UpstreamGroup are mutually exclusive", i, route.Path)
+		}
+		if err := validateHeaderRules(route.RequestHeaders); err != nil {
+			return nil, fmt.Errorf("route %d (%s): request headers: %v", i, route.Path, err)
+		}
+		if err := validateHeaderRules(route.ResponseHeaders); err != nil {
+			return nil, fmt.Errorf("route %d (%s): response headers: %v", i, route.Path, err)
+		}
+		if err := validateRouteCache(route.Cache); err != nil {
+			return nil, fmt.Errorf("route %d (%s): cache: %v", i, route.Path, err)
+		}
+		if err := validateUpstreamProtocol(route.Protocol); err != nil {
+			return nil, fmt.Errorf("route %d (%s): %v", i, route.Path, err)
+		}
+		if err := validateMirror(route.Mirror); err != nil {
+			return nil, fmt.Errorf("route %d (%s): %v", i, route.Path, err)
+		}
+		if err := validateBody(route.Body); err != nil {
+			return nil, fmt.Errorf("route %d (%s): %v", i, route.Path, err)
+		}
+
+		host := normalizeHost(route.Host)
+		var group *routeGroup
+		switch {
+		case host == "":
+			if router.anyHost == nil {
+				router.anyHost = newRouteGroup("")
+			}
+			group = router.anyHost
+		case strings.HasPrefix(host, "*."):
+			suffix := host[1:]
+			if wildcards[suffix] == nil {
+				wildcards[suffix] = newRouteGroup(suffix)
+				router.wildcardHosts = append(router.wildcardHosts, wildcards[suffix])
+			}
+			group = wildcards[suffix]
+		case strings.Contains(host, "*"):
+			return nil, fmt.Errorf("route %d: %q is not a valid host, wildcards are only allowed as the first label", i, route.Host)
+		default:
+			if router.exactHosts[host] == nil {
+				router.exactHosts[host] = newRouteGroup("")
+			}
+			group = router.exactHosts[host]
+		}
+
+		if err := group.add(route); err != nil {
+			return nil, fmt.Errorf("route %d: %v", i, err)
+		}
+	}
+
+	sort.SliceStable(router.wildcardHosts, func(i, j int) bool {
+		return len(router.wildcardHosts[i].suffix) > len(router.wildcardHosts[j].suffix)
+	})
+
+	return router, nil
+}
+
+// RoutesFromTable turns a legacy route table into prefix routes. The routes are sorted by path so that the
+// result does not depend on the iteration order of the map.
+func RoutesFromTable(routeTable types.RouteTable) []types.Route {
+	routes := make([]types.Route, 0, len(routeTable))
+	for routePath, upstream := range routeTable {
+		routes = append(routes, types.Route{Path: routePath, Match: constant.ROUTE_MATCH_PREFIX, Upstream: upstream})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+// LoadRoutingConfig reads the route file at path. Two formats are understood: an object with a "Routes" array of
+// types.Route entries and an optional "UpstreamGroups" object of the groups routes are balanced over, and the
+// legacy route table mapping paths to upstream URLs. Entries of a legacy route table become prefix routes, so
+// "/api" also forwards "/api/users/42".
+//
+// Parameters:
+//   - path: The path of the JSON route file.
+//
+// Returns:
+//   - The types.RoutingConfig described by the file, or an error if it cannot be read or decoded.
+func LoadRoutingConfig(path string) (types.RoutingConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return types.RoutingConfig{}, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if decodeErr := json.Unmarshal(content, &fields); decodeErr != nil {
+		return types.RoutingConfig{}, decodeErr
+	}
+
+	routes, hasRoutes := fields["Routes"]
+	groups, hasGroups := fields["UpstreamGroups"]
+	if (hasRoutes && bytes.HasPrefix(bytes.TrimSpace(routes), []byte("["))) || (hasGroups && bytes.HasPrefix(bytes.TrimSpace(groups), []byte("{"))) {
+		var routingConfig types.RoutingConfig
+		if decodeErr := json.Unmarshal(content, &routingConfig); decodeErr != nil {
+			return types.RoutingConfig{}, decodeErr
+		}
+		return routingConfig, nil
+	}
+
+	routeTable := make(types.RouteTable)
+	if decodeErr := json.Unmarshal(content, &routeTable); decodeErr != nil {
+		return types.RoutingConfig{}, decodeErr
+	}
+
+	return types.RoutingConfig{Routes: RoutesFromTable(routeTable)}, nil
+}
+
+// NewRouting builds the router and the upstream groups of the reverse proxy and checks that every route balanced
+// over an upstream group names a group that exists.
+//
+// Parameters:
+//   - routes: The routes of the reverse proxy in declaration order.
+//   - groupConfigs: The upstream groups routes may refer to by name.
+//
+// Returns:
+//   - The *Router and the groups by name, or an error if a route or a group is invalid.
+func NewRouting(routes []types.Route, groupConfigs map[string]types.UpstreamGroupConfig) (*Router, map[string]*upstream.Group, error) {
+	router, err := NewRouter(routes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	groups, err := upstream.NewGroups(groupConfigs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkUpstreamGroups(routes, groups); err != nil {
+		return nil, nil, err
+	}
+
+	return router, groups, nil
+}
+
+// checkUpstreamGroups checks that the upstream groups routes and the versions of their splits are balanced over exist.
+func checkUpstreamGroups(routes []types.Route, groups map[string]*upstream.Group) error {
+	for i, route := range routes {
+		if _, ok := groups[route.UpstreamGroup]; route.UpstreamGroup != "" && !ok {
+			return fmt.Errorf("route %d (%s): unknown upstream group %s", i, route.Path, route.UpstreamGroup)
+		}
+		for _, version := range route.Split.Versions {
+			if _, ok := groups[version.UpstreamGroup]; version.UpstreamGroup != "" && !ok {
+				return fmt.Errorf("route %d (%s): unknown upstream group %s of version %s", i, route.Path, version.UpstreamGroup, version.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Match returns the route for a request to host and requestPath.
+//
+// Parameters:
+//   - host: The Host header of the request, a port is ignored.
+//   - requestPath: The path of the request URL. It is cleaned with URL semantics before matching, so dot
+//     segments cannot be used to reach another route.
+//
+// Returns:
+//   - The *RouteMatch, or nil and false when no route matches.
+func (router *Router) Match(host string, requestPath string) (*RouteMatch, bool) {
+	requestPath = CleanPath(requestPath)
+	return router.match(host, requestPath, escapePath(requestPath))
+}
+
+// match implements Match for a cleaned request path. Regex routes are matched against escapedPath, the path as the
+// client sent it, so that their captures keep escaped characters such as %2F, %3F and %23 escaped in the upstream
+// path instead of turning them into path separators, a query or a fragment.
+func (router *Router) match(host string, requestPath string, escapedPath string) (*RouteMatch, bool) {
+	host = normalizeHost(host)
+
+	groups := make([]*routeGroup, 0, 3)
+	if group, ok := router.exactHosts[host]; ok {
+		groups = append(groups, group)
+	}
+	for _, group := range router.wildcardHosts {
+		// A wildcard requires at least one label in front of the suffix
+		if strings.HasSuffix(host, group.suffix) && len(host) > len(group.suffix) {
+			groups = append(groups, group)
+		}
+	}
+	if router.anyHost != nil {
+		groups = append(groups, router.anyHost)
+	}
+
+	for _, group := range groups {
+		if match, ok := group.match(requestPath, escapedPath); ok {
+			return match, true
+		}
+	}
+
+	return nil, false
+}
+
+func newRouteGroup(suffix string) *routeGroup {
+	return &routeGroup{suffix: suffix, exact: make(map[string]*compiledRoute)}
+}
+
+func (group *routeGroup) add(route types.Route) error {
+	rewriter, err := newPathRewriter(route)
+	if err != nil {
+		return fmt.Errorf("rewrite of %s: %v", route.Path, err)
+	}
+	split, err := newTrafficSplit(route.Split)
+	if err != nil {
+		return fmt.Errorf("split of %s: %v", route.Path, err)
+	}
+	tlsConfig, err := jinx_tls.NewUpstreamTLSConfig(route.TLS)
+	if err != nil {
+		return fmt.Errorf("TLS of %s: %v", route.Path, err)
+	}
+	compiled := &compiledRoute{route: route, rewriter: rewriter, split: split, tlsConfig: tlsConfig}
+	if route.Static.Root != "" {
+		if tlsConfig != nil {
+			return fmt.Errorf("TLS of %s: static routes have no upstream", route.Path)
+		}
+		compiled.static, err = jinx_http.NewStaticFiles(route.Static, strings.TrimSuffix(CleanPath(route.Path), "/"))
+		if err != nil {
+			return fmt.Errorf("static location %s: %v", route.Path, err)
+		}
+	}
+
+	switch strings.ToLower(route.Match) {
+	case constant.ROUTE_MATCH_EXACT:
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("%q is not a valid path, paths must start with /", route.Path)
+		}
+		routePath := CleanPath(route.Path)
+		if _, exists := group.exact[routePath]; exists {
+			return fmt.Errorf("duplicate exact route for %s", route.Path)
+		}
+		group.exact[routePath] = compiled
+
+	case "", constant.ROUTE_MATCH_PREFIX:
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("%q is not a valid path, paths must start with /", route.Path)
+		}
+		compiled.pathPrefix = strings.TrimSuffix(CleanPath(route.Path), "/")
+		for _, existing := range group.prefixes {
+			if existing.pathPrefix == compiled.pathPrefix {
+				return fmt.Errorf("duplicate prefix route for %s", route.Path)
+			}
+		}
+		group.prefixes = append(group.prefixes, compiled)
+		sort.SliceStable(group.prefixes, func(i, j int) bool {
+			return len(group.prefixes[i].pathPrefix) > len(group.prefixes[j].pathPrefix)
+		})
+
+	case constant.ROUTE_MATCH_REGEX:
+		expression, err := regexp.Compile(route.Path)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression %q: %v", route.Path, err)
+		}
+		compiled.expression = expression
+		group.regex = append(group.regex, compiled)
+
+	default:
+		return fmt.Errorf("%q is not a valid match mode, valid modes are: exact, prefix and regex", route.Match)
+	}
+
+	return nil
+}
+
+func (group *routeGroup) match(requestPath string, escapedPath string) (*RouteMatch, bool) {
+	if compiled, ok := group.exact[requestPath]; ok {
+		return compiled.newMatch(compiled.route.Upstream, false), true
+	}
+
+	for _, compiled := range group.regex {
+		captures := compiled.expression.FindStringSubmatchIndex(escapedPath)
+		if captures == nil {
+			continue
+		}
+
+		if compiled.route.Upstream == "" {
+			return compiled.newMatch("", false), true
+		}
+
+		upstream := string(compiled.expression.ExpandString(nil, compiled.route.Upstream, escapedPath, captures))
+		return compiled.newMatch(upstream, upstream != compiled.route.Upstream), true
+	}
+
+	for _, compiled := range group.prefixes {
+		// Prefixes match whole path segments, /api matches /api and /api/users but not /apiv2
+		if compiled.pathPrefix == "" || requestPath == compiled.pathPrefix || strings.HasPrefix(requestPath, compiled.pathPrefix+"/") {
+			return compiled.newMatch(compiled.route.Upstream, false), true
+		}
+	}
+
+	return nil, false
+}
+
+// newMatch returns the RouteMatch of a request matching the route.
+func (compiled *compiledRoute) newMatch(upstream string, replacePath bool) *RouteMatch {
+	match := &RouteMatch{
+		Route:       compiled.route,
+		Upstream:    upstream,
+		ReplacePath: replacePath,
+		split:       compiled.split,
+		tlsConfig:   compiled.tlsConfig,
+		pool:        compiled.pool,
+		static:      compiled.static,
+	}
+	// Regex routes rewrite through their captures rather than their rewrite rules
+	if compiled.expression == nil {
+		match.rewriter = compiled.rewriter
+	}
+	return match
+}
+
+// newConnectionPools gives every route with TLS settings a connection pool of its own with the settings of config.
+// The other routes share the default pool of the server.
+func (router *Router) newConnectionPools(config types.ConnectionPoolConfig) {
+	for _, compiled := range router.routes() {
+		if compiled.tlsConfig != nil {
+			compiled.pool = newConnectionPool("route "+compiled.route.Host+compiled.route.Path, config, compiled.tlsConfig)
+		}
+	}
+}
+
+// connectionPools returns the connection pools of the routes with TLS settings.
+func (router *Router) connectionPools() []*connectionPool {
+	var pools []*connectionPool
+	for _, compiled := range router.routes() {
+		if compiled.pool != nil {
+			pools = append(pools, compiled.pool)
+		}
+	}
+	return pools
+}
+
+// routes returns the compiled routes of router.
+func (router *Router) routes() []*compiledRoute {
+	groups := append([]*routeGroup{router.anyHost}, router.wildcardHosts...)
+	for _, group := range router.exactHosts {
+		groups = append(groups, group)
+	}
+
+	var compiledRoutes []*compiledRoute
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		compiledRoutes = append(compiledRoutes, group.regex...)
+		compiledRoutes = append(compiledRoutes, group.prefixes...)
+		for _, compiled := range group.exact {
+			compiledRoutes = append(compiledRoutes, compiled)
+		}
+	}
+	return compiledRoutes
+}
+
+// CleanPath cleans a request path with URL semantics: it is made absolute, dot segments and repeated slashes are
+// removed and a trailing slash is kept, since /docs/ and /docs are different resources for many upstreams.
+func CleanPath(requestPath string) string {
+	cleaned := path.Clean("/" + requestPath)
+	if strings.HasSuffix(requestPath, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+func normalizeHost(host string) string {
+	host = strings.TrimSpace(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
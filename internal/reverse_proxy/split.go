@@ -0,0 +1,165 @@
+// File: split.go
+// Package: reverse_proxy
+
+// Program Description:
+// This file implements the traffic splitting of routes between versions of
+// their upstream, for canary releases and gradual rollouts. Requests are sent
+// to a version when they carry its header or cookie, otherwise by the weights
+// of the versions. Assignments may be sticky so that a client keeps seeing the
+// same version while the weights do not change
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package reverse_proxy
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// splitCookieMaxAge is the lifetime of the cookies of sticky cookie assignments in seconds.
+const splitCookieMaxAge = 30 * 24 * 60 * 60
+
+// trafficSplit is the compiled types.TrafficSplit of a route.
+type trafficSplit struct {
+	versions []*splitVersion
+	sticky   string
+	cookie   string
+}
+
+type splitVersion struct {
+	config      types.SplitVersion
+	name        string
+	header      string
+	headerValue string
+	cookie      string
+	cookieValue string
+	from        int // the version receives the requests whose bucket is in [from, to)
+	to          int
+}
+
+// newTrafficSplit compiles the traffic split of a route. Routes without versions have no split.
+func newTrafficSplit(config types.TrafficSplit) (*trafficSplit, error) {
+	if len(config.Versions) == 0 {
+		if config.Sticky != "" || config.Cookie != "" {
+			return nil, errors.New("a split without versions cannot be sticky")
+		}
+		return nil, nil
+	}
+
+	switch {
+	case config.Sticky == "", config.Sticky == constant.SPLIT_STICKY_CLIENT_IP, config.Sticky == constant.SPLIT_STICKY_COOKIE:
+	case strings.HasPrefix(config.Sticky, constant.SPLIT_STICKY_HEADER_PREFIX) && len(config.Sticky) > len(constant.SPLIT_STICKY_HEADER_PREFIX):
+	default:
+		return nil, fmt.Errorf("unknown sticky assignment %q, expected %s, %s or %sName", config.Sticky, constant.SPLIT_STICKY_CLIENT_IP, constant.SPLIT_STICKY_COOKIE, constant.SPLIT_STICKY_HEADER_PREFIX)
+	}
+
+	split := &trafficSplit{sticky: config.Sticky, cookie: config.Cookie}
+	if split.cookie == "" {
+		split.cookie = constant.DEFAULT_SPLIT_COOKIE
+	}
+
+	total := 0
+	for i, versionConfig := range config.Versions {
+		version := &splitVersion{config: versionConfig, name: versionConfig.Name}
+		if version.name == "" {
+			version.name = strconv.Itoa(i)
+		}
+
+		if (versionConfig.Upstream == "") == (versionConfig.UpstreamGroup == "") {
+			return nil, fmt.Errorf("version %s: exactly one of Upstream and UpstreamGroup is required", version.name)
+		}
+		if versionConfig.Weight < 0 || versionConfig.Weight > 100 {
+			return nil, fmt.Errorf("version %s: the weight must be between 0 and 100, got %d", version.name, versionConfig.Weight)
+		}
+		if versionConfig.Header != "" {
+			name, value, ok := strings.Cut(versionConfig.Header, ":")
+			version.header = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			version.headerValue = strings.TrimSpace(value)
+			// An empty value would match every request without the header
+			if !ok || version.header == "" || version.headerValue == "" {
+				return nil, fmt.Errorf("version %s: %q is not a valid header, expected Name: value", version.name, versionConfig.Header)
+			}
+		}
+		if versionConfig.Cookie != "" {
+			name, value, ok := strings.Cut(versionConfig.Cookie, "=")
+			version.cookie = strings.TrimSpace(name)
+			version.cookieValue = strings.TrimSpace(value)
+			if !ok || version.cookie == "" {
+				return nil, fmt.Errorf("version %s: %q is not a valid cookie, expected name=value", version.name, versionConfig.Cookie)
+			}
+		}
+
+		version.from = total
+		total += versionConfig.Weight
+		version.to = total
+		split.versions = append(split.versions, version)
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("the weights of the versions add up to %d percent", total)
+	}
+
+	return split, nil
+}
+
+// pick returns the version r is sent to, or nil for the upstream of the route.
+//
+// Parameters:
+//   - r: The *http.Request of the client.
+//   - clientIP: The IP address of the client, the key of client_ip assignments.
+//
+// Returns:
+//   - The version of the request, or nil when it stays on the upstream of the route.
+//   - The cookie to set on the response for sticky cookie assignments of new clients, or nil.
+func (s *trafficSplit) pick(r *http.Request, clientIP string) (*splitVersion, *http.Cookie) {
+	for _, version := range s.versions {
+		if version.header != "" && r.Header.Get(version.header) == version.headerValue {
+			return version, nil
+		}
+		if version.cookie != "" {
+			if cookie, err := r.Cookie(version.cookie); err == nil && cookie.Value == version.cookieValue {
+				return version, nil
+			}
+		}
+	}
+
+	var key string
+	var newCookie *http.Cookie
+	switch {
+	case s.sticky == constant.SPLIT_STICKY_CLIENT_IP:
+		key = clientIP
+	case s.sticky == constant.SPLIT_STICKY_COOKIE:
+		if cookie, err := r.Cookie(s.cookie); err == nil && cookie.Value != "" {
+			key = cookie.Value
+		} else {
+			key = helper.NewRequestID()
+			newCookie = &http.Cookie{Name: s.cookie, Value: key, Path: "/", MaxAge: splitCookieMaxAge, HttpOnly: true, SameSite: http.SameSiteLaxMode}
+		}
+	case strings.HasPrefix(s.sticky, constant.SPLIT_STICKY_HEADER_PREFIX):
+		key = r.Header.Get(strings.TrimPrefix(s.sticky, constant.SPLIT_STICKY_HEADER_PREFIX))
+	}
+
+	bucket := rand.Intn(100)
+	if key != "" {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(key))
+		bucket = int(hash.Sum32() % 100)
+	}
+
+	for _, version := range s.versions {
+		if bucket >= version.from && bucket < version.to {
+			return version, newCookie
+		}
+	}
+	return nil, newCookie
+}
@@ -0,0 +1,74 @@
+// File: static.go
+// Package: reverse_proxy
+
+// Program Description:
+// This file implements the static locations of the reverse proxy. Routes
+// with a static root are answered from the files of the root, with the file
+// serving of the HTTP server, instead of being forwarded to an upstream.
+// One server can then host a single page application at / and proxy /api
+// to its backend without a second Jinx process in front of it
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package reverse_proxy
+
+import (
+	"fmt"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net/http"
+	"strings"
+)
+
+// validateStatic checks that a static route has none of the settings of forwarded routes.
+func validateStatic(route types.Route) error {
+	if route.Upstream != "" || route.UpstreamGroup != "" {
+		return fmt.Errorf("Static, Upstream and UpstreamGroup are mutually exclusive")
+	}
+	if len(route.Split.Versions) > 0 || len(route.Mirror.Upstreams) > 0 || route.Protocol != "" || route.Rewrite != (types.PathRewrite{}) {
+		return fmt.Errorf("static routes cannot be split, mirrored, rewritten or have a protocol")
+	}
+	if route.Static.StripPrefix && strings.ToLower(route.Match) == constant.ROUTE_MATCH_REGEX {
+		return fmt.Errorf("the static StripPrefix is not available for regex routes")
+	}
+	return nil
+}
+
+// serveStatic answers r from the static root of its route, with the response header rules of the route applied.
+//
+// Parameters:
+//   - w: The http.ResponseWriter of the client.
+//   - r: The *http.Request of the client.
+//   - target: The static route r resolved to.
+func (jx *JinxReverseProxyServer) serveStatic(w http.ResponseWriter, r *http.Request, target upstreamTarget) {
+	jx.serverLogger.Info(fmt.Sprintf("Serving %s %s from %s", r.Method, r.URL.Path, target.route.Static.Root))
+
+	vars := requestVariables(r, jx.realClientIP(r), "")
+	target.static.ServeHTTP(&headerRulesWriter{ResponseWriter: w, rules: target.route.ResponseHeaders, vars: vars}, r)
+}
+
+// headerRulesWriter applies header rules to a response right before its headers are written, so that the rules
+// take precedence over the headers set by the file server.
+type headerRulesWriter struct {
+	http.ResponseWriter
+	rules   types.HeaderRules
+	vars    map[string]string
+	applied bool
+}
+
+func (w *headerRulesWriter) WriteHeader(status int) {
+	if !w.applied {
+		w.applied = true
+		applyHeaderRules(w.Header(), w.rules, w.vars)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *headerRulesWriter) Write(p []byte) (int, error) {
+	if !w.applied {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,346 @@
+// File: websocket.go
+// Package: reverse_proxy
+
+// Program Description:
+// This file implements the WebSocket proxying of the reverse proxy. Upgrade
+// requests are checked against the allowed origins and sent to the upstream
+// of their route, over TLS for https and wss upstreams. Once the upstream
+// accepted the upgrade, the connection of the client is hijacked and data is
+// relayed in both directions until both sides closed the connection, the
+// connection was idle for too long or reached its maximum lifetime
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package reverse_proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"jinx/internal/jinx_tls"
+	"jinx/pkg/util/constant"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// webSocketHandshakeTimeout bounds the connection to the upstream and the upgrade handshake.
+const webSocketHandshakeTimeout = 10 * time.Second
+
+// webSocketBufferSize is the size of the buffers data is relayed through.
+const webSocketBufferSize = 32 << 10
+
+// handleWebSocketConnect proxies the WebSocket upgrade request r to the upstream of target and relays the
+// connection once the upstream accepted the upgrade. The request is checked against the allowed origins and the
+// connection limit of the server first, and reaches the upstream with the same path rewriting, forwarding headers
+// and header rules as other requests of its route.
+//
+// Parameters:
+//   - w: The http.ResponseWriter of the client, hijacked once the upstream switched protocols.
+//   - r: The *http.Request of the client, carrying the WebSocket upgrade headers.
+//   - target: The upstream the route of r resolved to. Upstreams with the https or wss scheme are reached over TLS.
+//
+// Returns:
+//   - true if the upstream failed, that is if it could not be reached or answered the upgrade with a server error.
+//
+// Workflow:
+//   - Requests from origins that are not allowed are rejected with 403 Forbidden and requests beyond the connection
+//     limit with 503 Service Unavailable.
+//   - The upgrade request is sent to the upstream. When the upstream cannot be reached, the client receives 502
+//     Bad Gateway, or 504 Gateway Timeout when the upstream did not answer in time, see writeUpstreamError. When
+//     the upstream does not switch protocols, its response is passed on to the client.
+//   - Otherwise the client connection is hijacked, the 101 Switching Protocols response of the upstream is
+//     passed on and data is relayed until both directions are closed. When one side closes its direction, the
+//     close is passed on to the other side, which may still finish sending. Connections idle for the idle timeout
+//     or open for the maximum lifetime are closed.
+//
+// Note:
+//   - The handler returns once the connection is closed, so that the connection counts against the upstream group
+//     and the connection limit for its whole lifetime.
+func (jx *JinxReverseProxyServer) handleWebSocketConnect(w http.ResponseWriter, r *http.Request, target upstreamTarget) (failed bool) {
+	config := jx.config.WebSocket
+
+	if !originAllowed(r.Header.Get("Origin"), config.AllowedOrigins) {
+		jx.errorLogger.Error(fmt.Sprintf("Rejected WebSocket connection for %s from origin %s", r.URL.Path, r.Header.Get("Origin")))
+		http.Error(w, "Forbidden: origin not allowed", http.StatusForbidden)
+		return false
+	}
+
+	active := jx.webSockets.Add(1)
+	defer jx.webSockets.Add(-1)
+	if config.MaxConnections > 0 && active > int64(config.MaxConnections) {
+		jx.errorLogger.Error(fmt.Sprintf("Rejected WebSocket connection for %s: %d connections open", r.URL.Path, config.MaxConnections))
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return false
+	}
+
+	start := time.Now()
+	upstreamConn, upstreamReader, res, err := jx.dialWebSocket(r, target)
+	if err != nil {
+		jx.writeUpstreamError(w, r, target.url, &target, err, start)
+		return true
+	}
+	defer func() {
+		_ = upstreamConn.Close()
+	}()
+
+	vars := requestVariables(r, jx.realClientIP(r), target.url)
+	applyHeaderRules(res.Header, target.route.ResponseHeaders, vars)
+
+	// The upstream refused the upgrade, its answer is an ordinary response
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		defer func() {
+			_ = res.Body.Close()
+		}()
+		for name, values := range res.Header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(res.StatusCode)
+		_, _ = io.Copy(w, res.Body)
+		return res.StatusCode >= http.StatusInternalServerError
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "HTTP Server does not support hijacking", http.StatusInternalServerError)
+		return false
+	}
+	clientConn, clientBuffer, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	defer func() {
+		_ = clientConn.Close()
+	}()
+
+	// The read and write deadlines armed for the HTTP request must not cut the connection short
+	_ = clientConn.SetDeadline(time.Time{})
+	if err = res.Write(clientConn); err != nil {
+		return false
+	}
+
+	jx.serverLogger.Info(fmt.Sprintf("WebSocket connection to %s opened (%d open)", target.url, jx.webSockets.Load()))
+	start = time.Now()
+
+	idleTimeout := time.Duration(config.IdleTimeout) * time.Second
+	if idleTimeout == 0 {
+		idleTimeout = constant.DEFAULT_WEBSOCKET_IDLE_TIMEOUT * time.Second
+	}
+	tunnel := &webSocketTunnel{client: clientConn, upstream: upstreamConn, idleTimeout: idleTimeout}
+	tunnel.run(clientBuffer.Reader, upstreamReader, time.Duration(config.MaxLifetime)*time.Second)
+
+	jx.serverLogger.Info(fmt.Sprintf("WebSocket connection to %s closed after %s", target.url, time.Since(start).Round(time.Millisecond)))
+	return false
+}
+
+// ActiveWebSockets returns the number of WebSocket connections currently open through the server.
+func (jx *JinxReverseProxyServer) ActiveWebSockets() int {
+	return int(jx.webSockets.Load())
+}
+
+// dialWebSocket connects to the upstream of target and sends it the upgrade request r.
+//
+// Returns:
+//   - The connection to the upstream, the reader buffering it and the response of the upstream to the upgrade, or
+//     an error if the upstream could not be reached or did not answer.
+func (jx *JinxReverseProxyServer) dialWebSocket(r *http.Request, target upstreamTarget) (net.Conn, *bufio.Reader, *http.Response, error) {
+	base, err := url.Parse(target.url)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	secure := false
+	port := "80"
+	switch strings.ToLower(base.Scheme) {
+	case "https", "wss":
+		secure = true
+		port = "443"
+	case "http", "ws":
+	default:
+		return nil, nil, nil, fmt.Errorf("unsupported upstream scheme %s", base.Scheme)
+	}
+	if base.Port() != "" {
+		port = base.Port()
+	}
+	address := net.JoinHostPort(base.Hostname(), port)
+
+	ctx, cancel := context.WithTimeout(r.Context(), webSocketHandshakeTimeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	if secure {
+		tlsConn := tls.Client(conn, jinx_tls.UpstreamTLSConfigFor(target.tlsConfig, base.Hostname(), "http/1.1"))
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, nil, nil, err
+		}
+		conn = tlsConn
+	}
+
+	if err = jx.newUpgradeRequest(r, base, target).Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, nil, nil, err
+	}
+
+	reader := bufio.NewReaderSize(conn, webSocketBufferSize)
+	res, err := http.ReadResponse(reader, r)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, nil, err
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return conn, reader, res, nil
+}
+
+// newUpgradeRequest returns the upgrade request sent to the upstream base for the request r of the client.
+func (jx *JinxReverseProxyServer) newUpgradeRequest(r *http.Request, base *url.URL, target upstreamTarget) *http.Request {
+	out := jx.newUpstreamRequest(r.Context(), r, base, target)
+	out.Body = http.NoBody
+	out.ContentLength = 0
+	out.Header.Set("Connection", "Upgrade")
+	out.Header.Set("Upgrade", r.Header.Get("Upgrade"))
+	return out
+}
+
+// originAllowed reports whether a WebSocket connection from origin may be opened. Requests without an origin are
+// not sent by browsers and are always allowed, as are all requests when no origins are configured.
+func originAllowed(origin string, allowedOrigins []string) bool {
+	if origin == "" || len(allowedOrigins) == 0 {
+		return true
+	}
+
+	requested, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	for _, allowedOrigin := range allowedOrigins {
+		if allowedOrigin == "*" {
+			return true
+		}
+		allowed, err := url.Parse(allowedOrigin)
+		if err != nil || !strings.EqualFold(allowed.Scheme, requested.Scheme) {
+			continue
+		}
+
+		host := strings.ToLower(requested.Host)
+		allowedHost := strings.ToLower(allowed.Host)
+		if host == allowedHost {
+			return true
+		}
+		// A wildcard requires at least one label in front of the suffix
+		if suffix, ok := strings.CutPrefix(allowedHost, "*"); ok && strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// webSocketTunnel relays the data of an upgraded connection between the client and the upstream.
+type webSocketTunnel struct {
+	client      net.Conn
+	upstream    net.Conn
+	idleTimeout time.Duration
+
+	lastActivity atomic.Int64 // unix nanoseconds of the last data relayed in either direction
+	closeOnce    sync.Once
+}
+
+// run relays data in both directions until both are closed, or the connection is closed after maxLifetime when it
+// is not zero.
+func (t *webSocketTunnel) run(clientReader io.Reader, upstreamReader io.Reader, maxLifetime time.Duration) {
+	if maxLifetime > 0 {
+		timer := time.AfterFunc(maxLifetime, t.close)
+		defer timer.Stop()
+	}
+
+	t.touch()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		t.relay(t.upstream, t.client, clientReader)
+	}()
+	go func() {
+		defer wg.Done()
+		t.relay(t.client, t.upstream, upstreamReader)
+	}()
+	wg.Wait()
+
+	t.close()
+}
+
+// relay copies the data read from src through reader to dst. When src closes its direction, dst is told that no
+// more data follows while the other direction keeps running. Read timeouts only end the relay when no data was
+// relayed in either direction for the idle timeout.
+func (t *webSocketTunnel) relay(dst net.Conn, src net.Conn, reader io.Reader) {
+	buffer := make([]byte, webSocketBufferSize)
+	for {
+		_ = src.SetReadDeadline(time.Now().Add(t.idleTimeout))
+		n, err := reader.Read(buffer)
+		if n > 0 {
+			t.touch()
+			_ = dst.SetWriteDeadline(time.Now().Add(t.idleTimeout))
+			if _, writeErr := dst.Write(buffer[:n]); writeErr != nil {
+				t.close()
+				return
+			}
+		}
+
+		var netErr net.Error
+		switch {
+		case err == nil:
+		case errors.As(err, &netErr) && netErr.Timeout() && time.Since(t.lastActive()) < t.idleTimeout:
+			// The other direction is still in use
+		case errors.Is(err, io.EOF):
+			closeWrite(dst, t.close)
+			return
+		default:
+			t.close()
+			return
+		}
+	}
+}
+
+func (t *webSocketTunnel) touch() {
+	t.lastActivity.Store(time.Now().UnixNano())
+}
+
+func (t *webSocketTunnel) lastActive() time.Time {
+	return time.Unix(0, t.lastActivity.Load())
+}
+
+// close closes both connections, which ends the relays in both directions.
+func (t *webSocketTunnel) close() {
+	t.closeOnce.Do(func() {
+		_ = t.client.Close()
+		_ = t.upstream.Close()
+	})
+}
+
+// closeWrite closes the writing direction of conn, or calls closeAll when conn cannot be half closed.
+func closeWrite(conn net.Conn, closeAll func()) {
+	if halfCloser, ok := conn.(interface{ CloseWrite() error }); ok && halfCloser.CloseWrite() == nil {
+		return
+	}
+	closeAll()
+}
@@ -0,0 +1,160 @@
+// File: admin.go
+// Package: upstream
+
+// Program Description:
+// This file implements the admin view of the upstream groups, reporting the
+// health, circuit breaker state and load of every member as JSON
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package upstream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// GroupStatus is the admin view of an upstream group.
+type GroupStatus struct {
+	Name    string
+	Members []MemberStatus
+}
+
+// MemberStatus is the admin view of a member of an upstream group.
+type MemberStatus struct {
+	Address           string
+	Weight            int
+	Healthy           bool
+	Breaker           string     // closed, open or half_open
+	EjectedUntil      *time.Time `json:",omitempty"`
+	RecentFailures    int
+	ActiveConnections int64
+	ResponseTimeMs    float64 // moving average of the response time in milliseconds
+}
+
+// PoolStatus is the admin view of a connection pool of the reverse proxy. Requests sent over a connection of the
+// pool that served an earlier request count as ReusedRequests, a low share of them hints at connection churn.
+type PoolStatus struct {
+	Name            string
+	OpenConnections int64
+	Dials           int64 // connections opened since the start
+	DialErrors      int64
+	ActiveRequests  int64
+	Requests        int64
+	ReusedRequests  int64
+}
+
+// Status returns the admin view of the group.
+func (g *Group) Status() GroupStatus {
+	status := GroupStatus{Name: g.name, Members: make([]MemberStatus, 0, len(g.pool.Members))}
+
+	for _, member := range g.pool.Members {
+		state, ejectedUntil, failures := g.breakerStatus(member)
+
+		memberStatus := MemberStatus{
+			Address:           member.Address,
+			Weight:            member.Weight,
+			Healthy:           !member.Unhealthy.Load(),
+			Breaker:           state,
+			RecentFailures:    failures,
+			ActiveConnections: member.ActiveConnections.Load(),
+			ResponseTimeMs:    float64(member.ResponseTime.Load()) / float64(time.Millisecond),
+		}
+		if !ejectedUntil.IsZero() {
+			memberStatus.EjectedUntil = &ejectedUntil
+		}
+		status.Members = append(status.Members, memberStatus)
+	}
+
+	return status
+}
+
+// AdminHandler returns the handler of the admin listener. It answers GET requests for /upstreams with the status
+// of every group, sorted by name, as a JSON array, and for /pools with the status of the connection pools reported
+// by pools when it is not nil.
+func AdminHandler(groups []*Group, pools func() []PoolStatus) http.Handler {
+	sorted := make([]*Group, len(groups))
+	copy(sorted, groups)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].name < sorted[j].name
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(constant.ADMIN_UPSTREAMS_PATH, func(w http.ResponseWriter, r *http.Request) {
+		statuses := make([]GroupStatus, 0, len(sorted))
+		for _, group := range sorted {
+			statuses = append(statuses, group.Status())
+		}
+		writeAdminJSON(w, r, statuses)
+	})
+	if pools != nil {
+		mux.HandleFunc(constant.ADMIN_POOLS_PATH, func(w http.ResponseWriter, r *http.Request) {
+			writeAdminJSON(w, r, pools())
+		})
+	}
+	return mux
+}
+
+// writeAdminJSON answers GET requests of the admin listener with value as JSON.
+func writeAdminJSON(w http.ResponseWriter, r *http.Request, value any) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// StartAdmin serves the admin view of groups and pools on the admin listener of config in the background. Nil is returned
+// when the admin listener is disabled.
+//
+// Parameters:
+//   - config: The types.AdminConfig of the server.
+//   - groups: The upstream groups of the server.
+//   - pools: Reports the connection pools of the server, nil for servers without connection pools.
+//   - errorLogger: The logger errors while serving are reported to.
+//
+// Returns:
+//   - The *http.Server of the admin listener to shut down with the server, or an error if its address could not
+//     be bound.
+func StartAdmin(config types.AdminConfig, groups []*Group, pools func() []PoolStatus, errorLogger *slog.Logger) (*http.Server, error) {
+	if config.Port == 0 {
+		return nil, nil
+	}
+
+	ip := config.IP
+	if ip == "" {
+		ip = constant.DEFAULT_IP
+	}
+
+	l, err := net.Listen("tcp", net.JoinHostPort(ip, strconv.Itoa(config.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("admin listener: %v", err)
+	}
+
+	s := &http.Server{
+		Handler:           AdminHandler(groups, pools),
+		ReadHeaderTimeout: time.Duration(constant.DEFAULT_HEADER_READ_TIMEOUT) * time.Second,
+	}
+	go func() {
+		if serveErr := s.Serve(l); serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+			errorLogger.Error(fmt.Sprintf("Admin listener stopped: %s", serveErr.Error()))
+		}
+	}()
+
+	return s, nil
+}
@@ -0,0 +1,219 @@
+// File: breaker.go
+// Package: upstream
+
+// Program Description:
+// This file implements passive failure detection. The outcome of every
+// request or connection is reported to the circuit breaker of its member,
+// which ejects members failing too often and lets them back in through
+// half-open trials once their backoff has elapsed
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package upstream
+
+import (
+	"fmt"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"sync"
+	"time"
+)
+
+// circuitBreaker is the breaker of one member. It is closed while the member works, open while the member is
+// ejected and half-open while trial requests decide whether it returns.
+type circuitBreaker struct {
+	mutex          sync.Mutex
+	state          string
+	failures       []time.Time // failures within the window, oldest first
+	backoff        time.Duration
+	ejectedUntil   time.Time
+	trialsInFlight int
+	trialSuccesses int
+	timer          *time.Timer
+}
+
+// Begin records the start of a request or connection to member and returns the function recording its end.
+// failed reports whether the upstream failed, for instance when it could not be reached, timed out or answered
+// with a server error. Failures count against the circuit breaker of the member, and the least connections and
+// least response time algorithms base their choice on the active requests and response times recorded here.
+//
+// Parameters:
+//   - member: The member picked for the request or connection.
+//
+// Returns:
+//   - The function to call exactly once when the outcome of the request or connection is known.
+func (g *Group) Begin(member *types.UpstreamMember) (done func(failed bool)) {
+	member.ActiveConnections.Add(1)
+	start := time.Now()
+	breaker := g.breakerOf(member)
+
+	trial := false
+	if breaker != nil {
+		trial = g.beginTrial(member, breaker)
+	}
+
+	var once sync.Once
+	return func(failed bool) {
+		once.Do(func() {
+			member.ActiveConnections.Add(-1)
+			recordResponseTime(member, time.Since(start))
+
+			if breaker != nil {
+				g.recordOutcome(member, breaker, trial, failed)
+			}
+		})
+	}
+}
+
+// breakerOf returns the circuit breaker of member, or nil when circuit breaking is disabled for the group.
+func (g *Group) breakerOf(member *types.UpstreamMember) *circuitBreaker {
+	if g.circuitBreaker.Failures == 0 {
+		return nil
+	}
+
+	g.breakerMutex.Lock()
+	defer g.breakerMutex.Unlock()
+
+	breaker, ok := g.breakers[member]
+	if !ok {
+		breaker = &circuitBreaker{state: constant.BREAKER_CLOSED}
+		g.breakers[member] = breaker
+	}
+	return breaker
+}
+
+// beginTrial reports whether a request to member is a half-open trial. The member is taken out of selection while
+// all trial requests of the group are in flight.
+func (g *Group) beginTrial(member *types.UpstreamMember, breaker *circuitBreaker) bool {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	if breaker.state != constant.BREAKER_HALF_OPEN {
+		return false
+	}
+
+	breaker.trialsInFlight++
+	if breaker.trialsInFlight >= g.halfOpenRequests() {
+		member.Ejected.Store(true)
+	}
+	return true
+}
+
+// recordOutcome updates the breaker of member with the outcome of a request or connection.
+func (g *Group) recordOutcome(member *types.UpstreamMember, breaker *circuitBreaker, trial bool, failed bool) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	now := time.Now()
+
+	if trial && breaker.state == constant.BREAKER_HALF_OPEN {
+		breaker.trialsInFlight--
+		if failed {
+			g.open(member, breaker, now, "a failed half-open trial")
+			return
+		}
+
+		breaker.trialSuccesses++
+		if breaker.trialSuccesses >= g.halfOpenRequests() {
+			breaker.state = constant.BREAKER_CLOSED
+			breaker.failures = nil
+			breaker.backoff = 0
+			member.Ejected.Store(false)
+			g.serverLogger.Info(fmt.Sprintf("Circuit breaker of upstream %s of %s closed: the member is back in service", member.Address, g.name))
+			return
+		}
+		member.Ejected.Store(false)
+		return
+	}
+
+	if !failed || breaker.state != constant.BREAKER_CLOSED {
+		return
+	}
+
+	window := seconds(g.circuitBreaker.Window, constant.DEFAULT_BREAKER_WINDOW)
+	recent := breaker.failures[:0]
+	for _, failure := range breaker.failures {
+		if now.Sub(failure) < window {
+			recent = append(recent, failure)
+		}
+	}
+	breaker.failures = append(recent, now)
+
+	if len(breaker.failures) >= g.circuitBreaker.Failures {
+		g.open(member, breaker, now, fmt.Sprintf("%d failures within %s", len(breaker.failures), window))
+	}
+}
+
+// open ejects member for the backoff of its breaker and schedules the half-open state. The backoff doubles every
+// time the breaker opens again without having closed in between.
+func (g *Group) open(member *types.UpstreamMember, breaker *circuitBreaker, now time.Time, reason string) {
+	initial := seconds(g.circuitBreaker.Backoff, constant.DEFAULT_BREAKER_BACKOFF)
+	maximum := seconds(g.circuitBreaker.MaxBackoff, constant.DEFAULT_BREAKER_MAX_BACKOFF)
+
+	if breaker.backoff == 0 {
+		breaker.backoff = initial
+	} else {
+		breaker.backoff = min(breaker.backoff*2, maximum)
+	}
+
+	breaker.state = constant.BREAKER_OPEN
+	breaker.failures = nil
+	breaker.trialsInFlight = 0
+	breaker.trialSuccesses = 0
+	breaker.ejectedUntil = now.Add(breaker.backoff)
+	member.Ejected.Store(true)
+
+	g.errorLogger.Error(fmt.Sprintf("Circuit breaker of upstream %s of %s opened after %s: ejected for %s", member.Address, g.name, reason, breaker.backoff))
+
+	if breaker.timer != nil {
+		breaker.timer.Stop()
+	}
+	breaker.timer = time.AfterFunc(breaker.backoff, func() {
+		breaker.mutex.Lock()
+		defer breaker.mutex.Unlock()
+
+		if breaker.state != constant.BREAKER_OPEN {
+			return
+		}
+		breaker.state = constant.BREAKER_HALF_OPEN
+		member.Ejected.Store(false)
+		g.serverLogger.Info(fmt.Sprintf("Circuit breaker of upstream %s of %s half-open: sending trial requests", member.Address, g.name))
+	})
+}
+
+func (g *Group) halfOpenRequests() int {
+	return positive(g.circuitBreaker.HalfOpenRequests, constant.DEFAULT_BREAKER_HALF_OPEN_REQUESTS)
+}
+
+// breakerStatus returns the state of the breaker of member, the time it is ejected until and the number of recent
+// failures.
+func (g *Group) breakerStatus(member *types.UpstreamMember) (state string, ejectedUntil time.Time, failures int) {
+	breaker := g.breakerOf(member)
+	if breaker == nil {
+		return constant.BREAKER_CLOSED, time.Time{}, 0
+	}
+
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	if breaker.state == constant.BREAKER_OPEN {
+		ejectedUntil = breaker.ejectedUntil
+	}
+	return breaker.state, ejectedUntil, len(breaker.failures)
+}
+
+// recordResponseTime adds elapsed to the moving average of the response time of member.
+func recordResponseTime(member *types.UpstreamMember, elapsed time.Duration) {
+	for {
+		average := member.ResponseTime.Load()
+		next := elapsed.Nanoseconds()
+		if average != 0 {
+			next = int64(responseTimeDecay*float64(next) + (1-responseTimeDecay)*float64(average))
+		}
+		if member.ResponseTime.CompareAndSwap(average, next) {
+			return
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// File: group.go
+// Package: upstream
+
+// Program Description:
+// This file implements groups of upstream servers balanced with the
+// algorithms of the load balancer. The reverse proxy balances routes over
+// groups of upstream URLs and the load balancer its server pool
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package upstream
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"jinx/internal/jinx_tls"
+	"jinx/internal/load_balancer/algo"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"log/slog"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// responseTimeDecay is the share of a new measurement in the moving average of the response time of a member.
+const responseTimeDecay = 0.3
+
+// ErrNoAvailableMember is reported when every member of a group is unhealthy or ejected by its circuit breaker.
+var ErrNoAvailableMember = errors.New("no available upstream")
+
+// Group is a named set of upstream servers together with the algorithm balancing traffic over them.
+type Group struct {
+	name         string
+	pool         *types.UpstreamPool
+	algorithm    types.LoadBalancingAlgorithm
+	healthCheck  types.HealthCheckConfig
+	expectedBody *regexp.Regexp
+	health       map[*types.UpstreamMember]*healthState
+	healthMutex  sync.Mutex
+
+	circuitBreaker types.CircuitBreakerConfig
+	breakers       map[*types.UpstreamMember]*circuitBreaker
+	breakerMutex   sync.Mutex
+
+	retry     types.RetryConfig
+	tlsConfig *tls.Config
+
+	serverLogger *slog.Logger
+	errorLogger  *slog.Logger
+}
+
+// NewGroup creates the upstream group name of the reverse proxy from its configuration.
+//
+// Parameters:
+//   - name: The name routes refer to the group by.
+//   - config: The types.UpstreamGroupConfig of the group.
+//
+// Returns:
+//   - The *Group, or an error if the group has no member, a member URL is not an absolute http or https URL,
+//     a weight is negative, the algorithm is unknown or not supported or the health check, circuit breaker, retry, TLS or connection
+//     pool settings are invalid.
+func NewGroup(name string, config types.UpstreamGroupConfig) (*Group, error) {
+	algorithmName := config.Algorithm
+	if algorithmName == "" {
+		algorithmName = constant.ROUND_ROBIN
+	}
+
+	if err := algo.Validate(algorithmName); err != nil {
+		return nil, fmt.Errorf("upstream group %s: %v", name, err)
+	}
+	algorithm, _ := algo.ByName(algorithmName)
+
+	if len(config.Members) == 0 {
+		return nil, fmt.Errorf("upstream group %s: no members", name)
+	}
+
+	if err := helper.ValidateRetryConfig(config.Retry); err != nil {
+		return nil, fmt.Errorf("upstream group %s: %v", name, err)
+	}
+
+	if config.ConnectionPool != nil {
+		if err := helper.ValidateConnectionPoolConfig(*config.ConnectionPool); err != nil {
+			return nil, fmt.Errorf("upstream group %s: %v", name, err)
+		}
+	}
+
+	tlsConfig, err := jinx_tls.NewUpstreamTLSConfig(config.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("upstream group %s: %v", name, err)
+	}
+
+	pool := &types.UpstreamPool{}
+	for _, memberConfig := range config.Members {
+		target, err := url.Parse(memberConfig.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return nil, fmt.Errorf("upstream group %s: %q is not an absolute http or https URL", name, memberConfig.URL)
+		}
+		if memberConfig.Weight < 0 {
+			return nil, fmt.Errorf("upstream group %s: the weight of %s must not be negative", name, memberConfig.URL)
+		}
+
+		pool.Members = append(pool.Members, &types.UpstreamMember{Address: memberConfig.URL, Weight: memberConfig.Weight})
+	}
+
+	group, err := newGroup(name, pool, algorithm, config.HealthCheck, config.CircuitBreaker)
+	if err != nil {
+		return nil, fmt.Errorf("upstream group %s: %v", name, err)
+	}
+	group.retry = config.Retry
+	group.tlsConfig = tlsConfig
+	return group, nil
+}
+
+// NewServerPoolGroup creates the group of the load balancer from its server pool. The address of every member is
+// the host:port of its server.
+func NewServerPoolGroup(name string, servers []types.UpStreamServer, algorithm types.LoadBalancingAlgorithm, healthCheck types.HealthCheckConfig, breakerConfig types.CircuitBreakerConfig) (*Group, error) {
+	pool := &types.UpstreamPool{}
+	for _, server := range servers {
+		pool.Members = append(pool.Members, &types.UpstreamMember{
+			Address:  net.JoinHostPort(server.IP, strconv.Itoa(server.Port)),
+			Weight:   server.Weight,
+			Location: server.Location,
+		})
+	}
+
+	return newGroup(name, pool, algorithm, healthCheck, breakerConfig)
+}
+
+func newGroup(name string, pool *types.UpstreamPool, algorithm types.LoadBalancingAlgorithm, healthCheck types.HealthCheckConfig, breakerConfig types.CircuitBreakerConfig) (*Group, error) {
+	if err := helper.ValidateHealthCheckConfig(healthCheck); err != nil {
+		return nil, err
+	}
+	if err := helper.ValidateCircuitBreakerConfig(breakerConfig); err != nil {
+		return nil, err
+	}
+
+	discard := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	group := &Group{
+		name:           name,
+		pool:           pool,
+		algorithm:      algorithm,
+		healthCheck:    healthCheck,
+		health:         make(map[*types.UpstreamMember]*healthState),
+		circuitBreaker: breakerConfig,
+		breakers:       make(map[*types.UpstreamMember]*circuitBreaker),
+		serverLogger:   discard,
+		errorLogger:    discard,
+	}
+	if healthCheck.ExpectedBody != "" {
+		group.expectedBody = regexp.MustCompile(healthCheck.ExpectedBody)
+	}
+
+	return group, nil
+}
+
+// NewGroups creates the upstream groups of the reverse proxy, see NewGroup.
+func NewGroups(configs map[string]types.UpstreamGroupConfig) (map[string]*Group, error) {
+	groups := make(map[string]*Group, len(configs))
+	for name, config := range configs {
+		if name == "" {
+			return nil, errors.New("upstream groups must have a name")
+		}
+
+		group, err := NewGroup(name, config)
+		if err != nil {
+			return nil, err
+		}
+		groups[name] = group
+	}
+	return groups, nil
+}
+
+// SetLoggers sets the loggers state changes of the circuit breakers of the group are reported to.
+func (g *Group) SetLoggers(serverLogger *slog.Logger, errorLogger *slog.Logger) {
+	g.serverLogger = serverLogger
+	g.errorLogger = errorLogger
+}
+
+// Name returns the name of the group.
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Retry returns the retry settings of the group. The server pool of the load balancer has its own, see
+// types.JinxLoadBalancingServerConfig.
+func (g *Group) Retry() types.RetryConfig {
+	return g.retry
+}
+
+// TLSConfig returns the TLS client settings of the https members of the group, nil for the default settings.
+func (g *Group) TLSConfig() *tls.Config {
+	return g.tlsConfig
+}
+
+// Members returns the members of the group.
+func (g *Group) Members() []*types.UpstreamMember {
+	return g.pool.Members
+}
+
+// Pick returns the member the next request or connection of the client identified by key is sent to. ok is false
+// when no member is available, see ErrNoAvailableMember.
+func (g *Group) Pick(key string) (*types.UpstreamMember, bool) {
+	member := g.algorithm(g.pool, key)
+	return member, member != nil
+}
+
+// PickRetry returns the member a request or connection is retried on after it could not be served by the members
+// in tried. The algorithm of the group is asked first, when it keeps returning members already tried, as hashing
+// does for the same key, the first available member not tried yet is used. ok is false when every available member
+// has been tried.
+func (g *Group) PickRetry(key string, tried []*types.UpstreamMember) (*types.UpstreamMember, bool) {
+	for range g.pool.Members {
+		member := g.algorithm(g.pool, key)
+		if member == nil {
+			return nil, false
+		}
+		if !slices.Contains(tried, member) {
+			return member, true
+		}
+	}
+
+	for _, member := range g.pool.Members {
+		if !slices.Contains(tried, member) && !member.Unhealthy.Load() && !member.Ejected.Load() {
+			return member, true
+		}
+	}
+	return nil, false
+}
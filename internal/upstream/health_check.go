@@ -0,0 +1,254 @@
+// File: health_check.go
+// Package: upstream
+
+// Program Description:
+// This file implements the active health checks of upstream groups. Every
+// member is probed at a fixed interval with a TCP connect, an HTTP request
+// or a TLS handshake and taken out of selection while it fails its checks
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"jinx/internal/jinx_tls"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHealthCheckBody is the size of the response body of an http check matched against ExpectedBody.
+const maxHealthCheckBody = 64 << 10
+
+// healthState counts the consecutive results of the checks of a member.
+type healthState struct {
+	successes int
+	failures  int
+}
+
+// StartHealthChecks checks the health of every member of the group in the background at the interval of its
+// health check configuration, until the returned function is called. Nothing is started when the group has no
+// health check. Changes of the health of a member are logged to serverLogger and errorLogger.
+//
+// Parameters:
+//   - serverLogger: The logger members becoming healthy are reported to.
+//   - errorLogger: The logger members becoming unhealthy are reported to, with the reason of the last failure.
+//
+// Returns:
+//   - A function stopping the checks.
+func (g *Group) StartHealthChecks(serverLogger *slog.Logger, errorLogger *slog.Logger) (stop func()) {
+	if g.healthCheck.Type == "" {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	interval := seconds(g.healthCheck.Interval, constant.DEFAULT_HEALTH_CHECK_INTERVAL)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			g.CheckHealth(serverLogger, errorLogger)
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
+
+// CheckHealth checks every member of the group once, concurrently, and updates their health once the rise or
+// fall threshold of the group is reached. It returns when all checks have completed.
+func (g *Group) CheckHealth(serverLogger *slog.Logger, errorLogger *slog.Logger) {
+	rise := positive(g.healthCheck.Rise, constant.DEFAULT_HEALTH_CHECK_RISE)
+	fall := positive(g.healthCheck.Fall, constant.DEFAULT_HEALTH_CHECK_FALL)
+
+	var wg sync.WaitGroup
+	for _, member := range g.pool.Members {
+		wg.Add(1)
+		go func(member *types.UpstreamMember) {
+			defer wg.Done()
+
+			err := g.checkMember(member)
+
+			g.healthMutex.Lock()
+			defer g.healthMutex.Unlock()
+
+			state := g.health[member]
+			if state == nil {
+				state = &healthState{}
+				g.health[member] = state
+			}
+
+			if err == nil {
+				state.successes++
+				state.failures = 0
+				if member.Unhealthy.Load() && state.successes >= rise {
+					member.Unhealthy.Store(false)
+					serverLogger.Info(fmt.Sprintf("Upstream %s of %s is healthy again", member.Address, g.name))
+				}
+				return
+			}
+
+			state.failures++
+			state.successes = 0
+			if !member.Unhealthy.Load() && state.failures >= fall {
+				member.Unhealthy.Store(true)
+				errorLogger.Error(fmt.Sprintf("Upstream %s of %s is unhealthy: %v", member.Address, g.name, err))
+			}
+		}(member)
+	}
+	wg.Wait()
+}
+
+// checkMember runs the health check of the group against member.
+func (g *Group) checkMember(member *types.UpstreamMember) error {
+	config := g.healthCheck
+	timeout := seconds(config.Timeout, constant.DEFAULT_HEALTH_CHECK_TIMEOUT)
+
+	scheme, address, err := memberAddress(member.Address)
+	if err != nil {
+		return err
+	}
+
+	switch config.Type {
+	case constant.HEALTH_CHECK_TCP:
+		conn, dialErr := net.DialTimeout("tcp", address, timeout)
+		if dialErr != nil {
+			return dialErr
+		}
+		return conn.Close()
+
+	case constant.HEALTH_CHECK_TLS:
+		dialer := &net.Dialer{Timeout: timeout}
+		conn, dialErr := tls.DialWithDialer(dialer, "tcp", address, g.checkTLSConfig(address))
+		if dialErr != nil {
+			return dialErr
+		}
+		return conn.Close()
+
+	default:
+		return checkHTTP(config, g.expectedBody, scheme+"://"+address, g.checkTLSConfig(address), timeout)
+	}
+}
+
+// checkTLSConfig returns the TLS settings of the checks of the member at address. Members of a group with TLS
+// settings are checked with them, client certificate and verification included, like the traffic proxied to them.
+// Without TLS settings the check is only about the availability of the member and its certificate is not verified.
+func (g *Group) checkTLSConfig(address string) *tls.Config {
+	host, _, _ := net.SplitHostPort(address)
+	if g.tlsConfig == nil {
+		return &tls.Config{ServerName: host, InsecureSkipVerify: true}
+	}
+	return jinx_tls.UpstreamTLSConfigFor(g.tlsConfig, host)
+}
+
+// checkHTTP requests the health check path of the member at base over tlsConfig and matches the response against
+// the expected status and body.
+func checkHTTP(config types.HealthCheckConfig, expectedBody *regexp.Regexp, base string, tlsConfig *tls.Config, timeout time.Duration) error {
+	checkPath := config.Path
+	if checkPath == "" {
+		checkPath = "/"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+checkPath, nil)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   tlsConfig,
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if len(config.ExpectedStatus) > 0 && !slices.Contains(config.ExpectedStatus, res.StatusCode) {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	if len(config.ExpectedStatus) == 0 && (res.StatusCode < 200 || res.StatusCode > 399) {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	if expectedBody != nil {
+		body, readErr := io.ReadAll(io.LimitReader(res.Body, maxHealthCheckBody))
+		if readErr != nil {
+			return readErr
+		}
+		if !expectedBody.Match(body) {
+			return fmt.Errorf("the response body does not match %q", config.ExpectedBody)
+		}
+	}
+
+	return nil
+}
+
+// memberAddress returns the scheme and host:port of a member address. Members of the load balancer are plain
+// host:port addresses and are checked over http, the port of URLs without one follows from their scheme.
+func memberAddress(address string) (scheme string, hostPort string, err error) {
+	if !strings.Contains(address, "://") {
+		return "http", address, nil
+	}
+
+	target, err := url.Parse(address)
+	if err != nil {
+		return "", "", err
+	}
+
+	port := target.Port()
+	if port == "" {
+		port = "80"
+		if target.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return target.Scheme, net.JoinHostPort(target.Hostname(), port), nil
+}
+
+func seconds(value int, defaultValue int) time.Duration {
+	return time.Duration(positive(value, defaultValue)) * time.Second
+}
+
+func positive(value int, defaultValue int) int {
+	if value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,150 @@
+// File: upstream_error.go
+// Package: upstream_error
+
+// Program Description:
+// This file classifies the errors of requests the reverse proxy and the
+// forward proxy could not forward to their upstream (refused connection,
+// failed DNS lookup, failed TLS handshake, timeout or reset connection) and
+// answers them with 502 Bad Gateway, 503 Service Unavailable or 504 Gateway
+// Timeout, using the configured error page of the status and naming the ID
+// of the request
+
+// Author: Martin Alemajoh
+// Jinx- v1.0.0
+// Created on: October 18, 2026
+
+package upstream_error
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Pages holds the error pages of a proxy, read once when the proxy is created.
+type Pages struct {
+	pages map[int]string
+}
+
+// NewPages reads the error pages of a proxy.
+//
+// Parameters:
+//   - config: The types.ErrorPages of the proxy, by status.
+//
+// Returns:
+//   - The *Pages, or an error if a page is set for another status than 502, 503 or 504 or could not be read.
+func NewPages(config types.ErrorPages) (*Pages, error) {
+	if err := helper.ValidateErrorPages(config); err != nil {
+		return nil, err
+	}
+
+	pages := make(map[int]string, len(config))
+	for status, file := range config {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the error page of %d: %v", status, err)
+		}
+		pages[status] = string(content)
+	}
+	return &Pages{pages: pages}, nil
+}
+
+// Write answers a request that could not be forwarded with status and the error page of status, or a plain text
+// message when there is none. The request ID is returned in the X-Request-Id header and in the body, so that
+// clients can quote it when reporting the failure. A nil *Pages answers every status with the plain text message.
+//
+// Parameters:
+//   - w: The http.ResponseWriter of the client, nothing must have been written to it yet.
+//   - status: The status of the response, see Status.
+//   - requestID: The ID of the request.
+func (p *Pages) Write(w http.ResponseWriter, status int, requestID string) {
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	header.Set("Cache-Control", "no-store")
+	header.Set(constant.REQUEST_ID_HEADER, requestID)
+
+	var page string
+	var ok bool
+	if p != nil {
+		page, ok = p.pages[status]
+	}
+	if !ok {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		header.Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+		_, _ = fmt.Fprintf(w, "%d %s\nRequest ID: %s\n", status, http.StatusText(status), requestID)
+		return
+	}
+
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	// Request IDs of trusted proxies are passed on as they came, they must not inject markup into the page
+	replacer := strings.NewReplacer("${status}", strconv.Itoa(status), "${request_id}", html.EscapeString(requestID))
+	_, _ = io.WriteString(w, replacer.Replace(page))
+}
+
+// Classify returns the class of the error of a request that could not be forwarded to its upstream.
+//
+// Parameters:
+//   - err: The error of the transport, or of the dial of a tunnel.
+//
+// Returns:
+//   - One of the UPSTREAM_ERROR_* constants: refused when nothing listens on the address of the upstream, dns when
+//     its host name could not be resolved, tls when the TLS handshake failed, timeout when it did not answer in
+//     time, reset when it closed the connection without answering and other for any other error.
+func Classify(err error) string {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var recordErr tls.RecordHeaderError
+	var verificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return constant.UPSTREAM_ERROR_DNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return constant.UPSTREAM_ERROR_REFUSED
+	case errors.As(err, &recordErr), errors.As(err, &verificationErr), errors.As(err, &unknownAuthorityErr),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return constant.UPSTREAM_ERROR_TLS
+	case errors.As(err, &opErr) && opErr.Op == "remote error":
+		// The upstream rejected the handshake with a TLS alert, such as for a missing client certificate
+		return constant.UPSTREAM_ERROR_TLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return constant.UPSTREAM_ERROR_TIMEOUT
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF):
+		return constant.UPSTREAM_ERROR_RESET
+	}
+	return constant.UPSTREAM_ERROR_OTHER
+}
+
+// Status returns the status a request failing with an error of class is answered with: 504 Gateway Timeout for
+// timeouts, 503 Service Unavailable when no member of the upstream group can take the request and 502 Bad Gateway
+// otherwise.
+func Status(class string) int {
+	switch class {
+	case constant.UPSTREAM_ERROR_TIMEOUT:
+		return http.StatusGatewayTimeout
+	case constant.UPSTREAM_ERROR_UNAVAILABLE:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
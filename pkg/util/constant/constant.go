@@ -16,6 +16,8 @@ const LOG_ROOT = "logs"
 const DEFAULT_WEBSITE_ROOT_DIR = BASE + "/" + HTTP_SERVER + "/" + DEFAULT_WEBSITE_ROOT
 const DEFAULT_IP = "127.0.0.1"
 const CONFIG_FILE = "jinx_config.json"
+const ACME_CERTIFICATE_DIR = BASE + "/certificates"
+const PID_FILE_PATH = BASE + "/jinx.pid"
 
 const JINX_ICO_URL = "https://gemkox-spaces.nyc3.cdn.digitaloceanspaces.com/jinx/jinx.ico"
 const JINX_SVG_URL = "https://gemkox-spaces.nyc3.cdn.digitaloceanspaces.com/jinx/jinx.svg"
@@ -66,17 +68,146 @@ const WEIGHTED_LEAST_RESPONSE_TIME types.LoadBalancerAlgo = "weighted_least_resp
 const RANDOM types.LoadBalancerAlgo = "random"
 
 // RESOURCE_BASED Decisions on where to route traffic are made based on the actual resource usage of a server, such as CPU or memory utilization, ensuring that servers with sufficient resources are prioritized.
+// Not supported: Jinx collects no resource metrics of the upstream servers, configurations naming it are rejected.
 const RESOURCE_BASED types.LoadBalancerAlgo = "resource_based"
 
 // GEOGRAPHICAL Traffic is distributed based on the geographical location of the client, directing clients to the server closest to them.
-// This can significantly reduce latency and improve user experience for geographically distributed applications
+// This can significantly reduce latency and improve user experience for geographically distributed applications.
+// Not supported: Jinx has no geolocation data to place clients, configurations naming it are rejected.
 const GEOGRAPHICAL types.LoadBalancerAlgo = "geographical"
 
+// Default listener limits used when a setting is left at zero in the configuration. Timeouts are in seconds.
+const DEFAULT_HEADER_READ_TIMEOUT = 10
+const DEFAULT_BODY_READ_TIMEOUT = 60
+const DEFAULT_IDLE_TIMEOUT = 120
+const DEFAULT_WRITE_TIMEOUT = 0 // no limit so large downloads and long-poll responses are not cut off
+const DEFAULT_MAX_HEADER_BYTES = 1 << 20
+
+// DEFAULT_ACME_RENEW_BEFORE is the number of days before expiry a certificate obtained through ACME is renewed
+const DEFAULT_ACME_RENEW_BEFORE = 30
+
 const START string = "start"
 const STOP string = "stop"
 const RESTART string = "restart"
+const RELOAD string = "reload"
 const DESTROY string = "destroy"
 
+// Path matching modes of reverse proxy routes
+const ROUTE_MATCH_EXACT = "exact"
+const ROUTE_MATCH_PREFIX = "prefix"
+const ROUTE_MATCH_REGEX = "regex"
+
+// Protocols spoken by the reverse proxy to the upstream of a route
+const UPSTREAM_PROTOCOL_HTTP1 = "http1"
+const UPSTREAM_PROTOCOL_H2 = "h2"
+const UPSTREAM_PROTOCOL_H2C = "h2c"
+
+// Kinds of active health checks of upstream servers
+const HEALTH_CHECK_TCP = "tcp"
+const HEALTH_CHECK_HTTP = "http"
+const HEALTH_CHECK_TLS = "tls"
+
+// Defaults of active health checks, the interval and timeout are in seconds
+const DEFAULT_HEALTH_CHECK_INTERVAL = 10
+const DEFAULT_HEALTH_CHECK_TIMEOUT = 5
+const DEFAULT_HEALTH_CHECK_RISE = 2
+const DEFAULT_HEALTH_CHECK_FALL = 3
+
+// States of the circuit breaker of an upstream server
+const BREAKER_CLOSED = "closed"
+const BREAKER_OPEN = "open"
+const BREAKER_HALF_OPEN = "half_open"
+
+// Defaults of the circuit breaker, durations are in seconds
+const DEFAULT_BREAKER_WINDOW = 10
+const DEFAULT_BREAKER_BACKOFF = 30
+const DEFAULT_BREAKER_MAX_BACKOFF = 300
+const DEFAULT_BREAKER_HALF_OPEN_REQUESTS = 1
+
+// DEFAULT_RETRY_ATTEMPTS is the number of tries of a request or connection to an upstream group, the first included
+const DEFAULT_RETRY_ATTEMPTS = 3
+
+// REQUEST_ID_HEADER identifies a request in the logs of the reverse proxy and of its upstreams
+const REQUEST_ID_HEADER = "X-Request-Id"
+
+// CACHE_STATUS_HEADER tells clients how the response cache of the reverse proxy served a request
+const CACHE_STATUS_HEADER = "X-Cache"
+
+// Values of the cache status header
+const CACHE_HIT = "HIT"                 // served from the cache
+const CACHE_MISS = "MISS"               // not in the cache, fetched from the upstream
+const CACHE_EXPIRED = "EXPIRED"         // stale in the cache, fetched again from the upstream
+const CACHE_REVALIDATED = "REVALIDATED" // stale in the cache, confirmed unchanged by the upstream
+const CACHE_UPDATING = "UPDATING"       // stale in the cache, served while revalidated in the background
+const CACHE_STALE = "STALE"             // stale in the cache, served because the upstream failed
+const CACHE_BYPASS = "BYPASS"           // not looked up in the cache
+
+// Defaults of the response cache of the reverse proxy, sizes are in megabytes
+const DEFAULT_CACHE_MAX_ENTRY_SIZE = 8
+const DEFAULT_CACHE_DIR = "cache"
+
+// Keys of rate limit rules, header keys are written as header:Name
+const RATE_LIMIT_CLIENT_IP = "client_ip"
+const RATE_LIMIT_ROUTE = "route"
+const RATE_LIMIT_HEADER_PREFIX = "header:"
+
+// DEFAULT_WEBSOCKET_IDLE_TIMEOUT is the idle timeout of proxied WebSocket connections in seconds
+const DEFAULT_WEBSOCKET_IDLE_TIMEOUT = 300
+
+// DEFAULT_RATE_LIMIT_PERIOD is the period of rate limit rules in seconds
+const DEFAULT_RATE_LIMIT_PERIOD = 1
+
+// Sticky assignments of traffic splits, header assignments are written as header:Name
+const SPLIT_STICKY_CLIENT_IP = "client_ip"
+const SPLIT_STICKY_COOKIE = "cookie"
+const SPLIT_STICKY_HEADER_PREFIX = "header:"
+const DEFAULT_SPLIT_COOKIE = "jinx_split"
+
+// Defaults of the request mirroring of reverse proxy routes, the body size is in bytes and the timeout in seconds
+const DEFAULT_MIRROR_PERCENTAGE = 100
+const DEFAULT_MIRROR_MAX_BODY_SIZE = 1 << 20
+const DEFAULT_MIRROR_TIMEOUT = 5
+
+// Defaults of the body buffering of reverse proxy routes, buffers spill to files in the body buffer directory of
+// the working directory of the server once they are larger than the memory buffer size in bytes
+const DEFAULT_BODY_MEMORY_BUFFER_SIZE = 1 << 20
+const BODY_BUFFER_DIR = "body_buffers"
+
+// Defaults of the upstream connection pools of the reverse proxy, timeouts are in seconds
+const DEFAULT_POOL_MAX_IDLE_CONNS = 100
+const DEFAULT_POOL_MAX_IDLE_CONNS_PER_HOST = 32
+const DEFAULT_POOL_IDLE_CONN_TIMEOUT = 90
+const DEFAULT_POOL_DIAL_TIMEOUT = 30
+const DEFAULT_POOL_TLS_HANDSHAKE_TIMEOUT = 10
+const DEFAULT_POOL_KEEP_ALIVE = 30
+
+// ADMIN_UPSTREAMS_PATH is the path of the admin listener reporting the state of the upstream servers
+const ADMIN_UPSTREAMS_PATH = "/upstreams"
+
+// ADMIN_POOLS_PATH is the path of the admin listener reporting the connection pools of the reverse proxy
+const ADMIN_POOLS_PATH = "/pools"
+
+// Classes of the errors of requests the proxies could not forward to their upstream, as logged with the error
+const UPSTREAM_ERROR_REFUSED = "refused"
+const UPSTREAM_ERROR_DNS = "dns"
+const UPSTREAM_ERROR_TLS = "tls"
+const UPSTREAM_ERROR_TIMEOUT = "timeout"
+const UPSTREAM_ERROR_RESET = "reset"
+const UPSTREAM_ERROR_UNAVAILABLE = "unavailable" // no member of the upstream group can take the request
+const UPSTREAM_ERROR_OTHER = "other"
+
+// Verify modes of mutual TLS client authentication
+const CLIENT_AUTH_NONE = "none"
+const CLIENT_AUTH_OPTIONAL = "optional"
+const CLIENT_AUTH_REQUIRE = "require"
+
+// Headers carrying the verified client certificate to upstream servers
+const HEADER_CLIENT_VERIFY = "X-Client-Verify"
+const HEADER_CLIENT_SUBJECT = "X-Client-Subject"
+const HEADER_CLIENT_SAN = "X-Client-SAN"
+const HEADER_CLIENT_ISSUER = "X-Client-Issuer"
+const HEADER_CLIENT_SERIAL = "X-Client-Serial"
+
 const INVALID_WEBSITE_DIR = 200 // invalid website directory
 const INVALID_PORT = 201        // invalid port number
 const INVALID_CERT_PATH = 202
@@ -89,3 +220,20 @@ const WRITE_FILE_ERR = 208
 const ERR_INVALID_ROUTE_TABLE = 209
 const ERR_INVALID_BLACK_LIST = 210
 const ERR_INVALID_SERVER_POOL_CONFIG = 211
+const ERR_INVALID_LISTENER_LIMITS = 212
+const ERR_INVALID_ACME_CONFIG = 213
+const ERR_RELOAD_CERTIFICATE = 214
+const ERR_INVALID_CLIENT_AUTH_CONFIG = 215
+const ERR_INVALID_LISTENER_CONFIG = 216
+const ERR_INVALID_TLS_POLICY = 217
+const ERR_INVALID_HEALTH_CHECK = 218
+const ERR_INVALID_CIRCUIT_BREAKER = 219
+const ERR_INVALID_RETRY_CONFIG = 220
+const ERR_INVALID_TRUSTED_PROXIES = 221
+const ERR_INVALID_CACHE_CONFIG = 222
+const ERR_INVALID_RATE_LIMIT = 223
+const ERR_INVALID_WEBSOCKET_CONFIG = 224
+const ERR_INVALID_UPSTREAM_TLS_CONFIG = 225
+const ERR_INVALID_CONNECTION_POOL_CONFIG = 226
+const ERR_INVALID_ERROR_PAGES = 227
+const ERR_INVALID_ALGORITHM = 228
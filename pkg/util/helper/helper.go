@@ -1,13 +1,20 @@
 package helper
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
@@ -259,6 +266,87 @@ func ValidatePort(port int) (bool, error) {
 	return true, nil
 }
 
+// ValidateListenerLimits checks that none of the connection limits or timeouts of a listener are negative.
+// Zero values are accepted since they select the Jinx default for the corresponding setting.
+//
+// Parameters:
+//   - limits: The types.ListenerLimits read from the server configuration.
+//
+// Returns:
+//   - An error naming the first invalid setting, or nil if all settings are usable.
+func ValidateListenerLimits(limits types.ListenerLimits) error {
+	settings := []struct {
+		name  string
+		value int64
+	}{
+		{"HeaderReadTimeout", int64(limits.HeaderReadTimeout)},
+		{"BodyReadTimeout", int64(limits.BodyReadTimeout)},
+		{"IdleTimeout", int64(limits.IdleTimeout)},
+		{"WriteTimeout", int64(limits.WriteTimeout)},
+		{"MaxHeaderBytes", int64(limits.MaxHeaderBytes)},
+		{"MaxBodyBytes", limits.MaxBodyBytes},
+		{"MaxConnections", int64(limits.MaxConnections)},
+		{"MaxConnectionsPerIP", int64(limits.MaxConnectionsPerIP)},
+	}
+
+	for _, setting := range settings {
+		if setting.value < 0 {
+			return fmt.Errorf("%s must not be negative", setting.name)
+		}
+	}
+
+	return nil
+}
+
+// ValidateACMEConfig checks the ACME settings of a server. An empty host list disables ACME and is always valid.
+// Host names must be plain DNS names since wildcard certificates and IP addresses cannot be validated through
+// the HTTP-01 or TLS-ALPN-01 challenges, and the directory must be reached over HTTPS.
+//
+// Parameters:
+//   - config: The types.ACMEConfig read from the server configuration.
+//
+// Returns:
+//   - An error describing the first invalid setting, or nil if the settings are usable.
+func ValidateACMEConfig(config types.ACMEConfig) error {
+	if len(config.Hosts) == 0 {
+		return nil
+	}
+
+	for _, host := range config.Hosts {
+		if host == "" || strings.Contains(host, "*") || net.ParseIP(host) != nil {
+			return fmt.Errorf("%q is not a valid ACME host name", host)
+		}
+	}
+
+	if config.DirectoryURL != "" {
+		directoryURL, err := url.Parse(config.DirectoryURL)
+		if err != nil {
+			return err
+		}
+		if directoryURL.Scheme != "https" {
+			return fmt.Errorf("ACME directory %s must use https", config.DirectoryURL)
+		}
+	}
+
+	if config.DirectoryCAFile != "" {
+		if _, err := os.Stat(config.DirectoryCAFile); err != nil {
+			return err
+		}
+	}
+
+	if config.RenewBefore < 0 {
+		return fmt.Errorf("RenewBefore must not be negative")
+	}
+
+	if config.HTTPChallengePort != 0 {
+		if _, err := ValidatePort(config.HTTPChallengePort); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // transfer is a utility function designed to relay data between two streams: `src` (source) and `dst` (destination).
 // It reads data from `src` and writes it to `dst`, facilitating the bidirectional flow of data in scenarios such as
 // proxying HTTP requests, handling WebSocket connections, or any other context where data needs to be passed
@@ -300,3 +388,488 @@ func Transfer(dst io.WriteCloser, src io.ReadCloser) {
 	}()
 	_, _ = io.Copy(dst, src)
 }
+
+// ValidateClientAuthConfig checks the mutual TLS settings of a listener. The verify mode must be none, optional or
+// require, and unless it is none a CA bundle must be given. Every configured file must exist.
+//
+// Parameters:
+//   - config: The types.ClientAuthConfig read from the server configuration.
+//
+// Returns:
+//   - An error describing the first invalid setting, or nil if the settings are usable.
+func ValidateClientAuthConfig(config types.ClientAuthConfig) error {
+	switch strings.ToLower(config.Mode) {
+	case "", constant.CLIENT_AUTH_NONE:
+		return nil
+	case constant.CLIENT_AUTH_OPTIONAL, constant.CLIENT_AUTH_REQUIRE:
+	default:
+		return fmt.Errorf("%q is not a valid client verify mode, valid modes are: none, optional and require", config.Mode)
+	}
+
+	if config.CAFile == "" {
+		return fmt.Errorf("a CA bundle is required to verify client certificates")
+	}
+	if _, err := os.Stat(config.CAFile); err != nil {
+		return err
+	}
+
+	for _, crlFile := range config.CRLFiles {
+		if _, err := os.Stat(crlFile); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateClientAuthRoutes checks the routes of the reverse proxy requiring a client certificate. Such routes can
+// only be served when the listener asks clients for a certificate, so the verify mode must be optional or require.
+//
+// Parameters:
+//   - routes: The types.ClientAuthRoute list read from the server configuration.
+//   - config: The types.ClientAuthConfig of the listener.
+//
+// Returns:
+//   - An error describing the first invalid route, or nil if the routes are usable.
+func ValidateClientAuthRoutes(routes []types.ClientAuthRoute, config types.ClientAuthConfig) error {
+	if len(routes) == 0 {
+		return nil
+	}
+
+	mode := strings.ToLower(config.Mode)
+	if mode != constant.CLIENT_AUTH_OPTIONAL && mode != constant.CLIENT_AUTH_REQUIRE {
+		return fmt.Errorf("routes requiring a client certificate need the optional or require client verify mode")
+	}
+
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("%q is not a valid route path, paths must start with /", route.Path)
+		}
+	}
+
+	return nil
+}
+
+// ValidateListeners checks the additional listeners of a server. Every listener needs a valid port, TLS
+// listeners need the server to have certificates and listeners redirecting to HTTPS need an HTTPS port to
+// redirect to. A listener cannot both serve TLS and redirect to HTTPS.
+//
+// Parameters:
+//   - listeners: The types.ListenerConfig list read from the server configuration.
+//   - redirect: The types.HTTPSRedirectConfig of the server.
+//   - tlsEnabled: Whether the server has certificates, either from files or through ACME.
+//
+// Returns:
+//   - An error describing the first invalid listener, or nil if the listeners are usable.
+func ValidateListeners(listeners []types.ListenerConfig, redirect types.HTTPSRedirectConfig, tlsEnabled bool) error {
+	anyTLS := false
+	for _, listener := range listeners {
+		if _, err := ValidatePort(listener.Port); err != nil {
+			return err
+		}
+		if listener.IP != "" && net.ParseIP(listener.IP) == nil {
+			return fmt.Errorf("%s is not a valid listener ip address", listener.IP)
+		}
+		if listener.TLS && listener.RedirectToHTTPS {
+			return fmt.Errorf("listener on port %d cannot both serve TLS and redirect to HTTPS", listener.Port)
+		}
+		if listener.TLS && !tlsEnabled {
+			return fmt.Errorf("listener on port %d serves TLS but no certificate is configured", listener.Port)
+		}
+		anyTLS = anyTLS || listener.TLS
+	}
+
+	for _, listener := range listeners {
+		if listener.RedirectToHTTPS && redirect.Port == 0 && !tlsEnabled && !anyTLS {
+			return fmt.Errorf("listener on port %d redirects to HTTPS but the server has no HTTPS listener", listener.Port)
+		}
+	}
+
+	if redirect.Port != 0 {
+		if _, err := ValidatePort(redirect.Port); err != nil {
+			return err
+		}
+	}
+
+	for _, exemptPath := range redirect.ExemptPaths {
+		if !strings.HasPrefix(exemptPath, "/") {
+			return fmt.Errorf("%q is not a valid exempt path, paths must start with /", exemptPath)
+		}
+	}
+
+	return nil
+}
+
+// ValidateHealthCheckConfig checks the active health check settings of an upstream group or server pool. An empty
+// Type disables the checks and is always valid, zero values select the Jinx defaults.
+//
+// Parameters:
+//   - config: The types.HealthCheckConfig read from the configuration.
+//
+// Returns:
+//   - An error naming the first invalid setting, or nil if all settings are usable.
+func ValidateHealthCheckConfig(config types.HealthCheckConfig) error {
+	switch config.Type {
+	case "":
+		return nil
+	case constant.HEALTH_CHECK_TCP, constant.HEALTH_CHECK_HTTP, constant.HEALTH_CHECK_TLS:
+	default:
+		return fmt.Errorf("%q is not a valid health check type, valid types are: tcp, http and tls", config.Type)
+	}
+
+	settings := []struct {
+		name  string
+		value int
+	}{
+		{"Interval", config.Interval},
+		{"Timeout", config.Timeout},
+		{"Rise", config.Rise},
+		{"Fall", config.Fall},
+	}
+	for _, setting := range settings {
+		if setting.value < 0 {
+			return fmt.Errorf("health check %s must not be negative", setting.name)
+		}
+	}
+
+	if config.Path != "" && !strings.HasPrefix(config.Path, "/") {
+		return fmt.Errorf("health check path %q must start with /", config.Path)
+	}
+
+	for _, status := range config.ExpectedStatus {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("%d is not a valid HTTP status", status)
+		}
+	}
+
+	if _, err := regexp.Compile(config.ExpectedBody); err != nil {
+		return fmt.Errorf("invalid health check body expression %q: %v", config.ExpectedBody, err)
+	}
+
+	return nil
+}
+
+// ValidateCircuitBreakerConfig checks that none of the circuit breaker settings of an upstream group or server pool
+// are negative and that the maximum backoff is not shorter than the initial one. Zero values select the Jinx
+// defaults, zero Failures disables circuit breaking.
+//
+// Parameters:
+//   - config: The types.CircuitBreakerConfig read from the configuration.
+//
+// Returns:
+//   - An error naming the first invalid setting, or nil if all settings are usable.
+func ValidateCircuitBreakerConfig(config types.CircuitBreakerConfig) error {
+	settings := []struct {
+		name  string
+		value int
+	}{
+		{"Failures", config.Failures},
+		{"Window", config.Window},
+		{"Backoff", config.Backoff},
+		{"MaxBackoff", config.MaxBackoff},
+		{"HalfOpenRequests", config.HalfOpenRequests},
+	}
+	for _, setting := range settings {
+		if setting.value < 0 {
+			return fmt.Errorf("circuit breaker %s must not be negative", setting.name)
+		}
+	}
+
+	if config.Backoff > 0 && config.MaxBackoff > 0 && config.MaxBackoff < config.Backoff {
+		return fmt.Errorf("circuit breaker MaxBackoff %d is shorter than Backoff %d", config.MaxBackoff, config.Backoff)
+	}
+
+	return nil
+}
+
+// ValidateRetryConfig checks that none of the retry settings of an upstream group or of the server pool are negative
+// and that the retried statuses are valid HTTP statuses. Zero values select the Jinx defaults.
+//
+// Parameters:
+//   - config: The types.RetryConfig read from the configuration.
+//
+// Returns:
+//   - An error naming the first invalid setting, or nil if all settings are usable.
+func ValidateRetryConfig(config types.RetryConfig) error {
+	settings := []struct {
+		name  string
+		value int
+	}{
+		{"Attempts", config.Attempts},
+		{"TryTimeout", config.TryTimeout},
+		{"Deadline", config.Deadline},
+	}
+	for _, setting := range settings {
+		if setting.value < 0 {
+			return fmt.Errorf("retry %s must not be negative", setting.name)
+		}
+	}
+
+	for _, status := range config.Statuses {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("%d is not a valid HTTP status", status)
+		}
+	}
+
+	return nil
+}
+
+// ValidateAdminConfig checks the address of the admin listener. A zero port disables the listener and is always
+// valid.
+func ValidateAdminConfig(config types.AdminConfig) error {
+	if config.Port == 0 {
+		return nil
+	}
+
+	if _, err := ValidatePort(config.Port); err != nil {
+		return fmt.Errorf("admin listener: %v", err)
+	}
+
+	if config.IP != "" && net.ParseIP(config.IP) == nil {
+		return fmt.Errorf("admin listener: %q is not a valid IP address", config.IP)
+	}
+
+	return nil
+}
+
+// ParseTrustedProxies parses the trusted proxies of the reverse proxy. Every entry is an IP address or a CIDR range,
+// addresses are turned into ranges holding just that address.
+//
+// Parameters:
+//   - proxies: The IP addresses and CIDR ranges read from the configuration.
+//
+// Returns:
+//   - The ranges of the trusted proxies, or an error naming the first entry that is neither an IP address nor a
+//     CIDR range.
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	ranges := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if strings.Contains(proxy, "/") {
+			_, ipRange, err := net.ParseCIDR(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q is not a valid CIDR range", proxy)
+			}
+			ranges = append(ranges, ipRange)
+			continue
+		}
+
+		ip := net.ParseIP(proxy)
+		if ip == nil {
+			return nil, fmt.Errorf("trusted proxy %q is not a valid IP address", proxy)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 8 * net.IPv4len
+		}
+		ranges = append(ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return ranges, nil
+}
+
+// IsTrustedIP reports whether ip belongs to one of the ranges of the trusted proxies.
+func IsTrustedIP(ip net.IP, trustedProxies []*net.IPNet) bool {
+	for _, ipRange := range trustedProxies {
+		if ipRange.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealClientIP returns the IP address of the client of r. When the peer is a trusted proxy, the client is the last
+// address of X-Forwarded-For that is not a trusted proxy itself, or the X-Real-IP the proxy sent.
+//
+// Parameters:
+//   - r: The *http.Request received from the peer.
+//   - trustedProxies: The ranges of the trusted proxies, see ParseTrustedProxies.
+//
+// Returns:
+//   - The IP address of the client, the address of the peer when it is not a trusted proxy.
+func RealClientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		peer = host
+	}
+	if ip := net.ParseIP(peer); ip == nil || !IsTrustedIP(ip, trustedProxies) {
+		return peer
+	}
+
+	var chain []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, address := range strings.Split(value, ",") {
+			chain = append(chain, strings.TrimSpace(address))
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := net.ParseIP(chain[i])
+		if ip == nil {
+			break
+		}
+		if !IsTrustedIP(ip, trustedProxies) || i == 0 {
+			return ip.String()
+		}
+	}
+
+	if ip := net.ParseIP(r.Header.Get("X-Real-IP")); ip != nil {
+		return ip.String()
+	}
+	return peer
+}
+
+// ValidateCacheConfig checks that the sizes of the response cache are not negative and that a single response may
+// not be larger than the cache.
+//
+// Parameters:
+//   - config: The types.CacheConfig read from the configuration.
+//
+// Returns:
+//   - An error naming the first invalid setting, or nil if all settings are usable.
+func ValidateCacheConfig(config types.CacheConfig) error {
+	if config.MaxSize < 0 {
+		return fmt.Errorf("cache MaxSize must not be negative")
+	}
+	if config.MaxEntrySize < 0 {
+		return fmt.Errorf("cache MaxEntrySize must not be negative")
+	}
+	if config.MaxSize > 0 && config.MaxEntrySize > config.MaxSize {
+		return fmt.Errorf("cache MaxEntrySize %d is larger than MaxSize %d", config.MaxEntrySize, config.MaxSize)
+	}
+	return nil
+}
+
+// ValidateRateLimitConfig checks the rules of the rate limiter and its trusted proxies. Every rule must allow a
+// positive number of requests and have a known key, its period, burst and paths must be usable.
+//
+// Parameters:
+//   - config: The types.RateLimitConfig read from the configuration.
+//
+// Returns:
+//   - An error naming the first invalid setting, or nil if all settings are usable.
+func ValidateRateLimitConfig(config types.RateLimitConfig) error {
+	if config.MaxTunnels < 0 {
+		return fmt.Errorf("rate limit MaxTunnels must not be negative")
+	}
+	if _, err := ParseTrustedProxies(config.TrustedProxies); err != nil {
+		return err
+	}
+
+	for i, rule := range config.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i)
+		}
+
+		if rule.Requests <= 0 {
+			return fmt.Errorf("rate limit rule %s: Requests must be positive", name)
+		}
+		if rule.Period < 0 || rule.Burst < 0 {
+			return fmt.Errorf("rate limit rule %s: Period and Burst must not be negative", name)
+		}
+
+		switch {
+		case rule.Key == "", rule.Key == constant.RATE_LIMIT_CLIENT_IP, rule.Key == constant.RATE_LIMIT_ROUTE:
+		case strings.HasPrefix(rule.Key, constant.RATE_LIMIT_HEADER_PREFIX) && len(rule.Key) > len(constant.RATE_LIMIT_HEADER_PREFIX):
+		default:
+			return fmt.Errorf("rate limit rule %s: %q is not a key, expected client_ip, header:Name or route", name, rule.Key)
+		}
+
+		for _, path := range rule.Paths {
+			if !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("rate limit rule %s: path %q must start with /", name, path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateConnectionPoolConfig checks that none of the settings of an upstream connection pool of the reverse proxy
+// are negative. Zero values select the Jinx defaults.
+//
+// Parameters:
+//   - config: The types.ConnectionPoolConfig read from the configuration.
+//
+// Returns:
+//   - An error naming the first invalid setting, or nil if all settings are usable.
+func ValidateConnectionPoolConfig(config types.ConnectionPoolConfig) error {
+	settings := []struct {
+		name  string
+		value int
+	}{
+		{"MaxIdleConns", config.MaxIdleConns},
+		{"MaxIdleConnsPerHost", config.MaxIdleConnsPerHost},
+		{"MaxConnsPerHost", config.MaxConnsPerHost},
+		{"IdleConnTimeout", config.IdleConnTimeout},
+		{"DialTimeout", config.DialTimeout},
+		{"TLSHandshakeTimeout", config.TLSHandshakeTimeout},
+		{"ResponseHeaderTimeout", config.ResponseHeaderTimeout},
+		{"KeepAlive", config.KeepAlive},
+	}
+	for _, setting := range settings {
+		if setting.value < 0 {
+			return fmt.Errorf("connection pool %s must not be negative", setting.name)
+		}
+	}
+
+	return nil
+}
+
+// ValidateWebSocketConfig checks the WebSocket settings of the reverse proxy. Timeouts and the connection limit
+// must not be negative and every allowed origin must be * or a scheme and a host, whose first label may be a
+// wildcard.
+//
+// Parameters:
+//   - config: The types.WebSocketConfig read from the configuration.
+//
+// Returns:
+//   - An error naming the first invalid setting, or nil if all settings are usable.
+func ValidateWebSocketConfig(config types.WebSocketConfig) error {
+	if config.IdleTimeout < 0 || config.MaxLifetime < 0 || config.MaxConnections < 0 {
+		return fmt.Errorf("WebSocket IdleTimeout, MaxLifetime and MaxConnections must not be negative")
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || strings.Contains(strings.TrimPrefix(u.Host, "*."), "*") {
+			return fmt.Errorf("%q is not a valid origin, expected a scheme and a host such as https://app.example.com", origin)
+		}
+	}
+
+	return nil
+}
+
+// ValidateErrorPages checks the error pages of a proxy. Pages may only be set for the statuses the proxies answer
+// failed upstream requests with, 502, 503 and 504, and must be readable files.
+//
+// Parameters:
+//   - pages: The types.ErrorPages read from the configuration.
+//
+// Returns:
+//   - An error naming the first invalid page, or nil if all pages are usable.
+func ValidateErrorPages(pages types.ErrorPages) error {
+	for status, file := range pages {
+		if status != http.StatusBadGateway && status != http.StatusServiceUnavailable && status != http.StatusGatewayTimeout {
+			return fmt.Errorf("error pages can only be set for 502, 503 and 504, not for %d", status)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("error page of %d: %v", status, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("error page of %d: %s is a directory", status, file)
+		}
+	}
+
+	return nil
+}
+
+// NewRequestID returns a random request ID, 16 bytes written as hexadecimal digits.
+func NewRequestID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
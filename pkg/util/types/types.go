@@ -3,50 +3,104 @@ package types
 import (
 	"net/http"
 	"sync"
+	"sync/atomic"
 )
 
 type JinxServer interface {
 	Start() JinxServer
 	Stop()
 	Restart() JinxServer
+	Reload()
 	Destroy()
 }
 
 type JinxHttpServerConfig struct {
-	IP          string
-	Port        int
-	LogRoot     string
-	WebsiteRoot string
-	CertFile    string
-	KeyFile     string
+	IP                string
+	Port              int
+	LogRoot           string
+	WebsiteRoot       string
+	CertFile          string
+	KeyFile           string
+	Certificates      []CertificateConfig
+	ACME              ACMEConfig
+	CertificateReload CertificateReloadConfig
+	ClientAuth        ClientAuthConfig
+	TLSPolicy         TLSPolicy
+	Limits            ListenerLimits
+	Listeners         []ListenerConfig
+	HTTPSRedirect     HTTPSRedirectConfig
+	RateLimit         RateLimitConfig
 }
 
 type JinxReverseProxyServerConfig struct {
-	IP         string
-	Port       int
-	LogRoot    string
-	RouteTable RouteTable
-	CertFile   string
-	KeyFile    string
+	IP                string
+	Port              int
+	LogRoot           string
+	RouteTable        RouteTable
+	RouteFile         string // path of the route file Reload reads the routes from again, routes are not reloaded when empty
+	Routes            []Route
+	UpstreamGroups    map[string]UpstreamGroupConfig
+	ClientAuthRoutes  []ClientAuthRoute
+	CertFile          string
+	KeyFile           string
+	Certificates      []CertificateConfig
+	ACME              ACMEConfig
+	CertificateReload CertificateReloadConfig
+	ClientAuth        ClientAuthConfig
+	TLSPolicy         TLSPolicy
+	Limits            ListenerLimits
+	Listeners         []ListenerConfig
+	HTTPSRedirect     HTTPSRedirectConfig
+	Admin             AdminConfig
+	TrustedProxies    []string // IP addresses or CIDR ranges whose forwarding headers are honored
+	PreserveHost      bool     // forward the Host of the client instead of the host of the upstream
+	Cache             CacheConfig
+	RateLimit         RateLimitConfig
+	WebSocket         WebSocketConfig
+	ConnectionPool    ConnectionPoolConfig
+	H2C               bool // accept cleartext HTTP/2 from clients with prior knowledge, as gRPC clients without TLS send
+	ErrorPages        ErrorPages
 }
 
 type JinxForwardProxyServerConfig struct {
-	IP        string
-	Port      int
-	LogRoot   string
-	BlackList []string
-	CertFile  string
-	KeyFile   string
+	IP                string
+	Port              int
+	LogRoot           string
+	BlackList         []string
+	CertFile          string
+	KeyFile           string
+	Certificates      []CertificateConfig
+	ACME              ACMEConfig
+	CertificateReload CertificateReloadConfig
+	ClientAuth        ClientAuthConfig
+	TLSPolicy         TLSPolicy
+	Limits            ListenerLimits
+	Listeners         []ListenerConfig
+	HTTPSRedirect     HTTPSRedirectConfig
+	RateLimit         RateLimitConfig
+	ErrorPages        ErrorPages
 }
 
 type JinxLoadBalancingServerConfig struct {
-	IP         string
-	Port       int
-	LogRoot    string
-	CertFile   string
-	KeyFile    string
-	ServerPool []UpStreamServer
-	Algorithm  LoadBalancerAlgo
+	IP                string
+	Port              int
+	LogRoot           string
+	CertFile          string
+	KeyFile           string
+	Certificates      []CertificateConfig
+	ACME              ACMEConfig
+	CertificateReload CertificateReloadConfig
+	ClientAuth        ClientAuthConfig
+	TLSPolicy         TLSPolicy
+	ServerPool        []UpStreamServer
+	Algorithm         LoadBalancerAlgo
+	HealthCheck       HealthCheckConfig
+	CircuitBreaker    CircuitBreakerConfig
+	Retry             RetryConfig
+	UpstreamTLS       *UpstreamTLSConfig // re-encrypts the connections to the server pool when set
+	Admin             AdminConfig
+	Limits            ListenerLimits
+	Listeners         []ListenerConfig
 }
 
 type JinxResourceResponse struct {
@@ -55,27 +109,64 @@ type JinxResourceResponse struct {
 }
 
 type HttpServerConfig struct {
-	Port           int
-	IP             string
-	CertFile       string
-	KeyFile        string
-	WebsiteRootDir string
+	Port              int
+	IP                string
+	CertFile          string
+	KeyFile           string
+	Certificates      []CertificateConfig
+	ACME              ACMEConfig
+	CertificateReload CertificateReloadConfig
+	ClientAuth        ClientAuthConfig
+	TLSPolicy         TLSPolicy
+	WebsiteRootDir    string
+	Limits            ListenerLimits
+	Listeners         []ListenerConfig
+	HTTPSRedirect     HTTPSRedirectConfig
+	RateLimit         RateLimitConfig
 }
 
 type ReverseProxyConfig struct {
-	Port         int
-	IP           string
-	CertFile     string
-	KeyFile      string
-	RoutingTable string
+	Port              int
+	IP                string
+	CertFile          string
+	KeyFile           string
+	Certificates      []CertificateConfig
+	ACME              ACMEConfig
+	CertificateReload CertificateReloadConfig
+	ClientAuth        ClientAuthConfig
+	TLSPolicy         TLSPolicy
+	RoutingTable      string
+	ClientAuthRoutes  []ClientAuthRoute
+	Limits            ListenerLimits
+	Listeners         []ListenerConfig
+	HTTPSRedirect     HTTPSRedirectConfig
+	Admin             AdminConfig
+	TrustedProxies    []string // IP addresses or CIDR ranges whose forwarding headers are honored
+	PreserveHost      bool     // forward the Host of the client instead of the host of the upstream
+	Cache             CacheConfig
+	RateLimit         RateLimitConfig
+	WebSocket         WebSocketConfig
+	ConnectionPool    ConnectionPoolConfig
+	H2C               bool // accept cleartext HTTP/2 from clients with prior knowledge, as gRPC clients without TLS send
+	ErrorPages        ErrorPages
 }
 
 type ForwardProxyConfig struct {
-	Port      int
-	IP        string
-	CertFile  string
-	KeyFile   string
-	BlackList string
+	Port              int
+	IP                string
+	CertFile          string
+	KeyFile           string
+	Certificates      []CertificateConfig
+	ACME              ACMEConfig
+	CertificateReload CertificateReloadConfig
+	ClientAuth        ClientAuthConfig
+	TLSPolicy         TLSPolicy
+	BlackList         string
+	Limits            ListenerLimits
+	Listeners         []ListenerConfig
+	HTTPSRedirect     HTTPSRedirectConfig
+	RateLimit         RateLimitConfig
+	ErrorPages        ErrorPages
 }
 
 type LoadBalancerConfig struct {
@@ -83,8 +174,20 @@ type LoadBalancerConfig struct {
 	IP                   string
 	CertFile             string
 	KeyFile              string
+	Certificates         []CertificateConfig
+	ACME                 ACMEConfig
+	CertificateReload    CertificateReloadConfig
+	ClientAuth           ClientAuthConfig
+	TLSPolicy            TLSPolicy
 	ServerPoolConfigPath string
 	Algo                 LoadBalancerAlgo
+	HealthCheck          HealthCheckConfig
+	CircuitBreaker       CircuitBreakerConfig
+	Retry                RetryConfig
+	UpstreamTLS          *UpstreamTLSConfig // re-encrypts the connections to the server pool when set
+	Admin                AdminConfig
+	Limits               ListenerLimits
+	Listeners            []ListenerConfig
 }
 
 type JinxServerConfiguration struct {
@@ -95,7 +198,115 @@ type JinxServerConfiguration struct {
 	LoadBalancerConfig LoadBalancerConfig
 }
 
-type LoadBalancingAlgorithm func([]UpStreamServer, int, *sync.Mutex) UpStreamServer
+// CertificateConfig describes an additional certificate presented by a TLS listener. ServerNames is optional,
+// by default the certificate is selected for the DNS names it was issued for. Wildcard names such as
+// *.example.com are supported.
+type CertificateConfig struct {
+	CertFile    string
+	KeyFile     string
+	ServerNames []string
+}
+
+// ACMEConfig enables automatic certificate management through an ACME (RFC 8555) certificate authority.
+// Certificates for Hosts are obtained on the first TLS handshake that asks for them, stored under the Jinx
+// base directory and renewed RenewBefore days before they expire.
+type ACMEConfig struct {
+	Hosts             []string // host names certificates are requested for, ACME is disabled when empty
+	Email             string   // contact address registered with the ACME account
+	DirectoryURL      string   // ACME directory, defaults to Let's Encrypt production
+	DirectoryCAFile   string   // CA bundle trusted when talking to the directory, e.g. the Pebble test CA
+	RenewBefore       int      // days before expiry a certificate is renewed, defaults to 30
+	HTTPChallengePort int      // port of a plain HTTP listener answering HTTP-01 challenges, 0 disables it
+}
+
+// CertificateReloadConfig controls how changes of the certificate and key files are detected while the server
+// is running. Changed certificates are always picked up by the reload command.
+type CertificateReloadConfig struct {
+	Interval int  // seconds between checks of the certificate files, 0 disables periodic checks
+	Watch    bool // reload as soon as the files change using file system notifications (inotify on Linux)
+}
+
+// ClientAuthConfig enables mutual TLS on a listener. Client certificates are verified against the CAs of
+// CAFile and rejected when they are revoked by one of the certificate revocation lists of CRLFiles.
+type ClientAuthConfig struct {
+	Mode     string   // none, optional or require, defaults to none
+	CAFile   string   // PEM bundle of the CAs client certificates must be issued by
+	CRLFiles []string // PEM or DER encoded CRLs, reloaded together with the certificates
+}
+
+// ClientAuthRoute requires a verified client certificate for the requests of the reverse proxy whose path
+// starts with Path. When AllowedNames is set, the certificate must also carry one of the names, either as
+// its subject common name or as a subject alternative name.
+type ClientAuthRoute struct {
+	Path         string
+	AllowedNames []string
+}
+
+// TLSPolicy holds the protocol settings of a TLS listener. Settings left empty keep the Go defaults, except for
+// the ALPN protocols which default to h2 and http/1.1 on the HTTP based modes and to none on the load balancer.
+type TLSPolicy struct {
+	MinVersion               string   // lowest accepted version: 1.0, 1.1, 1.2 or 1.3
+	MaxVersion               string   // highest accepted version: 1.0, 1.1, 1.2 or 1.3
+	CipherSuites             []string // TLS 1.2 cipher suites by IANA name, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+	Curves                   []string // key exchange curves in order of preference: X25519, P-256, P-384 or P-521
+	ALPN                     []string // application protocols offered in order of preference, e.g. h2 and http/1.1
+	SessionTicketKeyFile     string   // file of base64 encoded 32 byte keys, one per line, the first one encrypts new tickets
+	DisableSessionResumption bool     // disables session tickets so that every connection does a full handshake
+}
+
+// ListenerConfig describes an additional address a server listens on next to its main IP and Port. Every
+// listener serves the same content with the certificates of the server when TLS is set. A plain HTTP listener
+// with RedirectToHTTPS set answers requests with a permanent redirect to HTTPS instead of serving them.
+type ListenerConfig struct {
+	IP              string // defaults to the IP of the server
+	Port            int
+	TLS             bool
+	TLSPolicy       *TLSPolicy // TLS settings of the listener, defaults to the TLSPolicy of the server
+	RedirectToHTTPS bool
+}
+
+// HTTPSRedirectConfig controls the redirects issued by listeners with RedirectToHTTPS set. Requests for the
+// ACME HTTP-01 challenge path and for ExemptPaths are served over plain HTTP instead of being redirected.
+type HTTPSRedirectConfig struct {
+	Port        int      // port of the HTTPS listener, defaults to the first listener serving TLS
+	ExemptPaths []string // path prefixes served over plain HTTP
+}
+
+// ListenerLimits holds the connection limits and timeouts applied to a listener. Timeouts are expressed in
+// seconds and sizes in bytes. A zero value means the Jinx default for that setting is used.
+type ListenerLimits struct {
+	HeaderReadTimeout   int   // time allowed to read the request line and headers (slowloris protection)
+	BodyReadTimeout     int   // time allowed to read the request body once the headers have been read
+	IdleTimeout         int   // how long a keep-alive connection may sit idle between requests
+	WriteTimeout        int   // time allowed to write a response, the default is no limit
+	MaxHeaderBytes      int   // maximum size of the request line and headers
+	MaxBodyBytes        int64 // maximum size of a request body, the default is no limit
+	MaxConnections      int   // maximum number of concurrent connections, the default is no limit
+	MaxConnectionsPerIP int   // maximum number of concurrent connections from one client IP, the default is no limit
+}
+
+// LoadBalancingAlgorithm picks the member of pool the next connection or request is sent to. key identifies the
+// client, the hashing algorithm sends the same key to the same member. Nil is returned when the pool is empty.
+type LoadBalancingAlgorithm func(pool *UpstreamPool, key string) *UpstreamMember
+
+// UpstreamPool is the runtime state of a group of upstream servers shared by the balancing algorithms.
+type UpstreamPool struct {
+	Members []*UpstreamMember
+	Next    int // position of the round robin algorithms, guarded by Mutex
+	Mutex   sync.Mutex
+}
+
+// UpstreamMember is a server of an UpstreamPool together with the counters the balancing algorithms rely on.
+type UpstreamMember struct {
+	Address           string // host:port for the load balancer, the upstream URL for the reverse proxy
+	Weight            int
+	Location          string
+	ActiveConnections atomic.Int64
+	ResponseTime      atomic.Int64 // moving average of the response time in nanoseconds
+	CurrentWeight     int          // smooth weighted round robin state, guarded by the pool mutex
+	Unhealthy         atomic.Bool  // set by the active health checks, unhealthy members are not picked
+	Ejected           atomic.Bool  // set by the circuit breaker, ejected members are not picked
+}
 
 type UpStreamServer struct {
 	IP       string
@@ -111,3 +322,276 @@ type ServerMode string
 type LoadBalancerAlgo string
 
 type RouteTable map[string]string
+
+// Route sends the requests matching its Host and Path to Upstream. Match selects how Path is compared with the
+// request path: exact, prefix (the default, matching whole path segments) or regex. The Upstream of a regex route
+// may refer to the captures of the expression as $1 or ${name}, it then replaces the request path entirely and the
+// query of the client is appended to its own. Regex routes are matched against the escaped request path.
+// RequestHeaders and ResponseHeaders change the headers sent to the upstream and returned to the client. Rewrite
+// changes the path sent to the upstream of exact and prefix routes. Protocol selects the HTTP version spoken to the
+// upstream: http1, h2 (HTTP/2 over TLS) or h2c (cleartext HTTP/2), gRPC upstreams need h2 or h2c. When empty,
+// HTTP/2 is used with TLS upstreams offering it and HTTP/1.1 otherwise. Static serves the route from a document root
+// instead, so that one server can mix static and proxied locations.
+type Route struct {
+	Host            string // host name the route applies to, *.example.com matches every subdomain, empty matches any host
+	Path            string
+	Match           string
+	Upstream        string
+	UpstreamGroup   string // name of the upstream group balancing the route, used instead of Upstream
+	RequestHeaders  HeaderRules
+	ResponseHeaders HeaderRules
+	Cache           RouteCacheConfig
+	Rewrite         PathRewrite
+	Protocol        string
+	Mirror          MirrorConfig
+	Split           TrafficSplit
+	TLS             UpstreamTLSConfig // TLS client settings of an https Upstream and of the Upstream of its versions
+	Body            BodyConfig
+	Static          StaticLocation // serves the route from files instead of an upstream
+}
+
+// StaticLocation serves the requests of a route from the files of a document root, as the HTTP server does, instead
+// of forwarding them to an upstream. One server can then host a single page application at / and proxy /api to its
+// backend. The request path is looked up in Root, once the path of the route was removed from it when StripPrefix is
+// set. Directories are answered with their index.html file. Paths without a file are answered with Fallback, such
+// as the index.html of a single page application handling its own routes, or with the 404.html file of Root and
+// 404 Not Found when there is no fallback. Only GET and HEAD requests are served.
+type StaticLocation struct {
+	Root        string // document root, the route is static when set
+	Fallback    string // path of a file relative to Root
+	StripPrefix bool   // not available for regex routes
+}
+
+// PathRewrite changes the path a route sends to its upstream, which is otherwise the request path appended to the
+// path of the upstream URL. StripPrefix removes the path of the route from the request path, ReplacePrefix replaces
+// it with another prefix. Regex and Replacement then rewrite the escaped path, the replacement may refer to the
+// captures of the expression as $1 or ${name} and may add a query. Redirects and cookie paths of the upstream are
+// mapped back to the path of the route.
+type PathRewrite struct {
+	StripPrefix   bool
+	ReplacePrefix string
+	Regex         string
+	Replacement   string
+}
+
+// HeaderRules remove, set and add headers, in this order. Values may refer to the variables of the request as
+// $name or ${name}: client_ip, remote_addr, request_id, host, scheme, method, path, query and upstream.
+type HeaderRules struct {
+	Remove []string
+	Set    map[string]string // values replacing the header
+	Add    map[string]string // values added to the header
+}
+
+// UpstreamGroupConfig is a named group of upstreams a route can be balanced over. Algorithm takes the names used
+// by the load balancer, round_robin when empty.
+type UpstreamGroupConfig struct {
+	Algorithm      LoadBalancerAlgo
+	Members        []UpstreamMemberConfig
+	HealthCheck    HealthCheckConfig
+	CircuitBreaker CircuitBreakerConfig
+	Retry          RetryConfig
+	TLS            UpstreamTLSConfig     // TLS client settings of the https members
+	ConnectionPool *ConnectionPoolConfig // defaults to the ConnectionPool of the server
+}
+
+type UpstreamMemberConfig struct {
+	URL    string
+	Weight int // share of the traffic for the weighted algorithms, 1 when zero
+}
+
+// HealthCheckConfig enables active health checks of the members of an upstream group or of the server pool of the
+// load balancer. A member is marked unhealthy after Fall consecutive failed checks and healthy again after Rise
+// consecutive successful ones. Interval and Timeout are in seconds. The tls and https checks of an upstream group
+// use the TLS settings of the group.
+type HealthCheckConfig struct {
+	Type           string // tcp, http or tls, health checks are disabled when empty
+	Interval       int    // defaults to 10
+	Timeout        int    // defaults to 5
+	Rise           int    // defaults to 2
+	Fall           int    // defaults to 3
+	Path           string // path requested by http checks, defaults to /
+	ExpectedStatus []int  // statuses accepted by http checks, defaults to any 2xx or 3xx status
+	ExpectedBody   string // regular expression the body of http checks must match
+}
+
+// CircuitBreakerConfig ejects a member of an upstream group or of the server pool of the load balancer after
+// Failures failed requests or connections within Window seconds. After Backoff seconds the member is half-open:
+// it receives up to HalfOpenRequests trial requests, which close the breaker again when they all succeed. A failed
+// trial ejects the member again for twice the previous backoff, at most MaxBackoff seconds.
+type CircuitBreakerConfig struct {
+	Failures         int // circuit breaking is disabled when zero
+	Window           int // defaults to 10
+	Backoff          int // defaults to 30
+	MaxBackoff       int // defaults to 300
+	HalfOpenRequests int // defaults to 1
+}
+
+// CacheConfig enables the shared response cache of the reverse proxy. Responses are stored on disk, the least
+// recently used ones are evicted once MaxSize is exceeded.
+type CacheConfig struct {
+	Dir          string // directory the responses are stored in, defaults to the cache directory of the server
+	MaxSize      int    // megabytes stored on disk, the cache is disabled when zero
+	MaxEntrySize int    // megabytes of the largest response stored, defaults to 8
+}
+
+// RouteCacheConfig controls the caching of the responses of a route. Key is the cache key of a request and may
+// refer to the variables of header rules, it defaults to $scheme://$host$path?$query. Requests matching any of the
+// Bypass rules skip the cache: header:Name when the request has the header, cookie:name when it has the cookie
+// and query:name when its query has the parameter.
+type RouteCacheConfig struct {
+	Disabled bool
+	Key      string
+	Bypass   []string
+}
+
+// TrafficSplit divides the traffic of a route between its upstream, the stable version, and other versions of it.
+// Requests matching the Header or Cookie of a version are sent to that version. Other requests are assigned to a
+// version by the Weight of the versions, in percent, the rest of the traffic stays on the upstream of the route.
+// Sticky derives the assignment from the client instead of drawing it for every request, so that a client stays on
+// its version as long as the weights do not change: client_ip, header:Name or cookie, for which the proxy sets a
+// cookie identifying the client. Versions receive the path the upstream of an exact or prefix route would receive,
+// the captures of regex routes are not expanded for them. Splits are adjusted at runtime by editing the route file
+// and reloading the server.
+type TrafficSplit struct {
+	Versions []SplitVersion
+	Sticky   string
+	Cookie   string // name of the cookie of sticky cookie assignments, defaults to jinx_split
+}
+
+// SplitVersion is a version of the upstream of a route traffic is split to.
+type SplitVersion struct {
+	Name          string // reported in the logs, defaults to the position of the version
+	Upstream      string
+	UpstreamGroup string // name of the upstream group balancing the version, used instead of Upstream
+	Weight        int    // percent of the requests not matched by a header or cookie
+	Header        string // requests carrying this header, written as Name: value, are sent to the version
+	Cookie        string // requests carrying this cookie, written as name=value, are sent to the version
+}
+
+// MirrorConfig sends copies of a share of the requests of a route to shadow upstreams, for instance to compare a
+// new version of a service with the one in production. The copies are sent in the background once the body of the
+// request was received and their responses are discarded, the response to the client never waits for them.
+// Requests whose body is larger than MaxBodySize are not mirrored.
+type MirrorConfig struct {
+	Upstreams   []string // URLs of the shadow upstreams, mirroring is disabled when empty
+	Percentage  int      // share of the requests mirrored, defaults to 100
+	MaxBodySize int64    // in bytes, defaults to 1 MiB
+	Timeout     int      // seconds a copy may take, defaults to 5
+}
+
+// BodyConfig limits and buffers the bodies of the requests and responses of a route. Requests whose body is larger
+// than MaxRequestSize are rejected with 413 Request Entity Too Large, before the upstream is contacted when they
+// announce their size. BufferRequests reads the whole body of a request before the upstream is contacted, which
+// shields slow upstreams from slow clients, and BufferResponses reads the whole response of the upstream before it
+// is passed on, which frees the upstream from slow clients. Buffered bodies larger than MemoryBufferSize spill to a
+// temporary file in the working directory of the server. Responses larger than MaxResponseSize are answered with
+// 502 Bad Gateway, or cut off once they are streamed to the client. Streamed responses are flushed to the client
+// every FlushInterval milliseconds, -1 flushes after every write as long polling endpoints need. Server-sent events
+// and responses of unknown length are always flushed after every write. Sizes are in bytes.
+type BodyConfig struct {
+	MaxRequestSize   int64 // unlimited when zero
+	MaxResponseSize  int64 // unlimited when zero
+	BufferRequests   bool
+	BufferResponses  bool
+	MemoryBufferSize int64 // defaults to 1 MiB
+	FlushInterval    int   // when zero, responses of known length are flushed when the write buffer is full
+}
+
+// ConnectionPoolConfig tunes the connections the reverse proxy keeps open to its upstreams. Every upstream group has
+// a pool of its own, routes with a single upstream share the pool of the server unless they have TLS settings, which
+// give them a pool of their own. The limits on idle and per-host connections and the idle and response header
+// timeouts apply to HTTP/1.1 and to HTTP/2 negotiated with the upstream, h2 and h2c routes multiplex their requests
+// over one connection per upstream. Timeouts are in seconds.
+type ConnectionPoolConfig struct {
+	MaxIdleConns          int  // idle connections kept over all upstreams of the pool, defaults to 100
+	MaxIdleConnsPerHost   int  // idle connections kept per upstream, defaults to 32
+	MaxConnsPerHost       int  // connections per upstream including the busy ones, unlimited when zero
+	IdleConnTimeout       int  // defaults to 90
+	DialTimeout           int  // defaults to 30
+	TLSHandshakeTimeout   int  // defaults to 10
+	ResponseHeaderTimeout int  // time allowed for the response headers once the request was sent, unlimited when zero
+	KeepAlive             int  // interval of the TCP keep-alive probes, defaults to 30
+	DisableKeepAlives     bool // opens a connection for every request
+	DisableHTTP2          bool // speaks HTTP/1.1 to TLS upstreams of routes without a protocol
+}
+
+// UpstreamTLSConfig controls the TLS connections to upstream servers. The reverse proxy applies it to the https
+// upstreams of a route or of an upstream group, the load balancer re-encrypts the connections to its server pool
+// with it. Certificates are verified against the system roots when CAFile is empty. PinnedCertificates restricts
+// the upstream to certificates with one of the given SHA-256 fingerprints, as printed by
+// openssl x509 -fingerprint -sha256, and is checked even when InsecureSkipVerify disables the verification of the
+// certificate chain, which should only be used in labs.
+type UpstreamTLSConfig struct {
+	CAFile             string // PEM bundle of the CAs upstream certificates must be issued by
+	CertFile           string // client certificate presented to the upstream
+	KeyFile            string
+	ServerName         string // name sent as SNI and verified against the certificate, defaults to the upstream host
+	MinVersion         string // lowest accepted version: 1.0, 1.1, 1.2 or 1.3, defaults to 1.2
+	PinnedCertificates []string
+	InsecureSkipVerify bool
+}
+
+// WebSocketConfig controls the WebSocket connections the reverse proxy upgrades and relays to the upstream of their
+// route. Requests without an Origin header, sent by clients other than browsers, are not checked against
+// AllowedOrigins.
+type WebSocketConfig struct {
+	AllowedOrigins []string // origins such as https://app.example.com or https://*.example.com, any origin when empty
+	IdleTimeout    int      // seconds without data in either direction before a connection is closed, defaults to 300
+	MaxLifetime    int      // seconds a connection may stay open, unlimited when zero
+	MaxConnections int      // concurrent connections, unlimited when zero
+}
+
+// RateLimitConfig throttles the requests of the HTTP server, the reverse proxy and the forward proxy with token
+// buckets. A request exceeding one of the rules it matches is rejected with 429 Too Many Requests, or only logged
+// in DryRun mode. Client IP addresses are read from the forwarding headers sent by TrustedProxies, the reverse
+// proxy uses its own trusted proxies instead.
+type RateLimitConfig struct {
+	Rules          []RateLimitRule
+	DryRun         bool     // log the requests exceeding a limit instead of rejecting them
+	TrustedProxies []string // IP addresses or CIDR ranges whose forwarding headers are honored
+	MaxTunnels     int      // concurrent CONNECT tunnels of a client of the forward proxy, unlimited when zero
+}
+
+// RateLimitRule allows every key Requests per Period seconds, in bursts of up to Burst requests. Key is client_ip
+// (the default), header:Name to limit every value of a header such as an API key, requests without the header are
+// keyed by their client IP, or route to share one limit between all clients of a route. The route of the forward
+// proxy is the requested host and the route of the HTTP server the path prefix of the rule that matched.
+type RateLimitRule struct {
+	Name     string // identifies the rule in the logs
+	Key      string
+	Requests int
+	Period   int      // seconds, defaults to 1
+	Burst    int      // defaults to Requests
+	Paths    []string // path prefixes the rule applies to, matching whole path segments, every request when empty
+}
+
+// ErrorPages are the HTML files the reverse proxy and the forward proxy answer requests with when the upstream
+// could not serve them, by status: 502 Bad Gateway when it could not be reached or failed to answer, 503 Service
+// Unavailable when no member of its upstream group can take the request and 504 Gateway Timeout when it did not
+// answer in time. ${status} and ${request_id} in a page are replaced by the status and the ID of the request.
+// Statuses without a page are answered with a short plain text message naming the request ID.
+type ErrorPages map[int]string
+
+// RetryConfig retries requests or connections that could not be served by a member of an upstream group or of the
+// server pool of the load balancer on another member. Attempts is the retry budget of a request or connection,
+// counting the first try. TryTimeout bounds the wait for the response headers of a single try, the connect of a
+// single try for the load balancer, and Deadline the time spent trying overall. Both are in seconds.
+type RetryConfig struct {
+	Attempts   int   // defaults to 3, a single attempt disables retries
+	Statuses   []int // statuses of the reverse proxy retried on another member, defaults to 502, 503 and 504
+	TryTimeout int   // tries are not limited when zero
+	Deadline   int   // the retries are not limited in time when zero
+}
+
+// AdminConfig enables the admin listener, which reports the state of the upstream servers as JSON on /upstreams.
+type AdminConfig struct {
+	IP   string // defaults to 127.0.0.1, the admin listener should not be reachable from untrusted networks
+	Port int    // the admin listener is disabled when zero
+}
+
+// RoutingConfig is the content of the route file of the reverse proxy. The legacy route file format, an object
+// mapping paths to upstream URLs, is still accepted and turned into prefix routes.
+type RoutingConfig struct {
+	Routes         []Route
+	UpstreamGroups map[string]UpstreamGroupConfig
+}
@@ -13,6 +13,7 @@ package http_server_setup
 import (
 	"io"
 	"jinx/internal/jinx_http"
+	"jinx/internal/jinx_tls"
 	"jinx/pkg/util/constant"
 	"jinx/pkg/util/error_handler"
 	"jinx/pkg/util/helper"
@@ -82,6 +83,59 @@ func HTTPServerSetup(config types.HttpServerConfig, serverRootDir string) (types
 		}
 	}
 
+	for _, certificate := range config.Certificates {
+		if _, certFileErr := os.Stat(certificate.CertFile); certFileErr != nil {
+			log.Printf("%s: %v", certificate.CertFile, certFileErr)
+			return nil, error_handler.NewJinxError(constant.INVALID_CERT_PATH, certFileErr)
+		}
+
+		if _, keyFileErr := os.Stat(certificate.KeyFile); keyFileErr != nil {
+			log.Printf("%s: %v", certificate.KeyFile, keyFileErr)
+			return nil, error_handler.NewJinxError(constant.INVALID_KEY_PATH, keyFileErr)
+		}
+	}
+
+	if acmeErr := helper.ValidateACMEConfig(config.ACME); acmeErr != nil {
+		log.Printf("invalid ACME configuration: %v", acmeErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_ACME_CONFIG, acmeErr)
+	}
+
+	if clientAuthErr := helper.ValidateClientAuthConfig(config.ClientAuth); clientAuthErr != nil {
+		log.Printf("invalid client authentication configuration: %v", clientAuthErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_CLIENT_AUTH_CONFIG, clientAuthErr)
+	}
+
+	tlsEnabled := jinx_tls.IsEnabled(certFile, keyFile, config.Certificates, config.ACME)
+	if listenersErr := helper.ValidateListeners(config.Listeners, config.HTTPSRedirect, tlsEnabled); listenersErr != nil {
+		log.Printf("invalid listener configuration: %v", listenersErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_LISTENER_CONFIG, listenersErr)
+	}
+
+	if policyErr := jinx_tls.ValidateTLSPolicy(config.TLSPolicy); policyErr != nil {
+		log.Printf("invalid TLS policy: %v", policyErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_TLS_POLICY, policyErr)
+	}
+
+	for _, listenerConfig := range config.Listeners {
+		if listenerConfig.TLSPolicy == nil {
+			continue
+		}
+		if policyErr := jinx_tls.ValidateTLSPolicy(*listenerConfig.TLSPolicy); policyErr != nil {
+			log.Printf("invalid TLS policy of listener on port %d: %v", listenerConfig.Port, policyErr)
+			return nil, error_handler.NewJinxError(constant.ERR_INVALID_TLS_POLICY, policyErr)
+		}
+	}
+
+	if limitsErr := helper.ValidateListenerLimits(config.Limits); limitsErr != nil {
+		log.Printf("invalid listener limits: %v", limitsErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_LISTENER_LIMITS, limitsErr)
+	}
+
+	if rateLimitErr := helper.ValidateRateLimitConfig(config.RateLimit); rateLimitErr != nil {
+		log.Printf("invalid rate limit: %v", rateLimitErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_RATE_LIMIT, rateLimitErr)
+	}
+
 	//Create a directory for logs
 	logRoot := filepath.Join(serverRootDir, constant.LOG_ROOT)
 	if mkLogDirErr := os.MkdirAll(logRoot, 0755); !os.IsExist(mkLogDirErr) && mkLogDirErr != nil {
@@ -120,12 +174,21 @@ func HTTPServerSetup(config types.HttpServerConfig, serverRootDir string) (types
 	}
 
 	jinxHttpConfig := types.JinxHttpServerConfig{
-		IP:          string(ipAddress),
-		Port:        port,
-		LogRoot:     logRoot,
-		WebsiteRoot: webRootDir,
-		CertFile:    certFile,
-		KeyFile:     keyFile,
+		IP:                string(ipAddress),
+		Port:              port,
+		LogRoot:           logRoot,
+		WebsiteRoot:       webRootDir,
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		Certificates:      config.Certificates,
+		ACME:              config.ACME,
+		CertificateReload: config.CertificateReload,
+		ClientAuth:        config.ClientAuth,
+		TLSPolicy:         config.TLSPolicy,
+		Limits:            config.Limits,
+		Listeners:         config.Listeners,
+		HTTPSRedirect:     config.HTTPSRedirect,
+		RateLimit:         config.RateLimit,
 	}
 
 	jinx := jinx_http.NewJinxHttpServer(jinxHttpConfig, serverRootDir)
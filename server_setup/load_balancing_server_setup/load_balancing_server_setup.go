@@ -3,7 +3,10 @@ package load_balancing_server_setup
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"jinx/internal/jinx_tls"
 	"jinx/internal/load_balancer"
+	"jinx/internal/load_balancer/algo"
 	"jinx/pkg/util/constant"
 	"jinx/pkg/util/error_handler"
 	"jinx/pkg/util/helper"
@@ -39,6 +42,10 @@ func LoadBalancingServerSetup(config types.LoadBalancerConfig, serverRootDir str
 	if algorithm == "" {
 		algorithm = constant.ROUND_ROBIN
 	}
+	if algorithmErr := algo.Validate(algorithm); algorithmErr != nil {
+		log.Printf("invalid load balancing algorithm: %v", algorithmErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_ALGORITHM, algorithmErr)
+	}
 
 	certFile := config.CertFile
 	if certFile != "" {
@@ -56,6 +63,89 @@ func LoadBalancingServerSetup(config types.LoadBalancerConfig, serverRootDir str
 		}
 	}
 
+	for _, certificate := range config.Certificates {
+		if _, certFileErr := os.Stat(certificate.CertFile); certFileErr != nil {
+			log.Printf("%s: %v", certificate.CertFile, certFileErr)
+			return nil, error_handler.NewJinxError(constant.INVALID_CERT_PATH, certFileErr)
+		}
+
+		if _, keyFileErr := os.Stat(certificate.KeyFile); keyFileErr != nil {
+			log.Printf("%s: %v", certificate.KeyFile, keyFileErr)
+			return nil, error_handler.NewJinxError(constant.INVALID_KEY_PATH, keyFileErr)
+		}
+	}
+
+	if acmeErr := helper.ValidateACMEConfig(config.ACME); acmeErr != nil {
+		log.Printf("invalid ACME configuration: %v", acmeErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_ACME_CONFIG, acmeErr)
+	}
+
+	if clientAuthErr := helper.ValidateClientAuthConfig(config.ClientAuth); clientAuthErr != nil {
+		log.Printf("invalid client authentication configuration: %v", clientAuthErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_CLIENT_AUTH_CONFIG, clientAuthErr)
+	}
+
+	tlsEnabled := jinx_tls.IsEnabled(certFile, keyFile, config.Certificates, config.ACME)
+	if listenersErr := helper.ValidateListeners(config.Listeners, types.HTTPSRedirectConfig{}, tlsEnabled); listenersErr != nil {
+		log.Printf("invalid listener configuration: %v", listenersErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_LISTENER_CONFIG, listenersErr)
+	}
+
+	for _, listenerConfig := range config.Listeners {
+		if listenerConfig.RedirectToHTTPS {
+			redirectErr := fmt.Errorf("listener on port %d: the load balancer relays TCP and cannot redirect to HTTPS", listenerConfig.Port)
+			log.Println(redirectErr)
+			return nil, error_handler.NewJinxError(constant.ERR_INVALID_LISTENER_CONFIG, redirectErr)
+		}
+	}
+
+	if policyErr := jinx_tls.ValidateTLSPolicy(config.TLSPolicy); policyErr != nil {
+		log.Printf("invalid TLS policy: %v", policyErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_TLS_POLICY, policyErr)
+	}
+
+	for _, listenerConfig := range config.Listeners {
+		if listenerConfig.TLSPolicy == nil {
+			continue
+		}
+		if policyErr := jinx_tls.ValidateTLSPolicy(*listenerConfig.TLSPolicy); policyErr != nil {
+			log.Printf("invalid TLS policy of listener on port %d: %v", listenerConfig.Port, policyErr)
+			return nil, error_handler.NewJinxError(constant.ERR_INVALID_TLS_POLICY, policyErr)
+		}
+	}
+
+	if limitsErr := helper.ValidateListenerLimits(config.Limits); limitsErr != nil {
+		log.Printf("invalid listener limits: %v", limitsErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_LISTENER_LIMITS, limitsErr)
+	}
+
+	if healthCheckErr := helper.ValidateHealthCheckConfig(config.HealthCheck); healthCheckErr != nil {
+		log.Printf("invalid health check: %v", healthCheckErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_HEALTH_CHECK, healthCheckErr)
+	}
+
+	if breakerErr := helper.ValidateCircuitBreakerConfig(config.CircuitBreaker); breakerErr != nil {
+		log.Printf("invalid circuit breaker: %v", breakerErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_CIRCUIT_BREAKER, breakerErr)
+	}
+
+	if retryErr := helper.ValidateRetryConfig(config.Retry); retryErr != nil {
+		log.Printf("invalid retry settings: %v", retryErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_RETRY_CONFIG, retryErr)
+	}
+
+	if config.UpstreamTLS != nil {
+		if _, upstreamTLSErr := jinx_tls.NewUpstreamTLSConfig(*config.UpstreamTLS); upstreamTLSErr != nil {
+			log.Printf("invalid upstream TLS settings: %v", upstreamTLSErr)
+			return nil, error_handler.NewJinxError(constant.ERR_INVALID_UPSTREAM_TLS_CONFIG, upstreamTLSErr)
+		}
+	}
+
+	if adminErr := helper.ValidateAdminConfig(config.Admin); adminErr != nil {
+		log.Printf("invalid admin listener: %v", adminErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_LISTENER_CONFIG, adminErr)
+	}
+
 	serverPoolConfigPath := config.ServerPoolConfigPath
 	if serverPoolConfigPath == "" {
 		log.Println("a server pool config file must be provided")
@@ -74,13 +164,25 @@ func LoadBalancingServerSetup(config types.LoadBalancerConfig, serverRootDir str
 	}
 
 	jinxLoadBalancerConfig := types.JinxLoadBalancingServerConfig{
-		IP:         string(ipAddress),
-		Port:       port,
-		LogRoot:    logRoot,
-		CertFile:   certFile,
-		KeyFile:    keyFile,
-		ServerPool: serverPool,
-		Algorithm:  algorithm,
+		IP:                string(ipAddress),
+		Port:              port,
+		LogRoot:           logRoot,
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		Certificates:      config.Certificates,
+		ACME:              config.ACME,
+		CertificateReload: config.CertificateReload,
+		ClientAuth:        config.ClientAuth,
+		TLSPolicy:         config.TLSPolicy,
+		ServerPool:        serverPool,
+		Algorithm:         algorithm,
+		HealthCheck:       config.HealthCheck,
+		CircuitBreaker:    config.CircuitBreaker,
+		Retry:             config.Retry,
+		UpstreamTLS:       config.UpstreamTLS,
+		Admin:             config.Admin,
+		Limits:            config.Limits,
+		Listeners:         config.Listeners,
 	}
 
 	jinx := load_balancer.NewJinxLoadBalancingServer(jinxLoadBalancerConfig, filepath.Join(constant.BASE, string(constant.LOAD_BALANCER)))
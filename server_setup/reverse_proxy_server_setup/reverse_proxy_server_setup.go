@@ -13,6 +13,7 @@ package reverse_proxy_server_setup
 import (
 	"encoding/json"
 	"errors"
+	"jinx/internal/jinx_tls"
 	"jinx/internal/reverse_proxy"
 	"jinx/pkg/util/constant"
 	"jinx/pkg/util/error_handler"
@@ -95,6 +96,94 @@ func ReverseProxyServerSetup(config types.ReverseProxyConfig, serverRootDir stri
 		}
 	}
 
+	for _, certificate := range config.Certificates {
+		if _, certFileErr := os.Stat(certificate.CertFile); certFileErr != nil {
+			log.Printf("%s: %v", certificate.CertFile, certFileErr)
+			return nil, error_handler.NewJinxError(constant.INVALID_CERT_PATH, certFileErr)
+		}
+
+		if _, keyFileErr := os.Stat(certificate.KeyFile); keyFileErr != nil {
+			log.Printf("%s: %v", certificate.KeyFile, keyFileErr)
+			return nil, error_handler.NewJinxError(constant.INVALID_KEY_PATH, keyFileErr)
+		}
+	}
+
+	if acmeErr := helper.ValidateACMEConfig(config.ACME); acmeErr != nil {
+		log.Printf("invalid ACME configuration: %v", acmeErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_ACME_CONFIG, acmeErr)
+	}
+
+	if clientAuthErr := helper.ValidateClientAuthConfig(config.ClientAuth); clientAuthErr != nil {
+		log.Printf("invalid client authentication configuration: %v", clientAuthErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_CLIENT_AUTH_CONFIG, clientAuthErr)
+	}
+
+	if routesErr := helper.ValidateClientAuthRoutes(config.ClientAuthRoutes, config.ClientAuth); routesErr != nil {
+		log.Printf("invalid client authentication routes: %v", routesErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_CLIENT_AUTH_CONFIG, routesErr)
+	}
+
+	tlsEnabled := jinx_tls.IsEnabled(certFile, keyFile, config.Certificates, config.ACME)
+	if listenersErr := helper.ValidateListeners(config.Listeners, config.HTTPSRedirect, tlsEnabled); listenersErr != nil {
+		log.Printf("invalid listener configuration: %v", listenersErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_LISTENER_CONFIG, listenersErr)
+	}
+
+	if policyErr := jinx_tls.ValidateTLSPolicy(config.TLSPolicy); policyErr != nil {
+		log.Printf("invalid TLS policy: %v", policyErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_TLS_POLICY, policyErr)
+	}
+
+	for _, listenerConfig := range config.Listeners {
+		if listenerConfig.TLSPolicy == nil {
+			continue
+		}
+		if policyErr := jinx_tls.ValidateTLSPolicy(*listenerConfig.TLSPolicy); policyErr != nil {
+			log.Printf("invalid TLS policy of listener on port %d: %v", listenerConfig.Port, policyErr)
+			return nil, error_handler.NewJinxError(constant.ERR_INVALID_TLS_POLICY, policyErr)
+		}
+	}
+
+	if limitsErr := helper.ValidateListenerLimits(config.Limits); limitsErr != nil {
+		log.Printf("invalid listener limits: %v", limitsErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_LISTENER_LIMITS, limitsErr)
+	}
+
+	if rateLimitErr := helper.ValidateRateLimitConfig(config.RateLimit); rateLimitErr != nil {
+		log.Printf("invalid rate limit: %v", rateLimitErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_RATE_LIMIT, rateLimitErr)
+	}
+
+	if webSocketErr := helper.ValidateWebSocketConfig(config.WebSocket); webSocketErr != nil {
+		log.Printf("invalid WebSocket configuration: %v", webSocketErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_WEBSOCKET_CONFIG, webSocketErr)
+	}
+
+	if poolErr := helper.ValidateConnectionPoolConfig(config.ConnectionPool); poolErr != nil {
+		log.Printf("invalid connection pool: %v", poolErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_CONNECTION_POOL_CONFIG, poolErr)
+	}
+
+	if errorPagesErr := helper.ValidateErrorPages(config.ErrorPages); errorPagesErr != nil {
+		log.Printf("invalid error pages: %v", errorPagesErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_ERROR_PAGES, errorPagesErr)
+	}
+
+	if adminErr := helper.ValidateAdminConfig(config.Admin); adminErr != nil {
+		log.Printf("invalid admin listener: %v", adminErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_LISTENER_CONFIG, adminErr)
+	}
+
+	if _, trustedProxiesErr := helper.ParseTrustedProxies(config.TrustedProxies); trustedProxiesErr != nil {
+		log.Printf("invalid trusted proxies: %v", trustedProxiesErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_TRUSTED_PROXIES, trustedProxiesErr)
+	}
+
+	if cacheErr := helper.ValidateCacheConfig(config.Cache); cacheErr != nil {
+		log.Printf("invalid cache config: %v", cacheErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_CACHE_CONFIG, cacheErr)
+	}
+
 	routeTablePath := config.RoutingTable
 	if routeTablePath == "" {
 		log.Println("a route file must be provided")
@@ -106,19 +195,44 @@ func ReverseProxyServerSetup(config types.ReverseProxyConfig, serverRootDir stri
 		return nil, error_handler.NewJinxError(constant.ERR_INVALID_ROUTE_TABLE, validationErr)
 	}
 
-	routeTable, err := LoadRouteTable(routeTablePath)
+	routingConfig, err := LoadRoutingConfig(routeTablePath)
 	if err != nil {
 		log.Printf("error occurred while reading route table: %v", err)
 		return nil, error_handler.NewJinxError(constant.ERR_INVALID_ROUTE_TABLE, err)
 	}
 
+	if _, _, routerErr := reverse_proxy.NewRouting(routingConfig.Routes, routingConfig.UpstreamGroups); routerErr != nil {
+		log.Printf("invalid route table: %v", routerErr)
+		return nil, error_handler.NewJinxError(constant.ERR_INVALID_ROUTE_TABLE, routerErr)
+	}
+
 	jinxReversProxyConfig := types.JinxReverseProxyServerConfig{
-		IP:         string(ipAddress),
-		Port:       port,
-		LogRoot:    logRoot,
-		RouteTable: routeTable,
-		CertFile:   certFile,
-		KeyFile:    keyFile,
+		IP:                string(ipAddress),
+		Port:              port,
+		LogRoot:           logRoot,
+		RouteFile:         routeTablePath,
+		Routes:            routingConfig.Routes,
+		UpstreamGroups:    routingConfig.UpstreamGroups,
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		Certificates:      config.Certificates,
+		ACME:              config.ACME,
+		CertificateReload: config.CertificateReload,
+		ClientAuth:        config.ClientAuth,
+		TLSPolicy:         config.TLSPolicy,
+		ClientAuthRoutes:  config.ClientAuthRoutes,
+		Limits:            config.Limits,
+		Listeners:         config.Listeners,
+		HTTPSRedirect:     config.HTTPSRedirect,
+		Admin:             config.Admin,
+		TrustedProxies:    config.TrustedProxies,
+		PreserveHost:      config.PreserveHost,
+		Cache:             config.Cache,
+		RateLimit:         config.RateLimit,
+		WebSocket:         config.WebSocket,
+		ConnectionPool:    config.ConnectionPool,
+		H2C:               config.H2C,
+		ErrorPages:        config.ErrorPages,
 	}
 
 	jinx := reverse_proxy.NewJinxReverseProxyServer(jinxReversProxyConfig, filepath.Join(serverRootDir, string(constant.REVERSE_PROXY)))
@@ -205,3 +319,8 @@ func LoadRouteTable(path string) (types.RouteTable, error) {
 
 	return routeTable, nil
 }
+
+// LoadRoutingConfig reads the route file at path, see reverse_proxy.LoadRoutingConfig.
+func LoadRoutingConfig(path string) (types.RoutingConfig, error) {
+	return reverse_proxy.LoadRoutingConfig(path)
+}
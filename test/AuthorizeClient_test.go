@@ -0,0 +1,155 @@
+package test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorizeClient(t *testing.T) {
+	ca, _ := newTestCA(t, t.TempDir())
+	billing := ca.issueClientCertificate(t, "billing", 2, "billing.internal")
+	reports := ca.issueClientCertificate(t, "reports", 3)
+
+	config := types.JinxReverseProxyServerConfig{
+		LogRoot: t.TempDir(),
+		ClientAuthRoutes: []types.ClientAuthRoute{
+			{Path: "/internal"},
+			{Path: "/internal/billing/", AllowedNames: []string{"billing.internal"}},
+		},
+	}
+	jx := reverse_proxy.NewJinxReverseProxyServer(config, t.TempDir())
+
+	testCases := []struct {
+		name        string
+		path        string
+		certificate *x509.Certificate
+		expected    bool
+	}{
+		{"PublicRoute", "/public", nil, true},
+		{"SimilarPrefixIsPublic", "/internalize", nil, true},
+		{"ProtectedWithoutCertificate", "/internal/status", nil, false},
+		{"ProtectedRouteRoot", "/internal", nil, false},
+		{"DotSegmentsCannotEscape", "/public/../internal/status", nil, false},
+		{"ProtectedWithCertificate", "/internal/status", reports.Leaf, true},
+		{"AllowedName", "/internal/billing/invoices", billing.Leaf, true},
+		{"NameNotAllowed", "/internal/billing/invoices", reports.Leaf, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://proxy.example.com"+tc.path, nil)
+			r.URL.Path = tc.path
+			r.TLS = &tls.ConnectionState{}
+			if tc.certificate != nil {
+				r.TLS.VerifiedChains = [][]*x509.Certificate{{tc.certificate, ca.certificate}}
+			}
+
+			if authorized := jx.AuthorizeClient(r); authorized != tc.expected {
+				t.Errorf("Expected %v for %s, got %v", tc.expected, tc.path, authorized)
+			}
+		})
+	}
+}
+
+func TestClientCertificateHeaders(t *testing.T) {
+	ca, _ := newTestCA(t, t.TempDir())
+	billing := ca.issueClientCertificate(t, "billing", 2, "billing.internal")
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("X-Client-Verify")+"|"+r.Header.Get("X-Client-Subject")+"|"+r.Header.Get("X-Client-SAN"))
+	}))
+	defer upstream.Close()
+
+	config := types.JinxReverseProxyServerConfig{
+		LogRoot:          t.TempDir(),
+		RouteTable:       types.RouteTable{"/internal": upstream.URL},
+		ClientAuthRoutes: []types.ClientAuthRoute{{Path: "/internal"}},
+	}
+	jx := reverse_proxy.NewJinxReverseProxyServer(config, t.TempDir())
+
+	testCases := []struct {
+		name           string
+		certificate    *x509.Certificate
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"VerifiedCertificate", billing.Leaf, http.StatusOK, "SUCCESS|CN=billing|DNS:billing.internal"},
+		{"NoCertificate", nil, http.StatusForbidden, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://proxy.example.com/internal", nil)
+			r.Header.Set("X-Client-Subject", "CN=spoofed")
+			r.TLS = &tls.ConnectionState{}
+			if tc.certificate != nil {
+				r.TLS.VerifiedChains = [][]*x509.Certificate{{tc.certificate, ca.certificate}}
+			}
+
+			w := httptest.NewRecorder()
+			jx.ServeHTTP(w, r)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if tc.expectedStatus == http.StatusOK && w.Body.String() != tc.expectedBody {
+				t.Errorf("Expected upstream to receive %q, got %q", tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthorizeClientRegexRoute(t *testing.T) {
+	ca, _ := newTestCA(t, t.TempDir())
+	reports := ca.issueClientCertificate(t, "reports", 2)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	// The route replaces the request path, /internal/secret reaches the upstream as /secret
+	jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+		LogRoot:          t.TempDir(),
+		Routes:           []types.Route{{Path: "^/internal/(.*)", Match: "regex", Upstream: upstream.URL + "/$1"}},
+		ClientAuthRoutes: []types.ClientAuthRoute{{Path: "/internal"}},
+	}, t.TempDir())
+
+	testCases := []struct {
+		name           string
+		path           string
+		certificate    *x509.Certificate
+		expectedStatus int
+	}{
+		{"WithoutCertificate", "/internal/secret", nil, http.StatusForbidden},
+		{"DotSegments", "/public/../internal/secret", nil, http.StatusForbidden},
+		{"WithCertificate", "/internal/secret", reports.Leaf, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://proxy.example.com/", nil)
+			r.URL.Path = tc.path
+			r.TLS = &tls.ConnectionState{}
+			if tc.certificate != nil {
+				r.TLS.VerifiedChains = [][]*x509.Certificate{{tc.certificate, ca.certificate}}
+			}
+
+			w := httptest.NewRecorder()
+			jx.ServeHTTP(w, r)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got %d %q", tc.expectedStatus, w.Code, w.Body.String())
+			}
+			if tc.expectedStatus == http.StatusOK && w.Body.String() != "/secret" {
+				t.Errorf("Expected the upstream to receive /secret, got %q", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package test
+
+import (
+	"bufio"
+	"io"
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// echoBackend answers with the length and the body of a request and counts the requests it received.
+type echoBackend struct {
+	requests atomic.Int64
+}
+
+func (b *echoBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.requests.Add(1)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, _ = io.WriteString(w, strconv.FormatInt(r.ContentLength, 10)+" "+string(body))
+}
+
+// trickleReader returns its content in pieces, so that the request carrying it has no Content-Length.
+type trickleReader struct {
+	content string
+}
+
+func (r *trickleReader) Read(p []byte) (int, error) {
+	if r.content == "" {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), 1024)], r.content)
+	r.content = r.content[n:]
+	return n, nil
+}
+
+func TestBodyLimit(t *testing.T) {
+	large := strings.Repeat("a", 4096)
+
+	testCases := []struct {
+		name             string
+		body             types.BodyConfig
+		content          string
+		chunked          bool
+		expectedStatus   int
+		expectedBody     string // empty when the upstream must not be reached
+		expectedUpstream int64  // requests the upstream received
+	}{
+		{"Unlimited", types.BodyConfig{}, large, false, http.StatusOK, "4096 " + large, 1},
+		{"UnderLimit", types.BodyConfig{MaxRequestSize: 4096}, large, false, http.StatusOK, "4096 " + large, 1},
+		{"ContentLengthOverLimit", types.BodyConfig{MaxRequestSize: 1024}, large, false, http.StatusRequestEntityTooLarge, "", 0},
+		{"ChunkedOverLimit", types.BodyConfig{MaxRequestSize: 1024}, large, true, http.StatusRequestEntityTooLarge, "", -1},
+		{"BufferedOverLimit", types.BodyConfig{MaxRequestSize: 1024, BufferRequests: true}, large, true, http.StatusRequestEntityTooLarge, "", 0},
+		{"BufferedInMemory", types.BodyConfig{BufferRequests: true}, large, true, http.StatusOK, "4096 " + large, 1},
+		{"BufferedOnDisk", types.BodyConfig{BufferRequests: true, MemoryBufferSize: 1024}, large, true, http.StatusOK, "4096 " + large, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// A backend of its own, the request cut off in an earlier case may still reach the backend of that case
+			backend := &echoBackend{}
+			server := httptest.NewServer(backend)
+			defer server.Close()
+
+			workingDir := t.TempDir()
+			jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+				LogRoot: t.TempDir(),
+				Routes:  []types.Route{{Path: "/", Upstream: server.URL, Body: tc.body}},
+			}, workingDir)
+
+			var body io.Reader = strings.NewReader(tc.content)
+			if tc.chunked {
+				body = &trickleReader{content: tc.content}
+			}
+			r := httptest.NewRequest(http.MethodPost, "http://proxy.example.com/upload", body)
+			if tc.chunked {
+				r.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			jx.ServeHTTP(w, r)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected %d, got: %d %q", tc.expectedStatus, w.Code, w.Body.String())
+			}
+			if tc.expectedBody != "" && w.Body.String() != tc.expectedBody {
+				t.Errorf("Expected the upstream to receive the whole body with its length, got: %.40q", w.Body.String())
+			}
+			if tc.expectedUpstream >= 0 && backend.requests.Load() != tc.expectedUpstream {
+				t.Errorf("Expected %d requests to the upstream, got: %d", tc.expectedUpstream, backend.requests.Load())
+			}
+
+			// Bodies spilled to disk are removed once the request was handled
+			if entries, _ := os.ReadDir(filepath.Join(workingDir, constant.BODY_BUFFER_DIR)); len(entries) != 0 {
+				t.Errorf("Expected no buffered body left, got: %d files", len(entries))
+			}
+		})
+	}
+}
+
+func TestResponseBuffering(t *testing.T) {
+	content := strings.Repeat("b", 4096)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("length") != "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		}
+		// Streamed in two parts, the response has no length unless it was announced
+		_, _ = io.WriteString(w, content[:2048])
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, content[2048:])
+	}))
+	defer backend.Close()
+
+	testCases := []struct {
+		name           string
+		body           types.BodyConfig
+		query          string
+		expectedStatus int
+		expectedLength int64 // Content-Length of the response passed on, -1 when streamed
+	}{
+		{"Streamed", types.BodyConfig{}, "", http.StatusOK, -1},
+		{"Buffered", types.BodyConfig{BufferResponses: true}, "", http.StatusOK, 4096},
+		{"BufferedOnDisk", types.BodyConfig{BufferResponses: true, MemoryBufferSize: 1024}, "", http.StatusOK, 4096},
+		{"BufferedOverLimit", types.BodyConfig{BufferResponses: true, MaxResponseSize: 1024}, "", http.StatusBadGateway, 0},
+		{"LengthOverLimit", types.BodyConfig{MaxResponseSize: 1024}, "?length=1", http.StatusBadGateway, 0},
+		{"UnderLimit", types.BodyConfig{MaxResponseSize: 4096}, "?length=1", http.StatusOK, 4096},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+				LogRoot: t.TempDir(),
+				Routes:  []types.Route{{Path: "/", Upstream: backend.URL, Body: tc.body}},
+			}, t.TempDir())
+			proxy := httptest.NewServer(jx)
+			defer proxy.Close()
+
+			res, err := http.Get(proxy.URL + "/" + tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(res.Body)
+			_ = res.Body.Close()
+
+			if res.StatusCode != tc.expectedStatus {
+				t.Fatalf("Expected %d, got: %d %q", tc.expectedStatus, res.StatusCode, body)
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			if res.ContentLength != tc.expectedLength || string(body) != content {
+				t.Errorf("Expected %d bytes with a length of %d, got: %d bytes with a length of %d", len(content), tc.expectedLength, len(body), res.ContentLength)
+			}
+		})
+	}
+}
+
+func TestResponseFlushInterval(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A long poll announcing its length, which is not flushed before the write buffer is full by default
+		w.Header().Set("Content-Length", "12")
+		_, _ = io.WriteString(w, "first\n")
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = io.WriteString(w, "second")
+	}))
+	defer backend.Close()
+
+	jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+		LogRoot: t.TempDir(),
+		Routes:  []types.Route{{Path: "/", Upstream: backend.URL, Body: types.BodyConfig{FlushInterval: -1}}},
+	}, t.TempDir())
+	proxy := httptest.NewServer(jx)
+	defer proxy.Close()
+	// The upstream finishes its response before the servers are closed
+	defer close(release)
+
+	// Nothing, not even the headers, reaches the client before the first flush
+	lines := make(chan string, 1)
+	go func() {
+		res, err := http.Get(proxy.URL + "/poll")
+		if err != nil {
+			lines <- err.Error()
+			return
+		}
+		defer func() {
+			_ = res.Body.Close()
+		}()
+		line, _ := bufio.NewReader(res.Body).ReadString('\n')
+		lines <- line
+	}()
+
+	select {
+	case line := <-lines:
+		if line != "first\n" {
+			t.Errorf("Expected the first line, got: %q", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Expected the first line to be flushed before the upstream finished the response")
+	}
+}
+
+func TestBodyConfigValidation(t *testing.T) {
+	testCases := []struct {
+		name      string
+		body      types.BodyConfig
+		expectErr bool
+	}{
+		{"None", types.BodyConfig{}, false},
+		{"Complete", types.BodyConfig{MaxRequestSize: 1 << 20, MaxResponseSize: 1 << 24, BufferRequests: true, BufferResponses: true, MemoryBufferSize: 1 << 16}, false},
+		{"FlushEveryWrite", types.BodyConfig{FlushInterval: -1}, false},
+		{"NegativeMaxRequestSize", types.BodyConfig{MaxRequestSize: -1}, true},
+		{"NegativeMemoryBufferSize", types.BodyConfig{MemoryBufferSize: -1}, true},
+		{"InvalidFlushInterval", types.BodyConfig{FlushInterval: -2}, true},
+		{"FlushedBufferedResponses", types.BodyConfig{BufferResponses: true, FlushInterval: 100}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := reverse_proxy.NewRouter([]types.Route{{Path: "/", Upstream: "http://a", Body: tc.body}})
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
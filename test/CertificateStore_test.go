@@ -0,0 +1,120 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"jinx/internal/jinx_tls"
+	"jinx/pkg/util/types"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate creates a self-signed certificate for dnsNames in dir and returns the paths of the
+// certificate and key files.
+func writeTestCertificate(t *testing.T, dir string, name string, dnsNames ...string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestCertificateStore(t *testing.T) {
+	tempDir := t.TempDir()
+
+	defaultCert, defaultKey := writeTestCertificate(t, tempDir, "default", "default.local")
+	siteCert, siteKey := writeTestCertificate(t, tempDir, "site", "example.com", "www.example.com")
+	wildcardCert, wildcardKey := writeTestCertificate(t, tempDir, "wildcard", "*.example.org")
+	namedCert, namedKey := writeTestCertificate(t, tempDir, "named", "ignored.example.net")
+
+	store, err := jinx_tls.LoadCertificateStore(defaultCert, defaultKey, []types.CertificateConfig{
+		{CertFile: siteCert, KeyFile: siteKey},
+		{CertFile: wildcardCert, KeyFile: wildcardKey},
+		{CertFile: namedCert, KeyFile: namedKey, ServerNames: []string{"api.example.net"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		serverName string
+		want       string
+	}{
+		{name: "ExactName", serverName: "example.com", want: "site"},
+		{name: "SecondNameOfCertificate", serverName: "WWW.Example.com.", want: "site"},
+		{name: "WildcardName", serverName: "shop.example.org", want: "wildcard"},
+		{name: "WildcardDoesNotCoverApex", serverName: "example.org", want: "default"},
+		{name: "WildcardCoversSingleLabel", serverName: "a.shop.example.org", want: "default"},
+		{name: "ConfiguredServerName", serverName: "api.example.net", want: "named"},
+		{name: "ConfiguredNamesReplaceCertificateNames", serverName: "ignored.example.net", want: "default"},
+		{name: "UnknownName", serverName: "unknown.com", want: "default"},
+		{name: "NoServerName", serverName: "", want: "default"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			certificate, getErr := store.GetCertificate(&tls.ClientHelloInfo{ServerName: test.serverName})
+			if getErr != nil {
+				t.Fatal(getErr)
+			}
+			if got := certificate.Leaf.Subject.CommonName; got != test.want {
+				t.Errorf("expected certificate %s but got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadCertificateStoreErrors(t *testing.T) {
+	tempDir := t.TempDir()
+	certFile, _ := writeTestCertificate(t, tempDir, "first", "first.com")
+	_, otherKey := writeTestCertificate(t, tempDir, "second", "second.com")
+
+	if _, err := jinx_tls.LoadCertificateStore("", "", nil); err == nil {
+		t.Error("expected an error when no certificate is configured")
+	}
+
+	if _, err := jinx_tls.LoadCertificateStore(certFile, otherKey, nil); err == nil {
+		t.Error("expected an error when the key does not match the certificate")
+	}
+}
@@ -0,0 +1,172 @@
+package test
+
+import (
+	"encoding/json"
+	"jinx/internal/load_balancer/algo"
+	"jinx/internal/upstream"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newBreakerGroup(t *testing.T, breaker types.CircuitBreakerConfig) *upstream.Group {
+	t.Helper()
+	servers := []types.UpStreamServer{{IP: "127.0.0.1", Port: 8081}, {IP: "127.0.0.1", Port: 8082}}
+	group, err := upstream.NewServerPoolGroup("server pool", servers, algo.RoundRobin, types.HealthCheckConfig{}, breaker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return group
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	testCases := []struct {
+		name          string
+		failures      int
+		expectEjected bool
+	}{
+		{"BelowThreshold", 2, false},
+		{"AtThreshold", 3, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			group := newBreakerGroup(t, types.CircuitBreakerConfig{Failures: 3, Window: 10, Backoff: 10})
+			failing := group.Members()[0]
+
+			for i := 0; i < tc.failures; i++ {
+				group.Begin(failing)(true)
+			}
+
+			if ejected := failing.Ejected.Load(); ejected != tc.expectEjected {
+				t.Fatalf("Expected ejected: %v, got: %v", tc.expectEjected, ejected)
+			}
+
+			// Ejected members are skipped by the algorithm
+			for i := 0; i < 4; i++ {
+				member, ok := group.Pick("client")
+				if !ok {
+					t.Fatal("Expected a member to be available")
+				}
+				if tc.expectEjected && member == failing {
+					t.Fatalf("Expected %s to be skipped", failing.Address)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerSuccessesDoNotEject(t *testing.T) {
+	group := newBreakerGroup(t, types.CircuitBreakerConfig{Failures: 2})
+	member := group.Members()[0]
+
+	for i := 0; i < 10; i++ {
+		group.Begin(member)(false)
+	}
+
+	if member.Ejected.Load() {
+		t.Fatal("Expected successful requests not to eject the member")
+	}
+	if member.ActiveConnections.Load() != 0 {
+		t.Fatalf("Expected no active connections, got: %d", member.ActiveConnections.Load())
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	testCases := []struct {
+		name          string
+		trialFailed   bool
+		expectBreaker string
+	}{
+		{"TrialSucceeds", false, constant.BREAKER_CLOSED},
+		{"TrialFails", true, constant.BREAKER_OPEN},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			group := newBreakerGroup(t, types.CircuitBreakerConfig{Failures: 1, Backoff: 1, MaxBackoff: 4})
+			member := group.Members()[0]
+
+			group.Begin(member)(true)
+			if !member.Ejected.Load() {
+				t.Fatal("Expected the member to be ejected")
+			}
+
+			deadline := time.Now().Add(3 * time.Second)
+			for member.Ejected.Load() && time.Now().Before(deadline) {
+				time.Sleep(20 * time.Millisecond)
+			}
+			if status := group.Status().Members[0].Breaker; status != constant.BREAKER_HALF_OPEN {
+				t.Fatalf("Expected breaker %s after the backoff, got: %s", constant.BREAKER_HALF_OPEN, status)
+			}
+
+			done := group.Begin(member)
+			if !member.Ejected.Load() {
+				t.Fatal("Expected the member to leave selection while its trial is in flight")
+			}
+			done(tc.trialFailed)
+
+			status := group.Status().Members[0]
+			if status.Breaker != tc.expectBreaker {
+				t.Fatalf("Expected breaker %s, got: %s", tc.expectBreaker, status.Breaker)
+			}
+			if member.Ejected.Load() != tc.trialFailed {
+				t.Fatalf("Expected ejected: %v, got: %v", tc.trialFailed, member.Ejected.Load())
+			}
+			if tc.trialFailed {
+				// The backoff doubles when the breaker opens again
+				if status.EjectedUntil == nil || time.Until(*status.EjectedUntil) <= time.Second {
+					t.Fatalf("Expected a doubled backoff, got ejected until: %v", status.EjectedUntil)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	group := newBreakerGroup(t, types.CircuitBreakerConfig{Failures: 1, Backoff: 10})
+	group.Begin(group.Members()[1])(true)
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"Upstreams", http.MethodGet, constant.ADMIN_UPSTREAMS_PATH, http.StatusOK},
+		{"MethodNotAllowed", http.MethodPost, constant.ADMIN_UPSTREAMS_PATH, http.StatusMethodNotAllowed},
+		{"UnknownPath", http.MethodGet, "/unknown", http.StatusNotFound},
+	}
+
+	handler := upstream.AdminHandler([]*upstream.Group{group}, nil)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+
+			if rec.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got: %d", tc.expectedStatus, rec.Code)
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var statuses []upstream.GroupStatus
+			if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+				t.Fatal(err)
+			}
+			if len(statuses) != 1 || len(statuses[0].Members) != 2 {
+				t.Fatalf("Expected one group with two members, got: %+v", statuses)
+			}
+			if breaker := statuses[0].Members[0].Breaker; breaker != constant.BREAKER_CLOSED {
+				t.Errorf("Expected the first member %s, got: %s", constant.BREAKER_CLOSED, breaker)
+			}
+			if ejected := statuses[0].Members[1]; ejected.Breaker != constant.BREAKER_OPEN || ejected.EjectedUntil == nil {
+				t.Errorf("Expected the second member open with an ejection time, got: %+v", ejected)
+			}
+		})
+	}
+}
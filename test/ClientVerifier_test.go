@@ -0,0 +1,299 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"jinx/internal/jinx_tls"
+	"jinx/pkg/util/types"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA is a certificate authority issuing client certificates and revocation lists for the tests
+type testCA struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, dir string) (*testCA, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Jinx Internal CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCA{certificate: certificate, key: key}, caFile
+}
+
+func (ca *testCA) issueClientCertificate(t *testing.T, commonName string, serial int64, dnsNames ...string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.certificate, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func (ca *testCA) writeCRL(t *testing.T, file string, revokedSerials ...int64) {
+	t.Helper()
+
+	entries := make([]x509.RevocationListEntry, 0, len(revokedSerials))
+	for _, serial := range revokedSerials {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+	}
+
+	template := &x509.RevocationList{
+		Number:                    big.NewInt(time.Now().UnixNano()),
+		ThisUpdate:                time.Now().Add(-time.Minute),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: entries,
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca.certificate, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(file, later, later)
+}
+
+// handshake connects to addr presenting clientCertificate and reports whether the server accepted the client
+func handshake(t *testing.T, addr string, clientCertificate *tls.Certificate) bool {
+	t.Helper()
+
+	config := &tls.Config{InsecureSkipVerify: true}
+	if clientCertificate != nil {
+		config.Certificates = []tls.Certificate{*clientCertificate}
+	}
+
+	conn, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// With TLS 1.3 the client learns that its certificate was rejected on the first read
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reply, err := io.ReadAll(conn)
+	return err == nil && string(reply) == "ok"
+}
+
+func serveTLS(t *testing.T, config *tls.Config) string {
+	t.Helper()
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+
+	go func() {
+		for {
+			conn, acceptErr := l.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer func() {
+					_ = conn.Close()
+				}()
+				if conn.(*tls.Conn).Handshake() == nil {
+					_, _ = conn.Write([]byte("ok"))
+				}
+			}(conn)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestClientVerifier(t *testing.T) {
+	tempDir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, tempDir, "server", "server.example.com")
+	ca, caFile := newTestCA(t, tempDir)
+	otherCA, _ := newTestCA(t, t.TempDir())
+
+	crlFile := filepath.Join(tempDir, "ca.crl")
+	ca.writeCRL(t, crlFile, 3)
+
+	valid := ca.issueClientCertificate(t, "billing", 2)
+	revoked := ca.issueClientCertificate(t, "compromised", 3)
+	untrusted := otherCA.issueClientCertificate(t, "intruder", 2)
+
+	testCases := []struct {
+		name        string
+		mode        string
+		certificate *tls.Certificate
+		accepted    bool
+	}{
+		{"Require/Valid", "require", &valid, true},
+		{"Require/Missing", "require", nil, false},
+		{"Require/Revoked", "require", &revoked, false},
+		{"Require/Untrusted", "require", &untrusted, false},
+		{"Optional/Valid", "optional", &valid, true},
+		{"Optional/Missing", "optional", nil, true},
+		{"Optional/Revoked", "optional", &revoked, false},
+		{"Optional/Untrusted", "optional", &untrusted, false},
+		{"None/Untrusted", "none", &untrusted, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := jinx_tls.LoadCertificateStore(certFile, keyFile, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			verifier, err := jinx_tls.NewClientVerifier(types.ClientAuthConfig{Mode: tc.mode, CAFile: caFile, CRLFiles: []string{crlFile}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.SetClientVerifier(verifier)
+
+			addr := serveTLS(t, store.TLSConfig())
+			if accepted := handshake(t, addr, tc.certificate); accepted != tc.accepted {
+				t.Errorf("Expected accepted to be %v, got %v", tc.accepted, accepted)
+			}
+		})
+	}
+}
+
+func TestClientVerifierReloadCRL(t *testing.T) {
+	tempDir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, tempDir, "server", "server.example.com")
+	ca, caFile := newTestCA(t, tempDir)
+
+	crlFile := filepath.Join(tempDir, "ca.crl")
+	ca.writeCRL(t, crlFile)
+
+	client := ca.issueClientCertificate(t, "billing", 2)
+
+	store, err := jinx_tls.LoadCertificateStore(certFile, keyFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := jinx_tls.NewClientVerifier(types.ClientAuthConfig{Mode: "require", CAFile: caFile, CRLFiles: []string{crlFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetClientVerifier(verifier)
+	addr := serveTLS(t, store.TLSConfig())
+
+	if !handshake(t, addr, &client) {
+		t.Fatal("Expected the client certificate to be accepted before it is revoked")
+	}
+
+	ca.writeCRL(t, crlFile, 2)
+	if reloaded, err := store.ReloadIfChanged(); err != nil || !reloaded {
+		t.Fatalf("Expected the CRL to be reloaded, got reloaded=%v err=%v", reloaded, err)
+	}
+
+	if handshake(t, addr, &client) {
+		t.Error("Expected the client certificate to be rejected once the reloaded CRL revokes it")
+	}
+
+	// A broken CRL must not replace the one in use
+	if err := os.WriteFile(crlFile, []byte("not a crl"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.ReloadIfChanged(); err == nil {
+		t.Error("Expected an error when the CRL cannot be parsed")
+	}
+	if handshake(t, addr, &client) {
+		t.Error("Expected the previous CRL to be kept when the new one is broken")
+	}
+}
+
+func TestNewClientVerifierErrors(t *testing.T) {
+	tempDir := t.TempDir()
+	_, caFile := newTestCA(t, tempDir)
+	emptyFile := filepath.Join(tempDir, "empty.pem")
+	_ = os.WriteFile(emptyFile, []byte(""), 0644)
+
+	testCases := []struct {
+		name   string
+		config types.ClientAuthConfig
+	}{
+		{"UnknownMode", types.ClientAuthConfig{Mode: "sometimes", CAFile: caFile}},
+		{"MissingCA", types.ClientAuthConfig{Mode: "require", CAFile: filepath.Join(tempDir, "missing.crt")}},
+		{"EmptyCA", types.ClientAuthConfig{Mode: "require", CAFile: emptyFile}},
+		{"InvalidCRL", types.ClientAuthConfig{Mode: "require", CAFile: caFile, CRLFiles: []string{emptyFile}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := jinx_tls.NewClientVerifier(tc.config); err == nil {
+				t.Errorf("Expected an error for %+v", tc.config)
+			}
+		})
+	}
+
+	verifier, err := jinx_tls.NewClientVerifier(types.ClientAuthConfig{Mode: "none", CAFile: caFile})
+	if err != nil || verifier != nil {
+		t.Errorf("Expected no verifier when the verify mode is none, got %v, %v", verifier, err)
+	}
+}
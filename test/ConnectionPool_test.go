@@ -0,0 +1,220 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"jinx/internal/reverse_proxy"
+	"jinx/internal/upstream"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// concurrencyBackend answers after delay and records the highest number of requests it served at once.
+type concurrencyBackend struct {
+	delay   time.Duration
+	active  atomic.Int64
+	highest atomic.Int64
+}
+
+func (b *concurrencyBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	active := b.active.Add(1)
+	defer b.active.Add(-1)
+	for {
+		highest := b.highest.Load()
+		if active <= highest || b.highest.CompareAndSwap(highest, active) {
+			break
+		}
+	}
+
+	time.Sleep(b.delay)
+	_, _ = io.WriteString(w, "pooled")
+}
+
+// poolStatus returns the status of the pool name of jx.
+func poolStatus(t *testing.T, jx *reverse_proxy.JinxReverseProxyServer, name string) upstream.PoolStatus {
+	t.Helper()
+
+	for _, status := range jx.ConnectionPools() {
+		if status.Name == name {
+			return status
+		}
+	}
+	t.Fatalf("Expected a connection pool named %s, got: %v", name, jx.ConnectionPools())
+	return upstream.PoolStatus{}
+}
+
+func TestConnectionPool(t *testing.T) {
+	backend := &concurrencyBackend{}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	testCases := []struct {
+		name           string
+		config         types.ConnectionPoolConfig
+		group          *types.ConnectionPoolConfig
+		pool           string
+		expectedDials  int64
+		expectedReused int64
+	}{
+		{"Reused", types.ConnectionPoolConfig{}, nil, "default", 1, 9},
+		{"DisableKeepAlives", types.ConnectionPoolConfig{DisableKeepAlives: true}, nil, "default", 10, 0},
+		{"GroupWithServerSettings", types.ConnectionPoolConfig{}, nil, "upstream group pooled", 1, 9},
+		{"GroupSettings", types.ConnectionPoolConfig{}, &types.ConnectionPoolConfig{DisableKeepAlives: true}, "upstream group pooled", 10, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := types.JinxReverseProxyServerConfig{LogRoot: t.TempDir(), ConnectionPool: tc.config}
+			config.Routes = []types.Route{{Path: "/", Upstream: server.URL}}
+			if tc.pool != "default" {
+				config.Routes = []types.Route{{Path: "/", UpstreamGroup: "pooled"}}
+				config.UpstreamGroups = map[string]types.UpstreamGroupConfig{
+					"pooled": {Members: []types.UpstreamMemberConfig{{URL: server.URL}}, ConnectionPool: tc.group},
+				}
+			}
+			jx := reverse_proxy.NewJinxReverseProxyServer(config, t.TempDir())
+
+			for i := 0; i < 10; i++ {
+				w := httptest.NewRecorder()
+				jx.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://proxy.example.com/", nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("Request %d: expected 200, got: %d", i, w.Code)
+				}
+			}
+
+			status := poolStatus(t, jx, tc.pool)
+			if status.Requests != 10 || status.ActiveRequests != 0 {
+				t.Errorf("Expected 10 requests and none active, got: %+v", status)
+			}
+			if status.Dials != tc.expectedDials || status.ReusedRequests != tc.expectedReused {
+				t.Errorf("Expected %d dials and %d reused requests, got: %+v", tc.expectedDials, tc.expectedReused, status)
+			}
+		})
+	}
+}
+
+func TestConnectionPoolLimits(t *testing.T) {
+	testCases := []struct {
+		name            string
+		config          types.ConnectionPoolConfig
+		delay           time.Duration
+		expectedStatus  int
+		expectedHighest int64 // highest number of requests the upstream served at once, not checked when zero
+	}{
+		{"MaxConnsPerHost", types.ConnectionPoolConfig{MaxConnsPerHost: 1}, 50 * time.Millisecond, http.StatusOK, 1},
+		{"Unlimited", types.ConnectionPoolConfig{}, 200 * time.Millisecond, http.StatusOK, 5},
+		{"ResponseHeaderTimeout", types.ConnectionPoolConfig{ResponseHeaderTimeout: 1}, 1500 * time.Millisecond, http.StatusGatewayTimeout, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := &concurrencyBackend{delay: tc.delay}
+			server := httptest.NewServer(backend)
+			defer server.Close()
+
+			jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+				LogRoot:        t.TempDir(),
+				Routes:         []types.Route{{Path: "/", Upstream: server.URL}},
+				ConnectionPool: tc.config,
+			}, t.TempDir())
+
+			var wg sync.WaitGroup
+			codes := make([]int, 5)
+			for i := range codes {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					w := httptest.NewRecorder()
+					jx.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://proxy.example.com/", nil))
+					codes[i] = w.Code
+				}(i)
+			}
+			wg.Wait()
+
+			for i, code := range codes {
+				if code != tc.expectedStatus {
+					t.Errorf("Request %d: expected %d, got: %d", i, tc.expectedStatus, code)
+				}
+			}
+			if tc.expectedHighest != 0 && backend.highest.Load() != tc.expectedHighest {
+				t.Errorf("Expected at most %d requests at once, got: %d", tc.expectedHighest, backend.highest.Load())
+			}
+		})
+	}
+}
+
+func TestConnectionPoolAdmin(t *testing.T) {
+	server := httptest.NewServer(&concurrencyBackend{})
+	defer server.Close()
+
+	tlsConfig := types.UpstreamTLSConfig{InsecureSkipVerify: true}
+	jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+		LogRoot: t.TempDir(),
+		Routes: []types.Route{
+			{Path: "/", Upstream: server.URL},
+			{Path: "/secure", Upstream: "https://upstream.internal", TLS: tlsConfig},
+			{Path: "/api", UpstreamGroup: "api"},
+		},
+		UpstreamGroups: map[string]types.UpstreamGroupConfig{"api": {Members: []types.UpstreamMemberConfig{{URL: server.URL}}}},
+	}, t.TempDir())
+
+	w := httptest.NewRecorder()
+	jx.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://proxy.example.com/", nil))
+
+	rec := httptest.NewRecorder()
+	upstream.AdminHandler(nil, jx.ConnectionPools).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, constant.ADMIN_POOLS_PATH, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got: %d", rec.Code)
+	}
+
+	var statuses []upstream.PoolStatus
+	if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"default", "upstream group api", "route /secure"}
+	if len(statuses) != len(expected) {
+		t.Fatalf("Expected the pools %v, got: %+v", expected, statuses)
+	}
+	for i, name := range expected {
+		if statuses[i].Name != name {
+			t.Errorf("Expected pool %d to be %s, got: %s", i, name, statuses[i].Name)
+		}
+	}
+	if statuses[0].Requests != 1 || statuses[0].OpenConnections != 1 {
+		t.Errorf("Expected one request over one open connection in the default pool, got: %+v", statuses[0])
+	}
+}
+
+func TestValidateConnectionPoolConfig(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    types.ConnectionPoolConfig
+		expectErr bool
+	}{
+		{"Defaults", types.ConnectionPoolConfig{}, false},
+		{"Complete", types.ConnectionPoolConfig{MaxIdleConns: 200, MaxIdleConnsPerHost: 64, MaxConnsPerHost: 128, IdleConnTimeout: 60, DialTimeout: 5, TLSHandshakeTimeout: 5, ResponseHeaderTimeout: 30, KeepAlive: 15, DisableHTTP2: true}, false},
+		{"NegativeMaxIdleConns", types.ConnectionPoolConfig{MaxIdleConns: -1}, true},
+		{"NegativeMaxConnsPerHost", types.ConnectionPoolConfig{MaxConnsPerHost: -1}, true},
+		{"NegativeTimeout", types.ConnectionPoolConfig{ResponseHeaderTimeout: -1}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := helper.ValidateConnectionPoolConfig(tc.config); (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+
+			groups := map[string]types.UpstreamGroupConfig{"api": {Members: []types.UpstreamMemberConfig{{URL: "http://api"}}, ConnectionPool: &tc.config}}
+			if _, err := upstream.NewGroups(groups); (err != nil) != tc.expectErr {
+				t.Errorf("Expected error for the upstream group: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package test
+
+import (
+	"encoding/json"
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestForwardingHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers := map[string]string{"Host": r.Host}
+		for _, name := range []string{"X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto", "X-Real-Ip", "Forwarded", "X-Client", "X-Secret", constant.REQUEST_ID_HEADER} {
+			headers[name] = r.Header.Get(name)
+		}
+		w.Header().Set("Server", "backend")
+		_ = json.NewEncoder(w).Encode(headers)
+	}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+
+	route := types.Route{
+		Path:     "/",
+		Upstream: backend.URL,
+		RequestHeaders: types.HeaderRules{
+			Remove: []string{"X-Secret"},
+			Set:    map[string]string{"X-Client": "$client_ip via ${scheme}"},
+		},
+		ResponseHeaders: types.HeaderRules{
+			Remove: []string{"Server"},
+			Add:    map[string]string{"X-Route": "$method $path"},
+		},
+	}
+
+	testCases := []struct {
+		name         string
+		remoteAddr   string
+		preserveHost bool
+		headers      map[string]string
+		expected     map[string]string
+	}{
+		{
+			name:       "UntrustedClient",
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Forwarded-Host": "evil.example.com", "Forwarded": "for=203.0.113.7", "X-Secret": "s3cr3t", constant.REQUEST_ID_HEADER: "spoofed"},
+			expected: map[string]string{
+				"Host":              backendURL.Host,
+				"X-Forwarded-For":   "192.0.2.1",
+				"X-Forwarded-Host":  "proxy.example.com",
+				"X-Forwarded-Proto": "http",
+				"X-Real-Ip":         "192.0.2.1",
+				"Forwarded":         "for=192.0.2.1;host=proxy.example.com;proto=http",
+				"X-Client":          "192.0.2.1 via http",
+				"X-Secret":          "",
+			},
+		},
+		{
+			name:       "TrustedProxy",
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Forwarded-Host": "www.example.com", "X-Forwarded-Proto": "https", "Forwarded": "for=203.0.113.7;proto=https", constant.REQUEST_ID_HEADER: "upstream-id"},
+			expected: map[string]string{
+				"X-Forwarded-For":          "203.0.113.7, 10.0.0.5",
+				"X-Forwarded-Host":         "www.example.com",
+				"X-Forwarded-Proto":        "https",
+				"X-Real-Ip":                "203.0.113.7",
+				"Forwarded":                "for=203.0.113.7;proto=https, for=10.0.0.5;host=proxy.example.com;proto=http",
+				"X-Client":                 "203.0.113.7 via http",
+				constant.REQUEST_ID_HEADER: "upstream-id",
+			},
+		},
+		{
+			name:         "PreserveHost",
+			remoteAddr:   "192.0.2.1:1234",
+			preserveHost: true,
+			expected:     map[string]string{"Host": "proxy.example.com"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := types.JinxReverseProxyServerConfig{
+				LogRoot:        t.TempDir(),
+				Routes:         []types.Route{route},
+				TrustedProxies: []string{"10.0.0.0/8"},
+				PreserveHost:   tc.preserveHost,
+			}
+			jx := reverse_proxy.NewJinxReverseProxyServer(config, t.TempDir())
+
+			r := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/users", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for name, value := range tc.headers {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			jx.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got: %d", w.Code)
+			}
+
+			var received map[string]string
+			if err := json.NewDecoder(w.Body).Decode(&received); err != nil {
+				t.Fatal(err)
+			}
+			for name, value := range tc.expected {
+				if received[name] != value {
+					t.Errorf("Expected %s %q, got: %q", name, value, received[name])
+				}
+			}
+
+			requestID := w.Header().Get(constant.REQUEST_ID_HEADER)
+			if requestID == "" || requestID == "spoofed" || requestID != received[constant.REQUEST_ID_HEADER] {
+				t.Errorf("Expected the request ID sent upstream to be returned, got: %q and %q", requestID, received[constant.REQUEST_ID_HEADER])
+			}
+			if w.Header().Get("Server") != "" || w.Header().Get("X-Route") != "GET /users" {
+				t.Errorf("Expected the response header rules to be applied, got: %v", w.Header())
+			}
+		})
+	}
+}
+
+func TestHeaderRulesValidation(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rules     types.HeaderRules
+		expectErr bool
+	}{
+		{"Valid", types.HeaderRules{Remove: []string{"Server"}, Set: map[string]string{"X-Request": "$request_id"}}, false},
+		{"InvalidName", types.HeaderRules{Set: map[string]string{"X Client": "1"}}, true},
+		{"UnknownVariable", types.HeaderRules{Add: map[string]string{"X-User": "${user}"}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := reverse_proxy.NewRouter([]types.Route{{Path: "/", Upstream: "http://a", RequestHeaders: tc.rules}})
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/types"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// grpcEchoBackend is a cleartext HTTP/2 server answering every gRPC message with the same message, one by one,
+// and ending the stream with an OK status in its trailers. /version answers with the protocol of the request.
+func grpcEchoBackend() *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/version" {
+			_, _ = io.WriteString(w, r.Proto)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		for {
+			message, err := readGRPCMessage(r.Body)
+			if err != nil {
+				break
+			}
+			_, _ = w.Write(grpcMessage(message))
+			w.(http.Flusher).Flush()
+		}
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set("Grpc-Message", "")
+	})
+	return httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+}
+
+// grpcMessage frames message as a gRPC message: an uncompressed flag and the length of the message.
+func grpcMessage(message string) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+func readGRPCMessage(r io.Reader) (string, error) {
+	prefix := make([]byte, 5)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return "", err
+	}
+	message := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(r, message); err != nil {
+		return "", err
+	}
+	return string(message), nil
+}
+
+// newGRPCProxy serves the reverse proxy over TLS with HTTP/2, as gRPC clients reach it.
+func newGRPCProxy(t *testing.T, routes []types.Route) (*httptest.Server, *http.Client) {
+	t.Helper()
+
+	jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{LogRoot: t.TempDir(), Routes: routes}, t.TempDir())
+	if jx == nil {
+		t.Fatalf("Expected a server for %v", routes)
+	}
+
+	proxy := httptest.NewUnstartedServer(jx)
+	proxy.EnableHTTP2 = true
+	proxy.StartTLS()
+	t.Cleanup(proxy.Close)
+	return proxy, proxy.Client()
+}
+
+func TestGRPCProxyStreaming(t *testing.T) {
+	backend := grpcEchoBackend()
+	defer backend.Close()
+
+	proxy, client := newGRPCProxy(t, []types.Route{{Path: "/echo.Echo/", Upstream: backend.URL, Protocol: "h2c"}})
+
+	body, requestWriter := io.Pipe()
+	r, _ := http.NewRequest(http.MethodPost, proxy.URL+"/echo.Echo/Stream", body)
+	r.Header.Set("Content-Type", "application/grpc")
+	r.Header.Set("Te", "trailers")
+
+	responses := make(chan *http.Response, 1)
+	errs := make(chan error, 1)
+	go func() {
+		res, err := client.Do(r)
+		if err != nil {
+			errs <- err
+			return
+		}
+		responses <- res
+	}()
+
+	// Every message is echoed before the next one is sent, which requires both directions to stream
+	var res *http.Response
+	var reader *bufio.Reader
+	for i, message := range []string{"ping", "pong", "again"} {
+		if _, err := requestWriter.Write(grpcMessage(message)); err != nil {
+			t.Fatalf("Message %d: failed to send: %v", i, err)
+		}
+		if res == nil {
+			select {
+			case res = <-responses:
+				defer func() {
+					_ = res.Body.Close()
+				}()
+				reader = bufio.NewReader(res.Body)
+			case err := <-errs:
+				t.Fatalf("Request failed: %v", err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("No response from the proxy")
+			}
+			if res.ProtoMajor != 2 {
+				t.Errorf("Expected HTTP/2 to the client, got: %s", res.Proto)
+			}
+		}
+
+		echo, err := readGRPCMessage(reader)
+		if err != nil || echo != message {
+			t.Fatalf("Message %d: expected the echo %q, got: %q (%v)", i, message, echo, err)
+		}
+	}
+	_ = requestWriter.Close()
+
+	if _, err := io.ReadAll(reader); err != nil {
+		t.Fatalf("Failed to read the end of the stream: %v", err)
+	}
+	if status := res.Trailer.Get("Grpc-Status"); status != "0" {
+		t.Errorf("Expected the Grpc-Status trailer of the upstream, got: %q", status)
+	}
+}
+
+func TestGRPCProxyStatus(t *testing.T) {
+	backend := grpcEchoBackend()
+	defer backend.Close()
+
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedURL := "http://" + closed.Addr().String()
+	_ = closed.Close()
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer plain.Close()
+
+	proxy, client := newGRPCProxy(t, []types.Route{
+		{Path: "/down.Service/", Upstream: closedURL, Protocol: "h2c"},
+		{Path: "/plain.Service/", Upstream: plain.URL},
+	})
+
+	testCases := []struct {
+		name   string
+		path   string
+		status string
+	}{
+		{"UnreachableUpstream", "/down.Service/Call", "14"},
+		{"HTTPError", "/plain.Service/Call", "14"},
+		{"NoRoute", "/unknown.Service/Call", "12"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodPost, proxy.URL+tc.path, nil)
+			r.Header.Set("Content-Type", "application/grpc")
+			res, err := client.Do(r)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("Expected status 200, got: %d", res.StatusCode)
+			}
+			if contentType := res.Header.Get("Content-Type"); contentType != "application/grpc" {
+				t.Errorf("Expected a gRPC response, got: %s", contentType)
+			}
+			if status := res.Header.Get("Grpc-Status"); status != tc.status {
+				t.Errorf("Expected Grpc-Status %s, got: %q (%s)", tc.status, status, res.Header.Get("Grpc-Message"))
+			}
+		})
+	}
+}
+
+func TestUpstreamProtocol(t *testing.T) {
+	backend := grpcEchoBackend()
+	defer backend.Close()
+
+	testCases := []struct {
+		name      string
+		protocol  string
+		expected  string
+		expectErr bool
+	}{
+		{"Default", "", "HTTP/1.1", false},
+		{"HTTP1", "http1", "HTTP/1.1", false},
+		{"H2C", "h2c", "HTTP/2.0", false},
+		{"Unknown", "h3", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			routes := []types.Route{{Path: "/", Upstream: backend.URL, Protocol: tc.protocol}}
+			_, err := reverse_proxy.NewRouter(routes)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+			if tc.expectErr {
+				return
+			}
+
+			proxy, client := newGRPCProxy(t, routes)
+			res, err := client.Get(proxy.URL + "/version")
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			version, _ := io.ReadAll(res.Body)
+			_ = res.Body.Close()
+			if string(version) != tc.expected {
+				t.Errorf("Expected the upstream to receive %s, got: %s (status %d)", tc.expected, version, res.StatusCode)
+			}
+		})
+	}
+}
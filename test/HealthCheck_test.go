@@ -0,0 +1,175 @@
+package test
+
+import (
+	"io"
+	"jinx/internal/load_balancer/algo"
+	"jinx/internal/upstream"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckHealth(t *testing.T) {
+	var flappingUp atomic.Bool
+	flappingUp.Store(true)
+	flapping := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !flappingUp.Load() {
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "status: ok")
+	}))
+	defer flapping.Close()
+
+	stable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "status: ok")
+	}))
+	defer stable.Close()
+
+	config := types.UpstreamGroupConfig{
+		Members:     []types.UpstreamMemberConfig{{URL: flapping.URL}, {URL: stable.URL}},
+		HealthCheck: types.HealthCheckConfig{Type: constant.HEALTH_CHECK_HTTP, Path: "/health", Rise: 2, Fall: 2, ExpectedBody: "status: ok"},
+	}
+	group, err := upstream.NewGroup("api", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flappingMember := group.Members()[0]
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	steps := []struct {
+		name              string
+		up                bool
+		expectedUnhealthy bool
+	}{
+		{"Healthy", true, false},
+		{"FirstFailureIsTolerated", false, false},
+		{"FallThresholdReached", false, true},
+		{"FirstSuccessIsNotEnough", true, true},
+		{"RiseThresholdReached", true, false},
+		{"FailureResetsRise", false, false},
+		{"SuccessResetsFall", true, false},
+	}
+
+	for _, step := range steps {
+		flappingUp.Store(step.up)
+		group.CheckHealth(logger, logger)
+		if flappingMember.Unhealthy.Load() != step.expectedUnhealthy {
+			t.Fatalf("%s: expected unhealthy to be %v", step.name, step.expectedUnhealthy)
+		}
+	}
+
+	// Unhealthy members are not picked
+	flappingUp.Store(false)
+	group.CheckHealth(logger, logger)
+	group.CheckHealth(logger, logger)
+	for i := 0; i < 4; i++ {
+		if member, ok := group.Pick("client"); !ok || member == flappingMember {
+			t.Fatal("Expected only the healthy member to be picked")
+		}
+	}
+
+	stable.Close()
+	group.CheckHealth(logger, logger)
+	group.CheckHealth(logger, logger)
+	if _, ok := group.Pick("client"); ok {
+		t.Error("Expected no member to be picked when all members are unhealthy")
+	}
+}
+
+func TestHealthCheckTypes(t *testing.T) {
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+	plainServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer plainServer.Close()
+
+	closedPort := freePort(t)
+	tlsAddress := tlsServer.Listener.Addr().String()
+	plainAddress := plainServer.Listener.Addr().String()
+
+	testCases := []struct {
+		name              string
+		healthCheck       types.HealthCheckConfig
+		address           string
+		expectedUnhealthy bool
+	}{
+		{"TCPOpen", types.HealthCheckConfig{Type: constant.HEALTH_CHECK_TCP}, plainAddress, false},
+		{"TCPClosed", types.HealthCheckConfig{Type: constant.HEALTH_CHECK_TCP}, net.JoinHostPort("127.0.0.1", strconv.Itoa(closedPort)), true},
+		{"TLSHandshake", types.HealthCheckConfig{Type: constant.HEALTH_CHECK_TLS}, tlsAddress, false},
+		{"TLSAgainstPlainServer", types.HealthCheckConfig{Type: constant.HEALTH_CHECK_TLS}, plainAddress, true},
+		{"HTTPAnySuccess", types.HealthCheckConfig{Type: constant.HEALTH_CHECK_HTTP}, plainAddress, false},
+		{"HTTPExpectedStatus", types.HealthCheckConfig{Type: constant.HEALTH_CHECK_HTTP, ExpectedStatus: []int{200}}, plainAddress, true},
+	}
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			host, port, _ := net.SplitHostPort(tc.address)
+			portNumber, _ := strconv.Atoi(port)
+			tc.healthCheck.Fall = 1
+
+			group, err := upstream.NewServerPoolGroup("server pool", []types.UpStreamServer{{IP: host, Port: portNumber}}, algo.RoundRobin, tc.healthCheck, types.CircuitBreakerConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			group.CheckHealth(logger, logger)
+
+			if unhealthy := group.Members()[0].Unhealthy.Load(); unhealthy != tc.expectedUnhealthy {
+				t.Errorf("Expected unhealthy to be %v, got %v", tc.expectedUnhealthy, unhealthy)
+			}
+		})
+	}
+}
+
+func TestHealthCheckUpstreamTLS(t *testing.T) {
+	fixture := newUpstreamTLSFixture(t)
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backend.TLS = fixture.serverConfig
+	backend.StartTLS()
+	defer backend.Close()
+
+	trusted := types.UpstreamTLSConfig{CAFile: fixture.certFile, ServerName: "upstream.internal", CertFile: fixture.clientCertFile, KeyFile: fixture.clientKeyFile}
+	withoutServerName := types.UpstreamTLSConfig{CAFile: fixture.certFile, CertFile: fixture.clientCertFile, KeyFile: fixture.clientKeyFile}
+
+	testCases := []struct {
+		name              string
+		checkType         string
+		tlsConfig         types.UpstreamTLSConfig
+		expectedUnhealthy bool
+	}{
+		{"HTTPClientCertificate", constant.HEALTH_CHECK_HTTP, trusted, false},
+		{"TLSClientCertificate", constant.HEALTH_CHECK_TLS, trusted, false},
+		{"HTTPWithoutTLSSettings", constant.HEALTH_CHECK_HTTP, types.UpstreamTLSConfig{}, true},
+		{"TLSWithoutTLSSettings", constant.HEALTH_CHECK_TLS, types.UpstreamTLSConfig{}, true},
+		// The certificate of the member is verified like that of proxied traffic
+		{"HTTPUnverifiedCertificate", constant.HEALTH_CHECK_HTTP, withoutServerName, true},
+		{"TLSUnverifiedCertificate", constant.HEALTH_CHECK_TLS, withoutServerName, true},
+	}
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			group, err := upstream.NewGroup("secure", types.UpstreamGroupConfig{
+				Members:     []types.UpstreamMemberConfig{{URL: backend.URL}},
+				HealthCheck: types.HealthCheckConfig{Type: tc.checkType, Fall: 1},
+				TLS:         tc.tlsConfig,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			group.CheckHealth(logger, logger)
+
+			if unhealthy := group.Members()[0].Unhealthy.Load(); unhealthy != tc.expectedUnhealthy {
+				t.Errorf("Expected unhealthy to be %v, got %v", tc.expectedUnhealthy, unhealthy)
+			}
+		})
+	}
+}
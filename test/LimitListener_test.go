@@ -0,0 +1,158 @@
+package test
+
+import (
+	"errors"
+	"jinx/internal/listener"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimitListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := make(chan struct{}, 10)
+	l := listener.NewLimitListener(inner, 0, 2, func(conn net.Conn) {
+		rejected <- struct{}{}
+	})
+	defer func() {
+		_ = l.Close()
+	}()
+
+	accepted := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, acceptErr := l.Accept()
+			if acceptErr != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	clients := make([]net.Conn, 0)
+	defer func() {
+		for _, client := range clients {
+			_ = client.Close()
+		}
+	}()
+
+	for i := 0; i < 3; i++ {
+		client, dialErr := net.Dial("tcp", inner.Addr().String())
+		if dialErr != nil {
+			t.Fatal(dialErr)
+		}
+		clients = append(clients, client)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-accepted:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected connection %d to be accepted", i+1)
+		}
+	}
+
+	select {
+	case <-rejected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the third connection from the same ip to be rejected")
+	}
+
+	if active := l.ActiveConnections(); active != 2 {
+		t.Errorf("expected %d active connections but got %d", 2, active)
+	}
+}
+
+func TestLimitListenerReleasesSlots(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := listener.NewLimitListener(inner, 1, 0, nil)
+	defer func() {
+		_ = l.Close()
+	}()
+
+	accepted := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, acceptErr := l.Accept()
+			if acceptErr != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	first, _ := net.Dial("tcp", inner.Addr().String())
+	defer func() {
+		_ = first.Close()
+	}()
+	second, _ := net.Dial("tcp", inner.Addr().String())
+	defer func() {
+		_ = second.Close()
+	}()
+
+	conn := <-accepted
+	select {
+	case <-accepted:
+		t.Fatal("expected the second connection to wait for a free slot")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	_ = conn.Close()
+	select {
+	case <-accepted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the second connection to be accepted once the first was closed")
+	}
+}
+
+func TestLimitListenerCloseWhileFull(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := listener.NewLimitListener(inner, 1, 0, nil)
+
+	client, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	// The only slot stays held, like a hijacked tunnel, while the next Accept waits for it
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	acceptErr := make(chan error, 1)
+	go func() {
+		_, err := l.Accept()
+		acceptErr <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	_ = l.Close()
+
+	select {
+	case err := <-acceptErr:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("expected net.ErrClosed but got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Accept to return once the full listener was closed")
+	}
+}
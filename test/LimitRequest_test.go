@@ -0,0 +1,93 @@
+package test
+
+import (
+	"io"
+	"jinx/internal/listener"
+	"jinx/pkg/util/types"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowBodyReader returns its content after a delay, so that the request carrying it outlives the body read timeout.
+type slowBodyReader struct {
+	delay time.Duration
+	sent  bool
+}
+
+func (r *slowBodyReader) Read(p []byte) (int, error) {
+	if r.sent {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	r.sent = true
+	return copy(p, "late"), nil
+}
+
+func TestLimitRequestBodyReadTimeout(t *testing.T) {
+	// Reads the body, then answers like a slow upstream unless the request was canceled in the meantime
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "body read failed", http.StatusRequestTimeout)
+			return
+		}
+		select {
+		case <-time.After(1500 * time.Millisecond):
+			_, _ = io.WriteString(w, "slow "+string(body))
+		case <-r.Context().Done():
+		}
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := listener.NewHttpServer(l.Addr().String(), handler, types.ListenerLimits{BodyReadTimeout: 1})
+	go func() {
+		_ = s.Serve(l)
+	}()
+	defer func() {
+		_ = s.Close()
+	}()
+	url := "http://" + l.Addr().String() + "/"
+
+	testCases := []struct {
+		name           string
+		method         string
+		body           io.Reader
+		expectedStatus int // zero when the request must fail
+		expectedBody   string
+	}{
+		{"SlowResponseWithoutBody", http.MethodGet, nil, http.StatusOK, "slow "},
+		{"SlowResponseAfterBody", http.MethodPost, strings.NewReader("data"), http.StatusOK, "slow data"},
+		{"SlowBody", http.MethodPost, &slowBodyReader{delay: 1500 * time.Millisecond}, 0, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest(tc.method, url, tc.body)
+			client := &http.Client{Timeout: 5 * time.Second}
+			res, err := client.Do(r)
+			if tc.expectedStatus == 0 {
+				if err == nil {
+					_ = res.Body.Close()
+					if res.StatusCode == http.StatusOK {
+						t.Errorf("Expected the body read timeout to fail the request, got: %d", res.StatusCode)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected the response to outlive the body read timeout, got: %v", err)
+			}
+			body, _ := io.ReadAll(res.Body)
+			_ = res.Body.Close()
+			if res.StatusCode != tc.expectedStatus || string(body) != tc.expectedBody {
+				t.Errorf("Expected %d %q, got: %d %q", tc.expectedStatus, tc.expectedBody, res.StatusCode, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"jinx/internal/load_balancer"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net"
+	"testing"
+	"time"
+)
+
+// pongServer answers every connection with pong and returns its port.
+func pongServer(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+	go func() {
+		for {
+			conn, acceptErr := l.Accept()
+			if acceptErr != nil {
+				return
+			}
+			_, _ = io.WriteString(conn, "pong")
+			_ = conn.Close()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// waitForPong waits up to five seconds for addr to relay the pong of the server pool.
+func waitForPong(t *testing.T, addr string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		reply, err := readPong(addr)
+		if err == nil && reply == "pong" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s to relay pong, got: %q %v", addr, reply, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func readPong(addr string) (string, error) {
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	reply := make([]byte, 4)
+	n, err := io.ReadFull(conn, reply)
+	return string(reply[:n]), err
+}
+
+func TestLoadBalancerStopReleasesPorts(t *testing.T) {
+	port, extraPort := freePort(t), freePort(t)
+	jx := load_balancer.NewJinxLoadBalancingServer(types.JinxLoadBalancingServerConfig{
+		IP:         "127.0.0.1",
+		Port:       port,
+		LogRoot:    t.TempDir(),
+		Algorithm:  constant.ROUND_ROBIN,
+		ServerPool: []types.UpStreamServer{{IP: "127.0.0.1", Port: pongServer(t)}},
+		Listeners:  []types.ListenerConfig{{Port: extraPort}},
+	}, t.TempDir())
+	addrs := []string{fmt.Sprintf("127.0.0.1:%d", port), fmt.Sprintf("127.0.0.1:%d", extraPort)}
+
+	jx.Start()
+	defer jx.Stop()
+	for _, addr := range addrs {
+		waitForPong(t, addr)
+	}
+
+	// A restart binds the same ports again
+	if jx.Restart() == nil {
+		t.Fatal("Expected the running load balancer to restart")
+	}
+	for _, addr := range addrs {
+		waitForPong(t, addr)
+	}
+
+	jx.Stop()
+	for _, addr := range addrs {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Errorf("Expected %s to be free after Stop, got: %v", addr, err)
+			continue
+		}
+		_ = l.Close()
+	}
+	if jx.Restart() != nil {
+		t.Error("Expected a stopped load balancer not to restart")
+	}
+}
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"fmt"
+	"jinx/internal/load_balancer/algo"
+	"jinx/internal/upstream"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"testing"
+)
+
+func newTestPool(weights ...int) *types.UpstreamPool {
+	pool := &types.UpstreamPool{}
+	for i, weight := range weights {
+		pool.Members = append(pool.Members, &types.UpstreamMember{Address: fmt.Sprintf("10.0.0.%d:80", i+1), Weight: weight})
+	}
+	return pool
+}
+
+func TestLoadBalancingAlgorithm(t *testing.T) {
+	testCases := []struct {
+		name      string
+		algorithm types.LoadBalancerAlgo
+		weights   []int
+		active    []int64
+		picks     int
+		expected  []int // number of picks expected for every member
+	}{
+		{"RoundRobin", constant.ROUND_ROBIN, []int{1, 1, 1}, nil, 6, []int{2, 2, 2}},
+		{"RoundRobinIgnoresWeights", constant.ROUND_ROBIN, []int{5, 1}, nil, 4, []int{2, 2}},
+		{"WeightedRoundRobin", constant.WEIGHTED_ROUND_ROBIN, []int{3, 1, 0}, nil, 10, []int{6, 2, 2}},
+		{"LeastConnections", constant.LEAST_CONNECTIONS, []int{1, 1, 1}, []int64{4, 0, 2}, 3, []int{0, 3, 0}},
+		{"LeastConnectionsSharesTies", constant.LEAST_CONNECTIONS, []int{1, 1}, []int64{1, 1}, 4, []int{2, 2}},
+		{"WeightedLeastConnections", constant.WEIGHTED_LEAST_CONNECTIONS, []int{4, 1}, []int64{3, 1}, 2, []int{2, 0}},
+		{"LeastResponseTimeTriesNewMembers", constant.LEAST_RESPONSE_TIME, []int{1, 1}, nil, 2, []int{1, 1}},
+		{"Hashing", constant.HASHING, []int{1, 1, 1}, nil, 5, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			algorithm, ok := algo.ByName(tc.algorithm)
+			if !ok {
+				t.Fatalf("Expected %s to be a known algorithm", tc.algorithm)
+			}
+
+			pool := newTestPool(tc.weights...)
+			for i, active := range tc.active {
+				pool.Members[i].ActiveConnections.Store(active)
+			}
+
+			counts := make(map[*types.UpstreamMember]int)
+			for i := 0; i < tc.picks; i++ {
+				member := algorithm(pool, "192.0.2.7")
+				if member == nil {
+					t.Fatal("Expected a member to be picked")
+				}
+				counts[member]++
+			}
+
+			if tc.expected == nil {
+				// The same key always goes to the same member
+				if len(counts) != 1 {
+					t.Errorf("Expected one member for one key, got %d", len(counts))
+				}
+				return
+			}
+			for i, expected := range tc.expected {
+				if counts[pool.Members[i]] != expected {
+					t.Errorf("Expected member %d to be picked %d times, got %d", i, expected, counts[pool.Members[i]])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBalancingAlgorithmEmptyPool(t *testing.T) {
+	for _, name := range []types.LoadBalancerAlgo{constant.ROUND_ROBIN, constant.WEIGHTED_ROUND_ROBIN, constant.LEAST_CONNECTIONS, constant.HASHING, constant.RANDOM} {
+		algorithm, _ := algo.ByName(name)
+		if member := algorithm(&types.UpstreamPool{}, "key"); member != nil {
+			t.Errorf("%s: expected no member from an empty pool, got %s", name, member.Address)
+		}
+	}
+
+	if _, ok := algo.ByName("fastest"); ok {
+		t.Error("Expected an unknown algorithm to be reported")
+	}
+}
+
+func TestHashingSpreadsKeys(t *testing.T) {
+	pool := newTestPool(1, 1, 1)
+	seen := make(map[*types.UpstreamMember]bool)
+	for i := 0; i < 100; i++ {
+		seen[algo.Hash(pool, fmt.Sprintf("client-%d", i))] = true
+	}
+	if len(seen) != len(pool.Members) {
+		t.Errorf("Expected keys to be spread over all %d members, got %d", len(pool.Members), len(seen))
+	}
+
+	// Removing a member only moves the keys of that member
+	before := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("client-%d", i)
+		before[key] = algo.Hash(pool, key).Address
+	}
+	removed := pool.Members[2].Address
+	pool.Members = pool.Members[:2]
+	for key, address := range before {
+		if address != removed && algo.Hash(pool, key).Address != address {
+			t.Errorf("Expected %s to stay on %s", key, address)
+		}
+	}
+}
+
+func TestValidateLoadBalancingAlgorithm(t *testing.T) {
+	testCases := []struct {
+		name      string
+		algorithm types.LoadBalancerAlgo
+		expectErr bool
+	}{
+		{"RoundRobin", constant.ROUND_ROBIN, false},
+		{"WeightedLeastConnections", constant.WEIGHTED_LEAST_CONNECTIONS, false},
+		{"ResourceBased", constant.RESOURCE_BASED, true},
+		{"Geographical", constant.GEOGRAPHICAL, true},
+		{"Unknown", "fastest", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := algo.Validate(tc.algorithm); (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+
+			// Upstream groups naming an algorithm that cannot run are rejected as well
+			groups := map[string]types.UpstreamGroupConfig{"api": {Algorithm: tc.algorithm, Members: []types.UpstreamMemberConfig{{URL: "http://api"}}}}
+			if _, err := upstream.NewGroups(groups); (err != nil) != tc.expectErr {
+				t.Errorf("Expected error for the upstream group: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
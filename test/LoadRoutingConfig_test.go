@@ -0,0 +1,66 @@
+package test
+
+import (
+	"jinx/pkg/util/types"
+	"jinx/server_setup/reverse_proxy_server_setup"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadRoutingConfig(t *testing.T) {
+	tempDir := t.TempDir()
+
+	testCases := []struct {
+		name     string
+		content  string
+		expected types.RoutingConfig
+		wantErr  bool
+	}{
+		{
+			name:    "LegacyRouteTable",
+			content: `{"/web":"http://web", "/api":"http://api"}`,
+			expected: types.RoutingConfig{Routes: []types.Route{
+				{Path: "/api", Match: "prefix", Upstream: "http://api"},
+				{Path: "/web", Match: "prefix", Upstream: "http://web"},
+			}},
+		},
+		{
+			name:    "Routes",
+			content: `{"Routes":[{"Host":"*.example.com","Path":"^/u/(\\d+)$","Match":"regex","Upstream":"http://users/$1"}]}`,
+			expected: types.RoutingConfig{Routes: []types.Route{
+				{Host: "*.example.com", Path: `^/u/(\d+)$`, Match: "regex", Upstream: "http://users/$1"},
+			}},
+		},
+		{
+			name:    "UpstreamGroups",
+			content: `{"Routes":[{"Path":"/api","UpstreamGroup":"api"}],"UpstreamGroups":{"api":{"Algorithm":"least_connections","Members":[{"URL":"http://a","Weight":2}]}}}`,
+			expected: types.RoutingConfig{
+				Routes: []types.Route{{Path: "/api", UpstreamGroup: "api"}},
+				UpstreamGroups: map[string]types.UpstreamGroupConfig{
+					"api": {Algorithm: "least_connections", Members: []types.UpstreamMemberConfig{{URL: "http://a", Weight: 2}}},
+				},
+			},
+		},
+		{name: "InvalidJSON", content: `{"/api":`, wantErr: true},
+		{name: "InvalidRoutes", content: `{"Routes":[{"Path":1}]}`, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(tempDir, tc.name+".json")
+			if err := os.WriteFile(file, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			routingConfig, err := reverse_proxy_server_setup.LoadRoutingConfig(file)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(routingConfig, tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, routingConfig)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"crypto/tls"
+	"jinx/internal/jinx_tls"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestNewServerCertificateStore(t *testing.T) {
+	tempDir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, tempDir, "static", "static.example.com")
+
+	acmeConfig := types.ACMEConfig{Hosts: []string{"managed.example.com"}, DirectoryURL: "https://localhost:14000/dir"}
+
+	if _, err := jinx_tls.NewServerCertificateStore("", "", nil, types.ACMEConfig{}); err == nil {
+		t.Error("expected an error when neither certificate files nor ACME hosts are configured")
+	}
+
+	store, err := jinx_tls.NewServerCertificateStore(certFile, keyFile, nil, acmeConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Contains(store.TLSConfig().NextProtos, "acme-tls/1") {
+		t.Error("expected the TLS config to advertise acme-tls/1 for TLS-ALPN-01 challenges")
+	}
+
+	certificate, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: "static.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certificate.Leaf.Subject.CommonName != "static" {
+		t.Errorf("expected hosts not managed through ACME to use the certificate files")
+	}
+
+	// Requests other than HTTP-01 challenges are redirected to HTTPS by the challenge handler
+	w := httptest.NewRecorder()
+	store.HTTPChallengeHandler(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://managed.example.com/page", nil))
+	if w.Code != http.StatusFound {
+		t.Errorf("expected status %d but got %d", http.StatusFound, w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "https://managed.example.com/page" {
+		t.Errorf("expected redirect to https://managed.example.com/page but got %s", location)
+	}
+}
@@ -0,0 +1,44 @@
+package test
+
+import (
+	"jinx/pkg/util/helper"
+	"net"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	testCases := []struct {
+		name      string
+		proxies   []string
+		trusted   string
+		expected  bool
+		expectErr bool
+	}{
+		{"Address", []string{"10.0.0.1"}, "10.0.0.1", true, false},
+		{"OtherAddress", []string{"10.0.0.1"}, "10.0.0.2", false, false},
+		{"Range", []string{"10.0.0.0/8"}, "10.20.30.40", true, false},
+		{"IPv6Range", []string{"fd00::/8"}, "fd12::1", true, false},
+		{"InvalidAddress", []string{"proxy.local"}, "", false, true},
+		{"InvalidRange", []string{"10.0.0.0/33"}, "", false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ranges, err := helper.ParseTrustedProxies(tc.proxies)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			trusted := false
+			for _, ipRange := range ranges {
+				trusted = trusted || ipRange.Contains(net.ParseIP(tc.trusted))
+			}
+			if trusted != tc.expected {
+				t.Errorf("Expected %s trusted: %v, got: %v", tc.trusted, tc.expected, trusted)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"io"
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPathRewrite(t *testing.T) {
+	// The backend echoes the request URI, redirects below the requested path and sets a cookie for it
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		escapedPath := r.URL.EscapedPath()
+		w.Header().Set("Location", "http://"+r.Host+strings.TrimSuffix(escapedPath, "/")+"/next")
+		w.Header().Set("Set-Cookie", "session=1; Path="+escapedPath+"; HttpOnly")
+		_, _ = io.WriteString(w, r.RequestURI)
+	}))
+	defer backend.Close()
+
+	testCases := []struct {
+		name             string
+		route            types.Route
+		requestURI       string
+		expectedUpstream string
+		expectedLocation string
+		expectedCookie   string
+	}{
+		{
+			name:             "NoRewrite",
+			route:            types.Route{Path: "/billing", Upstream: backend.URL},
+			requestURI:       "/billing/invoices?page=2",
+			expectedUpstream: "/billing/invoices?page=2",
+			expectedLocation: "/billing/invoices/next",
+			expectedCookie:   "session=1; Path=/billing/invoices; HttpOnly",
+		},
+		{
+			name:             "StripPrefix",
+			route:            types.Route{Path: "/billing", Upstream: backend.URL, Rewrite: types.PathRewrite{StripPrefix: true}},
+			requestURI:       "/billing/invoices?page=2",
+			expectedUpstream: "/invoices?page=2",
+			expectedLocation: "/billing/invoices/next",
+			expectedCookie:   "session=1; Path=/billing/invoices; HttpOnly",
+		},
+		{
+			name:             "StripWholePath",
+			route:            types.Route{Path: "/billing/", Upstream: backend.URL, Rewrite: types.PathRewrite{StripPrefix: true}},
+			requestURI:       "/billing",
+			expectedUpstream: "/",
+			expectedLocation: "/billing/next",
+			expectedCookie:   "session=1; Path=/billing; HttpOnly",
+		},
+		{
+			name:             "ReplacePrefix",
+			route:            types.Route{Path: "/billing", Upstream: backend.URL + "/app", Rewrite: types.PathRewrite{ReplacePrefix: "/api/v2"}},
+			requestURI:       "/billing/invoices",
+			expectedUpstream: "/app/api/v2/invoices",
+			expectedLocation: "/billing/invoices/next",
+			expectedCookie:   "session=1; Path=/billing/invoices; HttpOnly",
+		},
+		{
+			name:             "Regex",
+			route:            types.Route{Path: "/users", Upstream: backend.URL, Rewrite: types.PathRewrite{Regex: `^/users/(\d+)$`, Replacement: "/profile?id=$1"}},
+			requestURI:       "/users/42?full=1",
+			expectedUpstream: "/profile?id=42&full=1",
+			expectedLocation: "/profile/next",
+			expectedCookie:   "session=1; Path=/profile; HttpOnly",
+		},
+		{
+			name:             "EncodedPath",
+			route:            types.Route{Path: "/files", Upstream: backend.URL, Rewrite: types.PathRewrite{StripPrefix: true}},
+			requestURI:       "/files/reports%2F2024.pdf",
+			expectedUpstream: "/reports%2F2024.pdf",
+			expectedLocation: "/files/reports%2F2024.pdf/next",
+			expectedCookie:   "session=1; Path=/files/reports%2F2024.pdf; HttpOnly",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := types.JinxReverseProxyServerConfig{
+				LogRoot: t.TempDir(),
+				Routes:  []types.Route{tc.route},
+			}
+			jx := reverse_proxy.NewJinxReverseProxyServer(config, t.TempDir())
+
+			w := httptest.NewRecorder()
+			jx.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://proxy.example.com"+tc.requestURI, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got: %d", w.Code)
+			}
+			if upstream := w.Body.String(); upstream != tc.expectedUpstream {
+				t.Errorf("Expected the upstream to receive %s, got: %s", tc.expectedUpstream, upstream)
+			}
+			if location := w.Header().Get("Location"); location != tc.expectedLocation {
+				t.Errorf("Expected Location %s, got: %s", tc.expectedLocation, location)
+			}
+			if cookie := w.Header().Get("Set-Cookie"); cookie != tc.expectedCookie {
+				t.Errorf("Expected Set-Cookie %q, got: %q", tc.expectedCookie, cookie)
+			}
+		})
+	}
+}
+
+func TestPathRewriteValidation(t *testing.T) {
+	testCases := []struct {
+		name      string
+		route     types.Route
+		expectErr bool
+	}{
+		{"StripPrefix", types.Route{Path: "/api", Rewrite: types.PathRewrite{StripPrefix: true}}, false},
+		{"Regex", types.Route{Path: "/api", Rewrite: types.PathRewrite{Regex: "^/api/(.*)$", Replacement: "/$1"}}, false},
+		{"StripAndReplace", types.Route{Path: "/api", Rewrite: types.PathRewrite{StripPrefix: true, ReplacePrefix: "/v2"}}, true},
+		{"RelativeReplacePrefix", types.Route{Path: "/api", Rewrite: types.PathRewrite{ReplacePrefix: "v2"}}, true},
+		{"InvalidRegex", types.Route{Path: "/api", Rewrite: types.PathRewrite{Regex: "("}}, true},
+		{"ReplacementWithoutRegex", types.Route{Path: "/api", Rewrite: types.PathRewrite{Replacement: "/v2"}}, true},
+		{"RegexRoute", types.Route{Path: "^/api/(.*)$", Match: "regex", Rewrite: types.PathRewrite{StripPrefix: true}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			route := tc.route
+			route.Upstream = "http://a"
+			_, err := reverse_proxy.NewRouter([]types.Route{route})
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package test
+
+import (
+	"io"
+	"jinx/internal/rate_limit"
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type rateLimitedRequest struct {
+	remoteAddr string
+	path       string
+	headers    map[string]string
+	route      string
+	allowed    bool
+}
+
+func TestRateLimit(t *testing.T) {
+	perMinute := func(key string, requests int, paths ...string) types.RateLimitRule {
+		return types.RateLimitRule{Key: key, Requests: requests, Period: 60, Paths: paths}
+	}
+
+	testCases := []struct {
+		name     string
+		config   types.RateLimitConfig
+		requests []rateLimitedRequest
+	}{
+		{
+			name:   "ClientIP",
+			config: types.RateLimitConfig{Rules: []types.RateLimitRule{perMinute("", 2)}},
+			requests: []rateLimitedRequest{
+				{remoteAddr: "192.0.2.1:1000", allowed: true},
+				{remoteAddr: "192.0.2.1:1001", allowed: true},
+				{remoteAddr: "192.0.2.1:1002", allowed: false},
+				{remoteAddr: "192.0.2.2:1000", allowed: true},
+			},
+		},
+		{
+			name:   "Burst",
+			config: types.RateLimitConfig{Rules: []types.RateLimitRule{{Requests: 1, Period: 60, Burst: 3}}},
+			requests: []rateLimitedRequest{
+				{remoteAddr: "192.0.2.1:1000", allowed: true},
+				{remoteAddr: "192.0.2.1:1000", allowed: true},
+				{remoteAddr: "192.0.2.1:1000", allowed: true},
+				{remoteAddr: "192.0.2.1:1000", allowed: false},
+			},
+		},
+		{
+			name:   "Header",
+			config: types.RateLimitConfig{Rules: []types.RateLimitRule{perMinute("header:X-Api-Key", 1)}},
+			requests: []rateLimitedRequest{
+				{remoteAddr: "192.0.2.1:1000", headers: map[string]string{"X-Api-Key": "a"}, allowed: true},
+				{remoteAddr: "192.0.2.2:1000", headers: map[string]string{"X-Api-Key": "a"}, allowed: false},
+				{remoteAddr: "192.0.2.1:1000", headers: map[string]string{"X-Api-Key": "b"}, allowed: true},
+				{remoteAddr: "192.0.2.1:1000", allowed: true},
+				{remoteAddr: "192.0.2.1:1000", allowed: false},
+			},
+		},
+		{
+			name:   "Route",
+			config: types.RateLimitConfig{Rules: []types.RateLimitRule{perMinute("route", 1)}},
+			requests: []rateLimitedRequest{
+				{remoteAddr: "192.0.2.1:1000", route: "/api", allowed: true},
+				{remoteAddr: "192.0.2.2:1000", route: "/api", allowed: false},
+				{remoteAddr: "192.0.2.2:1000", route: "/static", allowed: true},
+			},
+		},
+		{
+			name:   "Paths",
+			config: types.RateLimitConfig{Rules: []types.RateLimitRule{perMinute("", 1, "/login")}},
+			requests: []rateLimitedRequest{
+				{remoteAddr: "192.0.2.1:1000", path: "/login", allowed: true},
+				{remoteAddr: "192.0.2.1:1000", path: "/login/reset", allowed: false},
+				{remoteAddr: "192.0.2.1:1000", path: "/index.html", allowed: true},
+				{remoteAddr: "192.0.2.1:1000", path: "/index.html", allowed: true},
+				{remoteAddr: "192.0.2.1:1000", path: "/loginfo", allowed: true},
+				{remoteAddr: "192.0.2.1:1000", path: "/loginfo", allowed: true},
+			},
+		},
+		{
+			name:   "PathsWithTrailingSlash",
+			config: types.RateLimitConfig{Rules: []types.RateLimitRule{perMinute("", 1, "/api/")}},
+			requests: []rateLimitedRequest{
+				{remoteAddr: "192.0.2.1:1000", path: "/api", allowed: true},
+				{remoteAddr: "192.0.2.1:1000", path: "/api/users", allowed: false},
+				{remoteAddr: "192.0.2.1:1000", path: "/apiv2", allowed: true},
+			},
+		},
+		{
+			name:   "TrustedProxy",
+			config: types.RateLimitConfig{Rules: []types.RateLimitRule{perMinute("", 1)}, TrustedProxies: []string{"10.0.0.0/8"}},
+			requests: []rateLimitedRequest{
+				{remoteAddr: "10.0.0.5:1000", headers: map[string]string{"X-Forwarded-For": "203.0.113.7"}, allowed: true},
+				{remoteAddr: "10.0.0.5:1000", headers: map[string]string{"X-Forwarded-For": "203.0.113.8"}, allowed: true},
+				{remoteAddr: "10.0.0.6:1000", headers: map[string]string{"X-Forwarded-For": "203.0.113.7"}, allowed: false},
+				{remoteAddr: "192.0.2.1:1000", headers: map[string]string{"X-Forwarded-For": "203.0.113.9"}, allowed: true},
+				{remoteAddr: "192.0.2.1:1000", headers: map[string]string{"X-Forwarded-For": "203.0.113.10"}, allowed: false},
+			},
+		},
+		{
+			name:   "DryRun",
+			config: types.RateLimitConfig{Rules: []types.RateLimitRule{perMinute("", 1)}, DryRun: true},
+			requests: []rateLimitedRequest{
+				{remoteAddr: "192.0.2.1:1000", allowed: true},
+				{remoteAddr: "192.0.2.1:1000", allowed: true},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trustedProxies, err := helper.ParseTrustedProxies(tc.config.TrustedProxies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			limiter, err := rate_limit.New(tc.config, trustedProxies, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, request := range tc.requests {
+				path := request.path
+				if path == "" {
+					path = "/"
+				}
+				r := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+				r.RemoteAddr = request.remoteAddr
+				for name, value := range request.headers {
+					r.Header.Set(name, value)
+				}
+				w := httptest.NewRecorder()
+
+				if allowed := limiter.Allow(w, r, request.route); allowed != request.allowed {
+					t.Fatalf("Request %d: expected allowed %v, got: %v", i, request.allowed, allowed)
+				}
+				if request.allowed {
+					continue
+				}
+				if w.Code != http.StatusTooManyRequests {
+					t.Errorf("Request %d: expected status 429, got: %d", i, w.Code)
+				}
+				if retryAfter := w.Header().Get("Retry-After"); retryAfter == "" || retryAfter == "0" {
+					t.Errorf("Request %d: expected a Retry-After header, got: %q", i, retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitTunnels(t *testing.T) {
+	testCases := []struct {
+		name     string
+		dryRun   bool
+		expected []bool
+	}{
+		{"Enforced", false, []bool{true, true, false}},
+		{"DryRun", true, []bool{true, true, true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := types.RateLimitConfig{MaxTunnels: 2, DryRun: tc.dryRun}
+			limiter, err := rate_limit.New(config, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var closers []func()
+			for i, expected := range tc.expected {
+				r := httptest.NewRequest(http.MethodConnect, "http://example.com:443", nil)
+				r.RemoteAddr = "192.0.2.1:1000"
+				closeTunnel, ok := limiter.OpenTunnel(httptest.NewRecorder(), r)
+				if ok != expected {
+					t.Fatalf("Tunnel %d: expected allowed %v, got: %v", i, expected, ok)
+				}
+				if ok {
+					closers = append(closers, closeTunnel)
+				}
+			}
+
+			// Closed tunnels free their slot, closing twice has no effect
+			closers[0]()
+			closers[0]()
+			r := httptest.NewRequest(http.MethodConnect, "http://example.com:443", nil)
+			r.RemoteAddr = "192.0.2.1:1000"
+			if _, ok := limiter.OpenTunnel(httptest.NewRecorder(), r); !ok {
+				t.Errorf("Expected a tunnel to be allowed after another one was closed")
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"io"
+	"jinx/internal/listener"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "served")
+	})
+
+	testCases := []struct {
+		name             string
+		method           string
+		target           string
+		httpsPort        int
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"DefaultPort", http.MethodGet, "http://example.com/docs/index.html?page=2", 443, http.StatusMovedPermanently, "https://example.com/docs/index.html?page=2"},
+		{"HostWithPort", http.MethodGet, "http://example.com:80/", 443, http.StatusMovedPermanently, "https://example.com/"},
+		{"CustomPort", http.MethodHead, "http://example.com:8080/", 8443, http.StatusMovedPermanently, "https://example.com:8443/"},
+		{"IPv6Host", http.MethodGet, "http://[::1]:8080/", 8443, http.StatusMovedPermanently, "https://[::1]:8443/"},
+		{"PostKeepsMethod", http.MethodPost, "http://example.com/form", 443, http.StatusPermanentRedirect, "https://example.com/form"},
+		{"ExemptPath", http.MethodGet, "http://example.com/health", 443, http.StatusOK, ""},
+		{"ExemptPrefix", http.MethodGet, "http://example.com/health/live", 443, http.StatusOK, ""},
+		{"SimilarPrefixRedirected", http.MethodGet, "http://example.com/healthy", 443, http.StatusMovedPermanently, "https://example.com/healthy"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := listener.RedirectToHTTPS(tc.httpsPort, []string{"/health/"}, next)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, nil))
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if location := w.Header().Get("Location"); location != tc.expectedLocation {
+				t.Errorf("Expected location %q, got %q", tc.expectedLocation, location)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"jinx/internal/jinx_tls"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReloadCertificates(t *testing.T) {
+	testCases := []struct {
+		name           string
+		replace        func(t *testing.T, dir string)
+		changed        bool
+		expectReloaded bool
+		expectErr      bool
+		expectNewCert  bool
+	}{
+		{
+			name:           "Unchanged files",
+			replace:        func(t *testing.T, dir string) {},
+			expectReloaded: false,
+			expectErr:      false,
+			expectNewCert:  false,
+		},
+		{
+			name: "Renewed certificate",
+			replace: func(t *testing.T, dir string) {
+				writeTestCertificate(t, dir, "example.com", "example.com")
+			},
+			changed:        true,
+			expectReloaded: true,
+			expectErr:      false,
+			expectNewCert:  true,
+		},
+		{
+			name: "Key does not match certificate",
+			replace: func(t *testing.T, dir string) {
+				writeTestCertificate(t, dir, "example.com", "example.com")
+				_, otherKey := writeTestCertificate(t, dir, "other.com", "other.com")
+				content, err := os.ReadFile(otherKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(dir+"/example.com.key", content, 0600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			changed:        true,
+			expectReloaded: false,
+			expectErr:      true,
+			expectNewCert:  false,
+		},
+		{
+			name: "Truncated certificate",
+			replace: func(t *testing.T, dir string) {
+				if err := os.WriteFile(dir+"/example.com.crt", []byte("-----BEGIN CERTIFICATE-----\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			changed:        true,
+			expectReloaded: false,
+			expectErr:      true,
+			expectNewCert:  false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			certFile, keyFile := writeTestCertificate(t, dir, "example.com", "example.com")
+
+			store, err := jinx_tls.LoadCertificateStore(certFile, keyFile, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			original := store.Lookup("example.com")
+
+			tc.replace(t, dir)
+
+			// Make sure the replaced files are seen as changed even on file systems with coarse timestamps
+			if tc.changed {
+				later := time.Now().Add(time.Minute)
+				_ = os.Chtimes(certFile, later, later)
+				_ = os.Chtimes(keyFile, later, later)
+			}
+
+			reloaded, reloadErr := store.ReloadIfChanged()
+
+			if reloaded != tc.expectReloaded {
+				t.Errorf("Expected reloaded to be %v, got %v", tc.expectReloaded, reloaded)
+			}
+			if (reloadErr != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, reloadErr)
+			}
+
+			current := store.Lookup("example.com")
+			if current == nil {
+				t.Fatal("Expected a certificate for example.com after reload")
+			}
+			if (current != original) != tc.expectNewCert {
+				t.Errorf("Expected certificate to be replaced: %v", tc.expectNewCert)
+			}
+			if store.Lookup("") != current {
+				t.Errorf("Expected the default certificate to follow the reloaded certificate")
+			}
+
+			// A failed reload is not retried until the files change again
+			if tc.expectErr {
+				if _, err := store.ReloadIfChanged(); err != nil {
+					t.Errorf("Expected the failure to be reported once, got %v", err)
+				}
+			}
+		})
+	}
+}
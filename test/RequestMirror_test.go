@@ -0,0 +1,118 @@
+package test
+
+import (
+	"io"
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// shadowBackend records the requests it receives and may answer slowly.
+type shadowBackend struct {
+	delay    time.Duration
+	mutex    sync.Mutex
+	requests []string // method, path and body of every request
+}
+
+func (b *shadowBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	b.mutex.Lock()
+	b.requests = append(b.requests, r.Method+" "+r.URL.Path+" "+string(body))
+	b.mutex.Unlock()
+
+	time.Sleep(b.delay)
+	http.Error(w, "shadow failure", http.StatusInternalServerError)
+}
+
+func (b *shadowBackend) received() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]string(nil), b.requests...)
+}
+
+func TestRequestMirror(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, "primary "+string(body))
+	}))
+	defer primary.Close()
+
+	testCases := []struct {
+		name     string
+		mirror   types.MirrorConfig
+		method   string
+		body     string
+		expected []string
+	}{
+		{"Get", types.MirrorConfig{}, http.MethodGet, "", []string{"GET /v2/items "}},
+		{"Body", types.MirrorConfig{}, http.MethodPost, "payload", []string{"POST /v2/items payload"}},
+		{"BodyTooLarge", types.MirrorConfig{MaxBodySize: 4}, http.MethodPost, "payload", nil},
+		{"FullPercentage", types.MirrorConfig{Percentage: 100}, http.MethodGet, "", []string{"GET /v2/items "}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			shadow := &shadowBackend{delay: 1500 * time.Millisecond}
+			shadowServer := httptest.NewServer(shadow)
+			defer shadowServer.Close()
+
+			tc.mirror.Upstreams = []string{shadowServer.URL + "/v2"}
+			jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+				LogRoot: t.TempDir(),
+				Routes:  []types.Route{{Path: "/", Upstream: primary.URL, Mirror: tc.mirror}},
+			}, t.TempDir())
+
+			start := time.Now()
+			w := httptest.NewRecorder()
+			jx.ServeHTTP(w, httptest.NewRequest(tc.method, "http://proxy.example.com/items", strings.NewReader(tc.body)))
+
+			// The shadow answers slowly and with an error, neither reaches the client
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Expected the response not to wait for the mirror, took %s", elapsed)
+			}
+			if w.Code != http.StatusOK || w.Body.String() != "primary "+tc.body {
+				t.Errorf("Expected the response of the primary upstream, got: %d %q", w.Code, w.Body.String())
+			}
+
+			deadline := time.Now().Add(time.Second)
+			for len(shadow.received()) < len(tc.expected) && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if len(tc.expected) == 0 {
+				time.Sleep(100 * time.Millisecond)
+			}
+			received := shadow.received()
+			if strings.Join(received, "\n") != strings.Join(tc.expected, "\n") {
+				t.Errorf("Expected the shadow to receive %q, got: %q", tc.expected, received)
+			}
+		})
+	}
+}
+
+func TestRequestMirrorValidation(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    types.MirrorConfig
+		expectErr bool
+	}{
+		{"Disabled", types.MirrorConfig{}, false},
+		{"Complete", types.MirrorConfig{Upstreams: []string{"http://shadow-a", "https://shadow-b/v2"}, Percentage: 10, MaxBodySize: 65536, Timeout: 2}, false},
+		{"InvalidUpstream", types.MirrorConfig{Upstreams: []string{"shadow:8080"}}, true},
+		{"PercentageTooHigh", types.MirrorConfig{Upstreams: []string{"http://shadow"}, Percentage: 101}, true},
+		{"NegativeBodySize", types.MirrorConfig{Upstreams: []string{"http://shadow"}, MaxBodySize: -1}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := reverse_proxy.NewRouter([]types.Route{{Path: "/", Upstream: "http://a", Mirror: tc.config}})
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package test
+
+import (
+	"io"
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cacheBackend counts the requests it receives per path.
+type cacheBackend struct {
+	mutex sync.Mutex
+	hits  map[string]int
+}
+
+func (b *cacheBackend) count(path string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.hits[path]
+}
+
+func (b *cacheBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mutex.Lock()
+	b.hits[r.URL.Path]++
+	hits := b.hits[r.URL.Path]
+	b.mutex.Unlock()
+
+	switch {
+	case r.URL.Path == "/fresh":
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, "fresh")
+	case r.URL.Path == "/nostore":
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = io.WriteString(w, "private")
+	case r.URL.Path == "/vary":
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = io.WriteString(w, r.Header.Get("Accept-Language"))
+	case r.URL.Path == "/etag" || r.URL.Path == "/swr":
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = io.WriteString(w, "tagged")
+	case r.URL.Path == "/error":
+		if hits > 1 {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=0, stale-if-error=60")
+		_, _ = io.WriteString(w, "last good")
+	case strings.HasPrefix(r.URL.Path, "/big/"):
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write(make([]byte, 400<<10))
+	case r.URL.Path == "/resource":
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, "resource")
+	}
+}
+
+type cacheStep struct {
+	method  string
+	path    string
+	headers map[string]string
+	status  int
+	cache   string
+	hits    int // requests the backend received for path after the step
+}
+
+func TestResponseCache(t *testing.T) {
+	testCases := []struct {
+		name  string
+		steps []cacheStep
+	}{
+		{
+			name: "FreshResponse",
+			steps: []cacheStep{
+				{path: "/fresh", status: 200, cache: constant.CACHE_MISS, hits: 1},
+				{path: "/fresh", status: 200, cache: constant.CACHE_HIT, hits: 1},
+				{path: "/fresh", headers: map[string]string{"Cache-Control": "no-cache"}, status: 200, cache: constant.CACHE_EXPIRED, hits: 2},
+			},
+		},
+		{
+			name: "NoStore",
+			steps: []cacheStep{
+				{path: "/nostore", status: 200, cache: constant.CACHE_MISS, hits: 1},
+				{path: "/nostore", status: 200, cache: constant.CACHE_MISS, hits: 2},
+			},
+		},
+		{
+			name: "Vary",
+			steps: []cacheStep{
+				{path: "/vary", headers: map[string]string{"Accept-Language": "en"}, status: 200, cache: constant.CACHE_MISS, hits: 1},
+				{path: "/vary", headers: map[string]string{"Accept-Language": "fr"}, status: 200, cache: constant.CACHE_MISS, hits: 2},
+				{path: "/vary", headers: map[string]string{"Accept-Language": "en"}, status: 200, cache: constant.CACHE_HIT, hits: 2},
+			},
+		},
+		{
+			name: "Revalidation",
+			steps: []cacheStep{
+				{path: "/etag", status: 200, cache: constant.CACHE_MISS, hits: 1},
+				{path: "/etag", headers: map[string]string{"Cache-Control": "no-cache"}, status: 200, cache: constant.CACHE_REVALIDATED, hits: 2},
+				{path: "/etag", headers: map[string]string{"Cache-Control": "no-cache", "If-None-Match": `"v1"`}, status: 304, cache: constant.CACHE_REVALIDATED, hits: 3},
+			},
+		},
+		{
+			name: "StaleWhileRevalidate",
+			steps: []cacheStep{
+				{path: "/swr", status: 200, cache: constant.CACHE_MISS, hits: 1},
+				{path: "/swr", status: 200, cache: constant.CACHE_UPDATING, hits: 2},
+			},
+		},
+		{
+			name: "StaleIfError",
+			steps: []cacheStep{
+				{path: "/error", status: 200, cache: constant.CACHE_MISS, hits: 1},
+				{path: "/error", status: 200, cache: constant.CACHE_STALE, hits: 2},
+			},
+		},
+		{
+			name: "Bypass",
+			steps: []cacheStep{
+				{path: "/fresh", headers: map[string]string{"Cookie": "session=1"}, status: 200, cache: constant.CACHE_BYPASS, hits: 1},
+				{path: "/fresh", status: 200, cache: constant.CACHE_MISS, hits: 2},
+				{path: "/fresh", headers: map[string]string{"Cookie": "session=1"}, status: 200, cache: constant.CACHE_BYPASS, hits: 3},
+				{path: "/fresh", status: 200, cache: constant.CACHE_HIT, hits: 3},
+			},
+		},
+		{
+			name: "Invalidation",
+			steps: []cacheStep{
+				{path: "/resource", status: 200, cache: constant.CACHE_MISS, hits: 1},
+				{path: "/resource", status: 200, cache: constant.CACHE_HIT, hits: 1},
+				{method: http.MethodPost, path: "/resource", status: 204, cache: constant.CACHE_BYPASS, hits: 2},
+				{path: "/resource", status: 200, cache: constant.CACHE_MISS, hits: 3},
+			},
+		},
+		{
+			name: "Eviction",
+			steps: []cacheStep{
+				{path: "/big/1", status: 200, cache: constant.CACHE_MISS, hits: 1},
+				{path: "/big/2", status: 200, cache: constant.CACHE_MISS, hits: 1},
+				{path: "/big/3", status: 200, cache: constant.CACHE_MISS, hits: 1},
+				{path: "/big/3", status: 200, cache: constant.CACHE_HIT, hits: 1},
+				{path: "/big/1", status: 200, cache: constant.CACHE_MISS, hits: 2},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := &cacheBackend{hits: make(map[string]int)}
+			upstream := httptest.NewServer(backend)
+			defer upstream.Close()
+
+			jx := newCachingProxy(t, upstream.URL, t.TempDir())
+
+			for i, step := range tc.steps {
+				method := step.method
+				if method == "" {
+					method = http.MethodGet
+				}
+				r := httptest.NewRequest(method, "http://proxy.example.com"+step.path, nil)
+				for name, value := range step.headers {
+					r.Header.Set(name, value)
+				}
+				w := httptest.NewRecorder()
+				jx.ServeHTTP(w, r)
+
+				if w.Code != step.status {
+					t.Errorf("Step %d: expected status %d, got: %d", i, step.status, w.Code)
+				}
+				if cache := w.Header().Get(constant.CACHE_STATUS_HEADER); cache != step.cache {
+					t.Errorf("Step %d: expected cache status %s, got: %s", i, step.cache, cache)
+				}
+				if w.Header().Get(constant.REQUEST_ID_HEADER) == "" {
+					t.Errorf("Step %d: expected a request ID", i)
+				}
+
+				// Background revalidations reach the backend after the response
+				deadline := time.Now().Add(time.Second)
+				for backend.count(step.path) < step.hits && time.Now().Before(deadline) {
+					time.Sleep(10 * time.Millisecond)
+				}
+				if hits := backend.count(step.path); hits != step.hits {
+					t.Errorf("Step %d: expected %d backend requests, got: %d", i, step.hits, hits)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseCachePersistence(t *testing.T) {
+	backend := &cacheBackend{hits: make(map[string]int)}
+	upstream := httptest.NewServer(backend)
+	defer upstream.Close()
+
+	cacheDir := t.TempDir()
+	for i, expected := range []string{constant.CACHE_MISS, constant.CACHE_HIT} {
+		jx := newCachingProxy(t, upstream.URL, cacheDir)
+
+		w := httptest.NewRecorder()
+		jx.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://proxy.example.com/fresh", nil))
+
+		if cache := w.Header().Get(constant.CACHE_STATUS_HEADER); cache != expected {
+			t.Errorf("Run %d: expected cache status %s, got: %s", i, expected, cache)
+		}
+		if w.Body.String() != "fresh" {
+			t.Errorf("Run %d: expected the stored body, got: %q", i, w.Body.String())
+		}
+	}
+	if hits := backend.count("/fresh"); hits != 1 {
+		t.Errorf("Expected 1 backend request, got: %d", hits)
+	}
+}
+
+func TestRouteCacheValidation(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    types.RouteCacheConfig
+		expectErr bool
+	}{
+		{"Defaults", types.RouteCacheConfig{}, false},
+		{"Valid", types.RouteCacheConfig{Key: "$host$path", Bypass: []string{"header:Authorization", "query:nocache"}}, false},
+		{"UnknownVariable", types.RouteCacheConfig{Key: "$user$path"}, true},
+		{"InvalidBypass", types.RouteCacheConfig{Bypass: []string{"body:token"}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := reverse_proxy.NewRouter([]types.Route{{Path: "/", Upstream: "http://a", Cache: tc.config}})
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func newCachingProxy(t *testing.T, upstreamURL string, cacheDir string) *reverse_proxy.JinxReverseProxyServer {
+	t.Helper()
+
+	config := types.JinxReverseProxyServerConfig{
+		LogRoot: t.TempDir(),
+		Routes: []types.Route{{
+			Path:     "/",
+			Upstream: upstreamURL,
+			Cache:    types.RouteCacheConfig{Bypass: []string{"cookie:session"}},
+		}},
+		Cache: types.CacheConfig{Dir: cacheDir, MaxSize: 1},
+	}
+	jx := reverse_proxy.NewJinxReverseProxyServer(config, t.TempDir())
+	if jx == nil {
+		t.Fatalf("Expected a server for %s", upstreamURL)
+	}
+	return jx
+}
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"jinx/internal/reverse_proxy"
+	"jinx/internal/upstream"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// startReverseProxy starts jx in the background and waits until url answers through client. The server is stopped
+// when the test ends.
+func startReverseProxy(t *testing.T, jx *reverse_proxy.JinxReverseProxyServer, url string, client *http.Client) {
+	t.Helper()
+
+	go jx.Start()
+	t.Cleanup(jx.Stop)
+	waitForResponse(t, url, client)
+}
+
+// waitForResponse waits up to five seconds for a response to a GET request for url through client.
+func waitForResponse(t *testing.T, url string, client *http.Client) *http.Response {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		res, err := client.Get(url)
+		if err == nil {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+			return res
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s to answer, got: %v", url, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// newH2CClient returns a client speaking HTTP/2 with prior knowledge over cleartext connections, which only servers
+// speaking h2c answer.
+func newH2CClient() *http.Client {
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+	}
+}
+
+func TestRestartH2C(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "backend")
+	}))
+	defer backend.Close()
+
+	port := freePort(t)
+	jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+		IP:      "127.0.0.1",
+		Port:    port,
+		LogRoot: t.TempDir(),
+		Routes:  []types.Route{{Path: "/", Upstream: backend.URL}},
+		H2C:     true,
+	}, t.TempDir())
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/", port)
+
+	startReverseProxy(t, jx, url, newH2CClient())
+	if jx.Restart() == nil {
+		t.Fatal("Expected the running server to restart")
+	}
+
+	// Connections taken over by the h2c handler outlive the restart, a new client reaches the restarted server
+	res := waitForResponse(t, url, newH2CClient())
+	if res.StatusCode != http.StatusOK || res.ProtoMajor != 2 {
+		t.Errorf("Expected 200 over HTTP/2 after the restart, got: %d %s", res.StatusCode, res.Proto)
+	}
+}
+
+func TestRestartAdmin(t *testing.T) {
+	port, adminPort := freePort(t), freePort(t)
+	jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+		IP:      "127.0.0.1",
+		Port:    port,
+		LogRoot: t.TempDir(),
+		Routes:  []types.Route{{Path: "/", UpstreamGroup: "api"}},
+		UpstreamGroups: map[string]types.UpstreamGroupConfig{"api": {
+			Members:        []types.UpstreamMemberConfig{{URL: "http://" + closedUpstream(t)}},
+			CircuitBreaker: types.CircuitBreakerConfig{Failures: 1},
+			Retry:          types.RetryConfig{Attempts: 1},
+		}},
+		Admin: types.AdminConfig{Port: adminPort},
+	}, t.TempDir())
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	url := fmt.Sprintf("http://127.0.0.1:%d/", port)
+	adminURL := fmt.Sprintf("http://127.0.0.1:%d%s", adminPort, constant.ADMIN_UPSTREAMS_PATH)
+
+	// The first request fails and opens the breaker of the only member
+	startReverseProxy(t, jx, url, client)
+	if jx.Restart() == nil {
+		t.Fatal("Expected the running server to restart")
+	}
+
+	res := waitForResponse(t, adminURL, client)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the admin listener to answer after the restart, got: %d", res.StatusCode)
+	}
+
+	res, err := client.Get(adminURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	var groups []upstream.GroupStatus
+	if err := json.NewDecoder(res.Body).Decode(&groups); err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0].Members) != 1 || groups[0].Members[0].Breaker != "open" {
+		t.Errorf("Expected the open breaker of the member to survive the restart, got: %+v", groups)
+	}
+}
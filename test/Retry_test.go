@@ -0,0 +1,226 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"jinx/internal/load_balancer"
+	"jinx/internal/reverse_proxy"
+	"jinx/internal/upstream"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReverseProxyRetries(t *testing.T) {
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(1500 * time.Millisecond)
+		_, _ = io.WriteString(w, "slow")
+	}))
+	defer slow.Close()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, "ok "+string(body))
+	}))
+	defer healthy.Close()
+
+	testCases := []struct {
+		name           string
+		first          string
+		method         string
+		body           string
+		retry          types.RetryConfig
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"RefusedGet", refused.URL, http.MethodGet, "", types.RetryConfig{}, http.StatusOK, "ok "},
+		{"RefusedPost", refused.URL, http.MethodPost, "payload", types.RetryConfig{}, http.StatusOK, "ok payload"},
+		{"UnavailableGet", unavailable.URL, http.MethodGet, "", types.RetryConfig{}, http.StatusOK, "ok "},
+		{"UnavailablePost", unavailable.URL, http.MethodPost, "payload", types.RetryConfig{}, http.StatusServiceUnavailable, ""},
+		{"StatusNotRetried", unavailable.URL, http.MethodGet, "", types.RetryConfig{Statuses: []int{502}}, http.StatusServiceUnavailable, ""},
+		{"RetriesDisabled", unavailable.URL, http.MethodGet, "", types.RetryConfig{Attempts: 1}, http.StatusServiceUnavailable, ""},
+		{"TryTimeout", slow.URL, http.MethodGet, "", types.RetryConfig{TryTimeout: 1}, http.StatusOK, "ok "},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := types.JinxReverseProxyServerConfig{
+				LogRoot: t.TempDir(),
+				Routes:  []types.Route{{Path: "/", UpstreamGroup: "api"}},
+				UpstreamGroups: map[string]types.UpstreamGroupConfig{
+					"api": {
+						Algorithm: constant.ROUND_ROBIN,
+						Members:   []types.UpstreamMemberConfig{{URL: tc.first}, {URL: healthy.URL}},
+						Retry:     tc.retry,
+					},
+				},
+			}
+			jx := reverse_proxy.NewJinxReverseProxyServer(config, t.TempDir())
+
+			w := httptest.NewRecorder()
+			jx.ServeHTTP(w, httptest.NewRequest(tc.method, "http://proxy.example.com/", strings.NewReader(tc.body)))
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got: %d", tc.expectedStatus, w.Code)
+			}
+			if tc.expectedBody != "" && w.Body.String() != tc.expectedBody {
+				t.Errorf("Expected body %q, got: %q", tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPickRetry(t *testing.T) {
+	group, err := upstream.NewGroup("api", types.UpstreamGroupConfig{
+		Algorithm: constant.HASHING,
+		Members:   []types.UpstreamMemberConfig{{URL: "http://a"}, {URL: "http://b"}, {URL: "http://c"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := group.Pick("10.0.0.1")
+	tried := []*types.UpstreamMember{first}
+	for len(tried) < 3 {
+		member, ok := group.PickRetry("10.0.0.1", tried)
+		if !ok {
+			t.Fatalf("Expected a member after trying %d of 3", len(tried))
+		}
+		for _, triedMember := range tried {
+			if member == triedMember {
+				t.Fatalf("Expected %s not to be picked again", member.Address)
+			}
+		}
+		tried = append(tried, member)
+	}
+
+	if _, ok := group.PickRetry("10.0.0.1", tried); ok {
+		t.Error("Expected no member once all members were tried")
+	}
+}
+
+func TestLoadBalancerRetriesDial(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+	go func() {
+		for {
+			conn, acceptErr := l.Accept()
+			if acceptErr != nil {
+				return
+			}
+			_, _ = io.WriteString(conn, "pong")
+			_ = conn.Close()
+		}
+	}()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	config := types.JinxLoadBalancingServerConfig{
+		LogRoot:    t.TempDir(),
+		Algorithm:  constant.ROUND_ROBIN,
+		ServerPool: []types.UpStreamServer{{IP: "127.0.0.1", Port: freePort(t)}, {IP: "127.0.0.1", Port: port}},
+		Retry:      types.RetryConfig{TryTimeout: 1},
+	}
+	jx := load_balancer.NewJinxLoadBalancingServer(config, t.TempDir())
+
+	client, server := net.Pipe()
+	go jx.ProxyTCP(server)
+	defer func() {
+		_ = client.Close()
+	}()
+
+	_ = client.SetReadDeadline(time.Now().Add(3 * time.Second))
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(client, reply); err != nil {
+		t.Fatalf("Expected the connection to be retried on the second server, got: %v", err)
+	}
+	if string(reply) != "pong" {
+		t.Errorf("Expected pong, got: %q", reply)
+	}
+}
+
+func TestLoadBalancerTrialClosesBreakerOnConnect(t *testing.T) {
+	// Nothing listens on the port of the only server yet, the first connection opens its breaker
+	port := freePort(t)
+	config := types.JinxLoadBalancingServerConfig{
+		LogRoot:        t.TempDir(),
+		Algorithm:      constant.ROUND_ROBIN,
+		ServerPool:     []types.UpStreamServer{{IP: "127.0.0.1", Port: port}},
+		CircuitBreaker: types.CircuitBreakerConfig{Failures: 1, Backoff: 1},
+		Retry:          types.RetryConfig{Attempts: 1},
+	}
+	jx := load_balancer.NewJinxLoadBalancingServer(config, t.TempDir())
+
+	// relay proxies a new client connection and returns it with the first bytes relayed back, if any
+	relay := func() (net.Conn, string) {
+		client, server := net.Pipe()
+		go jx.ProxyTCP(server)
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		_ = client.SetReadDeadline(time.Now().Add(time.Second))
+		reply := make([]byte, 4)
+		n, _ := io.ReadFull(client, reply)
+		return client, string(reply[:n])
+	}
+	if _, reply := relay(); reply != "" {
+		t.Fatalf("Expected the first connection to fail, got: %q", reply)
+	}
+
+	// The server now answers and keeps its sessions open
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := make(chan net.Conn, 16)
+	defer func() {
+		_ = l.Close()
+		for len(sessions) > 0 {
+			_ = (<-sessions).Close()
+		}
+	}()
+	go func() {
+		for {
+			conn, acceptErr := l.Accept()
+			if acceptErr != nil {
+				return
+			}
+			_, _ = io.WriteString(conn, "pong")
+			sessions <- conn
+		}
+	}()
+
+	// The trial session starts once the backoff is over and stays open
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if _, reply := relay(); reply == "pong" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a trial connection after the backoff")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if _, reply := relay(); reply != "pong" {
+		t.Errorf("Expected the breaker to close once the trial connected, got: %q", reply)
+	}
+}
@@ -0,0 +1,149 @@
+package test
+
+import (
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/types"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	routes := []types.Route{
+		{Path: "/", Match: "prefix", Upstream: "http://default"},
+		{Path: "/api", Match: "prefix", Upstream: "http://api"},
+		{Path: "/api/v2/", Match: "prefix", Upstream: "http://api-v2"},
+		{Path: "/api/health", Match: "exact", Upstream: "http://health"},
+		{Path: `^/users/(\d+)$`, Match: "regex", Upstream: "http://users/profile?id=$1"},
+		{Path: `^/api/v2/legacy`, Match: "regex", Upstream: "http://legacy"},
+		{Host: "shop.example.com", Path: "/", Match: "prefix", Upstream: "http://shop"},
+		{Host: "*.example.com", Path: "/", Match: "prefix", Upstream: "http://tenants"},
+		{Host: "*.eu.example.com", Path: "/static", Match: "prefix", Upstream: "http://eu-static"},
+	}
+	router, err := reverse_proxy.NewRouter(routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name             string
+		host             string
+		path             string
+		expectedUpstream string
+		replacePath      bool
+	}{
+		{"LongestPrefix", "localhost", "/api/users/42", "http://api", false},
+		{"PrefixRoot", "localhost", "/api", "http://api", false},
+		{"PrefixMatchesSegments", "localhost", "/apiv2", "http://default", false},
+		{"LongerPrefixWins", "localhost", "/api/v2/orders", "http://api-v2", false},
+		{"ExactBeatsPrefix", "localhost", "/api/health", "http://health", false},
+		{"ExactIsExact", "localhost", "/api/health/deep", "http://api", false},
+		{"RegexBeatsPrefix", "localhost", "/api/v2/legacy/items", "http://legacy", false},
+		{"RegexCaptures", "localhost", "/users/42", "http://users/profile?id=42", true},
+		{"RegexNoMatch", "localhost", "/users/bob", "http://default", false},
+		{"URLSemantics", "localhost", "/static/../api//users", "http://api", false},
+		{"ExactHost", "shop.example.com:8443", "/cart", "http://shop", false},
+		{"WildcardHost", "tenant.example.com", "/cart", "http://tenants", false},
+		{"WildcardDeepSubdomain", "a.b.example.com", "/cart", "http://tenants", false},
+		{"WildcardNeedsSubdomain", "example.com", "/cart", "http://default", false},
+		{"LongerWildcardWins", "paris.eu.example.com", "/static/logo.png", "http://eu-static", false},
+		{"FallbackToShorterWildcard", "paris.eu.example.com", "/cart", "http://tenants", false},
+		{"HostIsCaseInsensitive", "SHOP.Example.COM", "/", "http://shop", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			match, ok := router.Match(tc.host, tc.path)
+			if !ok {
+				t.Fatalf("Expected a route for %s%s", tc.host, tc.path)
+			}
+			if match.Upstream != tc.expectedUpstream || match.ReplacePath != tc.replacePath {
+				t.Errorf("Expected %s (replace path: %v), got %s (replace path: %v)", tc.expectedUpstream, tc.replacePath, match.Upstream, match.ReplacePath)
+			}
+		})
+	}
+}
+
+func TestRouterNoMatch(t *testing.T) {
+	router, err := reverse_proxy.NewRouter([]types.Route{{Host: "example.com", Path: "/api", Upstream: "http://api"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := router.Match("example.com", "/other"); ok {
+		t.Error("Expected no route for a path outside of every prefix")
+	}
+	if _, ok := router.Match("other.com", "/api"); ok {
+		t.Error("Expected no route for another host")
+	}
+}
+
+func TestNewRouterInvalidRoutes(t *testing.T) {
+	testCases := []struct {
+		name  string
+		route types.Route
+	}{
+		{"UnknownMatch", types.Route{Path: "/", Match: "glob", Upstream: "http://a"}},
+		{"RelativePath", types.Route{Path: "api", Upstream: "http://a"}},
+		{"InvalidRegex", types.Route{Path: "^/(", Match: "regex", Upstream: "http://a"}},
+		{"NoUpstream", types.Route{Path: "/"}},
+		{"WildcardInTheMiddle", types.Route{Host: "api.*.com", Path: "/", Upstream: "http://a"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := reverse_proxy.NewRouter([]types.Route{tc.route}); err == nil {
+				t.Errorf("Expected %+v to be rejected", tc.route)
+			}
+		})
+	}
+
+	duplicate := []types.Route{{Path: "/api/", Upstream: "http://a"}, {Path: "/api", Upstream: "http://b"}}
+	if _, err := reverse_proxy.NewRouter(duplicate); err == nil {
+		t.Error("Expected duplicate prefix routes to be rejected")
+	}
+}
+
+func TestDetermineUpstreamURL(t *testing.T) {
+	config := types.JinxReverseProxyServerConfig{
+		LogRoot:    t.TempDir(),
+		RouteTable: types.RouteTable{"/api": "http://api:8080/base"},
+		Routes: []types.Route{
+			{Path: `^/files/(?P<name>[^/]+)$`, Match: "regex", Upstream: "http://files:8080/download/${name}?inline=1"},
+			{Path: `^/store/(.*)$`, Match: "regex", Upstream: "http://store:8080/objects/$1"},
+		},
+	}
+	jx := reverse_proxy.NewJinxReverseProxyServer(config, t.TempDir())
+
+	testCases := []struct {
+		name             string
+		url              string
+		expectedUpstream string
+		expectedPath     string // escaped
+		expectedQuery    string
+	}{
+		{"LegacyTableIsPrefix", "/api/users/42?page=2", "http://api:8080/base", "/api/users/42", "page=2"},
+		{"PathIsCleaned", "/api/../api/./users", "http://api:8080/base", "/api/users", ""},
+		{"CapturesReplacePath", "/files/report.pdf?page=2", "http://files:8080", "/download/report.pdf", "inline=1&page=2"},
+		{"CaptureKeepsQuery", "/store/a/b?user=bob", "http://store:8080", "/objects/a/b", "user=bob"},
+		{"EscapedQuestionMark", "/store/a%3Fadmin=1?user=bob", "http://store:8080", "/objects/a%3Fadmin=1", "user=bob"},
+		{"EscapedHash", "/store/a%23admin?user=bob", "http://store:8080", "/objects/a%23admin", "user=bob"},
+		{"EscapedSlash", "/store/a%2Fb?user=bob", "http://store:8080", "/objects/a%2Fb", "user=bob"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tc.url, nil)
+			upstream, err := jx.DetermineUpstreamURL(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if upstream != tc.expectedUpstream || r.URL.EscapedPath() != tc.expectedPath || r.URL.RawQuery != tc.expectedQuery {
+				t.Errorf("Expected %s %s?%s, got %s %s?%s", tc.expectedUpstream, tc.expectedPath, tc.expectedQuery, upstream, r.URL.EscapedPath(), r.URL.RawQuery)
+			}
+		})
+	}
+
+	if _, err := jx.DetermineUpstreamURL(httptest.NewRequest("GET", "/unknown", nil)); err == nil {
+		t.Error("Expected an error for a path without route")
+	}
+}
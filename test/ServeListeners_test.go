@@ -0,0 +1,123 @@
+package test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"jinx/internal/jinx_tls"
+	"jinx/internal/listener"
+	"jinx/pkg/util/types"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// freePort returns a port nothing listens on at the time of the call
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestServeListeners(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, t.TempDir(), "localhost", "localhost")
+	store, err := jinx_tls.LoadCertificateStore(certFile, keyFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plainPort, tlsPort, redirectPort := freePort(t), freePort(t), freePort(t)
+	configs := []types.ListenerConfig{
+		{Port: plainPort},
+		{Port: tlsPort, TLS: true, TLSPolicy: &types.TLSPolicy{MinVersion: "1.3"}},
+		{Port: redirectPort, RedirectToHTTPS: true},
+	}
+
+	content := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "content")
+	})
+	s := &http.Server{Handler: content}
+	redirectServer := &http.Server{Handler: listener.RedirectToHTTPS(tlsPort, nil, content)}
+	defer func() {
+		_ = s.Close()
+		_ = redirectServer.Close()
+	}()
+
+	errorLogger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	tlsConfig := func(config types.ListenerConfig) (*tls.Config, error) {
+		return store.NewTLSConfig(listener.TLSPolicy(config, types.TLSPolicy{}), listener.HTTPProtocols)
+	}
+	if err := listener.ServeListeners(s, redirectServer, configs, "127.0.0.1", types.ListenerLimits{}, tlsConfig, errorLogger); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{
+		Timeout:       5 * time.Second,
+		Transport:     &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	testCases := []struct {
+		name             string
+		url              string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"Plain", fmt.Sprintf("http://127.0.0.1:%d", plainPort), http.StatusOK, ""},
+		{"TLS", fmt.Sprintf("https://127.0.0.1:%d", tlsPort), http.StatusOK, ""},
+		{"Redirect", fmt.Sprintf("http://localhost:%d", redirectPort), http.StatusMovedPermanently, fmt.Sprintf("https://localhost:%d/", tlsPort)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := client.Get(tc.url + "/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = res.Body.Close()
+			}()
+
+			if res.StatusCode != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, res.StatusCode)
+			}
+			if location := res.Header.Get("Location"); location != tc.expectedLocation {
+				t.Errorf("Expected location %q, got %q", tc.expectedLocation, location)
+			}
+		})
+	}
+
+	// The TLS listener uses its own policy rather than the defaults
+	if _, err := connect(t, fmt.Sprintf("127.0.0.1:%d", tlsPort), &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12}); err == nil {
+		t.Error("Expected the TLS 1.3 only listener to reject a TLS 1.2 client")
+	}
+
+	// A listener that cannot be bound fails the whole call and releases the listeners already bound
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = busy.Close()
+	}()
+
+	freeListener := types.ListenerConfig{Port: freePort(t)}
+	busyListener := types.ListenerConfig{Port: busy.Addr().(*net.TCPAddr).Port}
+	if err := listener.ServeListeners(s, redirectServer, []types.ListenerConfig{freeListener, busyListener}, "127.0.0.1", types.ListenerLimits{}, tlsConfig, errorLogger); err == nil {
+		t.Fatal("Expected an error when a listener address is already in use")
+	}
+	if l, err := net.Listen("tcp", listener.Address(freeListener, "127.0.0.1")); err != nil {
+		t.Errorf("Expected %s to be released, got %v", listener.Address(freeListener, "127.0.0.1"), err)
+	} else {
+		_ = l.Close()
+	}
+}
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"io"
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// staticRoot writes a document root with an index page, an asset, a page in a directory and a 404 page.
+func staticRoot(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"index.html":       "<p>app</p>",
+		"app.js":           "console.log('app')",
+		"guide/index.html": "<p>guide</p>",
+		"404.html":         "<p>missing</p>",
+	}
+	for name, content := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestStaticLocation(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "api "+r.Method+" "+r.URL.Path)
+	}))
+	defer api.Close()
+	root := staticRoot(t)
+
+	spa := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+		LogRoot: t.TempDir(),
+		Routes: []types.Route{
+			{Path: "/", Static: types.StaticLocation{Root: root, Fallback: "index.html"}, ResponseHeaders: types.HeaderRules{Set: map[string]string{"Cache-Control": "no-cache"}}},
+			{Path: "/api", Upstream: api.URL},
+		},
+	}, t.TempDir())
+	site := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+		LogRoot: t.TempDir(),
+		Routes: []types.Route{
+			{Path: "/", Static: types.StaticLocation{Root: root}},
+			{Path: "/docs", Static: types.StaticLocation{Root: root, StripPrefix: true}},
+		},
+	}, t.TempDir())
+
+	testCases := []struct {
+		name           string
+		jx             *reverse_proxy.JinxReverseProxyServer
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+		cacheControl   string // not checked when empty
+	}{
+		{"Index", spa, http.MethodGet, "/", http.StatusOK, "<p>app</p>", "no-cache"},
+		{"Asset", spa, http.MethodGet, "/app.js", http.StatusOK, "console.log('app')", ""},
+		{"Fallback", spa, http.MethodGet, "/users/42", http.StatusOK, "<p>app</p>", "no-cache"},
+		{"DotSegments", spa, http.MethodGet, "/../../etc/passwd", http.StatusOK, "<p>app</p>", ""},
+		{"Proxied", spa, http.MethodGet, "/api/users", http.StatusOK, "api GET /api/users", ""},
+		{"ProxiedPost", spa, http.MethodPost, "/api/users", http.StatusOK, "api POST /api/users", ""},
+		{"StaticPost", spa, http.MethodPost, "/", http.StatusMethodNotAllowed, "Method Not Allowed\n", ""},
+		{"Head", spa, http.MethodHead, "/app.js", http.StatusOK, "", ""},
+		{"DirectoryIndex", site, http.MethodGet, "/guide/", http.StatusOK, "<p>guide</p>", "max-age=3600"},
+		{"NotFound", site, http.MethodGet, "/missing", http.StatusNotFound, "<p>missing</p>", ""},
+		{"StripPrefix", site, http.MethodGet, "/docs/app.js", http.StatusOK, "console.log('app')", ""},
+		{"StripPrefixRoot", site, http.MethodGet, "/docs", http.StatusOK, "<p>app</p>", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.jx.ServeHTTP(w, httptest.NewRequest(tc.method, "http://example.com"+tc.path, nil))
+
+			if w.Code != tc.expectedStatus || w.Body.String() != tc.expectedBody {
+				t.Errorf("Expected %d %q, got: %d %q", tc.expectedStatus, tc.expectedBody, w.Code, w.Body.String())
+			}
+			// Response header rules of the route take precedence over the headers of the file server
+			if tc.cacheControl != "" && w.Header().Get("Cache-Control") != tc.cacheControl {
+				t.Errorf("Expected Cache-Control %q, got: %q", tc.cacheControl, w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestStaticLocationValidation(t *testing.T) {
+	root := staticRoot(t)
+
+	testCases := []struct {
+		name      string
+		route     types.Route
+		expectErr bool
+	}{
+		{"Static", types.Route{Path: "/", Static: types.StaticLocation{Root: root, Fallback: "index.html"}}, false},
+		{"StripPrefix", types.Route{Path: "/docs", Static: types.StaticLocation{Root: root, StripPrefix: true}}, false},
+		{"Regex", types.Route{Path: `^/assets/.*\.js$`, Match: "regex", Static: types.StaticLocation{Root: root}}, false},
+		{"MissingRoot", types.Route{Path: "/", Static: types.StaticLocation{Root: filepath.Join(root, "missing")}}, true},
+		{"FileRoot", types.Route{Path: "/", Static: types.StaticLocation{Root: filepath.Join(root, "app.js")}}, true},
+		{"MissingFallback", types.Route{Path: "/", Static: types.StaticLocation{Root: root, Fallback: "app.html"}}, true},
+		{"DirectoryFallback", types.Route{Path: "/", Static: types.StaticLocation{Root: root, Fallback: "guide"}}, true},
+		{"WithUpstream", types.Route{Path: "/", Upstream: "http://a", Static: types.StaticLocation{Root: root}}, true},
+		{"WithMirror", types.Route{Path: "/", Mirror: types.MirrorConfig{Upstreams: []string{"http://a"}}, Static: types.StaticLocation{Root: root}}, true},
+		{"WithRewrite", types.Route{Path: "/docs", Rewrite: types.PathRewrite{StripPrefix: true}, Static: types.StaticLocation{Root: root}}, true},
+		{"WithTLS", types.Route{Path: "/", TLS: types.UpstreamTLSConfig{InsecureSkipVerify: true}, Static: types.StaticLocation{Root: root}}, true},
+		{"RegexStripPrefix", types.Route{Path: "^/docs/", Match: "regex", Static: types.StaticLocation{Root: root, StripPrefix: true}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := reverse_proxy.NewRouter([]types.Route{tc.route})
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
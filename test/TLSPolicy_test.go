@@ -0,0 +1,183 @@
+package test
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"jinx/internal/jinx_tls"
+	"jinx/pkg/util/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestApplyTLSPolicy(t *testing.T) {
+	testCases := []struct {
+		name      string
+		policy    types.TLSPolicy
+		expectErr bool
+		check     func(config *tls.Config) bool
+	}{
+		{
+			name:   "Defaults",
+			policy: types.TLSPolicy{},
+			check: func(config *tls.Config) bool {
+				return config.MinVersion == 0 && config.CipherSuites == nil && slices.Equal(config.NextProtos, []string{"h2", "http/1.1"})
+			},
+		},
+		{
+			name: "CompliancePolicy",
+			policy: types.TLSPolicy{
+				MinVersion:   "1.2",
+				MaxVersion:   "TLSv1.3",
+				CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+				Curves:       []string{"X25519", "secp384r1"},
+				ALPN:         []string{"http/1.1"},
+			},
+			check: func(config *tls.Config) bool {
+				return config.MinVersion == tls.VersionTLS12 && config.MaxVersion == tls.VersionTLS13 &&
+					slices.Equal(config.CipherSuites, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}) &&
+					slices.Equal(config.CurvePreferences, []tls.CurveID{tls.X25519, tls.CurveP384}) &&
+					slices.Equal(config.NextProtos, []string{"http/1.1"})
+			},
+		},
+		{
+			name:   "DisableSessionResumption",
+			policy: types.TLSPolicy{DisableSessionResumption: true},
+			check:  func(config *tls.Config) bool { return config.SessionTicketsDisabled },
+		},
+		{name: "UnknownVersion", policy: types.TLSPolicy{MinVersion: "1.4"}, expectErr: true},
+		{name: "MinAboveMax", policy: types.TLSPolicy{MinVersion: "1.3", MaxVersion: "1.2"}, expectErr: true},
+		{name: "UnknownCipherSuite", policy: types.TLSPolicy{CipherSuites: []string{"TLS_NOT_A_SUITE"}}, expectErr: true},
+		{name: "InsecureCipherSuite", policy: types.TLSPolicy{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, expectErr: true},
+		{name: "TLS13CipherSuite", policy: types.TLSPolicy{CipherSuites: []string{"TLS_AES_128_GCM_SHA256"}}, expectErr: true},
+		{name: "UnknownCurve", policy: types.TLSPolicy{Curves: []string{"P-224"}}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &tls.Config{}
+			err := jinx_tls.ApplyTLSPolicy(config, tc.policy, []string{"h2", "http/1.1"})
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+			if tc.check != nil && !tc.check(config) {
+				t.Errorf("Unexpected configuration for %+v: %+v", tc.policy, config)
+			}
+		})
+	}
+}
+
+func writeTicketKeys(t *testing.T, file string, count int) {
+	t.Helper()
+
+	content := ""
+	for i := 0; i < count; i++ {
+		key := make([]byte, 32)
+		_, _ = rand.Read(key)
+		content += base64.StdEncoding.EncodeToString(key) + "\n"
+	}
+	if err := os.WriteFile(file, []byte("# session ticket keys\n"+content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(file, later, later)
+}
+
+// connect performs a handshake with the server at addr and returns the state of the connection
+func connect(t *testing.T, addr string, config *tls.Config) (tls.ConnectionState, error) {
+	t.Helper()
+
+	conn, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// Reading the reply also processes the session ticket the server sends after a TLS 1.3 handshake
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	return conn.ConnectionState(), nil
+}
+
+func TestTLSPolicyHandshake(t *testing.T) {
+	tempDir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, tempDir, "localhost", "localhost")
+	ticketKeyFile := filepath.Join(tempDir, "ticket.keys")
+	writeTicketKeys(t, ticketKeyFile, 1)
+
+	store, err := jinx_tls.LoadCertificateStore(certFile, keyFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := types.TLSPolicy{MinVersion: "1.3", ALPN: []string{"h2", "http/1.1"}, SessionTicketKeyFile: ticketKeyFile}
+	config, err := store.NewTLSConfig(policy, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serveTLS(t, config)
+
+	if _, err := connect(t, addr, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12}); err == nil {
+		t.Error("Expected a TLS 1.2 client to be rejected by a TLS 1.3 only listener")
+	}
+
+	state, err := connect(t, addr, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"http/1.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.NegotiatedProtocol != "http/1.1" {
+		t.Errorf("Expected http/1.1 to be negotiated, got %q", state.NegotiatedProtocol)
+	}
+
+	sessions := tls.NewLRUClientSessionCache(8)
+	clientConfig := &tls.Config{InsecureSkipVerify: true, ClientSessionCache: sessions}
+	if _, err := connect(t, addr, clientConfig); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := connect(t, addr, clientConfig); err != nil || !state.DidResume {
+		t.Fatalf("Expected the second connection to resume the session, got resumed=%v err=%v", state.DidResume, err)
+	}
+
+	// Once the keys are rotated, tickets encrypted with the old key can no longer be used
+	writeTicketKeys(t, ticketKeyFile, 2)
+	if rotated, err := store.ReloadIfChanged(); err != nil || !rotated {
+		t.Fatalf("Expected the session ticket keys to be rotated, got rotated=%v err=%v", rotated, err)
+	}
+	if state, err := connect(t, addr, clientConfig); err != nil || state.DidResume {
+		t.Errorf("Expected a full handshake after the keys were rotated, got resumed=%v err=%v", state.DidResume, err)
+	}
+}
+
+func TestDisableSessionResumption(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, t.TempDir(), "localhost", "localhost")
+	store, err := jinx_tls.LoadCertificateStore(certFile, keyFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := store.NewTLSConfig(types.TLSPolicy{DisableSessionResumption: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serveTLS(t, config)
+
+	clientConfig := &tls.Config{InsecureSkipVerify: true, ClientSessionCache: tls.NewLRUClientSessionCache(8)}
+	for i := 0; i < 2; i++ {
+		state, err := connect(t, addr, clientConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.DidResume {
+			t.Error("Expected no session to be resumed when session resumption is disabled")
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// versionBackend answers every request with its name.
+func versionBackend(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, name)
+	}))
+}
+
+// splitRequest sends a request through jx and returns the version that answered it and the response.
+func splitRequest(jx *reverse_proxy.JinxReverseProxyServer, remoteAddr string, header map[string]string, cookies ...*http.Cookie) (string, *http.Response) {
+	r := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/app", nil)
+	r.RemoteAddr = remoteAddr
+	for name, value := range header {
+		r.Header.Set(name, value)
+	}
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	jx.ServeHTTP(w, r)
+	return w.Body.String(), w.Result()
+}
+
+func TestTrafficSplit(t *testing.T) {
+	stable := versionBackend("stable")
+	defer stable.Close()
+	canary := versionBackend("canary")
+	defer canary.Close()
+
+	testCases := []struct {
+		name     string
+		split    types.TrafficSplit
+		header   map[string]string
+		cookie   *http.Cookie
+		expected string
+	}{
+		{"NoWeight", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: canary.URL}}}, nil, nil, "stable"},
+		{"FullWeight", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: canary.URL, Weight: 100}}}, nil, nil, "canary"},
+		{"Header", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: canary.URL, Header: "X-Canary: 1"}}}, map[string]string{"X-Canary": "1"}, nil, "canary"},
+		{"OtherHeaderValue", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: canary.URL, Header: "X-Canary: 1"}}}, map[string]string{"X-Canary": "0"}, nil, "stable"},
+		{"Cookie", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: canary.URL, Cookie: "canary=always"}}}, nil, &http.Cookie{Name: "canary", Value: "always"}, "canary"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jx := newSplitProxy(t, stable.URL, tc.split)
+
+			var cookies []*http.Cookie
+			if tc.cookie != nil {
+				cookies = append(cookies, tc.cookie)
+			}
+			for i := 0; i < 20; i++ {
+				if version, _ := splitRequest(jx, "192.0.2.1:1234", tc.header, cookies...); version != tc.expected {
+					t.Fatalf("Request %d: expected %s, got: %s", i, tc.expected, version)
+				}
+			}
+		})
+	}
+}
+
+func TestTrafficSplitSticky(t *testing.T) {
+	stable := versionBackend("stable")
+	defer stable.Close()
+	canary := versionBackend("canary")
+	defer canary.Close()
+
+	t.Run("ClientIP", func(t *testing.T) {
+		jx := newSplitProxy(t, stable.URL, types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: canary.URL, Weight: 50}}, Sticky: "client_ip"})
+
+		seen := make(map[string]bool)
+		for client := 1; client <= 50; client++ {
+			remoteAddr := "192.0.2." + string(rune('0'+client%10)) + string(rune('0'+client/10)) + ":1234"
+			first, _ := splitRequest(jx, remoteAddr, nil)
+			seen[first] = true
+			for i := 0; i < 5; i++ {
+				if version, _ := splitRequest(jx, remoteAddr, nil); version != first {
+					t.Fatalf("Client %s: expected to stay on %s, got: %s", remoteAddr, first, version)
+				}
+			}
+		}
+		if !seen["stable"] || !seen["canary"] {
+			t.Errorf("Expected clients on both versions, got: %v", seen)
+		}
+	})
+
+	t.Run("Cookie", func(t *testing.T) {
+		jx := newSplitProxy(t, stable.URL, types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: canary.URL, Weight: 50}}, Sticky: "cookie"})
+
+		for client := 0; client < 20; client++ {
+			first, res := splitRequest(jx, "192.0.2.1:1234", nil)
+			cookies := res.Cookies()
+			if len(cookies) != 1 || cookies[0].Name != "jinx_split" {
+				t.Fatalf("Client %d: expected the assignment cookie, got: %v", client, cookies)
+			}
+			for i := 0; i < 5; i++ {
+				version, res := splitRequest(jx, "192.0.2.1:1234", nil, cookies[0])
+				if version != first {
+					t.Fatalf("Client %d: expected to stay on %s, got: %s", client, first, version)
+				}
+				if len(res.Cookies()) != 0 {
+					t.Errorf("Client %d: expected no new cookie for an assigned client", client)
+				}
+			}
+		}
+	})
+}
+
+func TestTrafficSplitReload(t *testing.T) {
+	stable := versionBackend("stable")
+	defer stable.Close()
+	canary := versionBackend("canary")
+	defer canary.Close()
+
+	routeFile := filepath.Join(t.TempDir(), "routes.json")
+	writeRoutes := func(content string) {
+		if err := os.WriteFile(routeFile, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write the route file: %v", err)
+		}
+	}
+	routesWithWeight := func(weight int) string {
+		routing := types.RoutingConfig{Routes: []types.Route{{
+			Path:     "/",
+			Upstream: stable.URL,
+			Split:    types.TrafficSplit{Versions: []types.SplitVersion{{Name: "canary", Upstream: canary.URL, Weight: weight}}},
+		}}}
+		content, _ := json.Marshal(routing)
+		return string(content)
+	}
+
+	writeRoutes(routesWithWeight(0))
+	routing, err := reverse_proxy.LoadRoutingConfig(routeFile)
+	if err != nil {
+		t.Fatalf("Failed to load the route file: %v", err)
+	}
+	jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{LogRoot: t.TempDir(), RouteFile: routeFile, Routes: routing.Routes}, t.TempDir())
+
+	steps := []struct {
+		routes   string
+		expected string
+	}{
+		{"", "stable"},
+		{routesWithWeight(100), "canary"},
+		{`{"Routes": [{"Path": "/", "Upstream": "http://a", "Split": {"Versions": [{"Upstream": "http://b", "Weight": 101}]}}]}`, "canary"},
+		{"not json", "canary"},
+		{routesWithWeight(0), "stable"},
+	}
+
+	for i, step := range steps {
+		if step.routes != "" {
+			writeRoutes(step.routes)
+			jx.Reload()
+		}
+		if version, _ := splitRequest(jx, "192.0.2.1:1234", nil); version != step.expected {
+			t.Errorf("Step %d: expected %s, got: %s", i, step.expected, version)
+		}
+	}
+}
+
+func TestTrafficSplitValidation(t *testing.T) {
+	testCases := []struct {
+		name      string
+		split     types.TrafficSplit
+		expectErr bool
+	}{
+		{"None", types.TrafficSplit{}, false},
+		{"Complete", types.TrafficSplit{Versions: []types.SplitVersion{{Name: "v2", Upstream: "http://v2", Weight: 10, Header: "X-Canary: 1", Cookie: "canary=1"}}, Sticky: "header:X-User-Id"}, false},
+		{"WeightsAbove100", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: "http://v2", Weight: 60}, {Upstream: "http://v3", Weight: 50}}}, true},
+		{"NegativeWeight", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: "http://v2", Weight: -1}}}, true},
+		{"NoUpstream", types.TrafficSplit{Versions: []types.SplitVersion{{Weight: 10}}}, true},
+		{"UpstreamAndGroup", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: "http://v2", UpstreamGroup: "v2"}}}, true},
+		{"InvalidHeader", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: "http://v2", Header: "X-Canary"}}}, true},
+		{"EmptyHeaderValue", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: "http://v2", Header: "X-Canary:"}}}, true},
+		{"BlankHeaderValue", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: "http://v2", Header: "X-Canary:  "}}}, true},
+		{"InvalidCookie", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: "http://v2", Cookie: "canary"}}}, true},
+		{"UnknownSticky", types.TrafficSplit{Versions: []types.SplitVersion{{Upstream: "http://v2"}}, Sticky: "session"}, true},
+		{"StickyWithoutVersions", types.TrafficSplit{Sticky: "cookie"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := reverse_proxy.NewRouter([]types.Route{{Path: "/", Upstream: "http://a", Split: tc.split}})
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+
+	t.Run("UnknownGroup", func(t *testing.T) {
+		routes := []types.Route{{Path: "/", Upstream: "http://a", Split: types.TrafficSplit{Versions: []types.SplitVersion{{UpstreamGroup: "v2"}}}}}
+		if _, _, err := reverse_proxy.NewRouting(routes, nil); err == nil {
+			t.Errorf("Expected an error for the unknown upstream group of the version")
+		}
+	})
+}
+
+func newSplitProxy(t *testing.T, upstreamURL string, split types.TrafficSplit) *reverse_proxy.JinxReverseProxyServer {
+	t.Helper()
+
+	jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+		LogRoot: t.TempDir(),
+		Routes:  []types.Route{{Path: "/", Upstream: upstreamURL, Split: split}},
+	}, t.TempDir())
+	if jx == nil {
+		t.Fatalf("Expected a server for %s", upstreamURL)
+	}
+	return jx
+}
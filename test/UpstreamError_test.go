@@ -0,0 +1,241 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"jinx/internal/forward_proxy"
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// closedUpstream returns the address of a port nothing listens on.
+func closedUpstream(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	_ = l.Close()
+	return address
+}
+
+// lastErrorLog returns the last entry of the error log written to logRoot.
+func lastErrorLog(t *testing.T, logRoot string) map[string]any {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join(logRoot, "error.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	entry := make(map[string]any)
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+		t.Fatalf("Expected a JSON log entry, got: %q", lines[len(lines)-1])
+	}
+	return entry
+}
+
+func TestUpstreamError(t *testing.T) {
+	closed := "http://" + closedUpstream(t)
+
+	untrusted := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer untrusted.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(1500 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	reset := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}))
+	defer reset.Close()
+
+	testCases := []struct {
+		name           string
+		upstream       string
+		group          *types.UpstreamGroupConfig // balances the route instead of upstream when set
+		pool           types.ConnectionPoolConfig
+		expectedStatus int
+		expectedClass  string
+	}{
+		{"Refused", closed, nil, types.ConnectionPoolConfig{}, http.StatusBadGateway, constant.UPSTREAM_ERROR_REFUSED},
+		{"DNS", "http://upstream.invalid", nil, types.ConnectionPoolConfig{}, http.StatusBadGateway, constant.UPSTREAM_ERROR_DNS},
+		{"TLS", untrusted.URL, nil, types.ConnectionPoolConfig{}, http.StatusBadGateway, constant.UPSTREAM_ERROR_TLS},
+		{"ResponseHeaderTimeout", slow.URL, nil, types.ConnectionPoolConfig{ResponseHeaderTimeout: 1}, http.StatusGatewayTimeout, constant.UPSTREAM_ERROR_TIMEOUT},
+		{"TryTimeout", "", &types.UpstreamGroupConfig{Members: []types.UpstreamMemberConfig{{URL: slow.URL}}, Retry: types.RetryConfig{Attempts: 1, TryTimeout: 1}}, types.ConnectionPoolConfig{}, http.StatusGatewayTimeout, constant.UPSTREAM_ERROR_TIMEOUT},
+		{"Reset", reset.URL, nil, types.ConnectionPoolConfig{}, http.StatusBadGateway, constant.UPSTREAM_ERROR_RESET},
+		{"GroupRefused", "", &types.UpstreamGroupConfig{Members: []types.UpstreamMemberConfig{{URL: closed}}, Retry: types.RetryConfig{Attempts: 1}}, types.ConnectionPoolConfig{}, http.StatusBadGateway, constant.UPSTREAM_ERROR_REFUSED},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logRoot := t.TempDir()
+			config := types.JinxReverseProxyServerConfig{LogRoot: logRoot, ConnectionPool: tc.pool}
+			config.Routes = []types.Route{{Path: "/", Upstream: tc.upstream}}
+			if tc.group != nil {
+				config.Routes = []types.Route{{Path: "/", UpstreamGroup: "failing"}}
+				config.UpstreamGroups = map[string]types.UpstreamGroupConfig{"failing": *tc.group}
+			}
+			jx := reverse_proxy.NewJinxReverseProxyServer(config, t.TempDir())
+
+			w := httptest.NewRecorder()
+			jx.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://proxy.example.com/", nil))
+
+			requestID := w.Header().Get(constant.REQUEST_ID_HEADER)
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected %d, got: %d %q", tc.expectedStatus, w.Code, w.Body.String())
+			}
+			if requestID == "" || !strings.Contains(w.Body.String(), requestID) {
+				t.Errorf("Expected the request ID %q in the body, got: %q", requestID, w.Body.String())
+			}
+
+			entry := lastErrorLog(t, logRoot)
+			if entry["class"] != tc.expectedClass {
+				t.Errorf("Expected the class %s, got: %v", tc.expectedClass, entry)
+			}
+			if entry["route"] != "/" || entry["upstream"] == "" || entry["duration"] == nil || entry["request_id"] != requestID {
+				t.Errorf("Expected the upstream, the route, the duration and the request ID in the log, got: %v", entry)
+			}
+		})
+	}
+}
+
+func TestUpstreamErrorPages(t *testing.T) {
+	dir := t.TempDir()
+	badGatewayPage := filepath.Join(dir, "502.html")
+	unavailablePage := filepath.Join(dir, "503.html")
+	if err := os.WriteFile(badGatewayPage, []byte("<p>${status} upstream down, request ${request_id}</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(unavailablePage, []byte("<p>${status} try again later, request ${request_id}</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + closedUpstream(t)
+
+	// The member is ejected after its first failure, later requests find no available member
+	jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+		LogRoot: t.TempDir(),
+		Routes:  []types.Route{{Path: "/", UpstreamGroup: "failing"}},
+		UpstreamGroups: map[string]types.UpstreamGroupConfig{"failing": {
+			Members:        []types.UpstreamMemberConfig{{URL: closed}},
+			CircuitBreaker: types.CircuitBreakerConfig{Failures: 1},
+			Retry:          types.RetryConfig{Attempts: 1},
+		}},
+		ErrorPages: types.ErrorPages{http.StatusBadGateway: badGatewayPage, http.StatusServiceUnavailable: unavailablePage},
+	}, t.TempDir())
+
+	steps := []struct {
+		expectedStatus int
+		expectedBody   string
+	}{
+		{http.StatusBadGateway, "<p>502 upstream down, request ${request_id}</p>"},
+		{http.StatusServiceUnavailable, "<p>503 try again later, request ${request_id}</p>"},
+	}
+
+	for i, step := range steps {
+		r := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/", nil)
+		r.Header.Set(constant.REQUEST_ID_HEADER, "<script>")
+		w := httptest.NewRecorder()
+		jx.ServeHTTP(w, r)
+
+		// Request IDs of untrusted clients are replaced
+		requestID := w.Header().Get(constant.REQUEST_ID_HEADER)
+		expectedBody := strings.ReplaceAll(step.expectedBody, "${request_id}", requestID)
+		if w.Code != step.expectedStatus || w.Body.String() != expectedBody {
+			t.Errorf("Step %d: expected %d %q, got: %d %q", i, step.expectedStatus, expectedBody, w.Code, w.Body.String())
+		}
+		if requestID == "<script>" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+			t.Errorf("Step %d: expected a new request ID and an HTML page, got: %v", i, w.Header())
+		}
+	}
+}
+
+func TestForwardProxyUpstreamError(t *testing.T) {
+	closed := closedUpstream(t)
+
+	testCases := []struct {
+		name      string
+		method    string
+		target    string
+		requestID string // sent by the client, kept by the forward proxy
+	}{
+		{"HTTP", http.MethodGet, "http://" + closed + "/", ""},
+		{"HTTPWithRequestID", http.MethodGet, "http://" + closed + "/", "client-id"},
+		{"Connect", http.MethodConnect, closed, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logRoot := t.TempDir()
+			jx := forward_proxy.NewJinxForwardProxyServer(types.JinxForwardProxyServerConfig{LogRoot: logRoot}, t.TempDir())
+
+			r := httptest.NewRequest(tc.method, tc.target, nil)
+			if tc.requestID != "" {
+				r.Header.Set(constant.REQUEST_ID_HEADER, tc.requestID)
+			}
+			w := httptest.NewRecorder()
+			jx.ServeHTTP(w, r)
+
+			requestID := w.Header().Get(constant.REQUEST_ID_HEADER)
+			if w.Code != http.StatusBadGateway {
+				t.Errorf("Expected 502, got: %d %q", w.Code, w.Body.String())
+			}
+			if requestID == "" || (tc.requestID != "" && requestID != tc.requestID) {
+				t.Errorf("Expected the request ID %q, got: %q", tc.requestID, requestID)
+			}
+			body, _ := io.ReadAll(w.Body)
+			if !strings.Contains(string(body), requestID) {
+				t.Errorf("Expected the request ID in the body, got: %q", body)
+			}
+
+			entry := lastErrorLog(t, logRoot)
+			if entry["class"] != constant.UPSTREAM_ERROR_REFUSED || entry["route"] != closed || entry["duration"] == nil {
+				t.Errorf("Expected a refused connection to %s in the log, got: %v", closed, entry)
+			}
+		})
+	}
+}
+
+func TestValidateErrorPages(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "502.html")
+	if err := os.WriteFile(page, []byte("<p>Bad Gateway</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		pages     types.ErrorPages
+		expectErr bool
+	}{
+		{"None", nil, false},
+		{"Pages", types.ErrorPages{http.StatusBadGateway: page, http.StatusGatewayTimeout: page}, false},
+		{"OtherStatus", types.ErrorPages{http.StatusNotFound: page}, true},
+		{"MissingFile", types.ErrorPages{http.StatusBadGateway: filepath.Join(dir, "missing.html")}, true},
+		{"Directory", types.ErrorPages{http.StatusServiceUnavailable: dir}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := helper.ValidateErrorPages(tc.pages); (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"io"
+	"jinx/internal/reverse_proxy"
+	"jinx/internal/upstream"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewGroup(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    types.UpstreamGroupConfig
+		expectErr bool
+	}{
+		{"DefaultAlgorithm", types.UpstreamGroupConfig{Members: []types.UpstreamMemberConfig{{URL: "http://10.0.0.1:8080"}}}, false},
+		{"Weighted", types.UpstreamGroupConfig{Algorithm: constant.WEIGHTED_ROUND_ROBIN, Members: []types.UpstreamMemberConfig{{URL: "https://a", Weight: 3}, {URL: "https://b/base"}}}, false},
+		{"UnknownAlgorithm", types.UpstreamGroupConfig{Algorithm: "fastest", Members: []types.UpstreamMemberConfig{{URL: "http://a"}}}, true},
+		{"NoMembers", types.UpstreamGroupConfig{}, true},
+		{"RelativeURL", types.UpstreamGroupConfig{Members: []types.UpstreamMemberConfig{{URL: "10.0.0.1:8080"}}}, true},
+		{"NegativeWeight", types.UpstreamGroupConfig{Members: []types.UpstreamMemberConfig{{URL: "http://a", Weight: -1}}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := upstream.NewGroup("api", tc.config)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestNewRoutingUnknownGroup(t *testing.T) {
+	routes := []types.Route{{Path: "/api", UpstreamGroup: "missing"}}
+	if _, _, err := reverse_proxy.NewRouting(routes, nil); err == nil {
+		t.Error("Expected a route referring to an unknown upstream group to be rejected")
+	}
+
+	routes = []types.Route{{Path: "/api", Upstream: "http://a", UpstreamGroup: "api"}}
+	groups := map[string]types.UpstreamGroupConfig{"api": {Members: []types.UpstreamMemberConfig{{URL: "http://a"}}}}
+	if _, _, err := reverse_proxy.NewRouting(routes, groups); err == nil {
+		t.Error("Expected a route with both an upstream and an upstream group to be rejected")
+	}
+}
+
+func TestReverseProxyUpstreamGroup(t *testing.T) {
+	newUpstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, name+" "+r.URL.Path)
+		}))
+	}
+	blue, green := newUpstream("blue"), newUpstream("green")
+	defer blue.Close()
+	defer green.Close()
+
+	config := types.JinxReverseProxyServerConfig{
+		LogRoot: t.TempDir(),
+		Routes:  []types.Route{{Path: "/api", UpstreamGroup: "api"}},
+		UpstreamGroups: map[string]types.UpstreamGroupConfig{
+			"api": {
+				Algorithm: constant.WEIGHTED_ROUND_ROBIN,
+				Members:   []types.UpstreamMemberConfig{{URL: blue.URL, Weight: 3}, {URL: green.URL + "/v2"}},
+			},
+		},
+	}
+	jx := reverse_proxy.NewJinxReverseProxyServer(config, t.TempDir())
+
+	counts := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		w := httptest.NewRecorder()
+		jx.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://proxy.example.com/api/users", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		counts[w.Body.String()]++
+	}
+
+	if counts["blue /api/users"] != 6 || counts["green /v2/api/users"] != 2 {
+		t.Errorf("Expected 6 requests to blue and 2 to green under /v2, got %v", counts)
+	}
+}
@@ -0,0 +1,239 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"jinx/internal/load_balancer"
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/constant"
+	"jinx/pkg/util/types"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// upstreamTLSFixture is a TLS upstream with a self-signed certificate for upstream.internal, which trusts the
+// client certificates of a test CA.
+type upstreamTLSFixture struct {
+	certFile       string // certificate of the upstream, which is its own CA
+	fingerprint    string
+	clientCertFile string
+	clientKeyFile  string
+	serverConfig   *tls.Config
+}
+
+func newUpstreamTLSFixture(t *testing.T) *upstreamTLSFixture {
+	t.Helper()
+
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "upstream", "upstream.internal")
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := sha256.Sum256(certificate.Certificate[0])
+
+	ca, _ := newTestCA(t, dir)
+	clientCertificate := ca.issueClientCertificate(t, "jinx", 2)
+	keyDer, err := x509.MarshalECPrivateKey(clientCertificate.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCertFile := filepath.Join(dir, "client.crt")
+	clientKeyFile := filepath.Join(dir, "client.key")
+	if err := os.WriteFile(clientCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCertificate.Certificate[0]}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(clientKeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.certificate)
+
+	return &upstreamTLSFixture{
+		certFile:       certFile,
+		fingerprint:    strings.ToUpper(hex.EncodeToString(fingerprint[:])),
+		clientCertFile: clientCertFile,
+		clientKeyFile:  clientKeyFile,
+		serverConfig: &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    clientCAs,
+			MaxVersion:   tls.VersionTLS12,
+		},
+	}
+}
+
+func TestUpstreamTLS(t *testing.T) {
+	fixture := newUpstreamTLSFixture(t)
+
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Proto+" "+r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	backend.TLS = fixture.serverConfig
+	backend.EnableHTTP2 = true
+	backend.StartTLS()
+	defer backend.Close()
+
+	trusted := types.UpstreamTLSConfig{CAFile: fixture.certFile, ServerName: "upstream.internal", CertFile: fixture.clientCertFile, KeyFile: fixture.clientKeyFile}
+	withMinVersion := trusted
+	withMinVersion.MinVersion = "1.3"
+
+	testCases := []struct {
+		name     string
+		config   types.UpstreamTLSConfig
+		protocol string
+		group    bool
+		expected string // the body of the response, empty when the upstream must not be reached
+	}{
+		{"DefaultSettings", types.UpstreamTLSConfig{}, "", false, ""},
+		{"CA", trusted, "", false, "HTTP/2.0 jinx"},
+		{"HTTP1", trusted, constant.UPSTREAM_PROTOCOL_HTTP1, false, "HTTP/1.1 jinx"},
+		{"H2", trusted, constant.UPSTREAM_PROTOCOL_H2, false, "HTTP/2.0 jinx"},
+		{"Group", trusted, "", true, "HTTP/2.0 jinx"},
+		{"CAWithoutServerName", types.UpstreamTLSConfig{CAFile: fixture.certFile, CertFile: fixture.clientCertFile, KeyFile: fixture.clientKeyFile}, "", false, ""},
+		{"NoClientCertificate", types.UpstreamTLSConfig{CAFile: fixture.certFile, ServerName: "upstream.internal"}, "", false, ""},
+		{"MinVersion", withMinVersion, "", false, ""},
+		{"InsecureSkipVerify", types.UpstreamTLSConfig{InsecureSkipVerify: true, CertFile: fixture.clientCertFile, KeyFile: fixture.clientKeyFile}, "", false, "HTTP/2.0 jinx"},
+		{"Pinned", types.UpstreamTLSConfig{InsecureSkipVerify: true, PinnedCertificates: []string{fixture.fingerprint}, CertFile: fixture.clientCertFile, KeyFile: fixture.clientKeyFile}, "", false, "HTTP/2.0 jinx"},
+		{"OtherPin", types.UpstreamTLSConfig{InsecureSkipVerify: true, PinnedCertificates: []string{strings.Repeat("ab", 32)}, CertFile: fixture.clientCertFile, KeyFile: fixture.clientKeyFile}, "", false, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := types.JinxReverseProxyServerConfig{LogRoot: t.TempDir()}
+			route := types.Route{Path: "/", Upstream: backend.URL, Protocol: tc.protocol, TLS: tc.config}
+			if tc.group {
+				route = types.Route{Path: "/", UpstreamGroup: "secure"}
+				config.UpstreamGroups = map[string]types.UpstreamGroupConfig{
+					"secure": {Members: []types.UpstreamMemberConfig{{URL: backend.URL}}, TLS: tc.config},
+				}
+			}
+			config.Routes = []types.Route{route}
+			jx := reverse_proxy.NewJinxReverseProxyServer(config, t.TempDir())
+
+			w := httptest.NewRecorder()
+			jx.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://proxy.example.com/", nil))
+
+			if tc.expected == "" {
+				if w.Code != http.StatusBadGateway {
+					t.Errorf("Expected 502 Bad Gateway, got: %d %q", w.Code, w.Body.String())
+				}
+				return
+			}
+			if w.Code != http.StatusOK || w.Body.String() != tc.expected {
+				t.Errorf("Expected 200 %q, got: %d %q", tc.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestLoadBalancerUpstreamTLS(t *testing.T) {
+	fixture := newUpstreamTLSFixture(t)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", fixture.serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+	go func() {
+		for {
+			conn, acceptErr := l.Accept()
+			if acceptErr != nil {
+				return
+			}
+			_, _ = io.WriteString(conn, "pong")
+			_ = conn.Close()
+		}
+	}()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	testCases := []struct {
+		name      string
+		config    types.UpstreamTLSConfig
+		expectErr bool
+	}{
+		{"Trusted", types.UpstreamTLSConfig{CAFile: fixture.certFile, ServerName: "upstream.internal", CertFile: fixture.clientCertFile, KeyFile: fixture.clientKeyFile}, false},
+		{"Pinned", types.UpstreamTLSConfig{InsecureSkipVerify: true, PinnedCertificates: []string{fixture.fingerprint}, CertFile: fixture.clientCertFile, KeyFile: fixture.clientKeyFile}, false},
+		{"Untrusted", types.UpstreamTLSConfig{CertFile: fixture.clientCertFile, KeyFile: fixture.clientKeyFile}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := types.JinxLoadBalancingServerConfig{
+				LogRoot:     t.TempDir(),
+				Algorithm:   constant.ROUND_ROBIN,
+				ServerPool:  []types.UpStreamServer{{IP: "127.0.0.1", Port: port}},
+				Retry:       types.RetryConfig{Attempts: 1, TryTimeout: 1},
+				UpstreamTLS: &tc.config,
+			}
+			jx := load_balancer.NewJinxLoadBalancingServer(config, t.TempDir())
+
+			client, server := net.Pipe()
+			go jx.ProxyTCP(server)
+			defer func() {
+				_ = client.Close()
+			}()
+
+			_ = client.SetReadDeadline(time.Now().Add(3 * time.Second))
+			reply := make([]byte, 4)
+			_, err := io.ReadFull(client, reply)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("Expected the connection to be refused, got: %q", reply)
+				}
+				return
+			}
+			if err != nil || string(reply) != "pong" {
+				t.Errorf("Expected pong, got: %q, %v", reply, err)
+			}
+		})
+	}
+}
+
+func TestUpstreamTLSValidation(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "upstream", "upstream.internal")
+
+	testCases := []struct {
+		name      string
+		config    types.UpstreamTLSConfig
+		expectErr bool
+	}{
+		{"None", types.UpstreamTLSConfig{}, false},
+		{"Complete", types.UpstreamTLSConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile, ServerName: "upstream.internal", MinVersion: "1.2", PinnedCertificates: []string{strings.Repeat("AB:", 31) + "AB"}}, false},
+		{"MissingCAFile", types.UpstreamTLSConfig{CAFile: filepath.Join(dir, "missing.crt")}, true},
+		{"CAFileWithoutCertificate", types.UpstreamTLSConfig{CAFile: keyFile}, true},
+		{"CertificateWithoutKey", types.UpstreamTLSConfig{CertFile: certFile}, true},
+		{"UnknownMinVersion", types.UpstreamTLSConfig{MinVersion: "1.4"}, true},
+		{"InvalidPin", types.UpstreamTLSConfig{PinnedCertificates: []string{"abcd"}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := reverse_proxy.NewRouter([]types.Route{{Path: "/", Upstream: "https://upstream.internal", TLS: tc.config}})
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+
+			groups := map[string]types.UpstreamGroupConfig{"secure": {Members: []types.UpstreamMemberConfig{{URL: "https://upstream.internal"}}, TLS: tc.config}}
+			_, _, err = reverse_proxy.NewRouting([]types.Route{{Path: "/", UpstreamGroup: "secure"}}, groups)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error for the upstream group: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package test
+
+import (
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"testing"
+)
+
+func TestValidateACMEConfig(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		config types.ACMEConfig
+		err    bool
+	}{
+		{name: "Disabled", config: types.ACMEConfig{}, err: false},
+		{name: "ValidHosts", config: types.ACMEConfig{Hosts: []string{"example.com", "www.example.com"}, Email: "admin@example.com"}, err: false},
+		{name: "LocalPebbleDirectory", config: types.ACMEConfig{Hosts: []string{"example.com"}, DirectoryURL: "https://localhost:14000/dir", HTTPChallengePort: 5002}, err: false},
+		{name: "WildcardHost", config: types.ACMEConfig{Hosts: []string{"*.example.com"}}, err: true},
+		{name: "IPHost", config: types.ACMEConfig{Hosts: []string{"127.0.0.1"}}, err: true},
+		{name: "PlainHTTPDirectory", config: types.ACMEConfig{Hosts: []string{"example.com"}, DirectoryURL: "http://localhost:14000/dir"}, err: true},
+		{name: "MissingDirectoryCAFile", config: types.ACMEConfig{Hosts: []string{"example.com"}, DirectoryCAFile: "/invalid/ca.pem"}, err: true},
+		{name: "NegativeRenewBefore", config: types.ACMEConfig{Hosts: []string{"example.com"}, RenewBefore: -1}, err: true},
+		{name: "InvalidChallengePort", config: types.ACMEConfig{Hosts: []string{"example.com"}, HTTPChallengePort: 70000}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := helper.ValidateACMEConfig(test.config); (err != nil) != test.err {
+				t.Errorf("expected error to be %v but got %v", test.err, err)
+			}
+		})
+	}
+}
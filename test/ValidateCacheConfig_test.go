@@ -0,0 +1,30 @@
+package test
+
+import (
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"testing"
+)
+
+func TestValidateCacheConfig(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    types.CacheConfig
+		expectErr bool
+	}{
+		{"Disabled", types.CacheConfig{}, false},
+		{"Complete", types.CacheConfig{Dir: "/var/cache/jinx", MaxSize: 512, MaxEntrySize: 16}, false},
+		{"NegativeSize", types.CacheConfig{MaxSize: -1}, true},
+		{"NegativeEntrySize", types.CacheConfig{MaxSize: 10, MaxEntrySize: -1}, true},
+		{"EntryLargerThanCache", types.CacheConfig{MaxSize: 10, MaxEntrySize: 20}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := helper.ValidateCacheConfig(tc.config)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package test
+
+import (
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"testing"
+)
+
+func TestValidateCircuitBreakerConfig(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    types.CircuitBreakerConfig
+		expectErr bool
+	}{
+		{"Disabled", types.CircuitBreakerConfig{}, false},
+		{"Defaults", types.CircuitBreakerConfig{Failures: 5}, false},
+		{"Complete", types.CircuitBreakerConfig{Failures: 5, Window: 10, Backoff: 30, MaxBackoff: 300, HalfOpenRequests: 2}, false},
+		{"NegativeFailures", types.CircuitBreakerConfig{Failures: -1}, true},
+		{"NegativeWindow", types.CircuitBreakerConfig{Failures: 5, Window: -10}, true},
+		{"MaxBackoffShorterThanBackoff", types.CircuitBreakerConfig{Failures: 5, Backoff: 60, MaxBackoff: 30}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := helper.ValidateCircuitBreakerConfig(tc.config)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateAdminConfig(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    types.AdminConfig
+		expectErr bool
+	}{
+		{"Disabled", types.AdminConfig{}, false},
+		{"Loopback", types.AdminConfig{IP: "127.0.0.1", Port: 9090}, false},
+		{"DefaultIP", types.AdminConfig{Port: 9090}, false},
+		{"InvalidPort", types.AdminConfig{Port: 70000}, true},
+		{"InvalidIP", types.AdminConfig{IP: "localhost:1", Port: 9090}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := helper.ValidateAdminConfig(tc.config)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package test
+
+import (
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateClientAuthConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	caFile := filepath.Join(tempDir, "ca.crt")
+	_ = os.WriteFile(caFile, []byte(""), 0644)
+	missing := filepath.Join(tempDir, "missing.pem")
+
+	testCases := []struct {
+		name      string
+		config    types.ClientAuthConfig
+		expectErr bool
+	}{
+		{"Disabled", types.ClientAuthConfig{}, false},
+		{"None", types.ClientAuthConfig{Mode: "none"}, false},
+		{"Require", types.ClientAuthConfig{Mode: "require", CAFile: caFile}, false},
+		{"OptionalWithCRL", types.ClientAuthConfig{Mode: "Optional", CAFile: caFile, CRLFiles: []string{caFile}}, false},
+		{"UnknownMode", types.ClientAuthConfig{Mode: "always", CAFile: caFile}, true},
+		{"MissingCABundle", types.ClientAuthConfig{Mode: "require"}, true},
+		{"CABundleNotFound", types.ClientAuthConfig{Mode: "require", CAFile: missing}, true},
+		{"CRLNotFound", types.ClientAuthConfig{Mode: "require", CAFile: caFile, CRLFiles: []string{missing}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := helper.ValidateClientAuthConfig(tc.config)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateClientAuthRoutes(t *testing.T) {
+	require := types.ClientAuthConfig{Mode: "require", CAFile: "ca.crt"}
+
+	testCases := []struct {
+		name      string
+		routes    []types.ClientAuthRoute
+		config    types.ClientAuthConfig
+		expectErr bool
+	}{
+		{"NoRoutes", nil, types.ClientAuthConfig{}, false},
+		{"ValidRoute", []types.ClientAuthRoute{{Path: "/internal"}}, require, false},
+		{"VerificationDisabled", []types.ClientAuthRoute{{Path: "/internal"}}, types.ClientAuthConfig{Mode: "none"}, true},
+		{"RelativePath", []types.ClientAuthRoute{{Path: "internal"}}, require, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := helper.ValidateClientAuthRoutes(tc.routes, tc.config)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
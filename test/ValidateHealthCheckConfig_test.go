@@ -0,0 +1,33 @@
+package test
+
+import (
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"testing"
+)
+
+func TestValidateHealthCheckConfig(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    types.HealthCheckConfig
+		expectErr bool
+	}{
+		{"Disabled", types.HealthCheckConfig{}, false},
+		{"TCP", types.HealthCheckConfig{Type: "tcp", Interval: 5, Timeout: 2, Rise: 1, Fall: 1}, false},
+		{"HTTP", types.HealthCheckConfig{Type: "http", Path: "/healthz", ExpectedStatus: []int{200, 204}, ExpectedBody: "^ok$"}, false},
+		{"UnknownType", types.HealthCheckConfig{Type: "icmp"}, true},
+		{"NegativeInterval", types.HealthCheckConfig{Type: "tcp", Interval: -1}, true},
+		{"RelativePath", types.HealthCheckConfig{Type: "http", Path: "healthz"}, true},
+		{"InvalidStatus", types.HealthCheckConfig{Type: "http", ExpectedStatus: []int{1000}}, true},
+		{"InvalidBodyExpression", types.HealthCheckConfig{Type: "http", ExpectedBody: "("}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := helper.ValidateHealthCheckConfig(tc.config)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
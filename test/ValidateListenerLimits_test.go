@@ -0,0 +1,30 @@
+package test
+
+import (
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"testing"
+)
+
+func TestValidateListenerLimits(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		limits types.ListenerLimits
+		err    bool
+	}{
+		{name: "DefaultLimits", limits: types.ListenerLimits{}, err: false},
+		{name: "CustomLimits", limits: types.ListenerLimits{HeaderReadTimeout: 5, BodyReadTimeout: 30, IdleTimeout: 60, WriteTimeout: 300, MaxHeaderBytes: 8192, MaxBodyBytes: 1 << 20, MaxConnections: 1000, MaxConnectionsPerIP: 20}, err: false},
+		{name: "NegativeTimeout", limits: types.ListenerLimits{WriteTimeout: -1}, err: true},
+		{name: "NegativeBodySize", limits: types.ListenerLimits{MaxBodyBytes: -10}, err: true},
+		{name: "NegativeConnectionLimit", limits: types.ListenerLimits{MaxConnectionsPerIP: -2}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := helper.ValidateListenerLimits(test.limits); (err != nil) != test.err {
+				t.Errorf("expected error to be %v but got %v", test.err, err)
+			}
+		})
+	}
+}
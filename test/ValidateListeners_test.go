@@ -0,0 +1,37 @@
+package test
+
+import (
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"testing"
+)
+
+func TestValidateListeners(t *testing.T) {
+	testCases := []struct {
+		name       string
+		listeners  []types.ListenerConfig
+		redirect   types.HTTPSRedirectConfig
+		tlsEnabled bool
+		expectErr  bool
+	}{
+		{"NoListeners", nil, types.HTTPSRedirectConfig{}, false, false},
+		{"PlainListener", []types.ListenerConfig{{Port: 8080}}, types.HTTPSRedirectConfig{}, false, false},
+		{"RedirectToMainTLS", []types.ListenerConfig{{Port: 80, RedirectToHTTPS: true}}, types.HTTPSRedirectConfig{ExemptPaths: []string{"/health"}}, true, false},
+		{"RedirectToAdditionalTLS", []types.ListenerConfig{{Port: 80, RedirectToHTTPS: true}, {Port: 443, TLS: true}}, types.HTTPSRedirectConfig{}, true, false},
+		{"InvalidPort", []types.ListenerConfig{{Port: 70000}}, types.HTTPSRedirectConfig{}, false, true},
+		{"InvalidIP", []types.ListenerConfig{{IP: "not-an-ip", Port: 80}}, types.HTTPSRedirectConfig{}, false, true},
+		{"TLSWithoutCertificate", []types.ListenerConfig{{Port: 443, TLS: true}}, types.HTTPSRedirectConfig{}, false, true},
+		{"TLSAndRedirect", []types.ListenerConfig{{Port: 443, TLS: true, RedirectToHTTPS: true}}, types.HTTPSRedirectConfig{}, true, true},
+		{"RedirectWithoutHTTPS", []types.ListenerConfig{{Port: 80, RedirectToHTTPS: true}}, types.HTTPSRedirectConfig{}, false, true},
+		{"RelativeExemptPath", []types.ListenerConfig{{Port: 80, RedirectToHTTPS: true}}, types.HTTPSRedirectConfig{ExemptPaths: []string{"health"}}, true, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := helper.ValidateListeners(tc.listeners, tc.redirect, tc.tlsEnabled)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
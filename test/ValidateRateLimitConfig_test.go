@@ -0,0 +1,35 @@
+package test
+
+import (
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"testing"
+)
+
+func TestValidateRateLimitConfig(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    types.RateLimitConfig
+		expectErr bool
+	}{
+		{"Disabled", types.RateLimitConfig{}, false},
+		{"Complete", types.RateLimitConfig{Rules: []types.RateLimitRule{{Name: "api", Key: "header:X-Api-Key", Requests: 100, Period: 60, Burst: 20, Paths: []string{"/api"}}}, MaxTunnels: 10}, false},
+		{"RouteKey", types.RateLimitConfig{Rules: []types.RateLimitRule{{Key: "route", Requests: 1000}}}, false},
+		{"NoRequests", types.RateLimitConfig{Rules: []types.RateLimitRule{{Requests: 0}}}, true},
+		{"NegativeBurst", types.RateLimitConfig{Rules: []types.RateLimitRule{{Requests: 1, Burst: -1}}}, true},
+		{"UnknownKey", types.RateLimitConfig{Rules: []types.RateLimitRule{{Key: "cookie:session", Requests: 1}}}, true},
+		{"EmptyHeader", types.RateLimitConfig{Rules: []types.RateLimitRule{{Key: "header:", Requests: 1}}}, true},
+		{"RelativePath", types.RateLimitConfig{Rules: []types.RateLimitRule{{Requests: 1, Paths: []string{"api"}}}}, true},
+		{"InvalidTrustedProxy", types.RateLimitConfig{TrustedProxies: []string{"proxy"}}, true},
+		{"NegativeTunnels", types.RateLimitConfig{MaxTunnels: -1}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := helper.ValidateRateLimitConfig(tc.config)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package test
+
+import (
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"testing"
+)
+
+func TestValidateRetryConfig(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    types.RetryConfig
+		expectErr bool
+	}{
+		{"Defaults", types.RetryConfig{}, false},
+		{"Complete", types.RetryConfig{Attempts: 2, Statuses: []int{502, 504}, TryTimeout: 5, Deadline: 15}, false},
+		{"NegativeAttempts", types.RetryConfig{Attempts: -1}, true},
+		{"NegativeTryTimeout", types.RetryConfig{TryTimeout: -5}, true},
+		{"InvalidStatus", types.RetryConfig{Statuses: []int{99}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := helper.ValidateRetryConfig(tc.config)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
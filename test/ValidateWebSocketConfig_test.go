@@ -0,0 +1,34 @@
+package test
+
+import (
+	"jinx/pkg/util/helper"
+	"jinx/pkg/util/types"
+	"testing"
+)
+
+func TestValidateWebSocketConfig(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    types.WebSocketConfig
+		expectErr bool
+	}{
+		{"Defaults", types.WebSocketConfig{}, false},
+		{"Complete", types.WebSocketConfig{AllowedOrigins: []string{"https://app.example.com", "https://*.example.com", "http://localhost:3000"}, IdleTimeout: 60, MaxLifetime: 3600, MaxConnections: 1000}, false},
+		{"AnyOrigin", types.WebSocketConfig{AllowedOrigins: []string{"*"}}, false},
+		{"NoScheme", types.WebSocketConfig{AllowedOrigins: []string{"app.example.com"}}, true},
+		{"Path", types.WebSocketConfig{AllowedOrigins: []string{"https://app.example.com/chat"}}, true},
+		{"InnerWildcard", types.WebSocketConfig{AllowedOrigins: []string{"https://app.*.com"}}, true},
+		{"NegativeIdleTimeout", types.WebSocketConfig{IdleTimeout: -1}, true},
+		{"NegativeMaxLifetime", types.WebSocketConfig{MaxLifetime: -1}, true},
+		{"NegativeMaxConnections", types.WebSocketConfig{MaxConnections: -1}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := helper.ValidateWebSocketConfig(tc.config)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
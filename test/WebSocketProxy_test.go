@@ -0,0 +1,224 @@
+package test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"jinx/internal/reverse_proxy"
+	"jinx/pkg/util/types"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webSocketBackend accepts upgrades on /chat/* and echoes what it receives until the client closes its direction,
+// then says goodbye and closes the connection. Other paths refuse the upgrade.
+func webSocketBackend(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/chat/") || r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "no upgrade here", http.StatusBadRequest)
+			return
+		}
+
+		conn, buffer, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Backend failed to hijack: %v", err)
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		_, _ = fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nX-Path: %s\r\nX-Host: %s\r\n\r\n", r.URL.Path, r.Host)
+		_, _ = io.Copy(conn, buffer)
+		_, _ = io.WriteString(conn, "bye")
+	}))
+}
+
+// dialWebSocket sends an upgrade request for path through the proxy and returns the connection and the response.
+func dialWebSocket(t *testing.T, proxyURL string, path string, origin string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(proxyURL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to connect to the proxy: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request := "GET " + path + " HTTP/1.1\r\nHost: proxy.example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"
+	if origin != "" {
+		request += "Origin: " + origin + "\r\n"
+	}
+	if _, err = io.WriteString(conn, request+"\r\n"); err != nil {
+		t.Fatalf("Failed to send the upgrade request: %v", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Failed to read the upgrade response: %v", err)
+	}
+	return conn, reader, res
+}
+
+func newWebSocketProxy(t *testing.T, upstreamURL string, config types.WebSocketConfig) (*reverse_proxy.JinxReverseProxyServer, *httptest.Server) {
+	t.Helper()
+
+	jx := reverse_proxy.NewJinxReverseProxyServer(types.JinxReverseProxyServerConfig{
+		LogRoot: t.TempDir(),
+		Routes: []types.Route{
+			{Path: "/ws", Upstream: upstreamURL + "/chat", Rewrite: types.PathRewrite{StripPrefix: true}},
+			{Path: "/plain", Upstream: upstreamURL},
+		},
+		WebSocket: config,
+	}, t.TempDir())
+	if jx == nil {
+		t.Fatalf("Expected a server for %s", upstreamURL)
+	}
+
+	proxy := httptest.NewServer(jx)
+	t.Cleanup(proxy.Close)
+	return jx, proxy
+}
+
+func TestWebSocketProxy(t *testing.T) {
+	backend := webSocketBackend(t)
+	defer backend.Close()
+
+	testCases := []struct {
+		name   string
+		config types.WebSocketConfig
+		path   string
+		origin string
+		status int
+	}{
+		{"Upgrade", types.WebSocketConfig{}, "/ws/room", "", http.StatusSwitchingProtocols},
+		{"AllowedOrigin", types.WebSocketConfig{AllowedOrigins: []string{"https://app.example.com"}}, "/ws/room", "https://app.example.com", http.StatusSwitchingProtocols},
+		{"WildcardOrigin", types.WebSocketConfig{AllowedOrigins: []string{"https://*.example.com"}}, "/ws/room", "https://chat.example.com", http.StatusSwitchingProtocols},
+		{"DisallowedOrigin", types.WebSocketConfig{AllowedOrigins: []string{"https://app.example.com"}}, "/ws/room", "https://evil.example.org", http.StatusForbidden},
+		{"WildcardRequiresLabel", types.WebSocketConfig{AllowedOrigins: []string{"https://*.example.com"}}, "/ws/room", "https://example.com", http.StatusForbidden},
+		{"RefusedUpgrade", types.WebSocketConfig{}, "/plain/room", "", http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, proxy := newWebSocketProxy(t, backend.URL, tc.config)
+
+			conn, reader, res := dialWebSocket(t, proxy.URL, tc.path, tc.origin)
+			defer func() {
+				_ = conn.Close()
+			}()
+
+			if res.StatusCode != tc.status {
+				t.Fatalf("Expected status %d, got: %d", tc.status, res.StatusCode)
+			}
+			if res.StatusCode != http.StatusSwitchingProtocols {
+				return
+			}
+			if path := res.Header.Get("X-Path"); path != "/chat/room" {
+				t.Errorf("Expected the upstream to receive /chat/room, got: %s", path)
+			}
+			if host := res.Header.Get("X-Host"); host != strings.TrimPrefix(backend.URL, "http://") {
+				t.Errorf("Expected the upstream host, got: %s", host)
+			}
+
+			if _, err := io.WriteString(conn, "hello"); err != nil {
+				t.Fatalf("Failed to send: %v", err)
+			}
+			echo := make([]byte, 5)
+			if _, err := io.ReadFull(reader, echo); err != nil || string(echo) != "hello" {
+				t.Fatalf("Expected the echo, got: %q (%v)", echo, err)
+			}
+
+			// Closing the direction of the client lets the upstream finish before the connection ends
+			_ = conn.(*net.TCPConn).CloseWrite()
+			rest, err := io.ReadAll(reader)
+			if err != nil || string(rest) != "bye" {
+				t.Errorf("Expected the upstream to finish after the close, got: %q (%v)", rest, err)
+			}
+		})
+	}
+}
+
+func TestWebSocketProxyLimits(t *testing.T) {
+	backend := webSocketBackend(t)
+	defer backend.Close()
+
+	t.Run("MaxConnections", func(t *testing.T) {
+		jx, proxy := newWebSocketProxy(t, backend.URL, types.WebSocketConfig{MaxConnections: 1})
+
+		first, _, res := dialWebSocket(t, proxy.URL, "/ws/room", "")
+		if res.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("Expected the first connection to be upgraded, got: %d", res.StatusCode)
+		}
+		if active := jx.ActiveWebSockets(); active != 1 {
+			t.Errorf("Expected 1 open connection, got: %d", active)
+		}
+
+		second, _, res := dialWebSocket(t, proxy.URL, "/ws/room", "")
+		_ = second.Close()
+		if res.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected the second connection to be rejected with 503, got: %d", res.StatusCode)
+		}
+
+		_ = first.Close()
+		deadline := time.Now().Add(2 * time.Second)
+		for jx.ActiveWebSockets() != 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if active := jx.ActiveWebSockets(); active != 0 {
+			t.Errorf("Expected the closed connection to be released, got: %d open", active)
+		}
+	})
+
+	t.Run("MaxLifetime", func(t *testing.T) {
+		_, proxy := newWebSocketProxy(t, backend.URL, types.WebSocketConfig{MaxLifetime: 1})
+
+		conn, reader, res := dialWebSocket(t, proxy.URL, "/ws/room", "")
+		defer func() {
+			_ = conn.Close()
+		}()
+		if res.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("Expected the connection to be upgraded, got: %d", res.StatusCode)
+		}
+
+		start := time.Now()
+		_, err := reader.ReadByte()
+		if !errors.Is(err, io.EOF) {
+			t.Errorf("Expected the proxy to close the connection, got: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+			t.Errorf("Expected the connection to stay open for its lifetime, closed after %s", elapsed)
+		}
+	})
+
+	t.Run("IdleTimeout", func(t *testing.T) {
+		_, proxy := newWebSocketProxy(t, backend.URL, types.WebSocketConfig{IdleTimeout: 1})
+
+		conn, reader, res := dialWebSocket(t, proxy.URL, "/ws/room", "")
+		defer func() {
+			_ = conn.Close()
+		}()
+		if res.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("Expected the connection to be upgraded, got: %d", res.StatusCode)
+		}
+
+		// Traffic in one direction keeps the connection open
+		for i := 0; i < 3; i++ {
+			time.Sleep(500 * time.Millisecond)
+			_, _ = io.WriteString(conn, "x")
+			if _, err := reader.ReadByte(); err != nil {
+				t.Fatalf("Expected the active connection to stay open, got: %v", err)
+			}
+		}
+
+		_, err := reader.ReadByte()
+		if !errors.Is(err, io.EOF) {
+			t.Errorf("Expected the idle connection to be closed, got: %v", err)
+		}
+	})
+}